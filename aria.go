@@ -0,0 +1,188 @@
+package cascadia
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// This file implements the accessibility pseudo-classes, which match
+// elements by their ARIA role or state.
+
+type ariaPseudoClassSelector struct {
+	abstractPseudoClass
+	role  string // if set, match elements with this role
+	attr  string // otherwise, match elements whose aria-* attribute attr is value
+	value string
+}
+
+// Match implements :aria(role) and :aria(attr=value).
+func (s ariaPseudoClassSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if s.role != "" {
+		return ariaRole(n) == s.role
+	}
+	return matchAttribute(n, s.attr, func(val string) bool {
+		return toLowerASCII(strings.TrimSpace(val)) == s.value
+	})
+}
+
+type headingPseudoClassSelector struct {
+	abstractPseudoClass
+	min, max int // the range of levels; if max is 0, any level matches
+}
+
+// Match implements :heading(min-max), matching heading elements and elements
+// with the heading role whose level is in the range.
+func (s headingPseudoClassSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	level := headingLevel(n)
+	if level == 0 {
+		return false
+	}
+	return s.max == 0 || s.min <= level && level <= s.max
+}
+
+// headingLevel returns the outline level of n, or 0 if n isn't a heading.
+func headingLevel(n *html.Node) int {
+	if ariaRole(n) != "heading" {
+		return 0
+	}
+	for _, a := range n.Attr {
+		if a.Key == "aria-level" {
+			if level, err := strconv.Atoi(strings.TrimSpace(a.Val)); err == nil && level > 0 {
+				return level
+			}
+			break
+		}
+	}
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return int(n.Data[1] - '0')
+	}
+	// the default for the heading role
+	return 2
+}
+
+// ariaRole returns the ARIA role of n: the first token of its role
+// attribute, or else the role implied by the element type.
+func ariaRole(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "role" {
+			if fields := strings.Fields(a.Val); len(fields) > 0 {
+				return toLowerASCII(fields[0])
+			}
+			break
+		}
+	}
+	return implicitRole(n)
+}
+
+// implicitRoles maps element types to the ARIA role they have by default,
+// for the elements where that doesn't depend on attributes or context.
+var implicitRoles = map[atom.Atom]string{
+	atom.Article:  "article",
+	atom.Aside:    "complementary",
+	atom.Button:   "button",
+	atom.Datalist: "listbox",
+	atom.Dd:       "definition",
+	atom.Details:  "group",
+	atom.Dialog:   "dialog",
+	atom.Dt:       "term",
+	atom.Fieldset: "group",
+	atom.Figure:   "figure",
+	atom.Form:     "form",
+	atom.H1:       "heading",
+	atom.H2:       "heading",
+	atom.H3:       "heading",
+	atom.H4:       "heading",
+	atom.H5:       "heading",
+	atom.H6:       "heading",
+	atom.Hr:       "separator",
+	atom.Li:       "listitem",
+	atom.Main:     "main",
+	atom.Math:     "math",
+	atom.Menu:     "list",
+	atom.Meter:    "meter",
+	atom.Nav:      "navigation",
+	atom.Ol:       "list",
+	atom.Optgroup: "group",
+	atom.Option:   "option",
+	atom.Output:   "status",
+	atom.Progress: "progressbar",
+	atom.Section:  "region",
+	atom.Table:    "table",
+	atom.Tbody:    "rowgroup",
+	atom.Td:       "cell",
+	atom.Textarea: "textbox",
+	atom.Tfoot:    "rowgroup",
+	atom.Th:       "columnheader",
+	atom.Thead:    "rowgroup",
+	atom.Tr:       "row",
+	atom.Ul:       "list",
+}
+
+// implicitRole returns the role that n has by default, according to
+// https://www.w3.org/TR/html-aria/#docconformance, or "" if it has none.
+func implicitRole(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.A, atom.Area:
+		if hasAttr(n, "href") {
+			return "link"
+		}
+		return ""
+	case atom.Img:
+		if matchAttribute(n, "alt", func(val string) bool { return val == "" }) {
+			return "presentation"
+		}
+		return "img"
+	case atom.Input:
+		switch inputType(n) {
+		case "button", "submit", "reset", "image":
+			return "button"
+		case "checkbox":
+			return "checkbox"
+		case "radio":
+			return "radio"
+		case "range":
+			return "slider"
+		case "number":
+			return "spinbutton"
+		case "email", "tel", "text", "url", "search":
+			if hasAttr(n, "list") {
+				return "combobox"
+			}
+			if inputType(n) == "search" {
+				return "searchbox"
+			}
+			return "textbox"
+		}
+		return ""
+	case atom.Select:
+		if hasAttr(n, "multiple") || matchAttribute(n, "size", func(val string) bool {
+			return val != "" && val != "0" && val != "1"
+		}) {
+			return "listbox"
+		}
+		return "combobox"
+	case atom.Header, atom.Footer:
+		// Only landmarks when they aren't part of sectioning content.
+		for p := n.Parent; p != nil; p = p.Parent {
+			switch p.DataAtom {
+			case atom.Article, atom.Aside, atom.Main, atom.Nav, atom.Section:
+				return ""
+			}
+		}
+		if n.DataAtom == atom.Header {
+			return "banner"
+		}
+		return "contentinfo"
+	}
+	return implicitRoles[n.DataAtom]
+}
@@ -0,0 +1,96 @@
+package cascadia
+
+import (
+	"strings"
+
+	"github.com/andybalholm/cascadia/ast"
+)
+
+// ToAST returns the syntax tree of m, which should be a Sel or SelectorGroup
+// from this package. It returns nil if m is some other kind of Matcher.
+func ToAST(m Matcher) ast.Node {
+	switch m := m.(type) {
+	case SelectorGroup:
+		return groupAST(m)
+	case Builder:
+		return ToAST(m.Sel())
+	case tagSelector:
+		return &ast.Type{Name: m.tag}
+	case classSelector:
+		return &ast.Class{Name: m.class}
+	case idSelector:
+		return &ast.ID{Name: m.id}
+	case attrSelector:
+		val := m.val
+		if m.operation == "#=" {
+			val = m.regexp.String()
+		}
+		return &ast.Attribute{Name: m.key, Operator: m.operation, Value: val, Insensitive: m.insensitive}
+	case compoundSelector:
+		c := &ast.Compound{PseudoElement: m.pseudoElement}
+		for _, sel := range m.selectors {
+			c.Parts = append(c.Parts, ToAST(sel))
+		}
+		return c
+	case combinedSelector:
+		if m.second == nil {
+			return ToAST(m.first)
+		}
+		c := &ast.Combined{Combinator: m.combinator, Second: ToAST(m.second)}
+		if s, ok := m.first.(scopePseudoClassSelector); !ok || !s.implicit {
+			c.First = ToAST(m.first)
+		}
+		return c
+	case relativePseudoClassSelector:
+		return &ast.PseudoClass{Name: m.name, Selectors: groupAST(m.match)}
+	case neverMatchSelector:
+		// Optimize uses neverMatchSelector for whole selectors that can't
+		// match, so show what they were.
+		if !strings.HasPrefix(m.value, ":") {
+			if g, err := ParseGroupWithPseudoElements(m.value); err == nil && len(g) == 1 {
+				return ToAST(g[0])
+			}
+		}
+		return &ast.PseudoClass{Name: strings.TrimPrefix(m.value, ":")}
+	case Sel:
+		// The other pseudo-classes are represented by their names and
+		// arguments, as they are written.
+		s := strings.TrimPrefix(m.String(), ":")
+		if i := strings.IndexByte(s, '('); i != -1 && strings.HasSuffix(s, ")") {
+			return &ast.PseudoClass{Name: s[:i], Argument: s[i+1 : len(s)-1]}
+		}
+		return &ast.PseudoClass{Name: s}
+	}
+	return nil
+}
+
+func groupAST(g SelectorGroup) *ast.Group {
+	result := &ast.Group{Selectors: make([]ast.Node, len(g))}
+	for i, sel := range g {
+		result.Selectors[i] = ToAST(sel)
+	}
+	return result
+}
+
+// FromAST parses the selector represented by node, as it would be
+// serialized by node.String(). Pseudo-elements are allowed, and so are
+// relative selectors (a Combined node without a First selector).
+//
+// This is the way to turn a syntax tree that has been rewritten with
+// ast.Transform back into a selector.
+func FromAST(node ast.Node) (SelectorGroup, error) {
+	opts := ParseOptions{PseudoElements: true}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Combined:
+			if n.First == nil {
+				opts.Relative = true
+			}
+		case *ast.PseudoClass:
+			// Relative selectors inside pseudo-classes aren't supported.
+			return false
+		}
+		return true
+	})
+	return ParseGroupWithOptions(node.String(), opts)
+}
@@ -0,0 +1,179 @@
+// Package ast declares the types used to represent the structure of CSS
+// selectors parsed by cascadia.
+//
+// Use cascadia.ToAST to get the syntax tree for a selector. Each node's
+// String method formats it in CSS syntax again.
+package ast
+
+import (
+	"strings"
+
+	"github.com/andybalholm/cascadia/internal/cssesc"
+)
+
+// A Node is a part of a selector's syntax tree. All node types implement
+// the Node interface.
+type Node interface {
+	// String returns the node in CSS syntax.
+	String() string
+	node()
+}
+
+// A Group is a list of selectors separated by commas. It matches an
+// element if any of the selectors match.
+type Group struct {
+	Selectors []Node
+}
+
+// A Combined node is two selectors joined by a combinator, like "ul > li".
+// Longer chains nest to the left, so "a b c" is parsed as "(a b) c".
+type Combined struct {
+	// First is the selector before the combinator. It is nil for relative
+	// selectors that begin with a combinator, like "> li".
+	First Node
+
+	// Combinator is ' ', '>', '+' or '~'.
+	Combinator byte
+
+	Second Node
+}
+
+// A Compound node is a sequence of simple selectors that must all match the
+// same element, like "a.external[href]".
+type Compound struct {
+	// Parts are the simple selectors. If there are none, the selector is
+	// the universal selector, "*".
+	Parts []Node
+
+	// PseudoElement is the name of the pseudo-element at the end of the
+	// selector, if any.
+	PseudoElement string
+}
+
+// A Type node matches elements by tag name.
+type Type struct {
+	Name string
+}
+
+// A Class node matches elements by class, like ".item".
+type Class struct {
+	Name string
+}
+
+// An ID node matches elements by ID, like "#main".
+type ID struct {
+	Name string
+}
+
+// An Attribute node matches elements by the value of an attribute, like
+// "[href^='/p']".
+type Attribute struct {
+	Name string
+
+	// Operator is the comparison operator, such as "=" or "^=". It is empty
+	// if the selector only checks that the attribute is present.
+	Operator string
+
+	// Value is the value to compare with. For the "#=" operator, it is a
+	// regular expression.
+	Value string
+
+	// Insensitive is true if the selector ends with the i flag, to compare
+	// values case-insensitively.
+	Insensitive bool
+}
+
+// A PseudoClass node is a pseudo-class, like ":first-child" or
+// ":not(.hidden)".
+type PseudoClass struct {
+	Name string
+
+	// Selectors holds the argument of pseudo-classes that take a selector
+	// list, like :not() and :has().
+	Selectors *Group
+
+	// Argument holds the argument of other functional pseudo-classes, as
+	// written in the selector (including any quotes), such as the
+	// "2n+1" in ":nth-child(2n+1)". It is empty for pseudo-classes without
+	// parentheses.
+	Argument string
+}
+
+func (*Group) node()       {}
+func (*Combined) node()    {}
+func (*Compound) node()    {}
+func (*Type) node()        {}
+func (*Class) node()       {}
+func (*ID) node()          {}
+func (*Attribute) node()   {}
+func (*PseudoClass) node() {}
+
+func (g *Group) String() string {
+	chunks := make([]string, len(g.Selectors))
+	for i, s := range g.Selectors {
+		chunks[i] = s.String()
+	}
+	return strings.Join(chunks, ", ")
+}
+
+func (c *Combined) String() string {
+	combinator := " "
+	if c.Combinator != ' ' {
+		combinator = " " + string(c.Combinator) + " "
+	}
+	if c.First == nil {
+		return strings.TrimLeft(combinator, " ") + c.Second.String()
+	}
+	return c.First.String() + combinator + c.Second.String()
+}
+
+func (c *Compound) String() string {
+	if len(c.Parts) == 0 && c.PseudoElement == "" {
+		return "*"
+	}
+	var b strings.Builder
+	for _, p := range c.Parts {
+		b.WriteString(p.String())
+	}
+	if c.PseudoElement != "" {
+		b.WriteString("::" + c.PseudoElement)
+	}
+	return b.String()
+}
+
+func (t *Type) String() string {
+	return cssesc.Ident(t.Name)
+}
+
+func (c *Class) String() string {
+	return "." + cssesc.Ident(c.Name)
+}
+
+func (id *ID) String() string {
+	return "#" + cssesc.Ident(id.Name)
+}
+
+func (a *Attribute) String() string {
+	val := a.Value
+	switch a.Operator {
+	case "", "#=", "<", "<=", ">", ">=":
+		// regular expressions and numbers aren't quoted
+	default:
+		val = cssesc.Quote(val)
+	}
+	s := "[" + cssesc.Ident(a.Name) + a.Operator + val
+	if a.Insensitive {
+		s += " i"
+	}
+	return s + "]"
+}
+
+func (p *PseudoClass) String() string {
+	switch {
+	case p.Selectors != nil:
+		return ":" + p.Name + "(" + p.Selectors.String() + ")"
+	case p.Argument != "":
+		return ":" + p.Name + "(" + p.Argument + ")"
+	}
+	return ":" + p.Name
+}
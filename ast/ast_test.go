@@ -0,0 +1,92 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var testTree = &Group{Selectors: []Node{
+	&Combined{
+		First: &Combined{
+			First:      &Type{Name: "div"},
+			Combinator: ' ',
+			Second:     &Compound{Parts: []Node{&Type{Name: "a"}, &Attribute{Name: "href", Operator: "^=", Value: `/"p"`}}},
+		},
+		Combinator: '+',
+		Second: &Compound{
+			Parts: []Node{
+				&Class{Name: "x.y"},
+				&PseudoClass{Name: "not", Selectors: &Group{Selectors: []Node{&ID{Name: "main"}}}},
+				&PseudoClass{Name: "nth-child", Argument: "2n+1"},
+			},
+			PseudoElement: "after",
+		},
+	},
+	&Combined{Combinator: '>', Second: &Compound{}},
+	&PseudoClass{Name: "root"},
+}}
+
+func TestString(t *testing.T) {
+	want := `div a[href^="/\"p\""] + .x\.y:not(#main):nth-child(2n+1)::after, > *, :root`
+	if got := testTree.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+type recorder struct {
+	depth  int
+	events *[]string
+}
+
+func (r recorder) Visit(node Node) Visitor {
+	if node == nil {
+		*r.events = append(*r.events, fmt.Sprintf("%d end", r.depth))
+		return nil
+	}
+	*r.events = append(*r.events, fmt.Sprintf("%d %T", r.depth, node))
+	return recorder{r.depth + 1, r.events}
+}
+
+func TestWalk(t *testing.T) {
+	var events []string
+	Walk(recorder{0, &events}, &Combined{
+		First:      &Type{Name: "ul"},
+		Combinator: '>',
+		Second:     &PseudoClass{Name: "has", Selectors: &Group{Selectors: []Node{&Class{Name: "a"}}}},
+	})
+	want := []string{
+		"0 *ast.Combined",
+		"1 *ast.Type",
+		"2 end",
+		"1 *ast.PseudoClass",
+		"2 *ast.Group",
+		"3 *ast.Class",
+		"4 end",
+		"3 end",
+		"2 end",
+		"1 end",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got events %q, want %q", events, want)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var names []string
+	Inspect(testTree, func(n Node) bool {
+		switch n := n.(type) {
+		case *Type:
+			names = append(names, n.Name)
+		case *PseudoClass:
+			names = append(names, ":"+n.Name)
+			// Don't look inside pseudo-class arguments.
+			return false
+		}
+		return true
+	})
+	want := []string{"div", "a", ":not", ":nth-child", ":root"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %q, want %q", names, want)
+	}
+}
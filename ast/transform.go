@@ -0,0 +1,77 @@
+package ast
+
+// Transform returns a copy of the syntax tree rooted at node, rewritten by
+// f. It works from the bottom up: the children of each node are
+// transformed first, then f is called with a copy of the node that has the
+// new children, and the result of f takes the node's place. The original
+// tree isn't changed, so f may modify the node it is given and return it.
+//
+// If f returns nil, the node is removed from the list it is part of (the
+// Selectors of a Group or the Parts of a Compound). If the Second selector
+// of a Combined node is removed, the Combined node is replaced by its
+// First selector, and if the First selector is removed, by its Second
+// selector; a Combined node without a First selector is only kept if it
+// didn't have one before (as in a relative selector like "> p").
+func Transform(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *Group:
+		c := *n
+		c.Selectors = transformList(n.Selectors, f)
+		return f(&c)
+	case *Combined:
+		c := *n
+		if n.First != nil {
+			c.First = Transform(n.First, f)
+		}
+		c.Second = Transform(n.Second, f)
+		if c.Second == nil {
+			return c.First
+		}
+		if n.First != nil && c.First == nil {
+			return c.Second
+		}
+		return f(&c)
+	case *Compound:
+		c := *n
+		c.Parts = transformList(n.Parts, f)
+		return f(&c)
+	case *PseudoClass:
+		c := *n
+		if n.Selectors != nil {
+			switch g := Transform(n.Selectors, f).(type) {
+			case *Group:
+				c.Selectors = g
+			case nil:
+				c.Selectors = nil
+			default:
+				c.Selectors = &Group{Selectors: []Node{g}}
+			}
+		}
+		return f(&c)
+	case *Type:
+		c := *n
+		return f(&c)
+	case *Class:
+		c := *n
+		return f(&c)
+	case *ID:
+		c := *n
+		return f(&c)
+	case *Attribute:
+		c := *n
+		return f(&c)
+	}
+	return f(node)
+}
+
+// transformList transforms each node in list, leaving out the ones that
+// f removes.
+func transformList(list []Node, f func(Node) Node) []Node {
+	var result []Node
+	for _, n := range list {
+		if t := Transform(n, f); t != nil {
+			result = append(result, t)
+		}
+	}
+	return result
+}
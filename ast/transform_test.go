@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	before := testTree.String()
+
+	for _, test := range []struct {
+		name string
+		f    func(Node) Node
+		want string
+	}{
+		{
+			"rename classes",
+			func(n Node) Node {
+				if c, ok := n.(*Class); ok {
+					c.Name = "app-" + c.Name
+				}
+				return n
+			},
+			`div a[href^="/\"p\""] + .app-x\.y:not(#main):nth-child(2n+1)::after, > *, :root`,
+		},
+		{
+			"strip pseudo-elements",
+			func(n Node) Node {
+				if c, ok := n.(*Compound); ok {
+					c.PseudoElement = ""
+				}
+				return n
+			},
+			`div a[href^="/\"p\""] + .x\.y:not(#main):nth-child(2n+1), > *, :root`,
+		},
+		{
+			"remove pseudo-classes",
+			func(n Node) Node {
+				if _, ok := n.(*PseudoClass); ok {
+					return nil
+				}
+				return n
+			},
+			`div a[href^="/\"p\""] + .x\.y::after, > *`,
+		},
+		{
+			"remove the last selector in a chain",
+			func(n Node) Node {
+				if c, ok := n.(*Compound); ok && len(c.Parts) > 0 {
+					if _, ok := c.Parts[0].(*Class); ok {
+						return nil
+					}
+				}
+				return n
+			},
+			`div a[href^="/\"p\""], > *, :root`,
+		},
+		{
+			"replace a selector list",
+			func(n Node) Node {
+				if g, ok := n.(*Group); ok && len(g.Selectors) == 1 {
+					return g.Selectors[0]
+				}
+				if id, ok := n.(*ID); ok {
+					return &Type{Name: strings.ToLower(id.Name)}
+				}
+				return n
+			},
+			`div a[href^="/\"p\""] + .x\.y:not(main):nth-child(2n+1)::after, > *, :root`,
+		},
+	} {
+		if got := Transform(testTree, test.f).String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+		if got := testTree.String(); got != before {
+			t.Errorf("%s: the original tree was changed to %s", test.name, got)
+		}
+	}
+}
+
+func TestTransformRemoveFirst(t *testing.T) {
+	// Removing the first selector of a chain doesn't make it relative.
+	got := Transform(testTree, func(n Node) Node {
+		if t, ok := n.(*Type); ok && t.Name == "div" {
+			return nil
+		}
+		return n
+	})
+	c := got.(*Group).Selectors[0].(*Combined)
+	if _, ok := c.First.(*Compound); !ok {
+		t.Errorf("the first selector is %s (%T), want a compound selector", c.First, c.First)
+	}
+
+	// A relative selector stays relative.
+	if c := got.(*Group).Selectors[1].(*Combined); c.First != nil {
+		t.Errorf("> * became %s", c)
+	}
+}
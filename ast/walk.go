@@ -0,0 +1,58 @@
+package ast
+
+// A Visitor's Visit method is called for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order: it starts by calling
+// v.Visit(node); node must not be nil. If the visitor returned by
+// v.Visit(node) is not nil, Walk is called recursively with that visitor for
+// each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Group:
+		for _, s := range n.Selectors {
+			Walk(v, s)
+		}
+	case *Combined:
+		if n.First != nil {
+			Walk(v, n.First)
+		}
+		Walk(v, n.Second)
+	case *Compound:
+		for _, p := range n.Parts {
+			Walk(v, p)
+		}
+	case *PseudoClass:
+		if n.Selectors != nil {
+			Walk(v, n.Selectors)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call
+// of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,133 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andybalholm/cascadia/ast"
+)
+
+func TestToAST(t *testing.T) {
+	var tests []string
+	for _, test := range selectorTests {
+		tests = append(tests, test.selector)
+	}
+	for _, test := range testsPseudo {
+		tests = append(tests, test.selector)
+	}
+	for _, test := range positionalTests {
+		tests = append(tests, test.selector)
+	}
+	for _, test := range loadValidSelectors(t) {
+		if test.Xfail {
+			continue
+		}
+		tests = append(tests, test.Selector)
+	}
+	tests = append(tests, `[title="say \"hi\""]`, `.\31 23`, `my\:tag`, `.tab\9 x`)
+
+	for _, test := range tests {
+		if skipRegexp(test) {
+			continue
+		}
+		s, err := ParseGroupWithPseudoElements(test)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test, err)
+		}
+
+		serialized := ToAST(s).String()
+		s2, err := ParseGroupWithPseudoElements(serialized)
+		if err != nil {
+			t.Errorf("error compiling %q: %s (original : %s)", serialized, err, test)
+		} else if !reflect.DeepEqual(s, s2) {
+			t.Errorf("can't retrieve selector from syntax tree: %s (original : %s)", serialized, test)
+		}
+	}
+
+	for _, test := range relativeTests {
+		s, err := ParseGroupWithOptions(test.selector, ParseOptions{Relative: true})
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.selector, err)
+		}
+		serialized := ToAST(s).String()
+		s2, err := ParseGroupWithOptions(serialized, ParseOptions{Relative: true})
+		if err != nil {
+			t.Errorf("error compiling %q: %s (original : %s)", serialized, err, test.selector)
+		} else if !reflect.DeepEqual(s, s2) {
+			t.Errorf("can't retrieve selector from syntax tree: %s (original : %s)", serialized, test.selector)
+		}
+	}
+}
+
+func TestASTStructure(t *testing.T) {
+	s := MustParseGroup(t, `ul > li.item:not([data-x="1" i]):nth-child(2n+1), p`)
+	want := &ast.Group{Selectors: []ast.Node{
+		&ast.Combined{
+			First:      &ast.Type{Name: "ul"},
+			Combinator: '>',
+			Second: &ast.Compound{Parts: []ast.Node{
+				&ast.Type{Name: "li"},
+				&ast.Class{Name: "item"},
+				&ast.PseudoClass{Name: "not", Selectors: &ast.Group{Selectors: []ast.Node{
+					&ast.Attribute{Name: "data-x", Operator: "=", Value: "1", Insensitive: true},
+				}}},
+				&ast.PseudoClass{Name: "nth-child", Argument: "2n+1"},
+			}},
+		},
+		&ast.Type{Name: "p"},
+	}}
+	if got := ToAST(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToAST(%s) = %s, want %s", s, got, want)
+	}
+
+	if got := ToAST(MustCompile("p")); got != nil {
+		t.Errorf("ToAST(Selector) = %v, want nil", got)
+	}
+}
+
+func TestFromAST(t *testing.T) {
+	s, err := ParseGroupWithPseudoElements(`ul > li.item, a.item[href]::before, :not(.item)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rename the classes, scope each selector to the .app element, and
+	// remove pseudo-elements.
+	tree := ast.Transform(ToAST(s), func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.Class:
+			n.Name = "app-" + n.Name
+		case *ast.Compound:
+			n.PseudoElement = ""
+		case *ast.Group:
+			for i, sel := range n.Selectors {
+				n.Selectors[i] = &ast.Combined{First: &ast.Class{Name: "app"}, Combinator: ' ', Second: sel}
+			}
+		}
+		return n
+	})
+	got, err := FromAST(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The :not() argument is a group too, so it is scoped as well.
+	want := MustParseGroup(t, `.app ul > li.app-item, .app a.app-item[href], .app :not(.app .app-item)`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	rel, err := FromAST(ToAST(MustRelative(t, "> li, + p")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := MustRelative(t, "> li, + p"); !reflect.DeepEqual(rel, want) {
+		t.Errorf("got %s, want %s", rel, want)
+	}
+}
+
+func MustRelative(t *testing.T, sel string) SelectorGroup {
+	s, err := ParseGroupWithOptions(sel, ParseOptions{Relative: true})
+	if err != nil {
+		t.Fatalf("error compiling %q: %s", sel, err)
+	}
+	return s
+}
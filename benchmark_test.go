@@ -51,3 +51,81 @@ func BenchmarkMatchAll(b *testing.B) {
 	}
 	_ = matches
 }
+
+const validateSelector = `div.content > p:matches(^[a-z]+\s+\d+$), ul li:nth-child(2n+1)`
+
+func BenchmarkValidate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := Validate(validateSelector); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseGroup(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseGroup(validateSelector); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseCompound(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseGroup("div.a.b > p#x[title], ul li.b:first-child, span.c"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompoundTagFilter(b *testing.B) {
+	doc := parseReference("test_resources/shakespeare.html")
+	s, err := ParseGroup(`span[class~="dialog"]:not(:empty)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		QueryAll(doc, s)
+	}
+}
+
+func BenchmarkCompoundAttributes(b *testing.B) {
+	s, err := ParseGroup(`input[name=q][type=text][required]`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bm := range []struct {
+		name, html string
+	}{
+		{"match", `<input name="q" type="text" class="field" autocomplete="off" placeholder="Search" required>`},
+		{"wrong-value", `<input name="p" type="password" class="field">`},
+		{"missing", `<input type="hidden" value="1">`},
+	} {
+		doc := MustParseHTML(strings.Repeat(bm.html, 200))
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				QueryAll(doc, s)
+			}
+		})
+	}
+}
+
+func BenchmarkUnionWide(b *testing.B) {
+	var page strings.Builder
+	page.WriteString("<ul>")
+	for i := 0; i < 5000; i++ {
+		page.WriteString("<li>x</li>")
+	}
+	page.WriteString("</ul>")
+	items := QueryAll(MustParseHTML(page.String()), MustCompile("li"))
+	reversed := make([]*html.Node, len(items))
+	for i, n := range items {
+		reversed[len(items)-1-i] = n
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Union(reversed)
+	}
+}
@@ -0,0 +1,300 @@
+package cascadia
+
+import (
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// This file implements a way to build selectors in code, as an alternative
+// to putting together a string to parse.
+
+// An AttrOperator is the operator in an attribute selector, such as the ^=
+// in [href^="/p"].
+type AttrOperator string
+
+// The operators for attribute selectors that compare strings.
+const (
+	Equals    AttrOperator = "="
+	NotEquals AttrOperator = "!="
+	Includes  AttrOperator = "~="
+	DashMatch AttrOperator = "|="
+	Prefix    AttrOperator = "^="
+	Suffix    AttrOperator = "$="
+	Substring AttrOperator = "*="
+)
+
+// valid returns whether op is one of the operators above.
+func (op AttrOperator) valid() bool {
+	switch op {
+	case Equals, NotEquals, Includes, DashMatch, Prefix, Suffix, Substring:
+		return true
+	}
+	return false
+}
+
+// A Builder constructs a selector one part at a time. Each method returns a
+// new Builder, so a partial selector can be reused:
+//
+//	item := cascadia.Class("item")
+//	links := cascadia.Tag("ul").Child(item.Attr("href", cascadia.Prefix, "/p"))
+//
+// is the same as "ul > .item[href^='/p']".
+//
+// A Builder is a Sel itself. Its Sel method returns the same selector that
+// parsing the equivalent string would produce.
+type Builder struct {
+	sel Sel // the selector so far; new simple selectors are added to its last compound selector
+}
+
+// Universal returns a Builder for the universal selector, *.
+func Universal() Builder {
+	return Builder{}
+}
+
+// Tag returns a Builder for a type selector, which matches elements by their
+// tag name.
+func Tag(name string) Builder {
+	name = toLowerASCII(name)
+	return Builder{}.add(tagSelector{tag: name, name: svgTagNames[name]})
+}
+
+// Class returns a Builder for a class selector, .class.
+func Class(class string) Builder {
+	return Builder{}.Class(class)
+}
+
+// ID returns a Builder for an ID selector, #id.
+func ID(id string) Builder {
+	return Builder{}.ID(id)
+}
+
+// Attr returns a Builder for an attribute selector, [key op value]. It
+// panics if op isn't one of the AttrOperator constants.
+func Attr(key string, op AttrOperator, value string) Builder {
+	return Builder{}.Attr(key, op, value)
+}
+
+// HasAttr returns a Builder for an attribute selector that only checks
+// whether the attribute is present, [key].
+func HasAttr(key string) Builder {
+	return Builder{}.HasAttr(key)
+}
+
+// Sel returns the selector that has been built.
+func (b Builder) Sel() Sel {
+	if b.sel == nil {
+		return compoundSelector{}
+	}
+	return b.sel
+}
+
+// Class adds a class selector.
+func (b Builder) Class(class string) Builder {
+	return b.add(classSelector{class: class})
+}
+
+// ID adds an ID selector.
+func (b Builder) ID(id string) Builder {
+	return b.add(idSelector{id: id})
+}
+
+// Attr adds an attribute selector, [key op value]. It panics if op isn't one
+// of the AttrOperator constants.
+func (b Builder) Attr(key string, op AttrOperator, value string) Builder {
+	if !op.valid() {
+		panic(fmt.Sprintf("cascadia: invalid attribute operator %q", op))
+	}
+	key = toLowerASCII(key)
+	return b.add(attrSelector{key: key, name: foreignAttributeNames[key], operation: string(op), val: value})
+}
+
+// HasAttr adds an attribute selector that only checks whether the attribute
+// is present, [key].
+func (b Builder) HasAttr(key string) Builder {
+	key = toLowerASCII(key)
+	return b.add(attrSelector{key: key, name: foreignAttributeNames[key]})
+}
+
+// Not adds :not(sels), which matches elements that don't match any of sels.
+func (b Builder) Not(sels ...Sel) Builder {
+	return b.add(relativePseudoClassSelector{name: "not", match: builtGroup(sels)})
+}
+
+// Has adds :has(sels), which matches elements with a descendant that matches
+// one of sels.
+func (b Builder) Has(sels ...Sel) Builder {
+	return b.add(relativePseudoClassSelector{name: "has", match: builtGroup(sels)})
+}
+
+// FirstChild adds :first-child.
+func (b Builder) FirstChild() Builder {
+	return b.add(nthPseudoClassSelector{a: 0, b: 1})
+}
+
+// LastChild adds :last-child.
+func (b Builder) LastChild() Builder {
+	return b.add(nthPseudoClassSelector{a: 0, b: 1, last: true})
+}
+
+// OnlyChild adds :only-child.
+func (b Builder) OnlyChild() Builder {
+	return b.add(onlyChildPseudoClassSelector{})
+}
+
+// NthChild adds :nth-child(an+b), where a is step and b is offset.
+func (b Builder) NthChild(step, offset int) Builder {
+	return b.add(nthPseudoClassSelector{a: step, b: offset})
+}
+
+// Empty adds :empty.
+func (b Builder) Empty() Builder {
+	return b.add(emptyElementPseudoClassSelector{})
+}
+
+// Root adds :root.
+func (b Builder) Root() Builder {
+	return b.add(rootPseudoClassSelector{})
+}
+
+// And adds the simple selectors in s, which must be a single compound
+// selector without combinators, such as one parsed from ":checked". It
+// allows a Builder to use features that don't have their own method. It
+// panics if both b and s have a type selector, as in Tag("a").And(Tag("b")).
+func (b Builder) And(s Sel) Builder {
+	if sb, ok := s.(Builder); ok {
+		s = sb.Sel()
+	}
+	if c, ok := s.(compoundSelector); ok && c.pseudoElement == "" {
+		for _, sel := range c.selectors {
+			b = b.add(sel)
+		}
+		return b
+	}
+	return b.add(s)
+}
+
+// Descendant joins b and next with the descendant combinator, as in "b next".
+func (b Builder) Descendant(next Sel) Builder {
+	return b.combine(' ', next)
+}
+
+// Child joins b and next with the child combinator, as in "b > next".
+func (b Builder) Child(next Sel) Builder {
+	return b.combine('>', next)
+}
+
+// Adjacent joins b and next with the next-sibling combinator, as in
+// "b + next".
+func (b Builder) Adjacent(next Sel) Builder {
+	return b.combine('+', next)
+}
+
+// Sibling joins b and next with the subsequent-sibling combinator, as in
+// "b ~ next".
+func (b Builder) Sibling(next Sel) Builder {
+	return b.combine('~', next)
+}
+
+// add adds a simple selector to the last compound selector in b. A type
+// selector goes at the start, since that is the only place CSS syntax allows
+// it, and there can only be one.
+func (b Builder) add(s Sel) Builder {
+	var (
+		first      Sel
+		combinator byte
+		last       = b.sel
+	)
+	if c, ok := b.sel.(combinedSelector); ok {
+		first, combinator, last = c.first, c.combinator, c.second
+	}
+
+	var (
+		selectors     []Sel
+		pseudoElement string
+	)
+	switch last := last.(type) {
+	case nil:
+	case compoundSelector:
+		selectors, pseudoElement = last.selectors, last.pseudoElement
+	default:
+		selectors = []Sel{last}
+	}
+	// Copy the slice so that other Builders aren't affected.
+	if _, ok := s.(tagSelector); ok {
+		if len(selectors) > 0 {
+			if t, ok := selectors[0].(tagSelector); ok {
+				panic(fmt.Sprintf("cascadia: a compound selector can't have two type selectors (%s and %s)", t, s))
+			}
+		}
+		selectors = append([]Sel{s}, selectors...)
+	} else {
+		selectors = append(selectors[:len(selectors):len(selectors)], s)
+	}
+
+	// Wrap the selectors the same way the parser does.
+	if len(selectors) == 1 && pseudoElement == "" {
+		last = selectors[0]
+	} else {
+		last = newCompoundSelector(selectors, pseudoElement)
+	}
+	if first != nil {
+		return Builder{combinedSelector{first: first, combinator: combinator, second: last}}
+	}
+	return Builder{last}
+}
+
+// combine joins b and next with combinator.
+func (b Builder) combine(combinator byte, next Sel) Builder {
+	if nb, ok := next.(Builder); ok {
+		next = nb.Sel()
+	}
+	return Builder{prependSelector(b.Sel(), combinator, next)}
+}
+
+// prependSelector returns s with first and combinator added at the start.
+func prependSelector(first Sel, combinator byte, s Sel) Sel {
+	if c, ok := s.(combinedSelector); ok {
+		c.first = prependSelector(first, combinator, c.first)
+		return c
+	}
+	return combinedSelector{first: first, combinator: combinator, second: s}
+}
+
+// builtGroup converts sels to a SelectorGroup, replacing Builders with the
+// selectors they have built.
+func builtGroup(sels []Sel) SelectorGroup {
+	group := make(SelectorGroup, len(sels))
+	for i, s := range sels {
+		if b, ok := s.(Builder); ok {
+			s = b.Sel()
+		}
+		group[i] = s
+	}
+	return group
+}
+
+// Match returns whether the selector that has been built matches n.
+func (b Builder) Match(n *html.Node) bool {
+	return b.Sel().Match(n)
+}
+
+func (b Builder) matchIn(c *matchContext, n *html.Node) bool {
+	return c.match(b.Sel(), n)
+}
+
+// Specificity returns the specificity of the selector that has been built.
+func (b Builder) Specificity() Specificity {
+	return b.Sel().Specificity()
+}
+
+// PseudoElement returns the pseudo-element of the selector that has been
+// built, if any.
+func (b Builder) PseudoElement() string {
+	return b.Sel().PseudoElement()
+}
+
+// String returns the selector that has been built, in CSS syntax.
+func (b Builder) String() string {
+	return b.Sel().String()
+}
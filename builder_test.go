@@ -0,0 +1,82 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	item := Class("item")
+	for _, test := range []struct {
+		built Builder
+		sel   string
+	}{
+		{Universal(), "*"},
+		{Tag("DIV"), "div"},
+		{Tag("div").Class("item"), "div.item"},
+		{Universal().Class("a").ID("b"), ".a#b"},
+		{Tag("ul").Child(item.Attr("href", Prefix, "/p")), `ul > .item[href^="/p"]`},
+		{HasAttr("Title").Attr("lang", DashMatch, "en"), `[title][lang|=en]`},
+		{Tag("table").Descendant(Tag("tr").Child(Tag("td"))), "table tr > td"},
+		{Tag("h1").Adjacent(Tag("p")).Sibling(Tag("ul")), "h1 + p ~ ul"},
+		{Tag("li").Not(Class("a"), Tag("p").Child(Tag("b"))).FirstChild(), "li:not(.a, p > b):first-child"},
+		{Tag("div").Has(Tag("img").Attr("alt", Equals, "")), `div:has(img[alt=""])`},
+		{Tag("li").NthChild(2, 1).LastChild(), "li:nth-child(2n+1):last-child"},
+		{Tag("p").OnlyChild().Empty(), "p:only-child:empty"},
+		{Universal().Root(), ":root"},
+		{Tag("input").And(MustParse(t, ":checked:disabled")), "input:checked:disabled"},
+		{Tag("a").And(Class("b")), "a.b"},
+		{Class("a").And(Tag("div")), "div.a"},
+		{Attr("href", Suffix, ".pdf").And(Tag("a")).Class("x"), `a[href$=".pdf"].x`},
+		{Tag("ul").Child(Class("b").And(Tag("li"))), "ul > li.b"},
+	} {
+		want, err := Parse(test.sel)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.sel, err)
+		}
+		if !reflect.DeepEqual(test.built.Sel(), want) {
+			t.Errorf("built %s, want %s", test.built, test.sel)
+		}
+		// The built selector's String must parse back to the same selector.
+		again, err := Parse(test.built.String())
+		if err != nil {
+			t.Errorf("parsing %s (built for %s): %v", test.built, test.sel, err)
+		} else if !reflect.DeepEqual(again, want) {
+			t.Errorf("%s doesn't round-trip: parsed as %s", test.built, again)
+		}
+	}
+
+	// Builders are values, so extending one doesn't change another.
+	a := Tag("p").Class("x")
+	b := a.Class("y")
+	c := a.Class("z")
+	if a.String() != "p.x" || b.String() != "p.x.y" || c.String() != "p.x.z" {
+		t.Errorf("builders share state: %s, %s, %s", a, b, c)
+	}
+}
+
+func MustParse(t *testing.T, sel string) Sel {
+	s, err := Parse(sel)
+	if err != nil {
+		t.Fatalf("error compiling %q: %s", sel, err)
+	}
+	return s
+}
+
+func TestBuilderInvalidOperator(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error(`Attr with operator "#=" didn't panic`)
+		}
+	}()
+	Attr("href", AttrOperator("#="), "x")
+}
+
+func TestBuilderTwoTypes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error(`Tag("a").And(Tag("b")) didn't panic`)
+		}
+	}()
+	Tag("a").Class("x").And(Tag("b"))
+}
@@ -0,0 +1,112 @@
+package cascadia
+
+import (
+	"container/list"
+	"sync"
+)
+
+// A Cache holds compiled selectors, so that programs that use the same
+// selectors repeatedly don't need to parse them each time. When it is full,
+// the least recently used selector is discarded. A Cache is safe for
+// concurrent use by multiple goroutines, and the selectors it returns are
+// shared between them.
+type Cache struct {
+	size int
+	opts ParseOptions
+
+	mu     sync.Mutex
+	lru    *list.List // of *cacheEntry, most recently used first
+	items  map[string]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	sel   string
+	group SelectorGroup
+	err   error
+}
+
+// CacheStats holds the counters returned by Cache.Stats.
+type CacheStats struct {
+	// Hits is the number of times a selector was found in the cache.
+	Hits uint64
+
+	// Misses is the number of times a selector had to be parsed.
+	Misses uint64
+
+	// Len is the number of selectors in the cache.
+	Len int
+}
+
+// NewCache returns a Cache that holds up to size selectors, parsed as
+// ParseGroup would.
+func NewCache(size int) *Cache {
+	return NewCacheWithOptions(size, ParseOptions{})
+}
+
+// NewCacheWithOptions returns a Cache that holds up to size selectors,
+// parsed with the features specified in opts.
+func NewCacheWithOptions(size int, opts ParseOptions) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		size:  size,
+		opts:  opts,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the compiled form of sel, parsing it if it isn't in the cache
+// already. Invalid selectors are cached too, so Get returns the same error
+// again without parsing them.
+func (c *Cache) Get(sel string) (SelectorGroup, error) {
+	c.mu.Lock()
+	if e, ok := c.items[sel]; ok {
+		c.lru.MoveToFront(e)
+		c.hits++
+		entry := e.Value.(*cacheEntry)
+		c.mu.Unlock()
+		return entry.group, entry.err
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Parse without holding the lock, so that other goroutines aren't kept
+	// waiting.
+	group, err := ParseGroupWithOptions(sel, c.opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[sel]; ok {
+		// Another goroutine parsed it at the same time.
+		c.lru.MoveToFront(e)
+		entry := e.Value.(*cacheEntry)
+		return entry.group, entry.err
+	}
+	c.items[sel] = c.lru.PushFront(&cacheEntry{sel: sel, group: group, err: err})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).sel)
+	}
+	return group, err
+}
+
+// MustGet is like Get, but panics if sel is not a valid selector.
+func (c *Cache) MustGet(sel string) SelectorGroup {
+	group, err := c.Get(sel)
+	if err != nil {
+		panic(err)
+	}
+	return group
+}
+
+// Stats returns the cache's hit and miss counters, and its current size.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Len: c.lru.Len()}
+}
@@ -0,0 +1,75 @@
+package cascadia
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+
+	a1, err := c.Get("li.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, _ := c.Get("li.a")
+	if !reflect.DeepEqual(a1, a2) || &a1[0] != &a2[0] {
+		t.Error("the second Get didn't return the cached selector")
+	}
+	if _, err := c.Get("li:bogus"); err == nil {
+		t.Error("expected an error for an invalid selector")
+	}
+	if _, err := c.Get("li:bogus"); err == nil {
+		t.Error("expected the cached error for an invalid selector")
+	}
+	if got, want := c.Stats(), (CacheStats{Hits: 2, Misses: 2, Len: 2}); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+
+	// li.a is the least recently used, so adding p discards it.
+	c.MustGet("p")
+	c.MustGet("li.a")
+	if got, want := c.Stats(), (CacheStats{Hits: 2, Misses: 4, Len: 2}); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("MustGet didn't panic for an invalid selector")
+			}
+		}()
+		c.MustGet("p >")
+	}()
+
+	rel := NewCacheWithOptions(10, ParseOptions{Relative: true})
+	if _, err := rel.Get("> p"); err != nil {
+		t.Errorf("relative selector: %s", err)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(10)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sel := fmt.Sprintf("p.c%d", (i+j)%20)
+				g, err := c.Get(sel)
+				if err != nil || g.String() != sel {
+					t.Errorf("Get(%s) = %v, %v", sel, g, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	s := c.Stats()
+	if s.Hits+s.Misses != 800 || s.Len != 10 {
+		t.Errorf("Stats = %+v", s)
+	}
+}
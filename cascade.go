@@ -0,0 +1,181 @@
+package cascadia
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A Declaration is a CSS property declaration, like "color: red".
+type Declaration struct {
+	Property  string
+	Value     string
+	Important bool
+}
+
+func (d Declaration) String() string {
+	if d.Important {
+		return d.Property + ": " + d.Value + " !important"
+	}
+	return d.Property + ": " + d.Value
+}
+
+// A Rule is a CSS style rule: the declarations that apply to the elements
+// that match Selector.
+type Rule struct {
+	Selector     SelectorGroup
+	Declarations []Declaration
+}
+
+// ParseDeclarations parses a list of declarations separated by semicolons,
+// like the contents of a style attribute or of the braces in a style rule.
+// Declarations without a colon or without a value are skipped, as browsers
+// do. Property names are converted to lower case, except for custom
+// properties like --main-color.
+func ParseDeclarations(s string) []Declaration {
+	var decls []Declaration
+	for _, text := range splitDeclarations(s) {
+		colon := strings.IndexByte(text, ':')
+		if colon == -1 {
+			continue
+		}
+		d := Declaration{
+			Property: strings.TrimSpace(text[:colon]),
+			Value:    strings.TrimSpace(text[colon+1:]),
+		}
+		if !strings.HasPrefix(d.Property, "--") {
+			d.Property = toLowerASCII(d.Property)
+		}
+		if i := strings.LastIndexByte(d.Value, '!'); i != -1 && toLowerASCII(strings.TrimSpace(d.Value[i+1:])) == "important" {
+			d.Value = strings.TrimSpace(d.Value[:i])
+			d.Important = true
+		}
+		if d.Property == "" || d.Value == "" {
+			continue
+		}
+		decls = append(decls, d)
+	}
+	return decls
+}
+
+// splitDeclarations splits s at the semicolons that aren't in strings,
+// parentheses or brackets.
+func splitDeclarations(s string) []string {
+	var parts []string
+	start, depth := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case (c == ')' || c == ']') && depth > 0:
+			depth--
+		case c == ';' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Cascade computes which declarations from rules apply to each element in
+// doc, resolving conflicts the way the CSS cascade does, for tools like
+// style inliners for HTML email. For each property of each element, the
+// winner is the declaration with the highest priority: !important
+// declarations first, then declarations in the element's style attribute,
+// then the declaration whose rule has the more specific matching selector,
+// and finally the one that comes last in rules.
+//
+// Rules should be in stylesheet order. Selectors with pseudo-elements are
+// ignored, since they don't apply to the element itself. The result maps
+// each element that has any declarations to its winning declarations, in
+// order of increasing priority.
+func Cascade(doc *html.Node, rules []Rule) map[*html.Node][]Declaration {
+	type candidate struct {
+		decl        Declaration
+		inline      bool
+		specificity Specificity
+		order       int
+	}
+	candidates := make(map[*html.Node][]candidate)
+	order := 0
+
+	for _, r := range rules {
+		specs := make(map[*html.Node]Specificity)
+		for _, sel := range r.Selector {
+			if sel.PseudoElement() != "" {
+				continue
+			}
+			spec := sel.Specificity()
+			for _, n := range QueryAll(doc, sel) {
+				if old, ok := specs[n]; !ok || old.Less(spec) {
+					specs[n] = spec
+				}
+			}
+		}
+		for n, spec := range specs {
+			for i, d := range r.Declarations {
+				candidates[n] = append(candidates[n], candidate{decl: d, specificity: spec, order: order + i})
+			}
+		}
+		order += len(r.Declarations)
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				if a.Namespace == "" && a.Key == "style" {
+					for _, d := range ParseDeclarations(a.Val) {
+						candidates[n] = append(candidates[n], candidate{decl: d, inline: true, order: order})
+						order++
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	result := make(map[*html.Node][]Declaration, len(candidates))
+	for n, list := range candidates {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			if a.decl.Important != b.decl.Important {
+				return b.decl.Important
+			}
+			if a.inline != b.inline {
+				return b.inline
+			}
+			if c := a.specificity.Compare(b.specificity); c != 0 {
+				return c < 0
+			}
+			return a.order < b.order
+		})
+		// Keep the last (highest-priority) declaration for each property.
+		winners := make(map[string]int)
+		for i, c := range list {
+			winners[c.decl.Property] = i
+		}
+		var decls []Declaration
+		for i, c := range list {
+			if winners[c.decl.Property] == i {
+				decls = append(decls, c.decl)
+			}
+		}
+		result[n] = decls
+	}
+	return result
+}
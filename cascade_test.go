@@ -0,0 +1,78 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseDeclarations(t *testing.T) {
+	got := ParseDeclarations(` COLOR: Red; background: url("a;b.png") ; margin:0 !IMPORTANT;; bogus; --Main-Color: blue; width: `)
+	want := []Declaration{
+		{Property: "color", Value: "Red"},
+		{Property: "background", Value: `url("a;b.png")`},
+		{Property: "margin", Value: "0", Important: true},
+		{Property: "--Main-Color", Value: "blue"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := want[2].String(); got != "margin: 0 !important" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestCascade(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p id=a class=note>one</p><p id=b style="color: green; font-size: 10px">two</p><p id=c>three</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := func(sel, decls string) Rule {
+		return Rule{Selector: MustParseGroupWithPseudoElements(t, sel), Declarations: ParseDeclarations(decls)}
+	}
+	rules := []Rule{
+		rule("#a, p", "color: black; margin: 0"),
+		rule("p", "color: gray; padding: 1px"),
+		rule(".note", "color: blue"),
+		rule("p", "font-size: 12px !important"),
+		rule("p::first-line", "color: purple"),
+		rule("p:last", "margin: 2px"),
+	}
+	styles := Cascade(doc, rules)
+
+	byID := func(id string) []Declaration {
+		return styles[Query(doc, MustParseGroup(t, "#"+id))]
+	}
+	for _, test := range []struct {
+		id   string
+		want string
+	}{
+		// #a wins over .note, and margin comes from #a too.
+		{"a", "padding: 1px; color: black; margin: 0; font-size: 12px !important"},
+		// The style attribute beats the rules, except for !important.
+		{"b", "margin: 0; padding: 1px; color: green; font-size: 12px !important"},
+		{"c", "color: gray; padding: 1px; margin: 2px; font-size: 12px !important"},
+	} {
+		var parts []string
+		for _, d := range byID(test.id) {
+			parts = append(parts, d.String())
+		}
+		if got := strings.Join(parts, "; "); got != test.want {
+			t.Errorf("#%s: got %q, want %q", test.id, got, test.want)
+		}
+	}
+	if len(styles) != 3 {
+		t.Errorf("got styles for %d elements, want 3", len(styles))
+	}
+}
+
+func MustParseGroupWithPseudoElements(t *testing.T, sel string) SelectorGroup {
+	t.Helper()
+	g, err := ParseGroupWithPseudoElements(sel)
+	if err != nil {
+		t.Fatalf("error compiling %q: %s", sel, err)
+	}
+	return g
+}
@@ -0,0 +1,234 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A chainQuery finds all the matches of a group of selectors in one pass
+// over the tree, without walking back up to the ancestors of each element.
+//
+// Each selector is split into its compound selectors. As the search goes
+// down the tree, it records which prefixes of the selector (the part up to
+// each compound selector) each node matches, and how many ancestors of the
+// current node match each prefix. Then a descendant combinator only needs
+// to check a count, and a child combinator only needs to look at its
+// parent's results. Sibling combinators are still matched by walking the
+// siblings.
+type chainQuery struct {
+	chains []chain
+
+	// width is the total number of compound selectors in chains: the
+	// number of results recorded for each node.
+	width int
+}
+
+type chain struct {
+	compounds   []Sel
+	combinators []byte // combinators[i] is the combinator before compounds[i]
+
+	// prefixes[i] is the selector up to compounds[i], for matching the
+	// sibling combinators the usual way.
+	prefixes []Sel
+
+	// offset is where this chain's results start in a node's results.
+	offset int
+}
+
+// newChainQuery returns a chainQuery for m, or nil if it wouldn't help: if
+// m is not a Sel or SelectorGroup, has result-set pseudo-classes, or has no
+// descendant or child combinators.
+func newChainQuery(m Matcher) *chainQuery {
+	var sels []Sel
+	switch m := m.(type) {
+	case SelectorGroup:
+		sels = m
+	case Sel:
+		sels = []Sel{m}
+	default:
+		return nil
+	}
+	useful := false
+	for _, s := range sels {
+		if hasAncestorCombinator(s) {
+			useful = true
+			break
+		}
+	}
+	if !useful || hasPositional(m) {
+		return nil
+	}
+
+	q := new(chainQuery)
+	for _, s := range sels {
+		compounds, combinators := steps(s)
+		ch := chain{
+			compounds:   compounds,
+			combinators: combinators,
+			offset:      q.width,
+		}
+		for i, c := range combinators {
+			switch c {
+			case '+', '~':
+				if ch.prefixes == nil {
+					ch.prefixes = make([]Sel, len(compounds))
+				}
+				ch.prefixes[i] = prefix(s, i)
+			}
+		}
+		q.chains = append(q.chains, ch)
+		q.width += len(compounds)
+	}
+	return q
+}
+
+// hasAncestorCombinator returns whether s has a descendant or child
+// combinator.
+func hasAncestorCombinator(s Sel) bool {
+	if b, ok := s.(Builder); ok {
+		s = b.Sel()
+	}
+	c, ok := s.(combinedSelector)
+	if !ok {
+		return false
+	}
+	if c.second != nil && (c.combinator == ' ' || c.combinator == '>') {
+		return true
+	}
+	return hasAncestorCombinator(c.first)
+}
+
+// queryAll returns the descendants of root that match the query in the
+// context c, in document order.
+func (q *chainQuery) queryAll(c *matchContext, root *html.Node) []*html.Node {
+	return q.queryInto(new(chainSearch), c, root, nil)
+}
+
+// queryInto is like queryAll, but it appends the matches to dst, and uses
+// the buffers in s (which may be left over from an earlier search) for its
+// scratch space.
+func (q *chainQuery) queryInto(s *chainSearch, c *matchContext, root *html.Node, dst []*html.Node) []*html.Node {
+	s.q, s.c, s.matches = q, c, dst
+	if cap(s.counts) < q.width {
+		s.counts = make([]int, q.width)
+	}
+	s.counts = s.counts[:q.width]
+	for i := range s.counts {
+		s.counts[i] = 0
+	}
+
+	// Record the results for root and its ancestors, from the top of the
+	// tree down.
+	s.path = s.path[:0]
+	for a := root; a != nil; a = a.Parent {
+		s.path = append(s.path, a)
+	}
+	depth := len(s.path)
+	var parent []bool
+	for i := depth - 1; i >= 0; i-- {
+		results := s.frame(depth - 1 - i)
+		s.eval(s.path[i], parent, results)
+		s.push(results)
+		parent = results
+	}
+
+	s.search(root, parent, depth)
+	matches := s.matches
+	s.q, s.c, s.matches = nil, nil, nil
+	for i := range s.path {
+		s.path[i] = nil
+	}
+	return matches
+}
+
+// A chainSearch holds the state of a chainQuery's search.
+type chainSearch struct {
+	q *chainQuery
+	c *matchContext
+
+	// counts holds the number of ancestors of the current node that match
+	// each prefix.
+	counts []int
+
+	// frames holds the results for the nodes on the path from the top of
+	// the tree to the current node, q.width for each level.
+	frames []bool
+
+	// path holds the search root and its ancestors.
+	path []*html.Node
+
+	matches []*html.Node
+}
+
+// frame returns the slice to store the results for the node at depth.
+func (s *chainSearch) frame(depth int) []bool {
+	w := s.q.width
+	if end := (depth + 1) * w; end > len(s.frames) {
+		// The slices already returned for shallower levels keep pointing
+		// to the old array, which still holds their results.
+		frames := make([]bool, 2*end)
+		copy(frames, s.frames)
+		s.frames = frames
+	}
+	return s.frames[depth*w : (depth+1)*w : (depth+1)*w]
+}
+
+// push adds the results for a node to counts, as its descendants are
+// searched.
+func (s *chainSearch) push(results []bool) {
+	for i, r := range results {
+		if r {
+			s.counts[i]++
+		}
+	}
+}
+
+// pop removes the results for a node from counts.
+func (s *chainSearch) pop(results []bool) {
+	for i, r := range results {
+		if r {
+			s.counts[i]--
+		}
+	}
+}
+
+func (s *chainSearch) search(n *html.Node, results []bool, depth int) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		childResults := s.frame(depth)
+		if s.eval(child, results, childResults) {
+			s.matches = append(s.matches, child)
+		}
+		if child.FirstChild != nil {
+			s.push(childResults)
+			s.search(child, childResults, depth+1)
+			s.pop(childResults)
+		}
+	}
+}
+
+// eval records in results which prefixes of the selectors n matches, given
+// the results for its parent and s.counts for its ancestors. It returns
+// whether n matches any of the selectors.
+func (s *chainSearch) eval(n *html.Node, parent []bool, results []bool) bool {
+	matched := false
+	for _, ch := range s.q.chains {
+		for i, c := range ch.compounds {
+			j := ch.offset + i
+			var r bool
+			switch ch.combinators[i] {
+			case 0:
+				r = s.c.match(c, n)
+			case ' ':
+				r = s.counts[j-1] > 0 && s.c.match(c, n)
+			case '>':
+				r = parent != nil && parent[j-1] && s.c.match(c, n)
+			default:
+				r = s.c.match(ch.prefixes[i], n)
+			}
+			results[j] = r
+		}
+		if results[ch.offset+len(ch.compounds)-1] {
+			matched = true
+		}
+	}
+	return matched
+}
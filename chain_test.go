@@ -0,0 +1,96 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// queryAllEveryNode is QueryAll without the chainQuery strategy.
+func queryAllEveryNode(n *html.Node, m Matcher) []*html.Node {
+	return filterResults(nil, m, queryInto(nil, n, m, nil))
+}
+
+func checkChainQuery(t *testing.T, root *html.Node, g SelectorGroup) {
+	t.Helper()
+	q := newChainQuery(g)
+	if q == nil {
+		return
+	}
+	want := queryAllEveryNode(root, g)
+	got := q.queryAll(nil, root)
+	if len(got) != len(want) {
+		t.Errorf("%s: found %d elements, want %d", g, len(got), len(want))
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s: match %d is %s, want %s", g, i, nodeString(got[i]), nodeString(want[i]))
+		}
+	}
+}
+
+func TestChainQuery(t *testing.T) {
+	for _, test := range selectorTests {
+		g, err := ParseGroup(test.selector)
+		if err != nil {
+			continue
+		}
+		doc, err := html.Parse(strings.NewReader(test.HTML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkChainQuery(t, doc, g)
+	}
+}
+
+func TestChainQueryShakespeare(t *testing.T) {
+	doc := parseReference("test_resources/shakespeare.html")
+	body := doc.FirstChild.NextSibling.LastChild
+	for _, sel := range []string{
+		"div div div",
+		"body > div div",
+		"html div.dialog",
+		"div.dialog .dialog .direction",
+		"div + div > div",
+		"div ~ div div, #speech5 *",
+		"div:not(.dialog) > div:nth-child(2n+1)",
+		"div:has(.dialog) div",
+	} {
+		g := MustParseGroup(t, sel)
+		checkChainQuery(t, doc, g)
+		checkChainQuery(t, body, g)
+	}
+}
+
+func TestChainQueryNotUsed(t *testing.T) {
+	for _, sel := range []string{"div", "div.a", "p + p", "li:first", "div p:last"} {
+		if q := newChainQuery(MustParseGroup(t, sel)); q != nil {
+			t.Errorf("%s: chainQuery used for a selector without descendant or child combinators, or with positional pseudo-classes", sel)
+		}
+	}
+}
+
+func BenchmarkDeepDescendants(b *testing.B) {
+	page := strings.Repeat("<div>", 200) + "<p>text</p>" + strings.Repeat("</div>", 200)
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// Every div has to be checked for a section ancestor.
+	g, err := ParseGroup("section div div")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("every-node", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			queryAllEveryNode(doc, g)
+		}
+	})
+	b.Run("chain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			QueryAll(doc, g)
+		}
+	})
+}
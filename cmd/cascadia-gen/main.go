@@ -0,0 +1,67 @@
+// Command cascadia-gen generates Go functions that match CSS selectors, so
+// that the selectors don't need to be parsed at run time.
+//
+// Usage:
+//
+//	cascadia-gen -pkg name [-o file] [-prefix prefix] Name=selector ...
+//
+// Each argument is the name of a function to generate, an equals sign, and
+// the selector it should match. For example, with go generate:
+//
+//	//go:generate cascadia-gen -pkg scrape -o selectors.go Links=a[href] Titles=h1,h2
+//
+// The output is written to standard output if -o is not given. See the
+// documentation of github.com/andybalholm/cascadia/gen for the selectors that
+// are supported.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/cascadia/gen"
+)
+
+func main() {
+	var config gen.Config
+	flag.StringVar(&config.Package, "pkg", os.Getenv("GOPACKAGE"), "the `name` of the package for the generated file (default $GOPACKAGE)")
+	flag.StringVar(&config.Prefix, "prefix", "", "a `prefix` for unexported names in the generated file (default \"cascadia\")")
+	output := flag.String("o", "", "the `file` to write to (default standard output)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: cascadia-gen -pkg name [-o file] [-prefix prefix] Name=selector ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var selectors []gen.Selector
+	for _, arg := range flag.Args() {
+		i := strings.IndexByte(arg, '=')
+		if i == -1 {
+			fmt.Fprintf(os.Stderr, "cascadia-gen: argument %q is not of the form Name=selector\n", arg)
+			os.Exit(2)
+		}
+		selectors = append(selectors, gen.Selector{Name: arg[:i], Selector: arg[i+1:]})
+	}
+
+	var b bytes.Buffer
+	if err := gen.Generate(&b, config, selectors); err != nil {
+		fmt.Fprintf(os.Stderr, "cascadia-gen: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		os.Stdout.Write(b.Bytes())
+		return
+	}
+	if err := ioutil.WriteFile(*output, b.Bytes(), 0666); err != nil {
+		fmt.Fprintf(os.Stderr, "cascadia-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,194 @@
+// Command cascadia finds the elements in HTML documents that match CSS
+// selectors, and prints them.
+//
+// Usage:
+//
+//	cascadia [flags] selector [file ...]
+//
+// The documents are read from the named files, or from standard input if no
+// files are named. By default, the outer HTML of each matching element is
+// printed. The -text flag prints the text each element contains instead, and
+// the -attr flag prints the value of an attribute, skipping elements that
+// don't have it.
+//
+// To use more than one selector, give each one with the -e flag, instead of
+// as the first argument. An element that matches any of them is printed
+// once.
+//
+// The exit status is 0 if an element was found, 1 if none was found, and 2
+// if an error occurred, as with grep.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// stringList is a flag.Value that collects the values of a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errNoMatch is returned by grep when no element matched.
+var errNoMatch = errors.New("no match")
+
+type options struct {
+	text      bool
+	attr      string
+	filenames bool
+	separator string
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cascadia", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cascadia [flags] selector [file ...]")
+		flags.PrintDefaults()
+	}
+	var (
+		opts      options
+		selectors stringList
+	)
+	flags.Var(&selectors, "e", "an additional `selector` (may be repeated)")
+	flags.BoolVar(&opts.text, "text", false, "print the text of each element instead of its HTML")
+	flags.StringVar(&opts.attr, "attr", "", "print the value of the attribute `name` instead of the HTML")
+	flags.BoolVar(&opts.filenames, "H", false, "print the file name before each match")
+	flags.StringVar(&opts.separator, "sep", "\n", "the `string` printed after each match")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+	if len(selectors) == 0 {
+		if len(args) == 0 {
+			flags.Usage()
+			return 2
+		}
+		selectors = append(selectors, args[0])
+		args = args[1:]
+	}
+	if opts.text && opts.attr != "" {
+		fmt.Fprintln(stderr, "cascadia: -text and -attr can't be used together")
+		return 2
+	}
+
+	var group cascadia.SelectorGroup
+	for _, s := range selectors {
+		g, err := cascadia.ParseGroup(s)
+		if err != nil {
+			fmt.Fprintf(stderr, "cascadia: %v\n", err)
+			return 2
+		}
+		group = append(group, g...)
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	found := false
+	if len(args) == 0 {
+		err := grep(out, stdin, "", group, opts)
+		switch err {
+		case nil:
+			found = true
+		case errNoMatch:
+		default:
+			fmt.Fprintf(stderr, "cascadia: %v\n", err)
+			return 2
+		}
+	}
+	failed := false
+	for _, name := range args {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "cascadia: %v\n", err)
+			failed = true
+			continue
+		}
+		err = grep(out, f, name, group, opts)
+		f.Close()
+		switch err {
+		case nil:
+			found = true
+		case errNoMatch:
+		default:
+			fmt.Fprintf(stderr, "cascadia: %s: %v\n", name, err)
+			failed = true
+		}
+	}
+
+	switch {
+	case failed:
+		return 2
+	case found:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// grep parses an HTML document from r, and writes the elements that match
+// m to w.
+func grep(w io.Writer, r io.Reader, name string, m cascadia.Matcher, opts options) error {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return err
+	}
+	matches := cascadia.QueryAll(doc, m)
+	if len(matches) == 0 {
+		return errNoMatch
+	}
+	for _, n := range matches {
+		var val string
+		if opts.attr != "" {
+			var ok bool
+			if val, ok = attr(n, opts.attr); !ok {
+				continue
+			}
+		}
+		if opts.filenames && name != "" {
+			fmt.Fprintf(w, "%s:", name)
+		}
+		switch {
+		case opts.text:
+			io.WriteString(w, strings.TrimSpace(cascadia.VisibleText(n)))
+		case opts.attr != "":
+			io.WriteString(w, val)
+		default:
+			if err := html.Render(w, n); err != nil {
+				return err
+			}
+		}
+		io.WriteString(w, opts.separator)
+	}
+	return nil
+}
+
+// attr returns the value of n's attribute named key, and whether it has one.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDoc = `<html><body>
+<ul>
+  <li><a href="/one">One</a></li>
+  <li class="x"><a href="/two">Two</a></li>
+  <li><a>Three</a></li>
+</ul>
+</body></html>`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "doc.html")
+	if err := os.WriteFile(file, []byte(testDoc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		out    string
+		status int
+	}{
+		{[]string{"li.x"}, testDoc, `<li class="x"><a href="/two">Two</a></li>` + "\n", 0},
+		{[]string{"-text", "a"}, testDoc, "One\nTwo\nThree\n", 0},
+		{[]string{"-attr", "href", "a"}, testDoc, "/one\n/two\n", 0},
+		{[]string{"-text", "-e", "li.x a", "-e", "a[href='/one']"}, testDoc, "One\nTwo\n", 0},
+		{[]string{"-text", "-sep", ",", "li:nth-child(odd)"}, testDoc, "One,Three,", 0},
+		{[]string{"table"}, testDoc, "", 1},
+		{[]string{"-H", "-text", "li.x", file}, "", file + ":Two\n", 0},
+		{[]string{"p["}, testDoc, "", 2},
+		{[]string{}, testDoc, "", 2},
+		{[]string{"li", filepath.Join(dir, "missing.html")}, "", "", 2},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if status != test.status {
+			t.Errorf("%q: exit status %d, want %d (stderr: %s)", test.args, status, test.status, stderr.String())
+		}
+		if stdout.String() != test.out {
+			t.Errorf("%q: got output %q, want %q", test.args, stdout.String(), test.out)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cascadia
+
+import (
+	"fmt"
+)
+
+// Complexity returns an estimate of how expensive s is to match, for
+// rejecting pathological selectors from untrusted sources. Each simple
+// selector and combinator counts 1, except for regular expressions, which
+// count 5. The arguments of :not() count once, but the arguments of :has()
+// and :haschild() count double, since they are matched against many
+// elements for each element tested; so nesting them increases the
+// complexity exponentially, like the cost of matching.
+func Complexity(s Sel) int {
+	switch s := s.(type) {
+	case Builder:
+		return Complexity(s.Sel())
+	case compoundSelector:
+		if len(s.selectors) == 0 {
+			return 1
+		}
+		total := 0
+		for _, sel := range s.selectors {
+			total += Complexity(sel)
+		}
+		return total
+	case combinedSelector:
+		total := Complexity(s.first)
+		if s.second != nil {
+			total += 1 + Complexity(s.second)
+		}
+		return total
+	case relativePseudoClassSelector:
+		inner := groupComplexity(s.match)
+		if s.name == "not" {
+			return 1 + inner
+		}
+		return 1 + 2*inner
+	case regexpPseudoClassSelector, tagRegexpPseudoClassSelector:
+		return 5
+	case attrSelector:
+		if s.operation == "#=" {
+			return 5
+		}
+	}
+	return 1
+}
+
+func groupComplexity(g SelectorGroup) int {
+	total := 0
+	for _, sel := range g {
+		total += Complexity(sel)
+	}
+	return total
+}
+
+// checkComplexity returns an error if the complexity of g is more than the
+// MaxComplexity in opts.
+func (opts ParseOptions) checkComplexity(g SelectorGroup) error {
+	if opts.MaxComplexity <= 0 {
+		return nil
+	}
+	if c := groupComplexity(g); c > opts.MaxComplexity {
+		return fmt.Errorf("selector is too complex (complexity %d, maximum %d)", c, opts.MaxComplexity)
+	}
+	return nil
+}
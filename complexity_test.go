@@ -0,0 +1,57 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComplexity(t *testing.T) {
+	for _, test := range []struct {
+		sel  string
+		want int
+	}{
+		{"*", 1},
+		{"p", 1},
+		{"p.a#b", 3},
+		{"ul > li", 3},
+		{"div p span", 5},
+		{"p:not(.a)", 3},
+		{"p:not(.a, .b)", 4},
+		{"div:has(p)", 4},
+		{"div:has(p:has(span))", 10},
+		{"p:matches(^a)", 6},
+		{"[href#=(\\.pdf$)]", 5},
+	} {
+		if skipRegexp(test.sel) {
+			continue
+		}
+		s := MustParse(t, test.sel)
+		if got := Complexity(s); got != test.want {
+			t.Errorf("Complexity(%s) = %d, want %d", test.sel, got, test.want)
+		}
+	}
+}
+
+func TestMaxComplexity(t *testing.T) {
+	opts := ParseOptions{MaxComplexity: 10}
+	deep := "div" + strings.Repeat(":has(div", 5) + strings.Repeat(")", 5)
+
+	if _, err := ParseWithOptions("div:has(p)", opts); err != nil {
+		t.Errorf("div:has(p): %s", err)
+	}
+	if _, err := ParseWithOptions(deep, opts); err == nil {
+		t.Errorf("%s: expected an error", deep)
+	}
+	if _, err := ParseWithOptions(deep, ParseOptions{}); err != nil {
+		t.Errorf("%s with no limit: %s", deep, err)
+	}
+	if _, err := ParseGroupWithOptions("a b c, d e f, g h i", opts); err == nil {
+		t.Error("group of 3 selectors with complexity 5: expected an error")
+	}
+	if err := ValidateWithOptions(deep, opts); err == nil {
+		t.Errorf("Validate(%s): expected an error", deep)
+	}
+	if _, err := ParseGroupRecover(deep+", p", opts); err == nil {
+		t.Errorf("ParseGroupRecover(%s, p): expected an error", deep)
+	}
+}
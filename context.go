@@ -0,0 +1,499 @@
+package cascadia
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A matchContext holds the state for a query that goes beyond the node being
+// tested, such as the node that :scope refers to. A nil *matchContext is
+// valid, and means the defaults.
+type matchContext struct {
+	// scope is the element matched by :scope. If it is nil, :scope matches
+	// the root element.
+	scope *html.Node
+
+	// root is the element matched by :root, which is treated as if it had
+	// no parent or siblings. If it is nil, :root matches the element whose
+	// parent is the document node.
+	root *html.Node
+
+	// bound is the highest node that combinators may look at. They don't
+	// look at its ancestors or siblings. If it is nil, there is no limit.
+	bound *html.Node
+
+	// trace, if it is not nil, is called with the result of each call to
+	// match. See Trace.
+	trace TraceFunc
+
+	// stats, if it is not nil, counts the work done while matching. See
+	// CollectStats.
+	stats *Stats
+
+	// limiter, if it is not nil, stops the query when it does too much
+	// work. See QueryAllLimited.
+	limiter *limiter
+
+	// inertTemplates is true if the content of template elements is hidden
+	// from the search, combinators and :has(). See MatchOptions.
+	inertTemplates bool
+
+	// fragment holds the roots of a document fragment, like the nodes
+	// returned by html.ParseFragment. The ones without a parent are
+	// treated as the children of a common parent, in order, by
+	// :nth-child() and the like. See QueryAllFrom.
+	fragment []*html.Node
+
+	// results is true if the matches will be passed to filterResults, so
+	// result-set pseudo-classes like :first should match every element
+	// until then. Otherwise they match nothing.
+	results bool
+
+	// siblings caches the positions of the children of each parent node
+	// that :nth-child() and the like have looked at, so that they don't
+	// count the siblings of every child again. It is created when it is
+	// first needed.
+	siblings map[*html.Node]*childPositions
+
+	// spare holds emptied childPositions left from an earlier query, to be
+	// reused.
+	spare []*childPositions
+
+	// texts, if it is not nil, caches the text of nodes for the
+	// pseudo-classes that match text. See Session.
+	texts map[textKey]string
+
+	// cacheClasses is true if the class selectors should split each class
+	// attribute only once, with classList. The last split is kept in
+	// classValue and classes.
+	cacheClasses bool
+	classesSplit bool
+	classValue   string
+	classes      []string
+}
+
+// A contextMatcher is a Matcher that can use the information in a
+// matchContext. Selectors that contain other selectors implement it, so that
+// the context is passed down to the selectors that need it.
+type contextMatcher interface {
+	matchIn(c *matchContext, n *html.Node) bool
+}
+
+// A contextWrapper is a Matcher that matches the same nodes as another
+// Matcher, but in a modified context, like the ones returned by Trace and
+// CollectStats.
+type contextWrapper interface {
+	unwrap(c *matchContext) (*matchContext, Matcher)
+}
+
+// match returns whether m matches n in the context c.
+func (c *matchContext) match(m Matcher, n *html.Node) bool {
+	var matched bool
+	if cm, ok := m.(contextMatcher); ok {
+		matched = cm.matchIn(c, n)
+	} else {
+		matched = m.Match(n)
+	}
+	if c != nil && c.stats != nil {
+		c.stats.Tests++
+	}
+	if c != nil && c.trace != nil {
+		c.trace(n, m, matched)
+	}
+	return matched
+}
+
+// observed returns whether each test is being recorded, for a trace or for
+// stats, so that shortcuts that skip the tests of single selectors must not
+// be taken.
+func (c *matchContext) observed() bool {
+	return c != nil && (c.trace != nil || c.stats != nil)
+}
+
+// step records a step taken by a combinator or a relative pseudo-class,
+// such as looking at a parent or a previous sibling.
+func (c *matchContext) step() {
+	if c == nil {
+		return
+	}
+	if c.stats != nil {
+		c.stats.CombinatorSteps++
+	}
+	if c.limiter != nil {
+		c.limiter.visit()
+	}
+}
+
+// enter records that the search is descending to the children of a node.
+func (c *matchContext) enter() {
+	if c != nil && c.limiter != nil {
+		c.limiter.enter()
+	}
+}
+
+// leave records that the search is returning from the children of a node.
+func (c *matchContext) leave() {
+	if c != nil && c.limiter != nil {
+		c.limiter.depth--
+	}
+}
+
+// matchRegexp returns whether rx matches s, and records that it was run.
+func (c *matchContext) matchRegexp(rx pattern, s string) bool {
+	if c != nil && c.stats != nil {
+		c.stats.RegexpEvaluations++
+	}
+	return rx.MatchString(s)
+}
+
+// scanText records that n bytes of the text of a node were extracted for a
+// pseudo-class like :contains().
+func (c *matchContext) scanText(n int) {
+	if c != nil && c.stats != nil {
+		c.stats.TextBytes += n
+	}
+}
+
+// A textKey identifies an entry in the text cache: a node, and which of its
+// texts it is.
+type textKey struct {
+	n    *html.Node
+	kind textKind
+}
+
+type textKind uint8
+
+const (
+	textAll textKind = iota // the text of the node and its descendants
+	textOwn                 // the text of the node's own text children
+
+	// Added to one of the above, for the lower-case or case-folded text.
+	textLower textKind = 2
+	textFold  textKind = 4
+)
+
+// text returns the text of n for a pseudo-class like :contains(): its own
+// text if own is true, or else the text returned by f. If c has a text
+// cache, the text of each node is only extracted once. Text from a TextFunc
+// other than the default is not cached, since functions can't be compared.
+func (c *matchContext) text(n *html.Node, own bool, f TextFunc) string {
+	key := textKey{n, textAll}
+	if own {
+		key.kind = textOwn
+	}
+	cached := c != nil && c.texts != nil && (own || f == nil)
+	if cached {
+		if t, ok := c.texts[key]; ok {
+			return t
+		}
+	}
+
+	var t string
+	if own {
+		t = nodeOwnText(n)
+	} else {
+		t = f.of(n)
+	}
+	c.scanText(len(t))
+	if cached {
+		c.texts[key] = t
+	}
+	return t
+}
+
+// lowerText is like text, but it returns the text in lower case, or with
+// Unicode case folding if fold is true.
+func (c *matchContext) lowerText(n *html.Node, own bool, f TextFunc, fold bool) string {
+	key := textKey{n, textAll + textLower}
+	if own {
+		key.kind = textOwn + textLower
+	}
+	if fold {
+		key.kind += textFold - textLower
+	}
+	cached := c != nil && c.texts != nil && (own || f == nil)
+	if cached {
+		if t, ok := c.texts[key]; ok {
+			return t
+		}
+	}
+
+	t := c.text(n, own, f)
+	if fold {
+		t = foldString(t)
+	} else {
+		t = strings.ToLower(t)
+	}
+	if cached {
+		c.texts[key] = t
+	}
+	return t
+}
+
+// textPseudoClasses returns the number of pseudo-classes in s that match
+// text, like :contains().
+func textPseudoClasses(s Sel) int {
+	return countSelectors(s, func(s Sel) bool {
+		switch s.(type) {
+		case containsPseudoClassSelector, textIsPseudoClassSelector, regexpPseudoClassSelector:
+			return true
+		}
+		return false
+	})
+}
+
+// classSelectors returns the number of class selectors in s (not counting
+// ones in quirks mode).
+func classSelectors(s Sel) int {
+	return countSelectors(s, func(s Sel) bool {
+		c, ok := s.(classSelector)
+		return ok && !c.quirks
+	})
+}
+
+// countSelectors returns the number of simple selectors in s, including
+// those in the arguments of pseudo-classes like :not(), for which f
+// returns true.
+func countSelectors(s Sel, f func(Sel) bool) int {
+	switch s := s.(type) {
+	case Builder:
+		return countSelectors(s.Sel(), f)
+	case compoundSelector:
+		total := 0
+		for _, sel := range s.selectors {
+			total += countSelectors(sel, f)
+		}
+		return total
+	case combinedSelector:
+		total := countSelectors(s.first, f)
+		if s.second != nil {
+			total += countSelectors(s.second, f)
+		}
+		return total
+	case relativePseudoClassSelector:
+		total := 0
+		for _, sel := range s.match {
+			total += countSelectors(sel, f)
+		}
+		return total
+	}
+	if f(s) {
+		return 1
+	}
+	return 0
+}
+
+// classList returns the classes in s, the value of a class attribute. The
+// list for the last value is kept, since the class selectors in a compound
+// or a group test the same element's classes one after another. The list
+// is only valid until the next call.
+func (c *matchContext) classList(s string) []string {
+	if c.classesSplit && s == c.classValue {
+		return c.classes
+	}
+	c.classes = c.classes[:0]
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || isHTMLSpace(rune(s[i])) {
+			if start >= 0 {
+				c.classes = append(c.classes, s[start:i])
+			}
+			start = -1
+		} else if start < 0 {
+			start = i
+		}
+	}
+	c.classValue, c.classesSplit = s, true
+	return c.classes
+}
+
+// newQueryContext returns a context for finding all the matches of m in a
+// document. If m has more than one pseudo-class that matches text, it
+// caches the text of the nodes.
+func newQueryContext(m Matcher) *matchContext {
+	c := new(matchContext)
+	c.reset(m)
+	return c
+}
+
+// reset prepares c for finding all the matches of m, as newQueryContext
+// does, but keeps its maps to be reused. If m is nil, it just empties them.
+func (c *matchContext) reset(m Matcher) {
+	siblings, texts, spare, classes := c.siblings, c.texts, c.spare, c.classes
+	for k, cp := range siblings {
+		delete(siblings, k)
+		cp.reset()
+		spare = append(spare, cp)
+	}
+	for k := range texts {
+		delete(texts, k)
+	}
+	classes = classes[:cap(classes)]
+	for i := range classes {
+		classes[i] = ""
+	}
+	*c = matchContext{siblings: siblings, spare: spare, classes: classes[:0], results: true}
+
+	var sels []Sel
+	switch m := m.(type) {
+	case SelectorGroup:
+		sels = m
+	case Sel:
+		sels = []Sel{m}
+	}
+	textCount, classCount := 0, 0
+	for _, s := range sels {
+		textCount += textPseudoClasses(s)
+		classCount += classSelectors(s)
+	}
+	if textCount > 1 {
+		if texts == nil {
+			texts = make(map[textKey]string)
+		}
+		c.texts = texts
+	}
+	c.cacheClasses = classCount > 1
+}
+
+// parent returns the parent of n, unless n is the bound of the match.
+func (c *matchContext) parent(n *html.Node) *html.Node {
+	if c != nil && (n == c.bound || c.inertTemplates && isTemplate(n.Parent)) {
+		return nil
+	}
+	return n.Parent
+}
+
+// inert returns whether the children of n are hidden from the match,
+// because n is a template element and c treats template content as inert.
+func (c *matchContext) inert(n *html.Node) bool {
+	return c != nil && c.inertTemplates && isTemplate(n)
+}
+
+// walk returns a walker for the descendants of root (and root itself if
+// self is true), which skips template content if c treats it as inert.
+func (c *matchContext) walk(root *html.Node, self bool) walker {
+	w := walk(root, self)
+	w.inert = c != nil && c.inertTemplates
+	return w
+}
+
+// prevSibling returns the previous sibling of n, unless n is the bound of
+// the match.
+func (c *matchContext) prevSibling(n *html.Node) *html.Node {
+	if c != nil && n == c.bound {
+		return nil
+	}
+	return n.PrevSibling
+}
+
+// scopeNode returns the node that :scope should match, or nil to use the
+// root element.
+func (c *matchContext) scopeNode() *html.Node {
+	if c == nil {
+		return nil
+	}
+	return c.scope
+}
+
+// rootNode returns the element that :root should match, or nil to use the
+// child of the document node.
+func (c *matchContext) rootNode() *html.Node {
+	if c == nil {
+		return nil
+	}
+	return c.root
+}
+
+// childPositions records the positions of a node's element children.
+type childPositions struct {
+	positions map[*html.Node]childPosition
+	count     int
+	typeCount map[string]int
+}
+
+// reset empties cp.
+func (cp *childPositions) reset() {
+	for k := range cp.positions {
+		delete(cp.positions, k)
+	}
+	for k := range cp.typeCount {
+		delete(cp.typeCount, k)
+	}
+	cp.count = 0
+}
+
+// A childPosition is the 1-based index of an element among its parent's
+// element children, and among those of the same type.
+type childPosition struct {
+	index, typeIndex int
+}
+
+// add records the position of the next child, s, if it is an element.
+func (cp *childPositions) add(s *html.Node) {
+	if s.Type != html.ElementNode {
+		return
+	}
+	cp.count++
+	cp.typeCount[s.Data]++
+	cp.positions[s] = childPosition{cp.count, cp.typeCount[s.Data]}
+}
+
+// inFragment returns whether n is one of the parentless roots of c.fragment.
+func (c *matchContext) inFragment(n *html.Node) bool {
+	if c == nil || n.Parent != nil {
+		return false
+	}
+	for _, root := range c.fragment {
+		if root == n {
+			return true
+		}
+	}
+	return false
+}
+
+// childIndex returns the 1-based index of the element n among its
+// parent's element children, for :nth-child(). If ofType is true, only
+// elements of the same type are counted; if last is true, they are counted
+// from the end. n must have a parent, or be one of the roots of c.fragment,
+// which are counted as the children of a common parent.
+func (c *matchContext) childIndex(n *html.Node, last, ofType bool) int {
+	if c.siblings == nil {
+		c.siblings = make(map[*html.Node]*childPositions)
+	}
+	cp := c.siblings[n.Parent]
+	if cp == nil {
+		if k := len(c.spare); k > 0 {
+			cp = c.spare[k-1]
+			c.spare[k-1] = nil
+			c.spare = c.spare[:k-1]
+		} else {
+			cp = &childPositions{
+				positions: make(map[*html.Node]childPosition),
+				typeCount: make(map[string]int),
+			}
+		}
+		if n.Parent != nil {
+			for s := n.Parent.FirstChild; s != nil; s = s.NextSibling {
+				cp.add(s)
+			}
+		} else {
+			for _, s := range c.fragment {
+				if s.Parent == nil {
+					cp.add(s)
+				}
+			}
+		}
+		c.siblings[n.Parent] = cp
+	}
+
+	p := cp.positions[n]
+	i, count := p.index, cp.count
+	if ofType {
+		i, count = p.typeIndex, cp.typeCount[n.Data]
+	}
+	if last {
+		i = count - i + 1
+	}
+	return i
+}
@@ -0,0 +1,373 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var relativeTests = []struct {
+	HTML, scope, selector string
+	results               []string
+}{
+	{
+		`<ul id=a><li id=1><ul><li id=2></ul></ul>`,
+		"#a",
+		"> li",
+		[]string{
+			`<li id="1"><ul><li id="2"></li></ul></li>`,
+		},
+	},
+	{
+		`<ul id=a><li id=1><ul><li id=2></ul></ul>`,
+		"#a",
+		"li",
+		[]string{
+			`<li id="1"><ul><li id="2"></li></ul></li>`,
+			`<li id="2"></li>`,
+		},
+	},
+	{
+		`<ul id=a><li id=1><ul><li id=2></ul></ul>`,
+		"#a",
+		"> li > ul > li",
+		[]string{
+			`<li id="2"></li>`,
+		},
+	},
+	{
+		`<p id=1><h2 id=a></h2><p id=2><p id=3><div><p id=4></div>`,
+		"#a",
+		"+ p",
+		[]string{
+			`<p id="2"></p>`,
+		},
+	},
+	{
+		`<p id=1><h2 id=a></h2><p id=2><p id=3><div><p id=4></div>`,
+		"#a",
+		"~ p",
+		[]string{
+			`<p id="2"></p>`,
+			`<p id="3"></p>`,
+		},
+	},
+	{
+		`<p id=1><h2 id=a></h2><p id=2><p id=3><div><p id=4></div>`,
+		"#a",
+		"~ div p, ~ p:last",
+		[]string{
+			`<p id="3"></p>`,
+			`<p id="4"></p>`,
+		},
+	},
+	{
+		`<div id=a><p id=1></p></div><div><p id=2></p></div>`,
+		"#a",
+		"div:not(:scope) > p",
+		[]string{},
+	},
+	{
+		`<div id=a><p id=1></p></div><div><p id=2></p></div>`,
+		"#a",
+		":scope p, :not(:scope) > p",
+		[]string{
+			`<p id="1"></p>`,
+		},
+	},
+	{
+		`<div id=a><p id=1></p><p id=2></p></div><p id=3></p>`,
+		"#a",
+		"p:eq(1)",
+		[]string{
+			`<p id="2"></p>`,
+		},
+	},
+}
+
+func TestRelative(t *testing.T) {
+	for _, test := range relativeTests {
+		doc, err := html.Parse(strings.NewReader(test.HTML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scope := Query(doc, MustParseGroup(t, test.scope))
+		s, err := ParseGroupWithOptions(test.selector, ParseOptions{Relative: true})
+		if err != nil {
+			t.Errorf("error compiling %q: %s", test.selector, err)
+			continue
+		}
+
+		matches := QueryAllRelative(scope, s)
+		if len(matches) != len(test.results) {
+			t.Errorf("selector %s wanted %d elements, got %d instead", test.selector, len(test.results), len(matches))
+			continue
+		}
+		for i, m := range matches {
+			got := nodeString(m)
+			if got != test.results[i] {
+				t.Errorf("selector %s wanted %s, got %s instead", test.selector, test.results[i], got)
+			}
+		}
+
+		firstMatch := QueryRelative(scope, s)
+		if len(test.results) == 0 {
+			if firstMatch != nil {
+				t.Errorf("QueryRelative: selector %s want nil, got %s", test.selector, nodeString(firstMatch))
+			}
+		} else if got := nodeString(firstMatch); got != test.results[0] {
+			t.Errorf("QueryRelative: selector %s want %s, got %s", test.selector, test.results[0], got)
+		}
+
+		serialized := s.String()
+		s2, err := ParseGroupWithOptions(serialized, ParseOptions{Relative: true})
+		if err != nil {
+			t.Errorf("error compiling %q: %s (original : %s)", serialized, err, test.selector)
+		} else if !reflect.DeepEqual(s, s2) {
+			t.Errorf("can't retrieve selector from serialized : %s (original : %s)", serialized, test.selector)
+		}
+	}
+}
+
+func TestRelativeSpecificity(t *testing.T) {
+	for sel, want := range map[string]Specificity{
+		"> p":       {0, 0, 1},
+		"+ p.a":     {0, 1, 1},
+		":scope p":  {0, 1, 1},
+		"div ~ #id": {1, 0, 1},
+	} {
+		s, err := ParseWithOptions(sel, ParseOptions{Relative: true})
+		if err != nil {
+			t.Errorf("error compiling %q: %s", sel, err)
+			continue
+		}
+		if got := s.Specificity(); got != want {
+			t.Errorf("%s: specificity %v, want %v", sel, got, want)
+		}
+	}
+
+	if _, err := Parse("> p"); err == nil {
+		t.Error("a leading combinator should be an error without the Relative option")
+	}
+}
+
+func TestQueryContext(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<table class=start><tr id=r1><td id=1></td></tr><tr id=r2 class=start><td id=2></td><td id=3></td></tr></table>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1 := Query(doc, MustParseGroup(t, "#r1"))
+	r2 := Query(doc, MustParseGroup(t, "#r2"))
+	for _, test := range []struct {
+		context  *html.Node
+		selector string
+		want     int
+	}{
+		{r1, ".start td", 0},
+		{r2, ".start td", 2},
+		{r1, "tr > td", 1},
+		{r1, "table td", 0},
+		{r2, "td + td", 1},
+		{r2, "td:last", 1},
+	} {
+		s := MustParseGroup(t, test.selector)
+		if got := len(QueryAll(test.context, s)); got < test.want {
+			t.Errorf("QueryAll(%s) found %d matches, expected at least %d", test.selector, got, test.want)
+		}
+		matches := QueryAllContext(test.context, s)
+		if len(matches) != test.want {
+			t.Errorf("QueryAllContext(%s) found %d matches, want %d", test.selector, len(matches), test.want)
+		}
+		first := QueryContext(test.context, s)
+		if test.want == 0 && first != nil || test.want > 0 && first != matches[0] {
+			t.Errorf("QueryContext(%s) returned the wrong node", test.selector)
+		}
+		if hasPositional(s) {
+			// Result-set pseudo-classes only work in queries.
+			continue
+		}
+		for _, m := range matches {
+			if !MatchContext(s, m, test.context) {
+				t.Errorf("MatchContext(%s) is false for a node returned by QueryAllContext", test.selector)
+			}
+		}
+	}
+
+	// The scope node's siblings are out of bounds too.
+	s := MustParseGroup(t, "tr + tr")
+	if MatchContext(s, r2, r2) {
+		t.Error("tr + tr matched the scope node by looking at its sibling")
+	}
+	if !s.Match(r2) {
+		t.Error("tr + tr should match without a scope")
+	}
+}
+
+func TestQueryRoot(t *testing.T) {
+	doc := MustParseHTML(`<div id=a><section id=b><p id=c>one</p><p id=d>two</p></section><section id=e></section></div>`)
+	b := Query(doc, MustParseGroup(t, "#b"))
+	for _, test := range []struct {
+		sel  string
+		want []string
+	}{
+		{":root", []string{"b"}},
+		{":scope", []string{"b"}},
+		{":root > p", []string{"c", "d"}},
+		{":first-child", []string{"b", "c"}},
+		{":last-child", []string{"b", "d"}},
+		{":only-child", []string{"b"}},
+		{":nth-of-type(2)", []string{"d"}},
+		{"section", []string{"b"}},
+		{"div p", nil},
+		{"section + section", nil},
+		{"section:first-child:last-child", []string{"b"}},
+	} {
+		s := MustParseGroup(t, test.sel)
+		var got []string
+		for _, n := range QueryAllRoot(b, s) {
+			got = append(got, n.Attr[0].Val)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("QueryAllRoot(%s) = %q, want %q", test.sel, got, test.want)
+		}
+		first := QueryRoot(b, s)
+		if len(test.want) == 0 && first != nil || len(test.want) > 0 && (first == nil || first.Attr[0].Val != test.want[0]) {
+			t.Errorf("QueryRoot(%s) returned the wrong node", test.sel)
+		}
+	}
+
+	// Without a root, b is neither :root nor the only child.
+	if s := MustParseGroup(t, ":root, :only-child"); s.Match(b) || !MatchRoot(s, b, b) {
+		t.Error("MatchRoot should treat b as the root")
+	}
+}
+
+// wideList returns a document with a list of n items, alternating between
+// li and p elements, with text between them.
+func wideList(n int) *html.Node {
+	var b strings.Builder
+	b.WriteString("<ul>")
+	for i := 0; i < n; i++ {
+		if i%3 == 2 {
+			b.WriteString("<p>x</p> ")
+		} else {
+			b.WriteString("<li>x</li> ")
+		}
+	}
+	b.WriteString("</ul>")
+	return MustParseHTML(b.String())
+}
+
+func TestChildIndexCache(t *testing.T) {
+	doc := wideList(50)
+	for _, sel := range []string{
+		":nth-child(3)",
+		":nth-child(2n+1)",
+		"li:nth-child(-n+4)",
+		":nth-last-child(5)",
+		":nth-last-child(3n)",
+		"li:nth-of-type(4)",
+		":nth-of-type(odd)",
+		"p:nth-last-of-type(2)",
+		":nth-last-of-type(3n+2)",
+		"ul > :first-child, :last-of-type",
+	} {
+		s := MustParseGroup(t, sel)
+		var want []*html.Node
+		for _, n := range QueryAll(doc, MustParseGroup(t, "*")) {
+			// Without a context, Match doesn't use the cache.
+			if s.Match(n) {
+				want = append(want, n)
+			}
+		}
+		got := QueryAll(doc, s)
+		if len(got) != len(want) {
+			t.Errorf("%s: found %d elements, want %d", sel, len(got), len(want))
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: match %d is wrong", sel, i)
+			}
+		}
+	}
+}
+
+func BenchmarkNthChildWide(b *testing.B) {
+	doc := wideList(2000)
+	s, err := ParseGroup("li:nth-child(2n+1)")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Match", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			queryInto(nil, doc, s, nil)
+		}
+	})
+	b.Run("QueryAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			QueryAll(doc, s)
+		}
+	})
+}
+
+func TestClassListCache(t *testing.T) {
+	doc := MustParseHTML(`<p class="a b c">1</p><p class=" a	c ">2</p><p class="ab c">3</p><p class="b">4</p><div class="a b c"><span class="c a">5</span></div>`)
+	for _, sel := range []string{
+		".a.b.c",
+		".a.c, .b",
+		"p.c:not(.b)",
+		"div.a.b > .c.a",
+		".ab.c, .a.b",
+		"[class].a.c",
+	} {
+		s := MustParseGroup(t, sel)
+		if c := newQueryContext(s); !c.cacheClasses {
+			t.Errorf("%s: class cache not used", sel)
+		}
+		want := queryInto(nil, doc, s, nil)
+		got := QueryAll(doc, s)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: found %d elements, want %d", sel, len(got), len(want))
+		}
+		session := NewSession()
+		if got := session.QueryAll(doc, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s in a session: found %d elements, want %d", sel, len(got), len(want))
+		}
+	}
+
+	var c matchContext
+	for _, test := range []struct {
+		value string
+		want  []string
+	}{
+		{"a b", []string{"a", "b"}},
+		{"a b", []string{"a", "b"}},
+		{"\tx\n y\f", []string{"x", "y"}},
+		{"", []string{}},
+	} {
+		if got := c.classList(test.value); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("classList(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func BenchmarkClassCompound(b *testing.B) {
+	doc := MustParseHTML(strings.Repeat(`<div class="card card-body shadow rounded p-3 mb-2"><span class="badge text-muted small">x</span></div>`, 200))
+	s, err := ParseGroup(".card.shadow.rounded, .badge.small")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Match", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			queryInto(nil, doc, s, nil)
+		}
+	})
+	b.Run("QueryAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			QueryAll(doc, s)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package cascadia
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// A customPseudoClass is a pseudo-class registered with RegisterPseudoClass.
+type customPseudoClass struct {
+	name     string
+	parseArg func(arg string) (interface{}, error)
+	match    func(n *html.Node, arg interface{}) bool
+}
+
+var (
+	customPseudoClassesMu sync.RWMutex
+	customPseudoClasses   = make(map[string]*customPseudoClass)
+)
+
+// RegisterPseudoClass adds a pseudo-class called name to the ones the parser
+// recognizes, so that application-specific tests can be used in selectors.
+// Names are ASCII case-insensitive, like the built-in pseudo-classes.
+//
+// If parseArg is nil, the pseudo-class doesn't take an argument, like
+// :external-link. Otherwise it is a functional pseudo-class, like
+// :price-above(10), and parseArg is called with the text between the
+// parentheses (without surrounding whitespace) when a selector is parsed.
+// The value it returns is passed to match, and if it returns an error,
+// parsing fails.
+//
+// match is called for each element the pseudo-class is tested against. It
+// must be safe to call from multiple goroutines.
+//
+// RegisterPseudoClass panics if name isn't a valid identifier, or if it is
+// the name of a built-in or registered pseudo-class or pseudo-element. It is
+// typically called from an init function.
+func RegisterPseudoClass(name string, parseArg func(arg string) (interface{}, error), match func(n *html.Node, arg interface{}) bool) {
+	if match == nil {
+		panic("cascadia: RegisterPseudoClass match function is nil")
+	}
+	p := &parser{s: name}
+	if id, err := p.parseIdentifier(); err != nil || id != name || p.i != len(name) {
+		panic(fmt.Sprintf("cascadia: invalid pseudo-class name %q", name))
+	}
+	name = toLowerASCII(name)
+
+	p = &parser{s: ":" + name}
+	if _, _, err := p.parsePseudoclassSelector(); err != unknownPseudoClassError(name) {
+		panic(fmt.Sprintf("cascadia: pseudo-class :%s is already defined", name))
+	}
+
+	customPseudoClassesMu.Lock()
+	defer customPseudoClassesMu.Unlock()
+	if customPseudoClasses[name] != nil {
+		panic(fmt.Sprintf("cascadia: pseudo-class :%s is already defined", name))
+	}
+	customPseudoClasses[name] = &customPseudoClass{name: name, parseArg: parseArg, match: match}
+}
+
+// lookupPseudoClass returns the registered pseudo-class called name, or nil.
+func lookupPseudoClass(name string) *customPseudoClass {
+	customPseudoClassesMu.RLock()
+	defer customPseudoClassesMu.RUnlock()
+	return customPseudoClasses[name]
+}
+
+type customPseudoClassSelector struct {
+	abstractPseudoClass
+	class    *customPseudoClass
+	argument string      // the argument as written, if any
+	arg      interface{} // the result of parsing argument
+}
+
+func (s customPseudoClassSelector) Match(n *html.Node) bool {
+	return n.Type == html.ElementNode && s.class.match(n, s.arg)
+}
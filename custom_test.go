@@ -0,0 +1,98 @@
+package cascadia
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func init() {
+	RegisterPseudoClass("external-link", nil, func(n *html.Node, _ interface{}) bool {
+		for _, a := range n.Attr {
+			if a.Key == "href" {
+				return strings.HasPrefix(a.Val, "http://") || strings.HasPrefix(a.Val, "https://")
+			}
+		}
+		return false
+	})
+	RegisterPseudoClass("Price-Above", func(arg string) (interface{}, error) {
+		return strconv.ParseFloat(arg, 64)
+	}, func(n *html.Node, arg interface{}) bool {
+		for _, a := range n.Attr {
+			if a.Key == "data-price" {
+				price, err := strconv.ParseFloat(a.Val, 64)
+				return err == nil && price > arg.(float64)
+			}
+		}
+		return false
+	})
+}
+
+func TestCustomPseudoClass(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<a id=a href="/local">1</a><a id=b href="https://example.com/">2</a>` +
+		`<p id=c data-price=5></p><p id=d data-price=12.5></p><p id=e data-price=20></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		sel  string
+		want []string
+	}{
+		{"a:external-link", []string{"b"}},
+		{":EXTERNAL-LINK", []string{"b"}},
+		{"a:not(:external-link)", []string{"a"}},
+		{"p:price-above(10)", []string{"d", "e"}},
+		{"p:price-above( 15 )", []string{"e"}},
+		{"p:price-above(/* ) */ 15 /* x */)", []string{"e"}},
+		{":price-above(1):not(:price-above(15))", []string{"c", "d"}},
+	} {
+		s := MustParseGroup(t, test.sel)
+		var got []string
+		for _, n := range QueryAll(doc, s) {
+			got = append(got, n.Attr[0].Val)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.sel, got, test.want)
+		}
+
+		s2, err := ParseGroup(s.String())
+		if err != nil {
+			t.Errorf("re-parsing %s: %s", s, err)
+		} else if !reflect.DeepEqual(s, s2) {
+			t.Errorf("%s doesn't round-trip through String: got %s", test.sel, s2)
+		}
+	}
+
+	for _, sel := range []string{
+		":price-above(ten)",
+		":price-above",
+		":price-above(10",
+		":external-link(10)",
+	} {
+		if _, err := Parse(sel); err == nil {
+			t.Errorf("%s: expected an error", sel)
+		}
+	}
+
+	opts := ParseOptions{PseudoClasses: []string{"not"}}
+	if _, err := ParseWithOptions("a:external-link", opts); err == nil {
+		t.Error("a:external-link with an allowlist: expected an error")
+	}
+}
+
+func TestRegisterPseudoClassPanics(t *testing.T) {
+	match := func(*html.Node, interface{}) bool { return true }
+	for _, name := range []string{"not", "first-child", "first", "before", "external-link", "Price-above", "", "a b", "1x"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterPseudoClass(%q) didn't panic", name)
+				}
+			}()
+			RegisterPseudoClass(name, nil, match)
+		}()
+	}
+}
@@ -0,0 +1,85 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// MatchDetails describes how an element matched a selector group, or why
+// it didn't, for debugging styles and selectors.
+type MatchDetails struct {
+	// Matched is whether any of the selectors matched.
+	Matched bool
+
+	// Index is the position in the group of the selector that matched. If
+	// more than one matched, it is the one with the highest specificity (the
+	// first one, if there is a tie), since that is the one that counts in
+	// the cascade. It is -1 if none matched.
+	Index int
+
+	// Selector is the matching selector, formatted by its String method.
+	Selector string
+
+	// Specificity is the specificity of the matching selector.
+	Specificity Specificity
+
+	// Failures has an entry for each selector in the group, in order, if
+	// none of them matched.
+	Failures []MatchFailure
+}
+
+// A MatchFailure explains why an element didn't match a selector.
+type MatchFailure struct {
+	// Index is the position of the selector in the group.
+	Index int
+
+	// Selector is the selector, formatted by its String method.
+	Selector string
+
+	// Part is the simple selector (or the pseudo-class, or other part of
+	// the selector) that rejected the element. If the chain of combinators
+	// broke because there was no element to test, like a parent for ">",
+	// it is the compound selector that had nothing to match.
+	Part Sel
+
+	// Node is the element that Part was tested against. It is the element
+	// being matched, or for a selector with combinators, it may be an
+	// ancestor or a preceding sibling.
+	Node *html.Node
+
+	// Reason describes the failure, as in the Report from Explain.
+	Reason string
+}
+
+// MatchDetails tests whether n matches s, and reports which of the
+// selectors matched or what prevented each of them from matching.
+// Selectors with result-set pseudo-classes like :first never match, as
+// with Match.
+func (s SelectorGroup) MatchDetails(n *html.Node) MatchDetails {
+	d := MatchDetails{Index: -1}
+	for i, sel := range s {
+		if !sel.Match(n) {
+			continue
+		}
+		spec := sel.Specificity()
+		if !d.Matched || d.Specificity.Less(spec) {
+			d.Matched = true
+			d.Index = i
+			d.Specificity = spec
+		}
+	}
+	if d.Matched {
+		d.Selector = s[d.Index].String()
+		return d
+	}
+
+	d.Failures = make([]MatchFailure, len(s))
+	for i, sel := range s {
+		r := explain(sel, n, 0, "")
+		part := r.Part
+		if part == nil {
+			part = r.Compound
+		}
+		d.Failures[i] = MatchFailure{Index: i, Selector: sel.String(), Part: part, Node: r.Node, Reason: r.Reason}
+	}
+	return d
+}
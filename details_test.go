@@ -0,0 +1,67 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestMatchDetails(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div class=menu><ul><li id=first class=item>One</li><li id=second class="item active">Two</li></ul></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := Query(doc, MustParseGroup(t, "#first"))
+	second := Query(doc, MustParseGroup(t, "#second"))
+
+	d := MustParseGroup(t, "p, li, .menu li.active, li.item").MatchDetails(second)
+	if !d.Matched || d.Index != 2 || d.Selector != MustParse(t, ".menu li.active").String() || d.Specificity != (Specificity{0, 2, 1}) || d.Failures != nil {
+		t.Errorf("matching #second: got %+v", d)
+	}
+
+	d = MustParseGroup(t, "li, li.item").MatchDetails(first)
+	if !d.Matched || d.Index != 1 {
+		t.Errorf("matching #first: got %+v", d)
+	}
+
+	for _, test := range []struct {
+		sel  string
+		part string
+		node string // the id or tag name of the node
+	}{
+		{"li.item.active", ".active", "first"},
+		{"p", "p", "first"},
+		{"ol > li", "ol", "ul"},
+		{".nav li", ".nav", "first"},
+		{"li + li", "li", "first"},
+		{"div > ul > li.active", ".active", "first"},
+		{"nav > ul > li", "nav", "div"},
+		{"li:not(#first)", ":not(#first)", "first"},
+	} {
+		d := MustParseGroup(t, "span, "+test.sel).MatchDetails(first)
+		if d.Matched || d.Index != -1 || len(d.Failures) != 2 {
+			t.Errorf("%s: got %+v", test.sel, d)
+			continue
+		}
+		f := d.Failures[1]
+		if f.Index != 1 || f.Selector != MustParse(t, test.sel).String() {
+			t.Errorf("%s: got failure %+v", test.sel, f)
+		}
+		if got := f.Part.String(); got != test.part {
+			t.Errorf("%s: rejected by %s, want %s", test.sel, got, test.part)
+		}
+		node := f.Node.Data
+		if id := f.Node.Attr; len(id) > 0 && id[0].Key == "id" {
+			node = id[0].Val
+		}
+		if node != test.node {
+			t.Errorf("%s: rejected at %s, want %s", test.sel, node, test.node)
+		}
+	}
+
+	// li + li: the second li's preceding sibling is an li, so it matches.
+	if d := MustParseGroup(t, "li + li").MatchDetails(second); !d.Matched {
+		t.Errorf("li + li on #second: got %+v", d)
+	}
+}
@@ -0,0 +1,123 @@
+package cascadia
+
+import (
+	"sort"
+
+	"golang.org/x/net/html"
+)
+
+// A RegionChange describes how a region of a document, an element matched
+// by a selector, differs between two versions of the document. See
+// DiffRegions.
+type RegionChange struct {
+	// Selector is the selector that matched the region, and Index is
+	// which of its matches the region is, counting from 0 in document
+	// order.
+	Selector string
+	Index    int
+
+	// Old and New are the region in the old and new documents. One of them
+	// is nil if the region is only in the other document.
+	Old, New *html.Node
+
+	// Attrs lists the attributes of the region's element that were added,
+	// removed or changed, sorted by name.
+	Attrs []string
+
+	// Text is true if the text in the region changed.
+	Text bool
+
+	// Markup is true if the elements inside the region changed: if they
+	// were added, removed, renamed or reordered, or their attributes
+	// changed.
+	Markup bool
+}
+
+// DiffRegions compares the regions of two versions of a document that the
+// selectors in g match, and returns the ones that changed. It is for
+// questions like "did anything inside #price or .availability change?",
+// which don't care about the rest of the page.
+//
+// Each selector in g is matched in both documents, and its matches are
+// paired up in document order: the first match in oldDoc with the first
+// match in newDoc, and so on. If one document has more matches, the extra ones are
+// reported as added or removed. The changes are returned in the order of
+// the selectors in g, and then of the matches.
+func DiffRegions(oldDoc, newDoc *html.Node, g SelectorGroup) []RegionChange {
+	var changes []RegionChange
+	for _, sel := range g {
+		oldMatches, newMatches := QueryAll(oldDoc, sel), QueryAll(newDoc, sel)
+		for i := 0; i < len(oldMatches) || i < len(newMatches); i++ {
+			c := RegionChange{Selector: sel.String(), Index: i}
+			if i < len(oldMatches) {
+				c.Old = oldMatches[i]
+			}
+			if i < len(newMatches) {
+				c.New = newMatches[i]
+			}
+			if c.Old != nil && c.New != nil {
+				c.Attrs = attrChanges(c.Old, c.New)
+				c.Text = nodeText(c.Old) != nodeText(c.New)
+				c.Markup = !sameMarkup(c.Old, c.New)
+				if len(c.Attrs) == 0 && !c.Text && !c.Markup {
+					continue
+				}
+			}
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// attrChanges returns the names of the attributes that differ between a
+// and b, sorted.
+func attrChanges(a, b *html.Node) []string {
+	oldAttrs := make(map[string]string, len(a.Attr))
+	for _, attr := range a.Attr {
+		oldAttrs[attrName(attr)] = attr.Val
+	}
+	var changed []string
+	for _, attr := range b.Attr {
+		name := attrName(attr)
+		if val, ok := oldAttrs[name]; !ok || val != attr.Val {
+			changed = append(changed, name)
+		}
+		delete(oldAttrs, name)
+	}
+	for name := range oldAttrs {
+		changed = append(changed, name)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// attrName returns the name of a, with its namespace prefix if it has
+// one.
+func attrName(a html.Attribute) string {
+	if a.Namespace != "" {
+		return a.Namespace + ":" + a.Key
+	}
+	return a.Key
+}
+
+// sameMarkup returns whether the descendant elements of a and b are the
+// same, with the same attributes, ignoring text and comments.
+func sameMarkup(a, b *html.Node) bool {
+	ca, cb := nextElement(a.FirstChild), nextElement(b.FirstChild)
+	for ca != nil && cb != nil {
+		if ca.Data != cb.Data || ca.Namespace != cb.Namespace || len(attrChanges(ca, cb)) > 0 || !sameMarkup(ca, cb) {
+			return false
+		}
+		ca, cb = nextElement(ca.NextSibling), nextElement(cb.NextSibling)
+	}
+	return ca == nil && cb == nil
+}
+
+// nextElement returns the first element among n and its later siblings, or
+// nil if there is none.
+func nextElement(n *html.Node) *html.Node {
+	for n != nil && n.Type != html.ElementNode {
+		n = n.NextSibling
+	}
+	return n
+}
@@ -0,0 +1,37 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRegions(t *testing.T) {
+	oldDoc := MustParseHTML(`<h1>Shop</h1><div id=price class=big>$10 <b>only</b></div><p class=availability>In stock</p><ul><li>a</li><li>b</li></ul><p class=note>x</p>`)
+	newDoc := MustParseHTML(`<h1>Shop!</h1><div id=price class=small>$12 <b>only</b></div><p class=availability>In <em>stock</em></p><ul><li>a</li><li title=b>b</li><li>c</li></ul>`)
+
+	type change struct {
+		sel            string
+		index          int
+		hasOld, hasNew bool
+		attrs          []string
+		text, markup   bool
+	}
+	var got []change
+	for _, c := range DiffRegions(oldDoc, newDoc, MustParseGroup(t, "#price, .availability, li, .note, ul > :first-child")) {
+		got = append(got, change{c.Selector, c.Index, c.Old != nil, c.New != nil, c.Attrs, c.Text, c.Markup})
+	}
+	want := []change{
+		{"#price", 0, true, true, []string{"class"}, true, false},
+		{".availability", 0, true, true, nil, false, true},
+		{"li", 1, true, true, []string{"title"}, false, false},
+		{"li", 2, false, true, nil, false, false},
+		{".note", 0, true, false, nil, false, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	if changes := DiffRegions(oldDoc, oldDoc, MustParseGroup(t, "*")); len(changes) != 0 {
+		t.Errorf("comparing a document with itself found %d changes", len(changes))
+	}
+}
@@ -0,0 +1,79 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A tagDispatch matches a group of selectors, testing each element only
+// against the selectors whose subject (the last compound selector) has the
+// element's tag name, and the selectors whose subject has no tag name,
+// instead of trying every selector in the group.
+type tagDispatch struct {
+	byTag map[string][]Sel
+	other []Sel
+}
+
+// newTagDispatch returns a tagDispatch for g, or nil if it wouldn't help:
+// if g has only one selector, none of its selectors have a tag name, or it
+// has result-set pseudo-classes.
+func newTagDispatch(g SelectorGroup) *tagDispatch {
+	if len(g) < 2 || hasPositional(g) {
+		return nil
+	}
+	d := &tagDispatch{byTag: make(map[string][]Sel)}
+	for _, s := range g {
+		if tag := subjectTag(s); tag != "" {
+			d.byTag[tag] = append(d.byTag[tag], s)
+		} else {
+			d.other = append(d.other, s)
+		}
+	}
+	if len(d.byTag) == 0 {
+		return nil
+	}
+	return d
+}
+
+// dispatchMatcher returns a tagDispatch for m, if m is a group that one
+// would help with, or else m itself.
+func dispatchMatcher(m Matcher) Matcher {
+	if g, ok := m.(SelectorGroup); ok {
+		if d := newTagDispatch(g); d != nil {
+			return d
+		}
+	}
+	return m
+}
+
+// subjectTag returns the tag name that the subject of s requires, or "".
+func subjectTag(s Sel) string {
+	switch last := lastCompound(s).(type) {
+	case tagSelector:
+		if last.name == "" {
+			return last.tag
+		}
+	case compoundSelector:
+		return last.tag
+	}
+	return ""
+}
+
+func (d *tagDispatch) Match(n *html.Node) bool {
+	return d.matchIn(nil, n)
+}
+
+func (d *tagDispatch) matchIn(c *matchContext, n *html.Node) bool {
+	if n.Type == html.ElementNode {
+		for _, s := range d.byTag[n.Data] {
+			if c.match(s, n) {
+				return true
+			}
+		}
+	}
+	for _, s := range d.other {
+		if c.match(s, n) {
+			return true
+		}
+	}
+	return false
+}
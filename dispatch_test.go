@@ -0,0 +1,72 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagDispatch(t *testing.T) {
+	checked := 0
+	for _, test := range selectorTests {
+		g, err := ParseGroup(test.selector)
+		if err != nil {
+			continue
+		}
+		d := newTagDispatch(g)
+		if d == nil {
+			continue
+		}
+		checked++
+		doc := MustParseHTML(test.HTML)
+		want := queryInto(nil, doc, g, nil)
+		if got := queryInto(nil, doc, d, nil); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: dispatch found %d nodes, want %d", test.selector, len(got), len(want))
+		}
+		if got := QueryAll(doc, g); !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryAll(%s) found %d nodes, want %d", test.selector, len(got), len(want))
+		}
+	}
+	if checked == 0 {
+		t.Error("no test selectors used tag dispatch")
+	}
+
+	for _, test := range []struct {
+		sel  string
+		want bool
+	}{
+		{"p", false},
+		{"p, div", true},
+		{"ul > li, .a", true},
+		{".a, .b", false},
+		{"p:first, div", false},
+	} {
+		if got := newTagDispatch(MustParseGroup(t, test.sel)) != nil; got != test.want {
+			t.Errorf("%s: tag dispatch = %v, want %v", test.sel, got, test.want)
+		}
+	}
+
+	doc := MustParseHTML(`<p class="x">1</p><div>2</div><span class="x">3</span><em>4</em>`)
+	s := MustCompile("p.x, div, ul > li, .x")
+	if got := len(s.MatchAll(doc)); got != 3 {
+		t.Errorf("MatchAll found %d nodes, want 3", got)
+	}
+}
+
+func BenchmarkTagDispatch(b *testing.B) {
+	doc := parseReference("test_resources/shakespeare.html")
+	g, err := ParseGroup("h1.title, h2.title, h3.scene, li.item, td.cell, input[type=text], a[href], p.note, span.note, div.act")
+	if err != nil {
+		b.Fatal(err)
+	}
+	d := newTagDispatch(g)
+	b.Run("dispatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			queryInto(nil, doc, d, nil)
+		}
+	})
+	b.Run("group", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			queryInto(nil, doc, g, nil)
+		}
+	})
+}
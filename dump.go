@@ -0,0 +1,100 @@
+package cascadia
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia/internal/cssesc"
+)
+
+// Dump returns a description of the structure of m, which should be a Sel
+// or a SelectorGroup from this package, for debugging. Each part of the
+// selector is on its own line, indented below the part that contains it,
+// with its specificity. For example, Dump of "ul > li.item" is:
+//
+//	child combinator: ul > li.item (0,1,2)
+//	  type: ul (0,0,1)
+//	  compound: li.item (0,1,1)
+//	    type: li (0,0,1)
+//	    class: item (0,1,0)
+func Dump(m Matcher) string {
+	var b strings.Builder
+	dump(&b, m, 0)
+	return b.String()
+}
+
+var combinatorNames = map[byte]string{
+	' ': "descendant",
+	'>': "child",
+	'+': "next-sibling",
+	'~': "subsequent-sibling",
+}
+
+func dump(b *strings.Builder, m Matcher, depth int) {
+	line := func(kind, detail string, spec Specificity) {
+		fmt.Fprintf(b, "%s%s: %s %s\n", strings.Repeat("  ", depth), kind, detail, spec)
+	}
+
+	switch s := m.(type) {
+	case Builder:
+		dump(b, s.Sel(), depth)
+	case SelectorGroup:
+		if len(s) == 1 {
+			dump(b, s[0], depth)
+			return
+		}
+		fmt.Fprintf(b, "%sgroup of %d selectors:\n", strings.Repeat("  ", depth), len(s))
+		for _, sel := range s {
+			dump(b, sel, depth+1)
+		}
+	case combinedSelector:
+		if s.second == nil {
+			dump(b, s.first, depth)
+			return
+		}
+		line(combinatorNames[s.combinator]+" combinator", ToAST(s).String(), s.Specificity())
+		if scope, ok := s.first.(scopePseudoClassSelector); ok && scope.implicit {
+			fmt.Fprintf(b, "%s(the scope element)\n", strings.Repeat("  ", depth+1))
+		} else {
+			dump(b, s.first, depth+1)
+		}
+		dump(b, s.second, depth+1)
+	case compoundSelector:
+		line("compound", s.String(), s.Specificity())
+		for _, sel := range s.selectors {
+			dump(b, sel, depth+1)
+		}
+	case tagSelector:
+		line("type", s.tag, s.Specificity())
+	case classSelector:
+		line("class", s.class, s.Specificity())
+	case idSelector:
+		line("id", s.id, s.Specificity())
+	case attrSelector:
+		detail := s.key
+		if s.operation != "" {
+			val := cssesc.Quote(s.val)
+			if s.operation == "#=" {
+				val = s.regexp.String()
+			}
+			detail += " " + s.operation + " " + val
+		}
+		if s.insensitive {
+			detail += " (case-insensitive)"
+		}
+		line("attribute", detail, s.Specificity())
+	case relativePseudoClassSelector:
+		line("pseudo-class", ":"+s.name+"()", s.Specificity())
+		dump(b, s.match, depth+1)
+	case nthPseudoClassSelector:
+		line("pseudo-class", fmt.Sprintf("%s (a=%d, b=%d)", s, s.a, s.b), s.Specificity())
+	case positionalPseudoClassSelector:
+		line("result-set pseudo-class", s.String(), s.Specificity())
+	case neverMatchSelector:
+		line("never matches", s.value, s.Specificity())
+	case Sel:
+		line("pseudo-class", s.String(), s.Specificity())
+	default:
+		fmt.Fprintf(b, "%s%T\n", strings.Repeat("  ", depth), m)
+	}
+}
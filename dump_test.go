@@ -0,0 +1,45 @@
+package cascadia
+
+import "testing"
+
+func TestDump(t *testing.T) {
+	tests := []struct {
+		sel, dump string
+	}{
+		{"ul > li.item", `child combinator: ul > li.item (0,1,2)
+  type: ul (0,0,1)
+  compound: li.item (0,1,1)
+    type: li (0,0,1)
+    class: item (0,1,0)
+`},
+		{"div:not(.a, #b) p + [href^=x i]", `next-sibling combinator: div:not(.a, #b) p + [href^="x" i] (1,1,2)
+  descendant combinator: div:not(.a, #b) p (1,0,2)
+    compound: div:not(.a, #b) (1,0,1)
+      type: div (0,0,1)
+      pseudo-class: :not() (1,0,0)
+        group of 2 selectors:
+          class: a (0,1,0)
+          id: b (1,0,0)
+    type: p (0,0,1)
+  attribute: href ^= "x" (case-insensitive) (0,1,0)
+`},
+		{"li:nth-child(2n+1):first", `compound: li:nth-child(2n+1):first (0,2,1)
+  type: li (0,0,1)
+  pseudo-class: :nth-child(2n+1) (a=2, b=1) (0,1,0)
+  result-set pseudo-class: :first (0,1,0)
+`},
+		{"h1, [lang]", `group of 2 selectors:
+  type: h1 (0,0,1)
+  attribute: lang (0,1,0)
+`},
+		{"p::before", `compound: p::before (0,0,2)
+  type: p (0,0,1)
+`},
+	}
+	for _, test := range tests {
+		got := Dump(MustParseGroupWithPseudoElements(t, test.sel))
+		if got != test.dump {
+			t.Errorf("Dump(%q) =\n%s\nwant:\n%s", test.sel, got, test.dump)
+		}
+	}
+}
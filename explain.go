@@ -0,0 +1,179 @@
+package cascadia
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A Report explains whether an element matches a selector, and if not,
+// where matching failed. See Explain.
+type Report struct {
+	Matched bool
+
+	// Node is the element where matching failed: the element being tested,
+	// or one of its ancestors or preceding siblings.
+	Node *html.Node
+
+	// Compound is the compound selector that failed, like "li.active" in
+	// "ul > li.active".
+	Compound Sel
+
+	// Part is the simple selector in Compound that rejected Node. It is
+	// nil if no element was found for Compound to be tested against, such
+	// as when no ancestor matched for a descendant combinator.
+	Part Sel
+
+	// Combinator is the combinator after Compound, like '>' in "ul > li", or
+	// 0 if Compound is the last one in the selector.
+	Combinator byte
+
+	// Reason describes the failure (or the match) in words, like
+	// "no ancestor matched `.start`".
+	Reason string
+}
+
+func (r Report) String() string {
+	return r.Reason
+}
+
+// Explain reports whether n matches s, and if it doesn't, which compound
+// selector failed and where the chain of combinators broke. For the
+// descendant and general sibling combinators, when several elements could
+// continue the chain, the nearest one is explained. Selectors with
+// result-set pseudo-classes like :first never match, as with Match.
+func Explain(s Sel, n *html.Node) Report {
+	if s.Match(n) {
+		return Report{Matched: true, Node: n, Reason: fmt.Sprintf("%s matches `%s`", describeNode(n), s)}
+	}
+	return explain(s, n, 0, "")
+}
+
+// explain explains why n doesn't match s, which is followed by combinator
+// in the full selector. relation describes how n was reached, like
+// "parent".
+func explain(s Sel, n *html.Node, combinator byte, relation string) Report {
+	switch sel := s.(type) {
+	case Builder:
+		return explain(sel.Sel(), n, combinator, relation)
+	case combinedSelector:
+		if sel.second == nil {
+			return explain(sel.first, n, combinator, relation)
+		}
+		if !sel.second.Match(n) {
+			return explain(sel.second, n, combinator, relation)
+		}
+		return explainCombinator(sel, n)
+	}
+
+	r := Report{Node: n, Compound: s, Part: rejectingPart(s, n), Combinator: combinator}
+	what := describeNode(n)
+	if relation != "" {
+		what = relation + " " + what
+	}
+	if r.Part != nil && r.Part.String() != s.String() {
+		r.Reason = fmt.Sprintf("%s does not match `%s` in `%s`", what, r.Part, s)
+	} else {
+		r.Reason = fmt.Sprintf("%s does not match `%s`", what, s)
+	}
+	return r
+}
+
+// explainCombinator explains why the left side of s doesn't match any
+// element related to n by s's combinator, when n matches the right side.
+func explainCombinator(s combinedSelector, n *html.Node) Report {
+	last := lastCompound(s.first)
+	fail := func(format string, args ...interface{}) Report {
+		return Report{Node: n, Compound: last, Combinator: s.combinator, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch s.combinator {
+	case '>':
+		p := n.Parent
+		if p == nil || p.Type != html.ElementNode {
+			return fail("%s has no parent element", describeNode(n))
+		}
+		return explain(s.first, p, '>', "parent")
+	case ' ':
+		for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+			if last.Match(p) {
+				return explain(s.first, p, ' ', "ancestor")
+			}
+		}
+		return fail("no ancestor matched `%s`", last)
+	case '+':
+		sib := prevElementSibling(n)
+		if sib == nil {
+			return fail("%s has no previous sibling element", describeNode(n))
+		}
+		return explain(s.first, sib, '+', "previous sibling")
+	case '~':
+		for sib := prevElementSibling(n); sib != nil; sib = prevElementSibling(sib) {
+			if last.Match(sib) {
+				return explain(s.first, sib, '~', "preceding sibling")
+			}
+		}
+		return fail("no preceding sibling matched `%s`", last)
+	}
+	return fail("%s does not match `%s`", describeNode(n), s)
+}
+
+// rejectingPart returns the first simple selector in s that doesn't match
+// n, or s itself if it isn't a compound selector.
+func rejectingPart(s Sel, n *html.Node) Sel {
+	switch sel := s.(type) {
+	case Builder:
+		return rejectingPart(sel.Sel(), n)
+	case compoundSelector:
+		for _, part := range sel.selectors {
+			if !part.Match(n) {
+				return rejectingPart(part, n)
+			}
+		}
+	}
+	return s
+}
+
+// lastCompound returns the last compound selector in s: the one that
+// must match the element itself.
+func lastCompound(s Sel) Sel {
+	switch sel := s.(type) {
+	case Builder:
+		return lastCompound(sel.Sel())
+	case combinedSelector:
+		if sel.second == nil {
+			return lastCompound(sel.first)
+		}
+		return lastCompound(sel.second)
+	}
+	return s
+}
+
+func prevElementSibling(n *html.Node) *html.Node {
+	for n = n.PrevSibling; n != nil; n = n.PrevSibling {
+		if n.Type == html.ElementNode {
+			return n
+		}
+	}
+	return nil
+}
+
+// describeNode returns a short description of n for messages, like
+// <li id="first" class="item">.
+func describeNode(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return "non-element node"
+	}
+	var b strings.Builder
+	b.WriteString("<" + n.Data)
+	for _, key := range []string{"id", "class"} {
+		for _, a := range n.Attr {
+			if a.Namespace == "" && a.Key == key {
+				fmt.Fprintf(&b, " %s=%q", key, a.Val)
+			}
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
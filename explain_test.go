@@ -0,0 +1,63 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExplain(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div class=menu><ul><li id=first class=item>One</li><li id=second class="item active">Two</li></ul></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := Query(doc, MustParseGroup(t, "#first"))
+	second := Query(doc, MustParseGroup(t, "#second"))
+
+	for _, test := range []struct {
+		sel        string
+		node       *html.Node
+		matched    bool
+		compound   string
+		part       string // "" for nil
+		combinator byte
+		reason     string
+	}{
+		{"li.active", second, true, "", "", 0, "<li id=\"second\" class=\"item active\"> matches `li.active`"},
+		{"li.active", first, false, "li.active", ".active", 0, "<li id=\"first\" class=\"item\"> does not match `.active` in `li.active`"},
+		{"p", first, false, "p", "p", 0, "<li id=\"first\" class=\"item\"> does not match `p`"},
+		{".start li", first, false, ".start", "", ' ', "no ancestor matched `.start`"},
+		{"ol > li", first, false, "ol", "ol", '>', "parent <ul> does not match `ol`"},
+		{".menu.open ul li", first, false, ".menu.open", "", ' ', "no ancestor matched `.menu.open`"},
+		{".nav > ul li", first, false, ".nav", ".nav", '>', "parent <div class=\"menu\"> does not match `.nav`"},
+		{"li + li", first, false, "li", "", '+', "<li id=\"first\" class=\"item\"> has no previous sibling element"},
+		{"li.x ~ li", second, false, "li.x", "", '~', "no preceding sibling matched `li.x`"},
+		{"li.item + li.x", second, false, "li.x", ".x", 0, "<li id=\"second\" class=\"item active\"> does not match `.x` in `li.x`"},
+		{"html > li", first, false, "html", "html", '>', "parent <ul> does not match `html`"},
+	} {
+		r := Explain(MustParse(t, test.sel), test.node)
+		if r.Matched != test.matched {
+			t.Errorf("%s: Matched = %v, want %v", test.sel, r.Matched, test.matched)
+		}
+		if r.Reason != test.reason || r.String() != test.reason {
+			t.Errorf("%s: Reason = %q, want %q", test.sel, r.Reason, test.reason)
+		}
+		if test.matched {
+			continue
+		}
+		if got := r.Compound.String(); got != MustParse(t, test.compound).String() {
+			t.Errorf("%s: Compound = %s, want %s", test.sel, got, test.compound)
+		}
+		var part string
+		if r.Part != nil {
+			part = r.Part.String()
+		}
+		if part != test.part {
+			t.Errorf("%s: Part = %q, want %q", test.sel, part, test.part)
+		}
+		if r.Combinator != test.combinator {
+			t.Errorf("%s: Combinator = %q, want %q", test.sel, r.Combinator, test.combinator)
+		}
+	}
+}
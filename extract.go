@@ -0,0 +1,147 @@
+package cascadia
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// This file implements a way to get strings out of the nodes a selector
+// matches, for the common pattern of selecting elements and then reading
+// their text or an attribute.
+
+// ExtractText returns the text of each node that matches m, from the
+// descendants of n, in document order.
+func ExtractText(n *html.Node, m Matcher) []string {
+	return Extract(m).All(n)
+}
+
+// ExtractAttr returns the value of the attribute key of each node that
+// matches m, from the descendants of n, in document order. Nodes that don't
+// have the attribute are skipped.
+func ExtractAttr(n *html.Node, m Matcher, key string) []string {
+	return Extract(m).Attr(key).All(n)
+}
+
+// An Extractor gets a string from each node that a selector matches, and
+// processes it in a fixed order: it takes the text or an attribute value,
+// trims it if Trim was called, and then applies the expression from
+// Capture, if any. Each method returns a new Extractor, so a partial one can
+// be reused:
+//
+//	price, err := cascadia.Extract(cascadia.Class("price")).Trim().Capture(`[\d.]+`)
+//	if err != nil {
+//		// ...
+//	}
+//	prices := price.All(doc)
+type Extractor struct {
+	m    Matcher
+	attr string // the attribute to read, or "" for the text
+	text TextFunc
+	trim bool
+	rx   pattern
+}
+
+// Extract returns an Extractor for the text of the nodes that match m.
+func Extract(m Matcher) Extractor {
+	return Extractor{m: m}
+}
+
+// Attr returns an Extractor that reads the value of the attribute key
+// instead of the text. Nodes without the attribute are skipped.
+func (e Extractor) Attr(key string) Extractor {
+	e.attr = key
+	return e
+}
+
+// Text returns an Extractor that gets the text of nodes with f, such as
+// VisibleText, instead of concatenating all the text nodes they contain.
+func (e Extractor) Text(f TextFunc) Extractor {
+	e.text = f
+	return e
+}
+
+// Trim returns an Extractor that removes leading and trailing whitespace
+// from the values.
+func (e Extractor) Trim() Extractor {
+	e.trim = true
+	return e
+}
+
+// Capture returns an Extractor that keeps only the part of each value that
+// matches the regular expression expr, or the part that matches its first
+// parenthesized subexpression if it has one. Values that don't match are
+// skipped. It returns an error if expr isn't a valid expression.
+func (e Extractor) Capture(expr string) (Extractor, error) {
+	rx, err := compilePattern(expr)
+	if err != nil {
+		return Extractor{}, err
+	}
+	e.rx = rx
+	return e, nil
+}
+
+// All returns the values from the descendants of n that match the
+// selector, in document order.
+func (e Extractor) All(n *html.Node) []string {
+	var values []string
+	for _, match := range QueryAll(n, e.m) {
+		if v, ok := e.value(match); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// First returns the first value from the descendants of n that match the
+// selector. If there is none, it returns "" and false.
+func (e Extractor) First(n *html.Node) (string, bool) {
+	if e.attr == "" && e.rx == nil {
+		// Every match has a value.
+		if match := Query(n, e.m); match != nil {
+			return e.value(match)
+		}
+		return "", false
+	}
+	for _, match := range QueryAll(n, e.m) {
+		if v, ok := e.value(match); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// value returns the value extracted from n, or false if it doesn't have
+// one.
+func (e Extractor) value(n *html.Node) (string, bool) {
+	var v string
+	if e.attr == "" {
+		v = e.text.of(n)
+	} else {
+		found := false
+		for _, a := range n.Attr {
+			if a.Key == e.attr {
+				v, found = a.Val, true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	if e.trim {
+		v = strings.TrimSpace(v)
+	}
+	if e.rx != nil {
+		m := e.rx.FindStringSubmatch(v)
+		switch {
+		case m == nil:
+			return "", false
+		case len(m) > 1:
+			v = m[1]
+		default:
+			v = m[0]
+		}
+	}
+	return v, true
+}
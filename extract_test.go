@@ -0,0 +1,35 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	doc := MustParseHTML(`<ul><li><a href="/a"> One </a></li><li><a>Two<script>x()</script></a></li><li><a href="/c">Three</a></li></ul>`)
+	links := MustParseGroup(t, "li a")
+
+	if got, want := ExtractText(doc, links), []string{" One ", "Twox()", "Three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractText: got %q, want %q", got, want)
+	}
+	if got, want := ExtractAttr(doc, links, "href"), []string{"/a", "/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractAttr: got %q, want %q", got, want)
+	}
+	if got := ExtractAttr(doc, links, "title"); got != nil {
+		t.Errorf("ExtractAttr of a missing attribute: got %q", got)
+	}
+
+	e := Extract(links).Text(VisibleText).Trim()
+	if got, want := e.All(doc), []string{"One", "Two", "Three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trimmed visible text: got %q, want %q", got, want)
+	}
+	if v, ok := e.First(doc); !ok || v != "One" {
+		t.Errorf("First: got %q, %v", v, ok)
+	}
+	if v, ok := e.Attr("href").First(MustParseHTML(`<li><a>x</a></li><li><a href=/y>y</a></li>`)); !ok || v != "/y" {
+		t.Errorf("First skipping a node without the attribute: got %q, %v", v, ok)
+	}
+	if v, ok := e.First(MustParseHTML(`<p>none</p>`)); ok || v != "" {
+		t.Errorf("First with no match: got %q, %v", v, ok)
+	}
+}
@@ -0,0 +1,51 @@
+package cascadia
+
+import (
+	"github.com/andybalholm/cascadia/internal/foreignname"
+	"golang.org/x/net/html"
+)
+
+// In an HTML document, the names of HTML elements and attributes are
+// matched without regard to case, so type and attribute selectors are
+// lowered when they are parsed. But in foreign content (SVG and MathML),
+// some names keep upper-case letters, like clipPath and viewBox: the HTML
+// parser restores them from a fixed list. Selectors match those elements
+// and attributes by the restored names, so "clipPath" (or "clippath")
+// matches an SVG clipPath element, whose Data is "clipPath".
+
+// svgTagNames maps the lower-case form of SVG element names that have
+// upper-case letters to their real names, as the HTML parser adjusts them.
+var svgTagNames = foreignname.Tags
+
+// foreignAttributeNames is like svgTagNames, for the attributes of SVG and
+// MathML elements.
+var foreignAttributeNames = foreignname.Attributes
+
+// isForeign returns whether n is in foreign content: an SVG or MathML
+// element.
+func isForeign(n *html.Node) bool {
+	return n.Namespace != ""
+}
+
+// nameFor returns the name that one of n's names must equal to match a
+// selector with the lower-case name lower and the foreign name foreign
+// (from svgTagNames or foreignAttributeNames, or "" if there is none).
+func nameFor(n *html.Node, lower, foreign string) string {
+	if foreign != "" && isForeign(n) {
+		return foreign
+	}
+	return lower
+}
+
+// hasForeignName returns whether s is a type or attribute selector with a
+// foreign name. The shortcuts that compare its lower-case name with n.Data
+// or with attribute keys directly can't be used for it.
+func hasForeignName(s Sel) bool {
+	switch s := s.(type) {
+	case tagSelector:
+		return s.name != ""
+	case attrSelector:
+		return s.name != ""
+	}
+	return false
+}
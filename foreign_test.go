@@ -0,0 +1,47 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForeignNames(t *testing.T) {
+	doc := MustParseHTML(`<div id=a viewbox="1"><clippath id=b></clippath></div>
+		<svg id=c viewBox="0 0 10 10" preserveAspectRatio="none"><clipPath id=d clipPathUnits="x"></clipPath><foreignObject id=e><p id=f viewbox="2"></p></foreignObject></svg>
+		<math id=g definitionURL="u"><mi id=h></mi></math>`)
+	for _, test := range []struct {
+		sel  string
+		want []string
+	}{
+		{"clipPath", []string{"b", "d"}},
+		{"clippath", []string{"b", "d"}},
+		{"CLIPPATH", []string{"b", "d"}},
+		{"svg clipPath", []string{"d"}},
+		{"foreignObject", []string{"e"}},
+		{"foreignObject > p", []string{"f"}},
+		{"[viewBox]", []string{"a", "c", "f"}},
+		{"[viewbox='0 0 10 10']", []string{"c"}},
+		{"[preserveAspectRatio=none][viewBox]", []string{"c"}},
+		{"[clipPathUnits]", []string{"d"}},
+		{"clipPath[clippathunits=x]", []string{"d"}},
+		{"[definitionURL]", []string{"g"}},
+		{"[id][viewBox!='0 0 10 10']", []string{"a", "b", "d", "e", "f", "g", "h"}},
+		{"mi, clipPath", []string{"b", "d", "h"}},
+		{"svg, clipPath, p", []string{"b", "c", "d", "f"}},
+	} {
+		s := MustParseGroup(t, test.sel)
+		for name, m := range map[string]Matcher{"group": s, "compiled": MustCompile(test.sel), "program": MustCompileProgram(test.sel)} {
+			var got []string
+			for _, n := range QueryAll(doc, m) {
+				got = append(got, n.Attr[0].Val)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s (%s): got %q, want %q", test.sel, name, got, test.want)
+			}
+		}
+	}
+
+	if got, want := Tag("clipPath").HasAttr("viewBox").String(), "clippath[viewbox]"; got != want {
+		t.Errorf("built %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,587 @@
+// Package gen generates Go source code for matching CSS selectors.
+//
+// Each selector becomes an ordinary function that takes an *html.Node and
+// reports whether the selector matches it, like the Match method of a
+// cascadia.Sel. The generated code doesn't parse anything at run time, and it
+// calls the functions for the parts of the selector directly, so it is
+// faster than the selector it was generated from. Use cascadia.MatcherFunc
+// to pass a generated function where a cascadia.Matcher is needed.
+//
+// The cascadia-gen command is a wrapper around Generate that is convenient to
+// use with go generate.
+//
+// Only selectors that depend on nothing but the document tree are supported:
+// tag, ID, class and attribute selectors, combinators, and the pseudo-classes
+// :not(), :has(), :haschild(), :empty, :parent, :root, :scope, and the
+// :first-child, :nth-child() and :only-child families. Text pseudo-classes
+// like :contains(), result-set pseudo-classes like :first, and
+// pseudo-elements make Generate return an error.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/andybalholm/cascadia/ast"
+	"github.com/andybalholm/cascadia/internal/foreignname"
+)
+
+// A Selector is a selector to generate a function for.
+type Selector struct {
+	// Name is the name of the generated function. It must be a valid Go
+	// identifier.
+	Name string
+
+	// Selector is the text of the selector, which may be a group of
+	// selectors separated by commas.
+	Selector string
+}
+
+// Config controls the generated file.
+type Config struct {
+	// Package is the name of the package the file belongs to.
+	Package string
+
+	// Prefix is added to the names of the unexported functions and
+	// variables that the generated functions use, so that files generated
+	// separately can be in the same package. If it is empty, "cascadia" is
+	// used.
+	Prefix string
+}
+
+// Generate writes a Go source file containing a function for each of
+// selectors.
+func Generate(w io.Writer, config Config, selectors []Selector) error {
+	if !token.IsIdentifier(config.Package) {
+		return fmt.Errorf("invalid package name %q", config.Package)
+	}
+	g := &generator{
+		prefix:  config.Prefix,
+		imports: map[string]bool{"golang.org/x/net/html": true},
+	}
+	if g.prefix == "" {
+		g.prefix = "cascadia"
+	}
+
+	var exported bytes.Buffer
+	for _, s := range selectors {
+		if !token.IsIdentifier(s.Name) {
+			return fmt.Errorf("invalid function name %q", s.Name)
+		}
+		sel, err := cascadia.ParseGroup(s.Selector)
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.Name, err)
+		}
+		node := cascadia.ToAST(sel).(*ast.Group)
+		var body string
+		if len(node.Selectors) == 1 {
+			body, err = g.function(node.Selectors[0])
+		} else {
+			body, err = g.function(node)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.Name, err)
+		}
+		fmt.Fprintf(&exported, "\n// %s matches the selector %s.\n", s.Name, strings.ReplaceAll(sel.String(), "\n", " "))
+		fmt.Fprintf(&exported, "func %s(n *html.Node) bool {\n\treturn %s(n)\n}\n", s.Name, body)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by cascadia-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", config.Package)
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(path, ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	out.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	if len(std) > 0 {
+		out.WriteString("\n")
+	}
+	for _, path := range other {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(exported.Bytes())
+	out.Write(g.vars.Bytes())
+	out.Write(g.funcs.Bytes())
+	for _, name := range g.helperOrder {
+		out.WriteString(g.helperCode(name))
+	}
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// A generator accumulates the functions for the parts of selectors.
+type generator struct {
+	prefix string
+	count  int
+
+	funcs bytes.Buffer
+	vars  bytes.Buffer
+
+	// helpers holds the names of the helper functions that are used.
+	helpers     map[string]bool
+	helperOrder []string
+
+	imports map[string]bool
+}
+
+// newName returns a name for a new unexported function or variable.
+func (g *generator) newName() string {
+	g.count++
+	return fmt.Sprintf("%s%d", g.prefix, g.count)
+}
+
+// helper returns the name of the helper function name, and arranges for it
+// to be included in the output.
+func (g *generator) helper(name string) string {
+	if g.helpers == nil {
+		g.helpers = make(map[string]bool)
+	}
+	if !g.helpers[name] {
+		g.helpers[name] = true
+		g.helperOrder = append(g.helperOrder, name)
+		switch name {
+		case "Include", "Empty":
+			g.imports["strings"] = true
+		case "Number":
+			g.imports["strconv"] = true
+		}
+	}
+	return g.prefix + name
+}
+
+// emit adds a function named name, with the given body, to the output.
+// The doc comment is name followed by comment.
+func (g *generator) emit(name, comment, body string) {
+	fmt.Fprintf(&g.funcs, "\n// %s %s\nfunc %s(n *html.Node) bool {\n%s}\n", name, comment, name, body)
+}
+
+// not returns the negation of the Go expression expr.
+func not(expr string) string {
+	switch {
+	case strings.HasPrefix(expr, "!") && isCall(expr[1:]):
+		return expr[1:]
+	case isCall(expr):
+		return "!" + expr
+	case strings.HasPrefix(expr, "n.Data == ") && !strings.ContainsAny(expr[len("n.Data == "):], " &|"):
+		return "n.Data != " + expr[len("n.Data == "):]
+	}
+	return "!(" + expr + ")"
+}
+
+// isCall returns whether expr is a single function call with simple
+// arguments, like f(n, 1).
+func isCall(expr string) bool {
+	i := strings.IndexByte(expr, '(')
+	return i > 0 && strings.HasSuffix(expr, ")") && !strings.ContainsAny(expr[:i], " !&|.") && strings.IndexByte(expr[i+1:], '(') == -1
+}
+
+// function generates a function for node, and returns its name.
+func (g *generator) function(node ast.Node) (string, error) {
+	name := g.newName()
+	var b strings.Builder
+
+	switch node := node.(type) {
+	case *ast.Group:
+		if len(node.Selectors) == 1 {
+			g.count--
+			return g.function(node.Selectors[0])
+		}
+		var calls []string
+		for _, s := range node.Selectors {
+			f, err := g.function(s)
+			if err != nil {
+				return "", err
+			}
+			calls = append(calls, f+"(n)")
+		}
+		fmt.Fprintf(&b, "\treturn %s\n", strings.Join(calls, " || "))
+
+	case *ast.Combined:
+		if node.First == nil {
+			return "", fmt.Errorf("relative selectors are not supported")
+		}
+		first, err := g.function(node.First)
+		if err != nil {
+			return "", err
+		}
+		second, err := g.function(node.Second)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\tif !%s(n) {\n\t\treturn false\n\t}\n", second)
+		switch node.Combinator {
+		case ' ':
+			fmt.Fprintf(&b, "\tfor p := n.Parent; p != nil; p = p.Parent {\n\t\tif %s(p) {\n\t\t\treturn true\n\t\t}\n\t}\n\treturn false\n", first)
+		case '>':
+			fmt.Fprintf(&b, "\treturn n.Parent != nil && %s(n.Parent)\n", first)
+		case '+':
+			fmt.Fprintf(&b, "\tfor s := n.PrevSibling; s != nil; s = s.PrevSibling {\n\t\tif s.Type == html.TextNode || s.Type == html.CommentNode {\n\t\t\tcontinue\n\t\t}\n\t\treturn %s(s)\n\t}\n\treturn false\n", first)
+		case '~':
+			fmt.Fprintf(&b, "\tfor s := n.PrevSibling; s != nil; s = s.PrevSibling {\n\t\tif %s(s) {\n\t\t\treturn true\n\t\t}\n\t}\n\treturn false\n", first)
+		default:
+			return "", fmt.Errorf("unknown combinator %q", node.Combinator)
+		}
+
+	case *ast.Compound:
+		if node.PseudoElement != "" {
+			return "", fmt.Errorf("pseudo-elements are not supported")
+		}
+		b.WriteString("\tif n.Type != html.ElementNode {\n\t\treturn false\n\t}\n")
+		for _, part := range node.Parts {
+			cond, err := g.condition(part)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, "\tif %s {\n\t\treturn false\n\t}\n", not(cond))
+		}
+		b.WriteString("\treturn true\n")
+
+	default:
+		cond, err := g.condition(node)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\treturn n.Type == html.ElementNode && %s\n", cond)
+	}
+
+	g.emit(name, "matches "+describe(node)+".", b.String())
+	return name, nil
+}
+
+// name returns a Go expression for the name that n.Data or an attribute key
+// must equal to match the lower-case name lower. If foreign has an entry for
+// lower, the name is different in SVG and MathML elements, as it is for the
+// interpreted selector.
+func (g *generator) name(lower string, foreign map[string]string) string {
+	if f := foreign[lower]; f != "" {
+		return fmt.Sprintf("%s(n, %q, %q)", g.helper("Name"), lower, f)
+	}
+	return strconv.Quote(lower)
+}
+
+// condition returns a Go expression that tests whether n (which is known to
+// be an element) matches node, which is a simple selector.
+func (g *generator) condition(node ast.Node) (string, error) {
+	switch node := node.(type) {
+	case *ast.Type:
+		return "n.Data == " + g.name(node.Name, foreignname.Tags), nil
+	case *ast.Class:
+		return fmt.Sprintf("%s(n, %q, %q, false)", g.helper("Include"), "class", node.Name), nil
+	case *ast.ID:
+		return g.attribute(&ast.Attribute{Name: "id", Operator: "=", Value: node.Name})
+	case *ast.Attribute:
+		return g.attribute(node)
+	case *ast.PseudoClass:
+		return g.pseudoClass(node)
+	case *ast.Compound, *ast.Combined, *ast.Group:
+		f, err := g.function(node)
+		if err != nil {
+			return "", err
+		}
+		return f + "(n)", nil
+	}
+	return "", fmt.Errorf("unsupported selector %s", node)
+}
+
+// attribute generates a function for an attribute selector, and returns a
+// call to it.
+func (g *generator) attribute(node *ast.Attribute) (string, error) {
+	key := g.name(node.Name, foreignname.Attributes)
+	val := node.Value
+	fold := node.Insensitive
+	lower := func(s string) string {
+		if fold {
+			g.imports["strings"] = true
+			return "strings.ToLower(" + s + ")"
+		}
+		return s
+	}
+	equal := fmt.Sprintf("a.Val == %q", val)
+	if fold {
+		g.imports["strings"] = true
+		equal = fmt.Sprintf("strings.EqualFold(a.Val, %q)", val)
+	}
+	if fold {
+		val = strings.ToLower(val)
+	}
+
+	var cond string
+	switch node.Operator {
+	case "":
+		cond = "true"
+	case "=":
+		cond = equal
+	case "!=":
+		name := g.newName()
+		g.emit(name, "matches "+describe(node)+".", fmt.Sprintf("\tfor _, a := range n.Attr {\n\t\tif a.Key == %s && %s {\n\t\t\treturn false\n\t\t}\n\t}\n\treturn true\n", key, equal))
+		return name + "(n)", nil
+	case "~=":
+		return fmt.Sprintf("%s(n, %s, %q, %v)", g.helper("Include"), key, node.Value, fold), nil
+	case "|=":
+		g.imports["strings"] = true
+		if fold {
+			cond = fmt.Sprintf("%s || len(a.Val) > %d && a.Val[%d] == '-' && strings.EqualFold(a.Val[:%d], %q)", equal, len(val), len(val), len(val), val)
+		} else {
+			cond = fmt.Sprintf("%s || strings.HasPrefix(a.Val, %q)", equal, val+"-")
+		}
+	case "^=", "$=", "*=":
+		g.imports["strings"] = true
+		f := map[string]string{"^=": "HasPrefix", "$=": "HasSuffix", "*=": "Contains"}[node.Operator]
+		cond = fmt.Sprintf("strings.TrimSpace(a.Val) != \"\" && strings.%s(%s, %q)", f, lower("a.Val"), val)
+	case "#=":
+		g.imports["regexp"] = true
+		rx := g.newName()
+		fmt.Fprintf(&g.vars, "\nvar %s = regexp.MustCompile(%q)\n", rx, node.Value)
+		cond = rx + ".MatchString(a.Val)"
+	case "<", "<=", ">", ">=":
+		number, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid number in %s", node)
+		}
+		g.imports["strings"] = true
+		name := g.newName()
+		g.emit(name, "matches "+describe(node)+".", fmt.Sprintf("\tfor _, a := range n.Attr {\n\t\tif a.Key != %s {\n\t\t\tcontinue\n\t\t}\n\t\tif f, ok := %s(strings.TrimSpace(a.Val)); ok && f %s %s {\n\t\t\treturn true\n\t\t}\n\t}\n\treturn false\n", key, g.helper("Number"), node.Operator, strconv.FormatFloat(number, 'g', -1, 64)))
+		return name + "(n)", nil
+	default:
+		return "", fmt.Errorf("unsupported attribute operator %s", node.Operator)
+	}
+
+	if strings.Contains(cond, "||") {
+		cond = "(" + cond + ")"
+	}
+	name := g.newName()
+	g.emit(name, "matches "+describe(node)+".", fmt.Sprintf("\tfor _, a := range n.Attr {\n\t\tif a.Key == %s && %s {\n\t\t\treturn true\n\t\t}\n\t}\n\treturn false\n", key, cond))
+	return name + "(n)", nil
+}
+
+// pseudoClass returns an expression that tests a pseudo-class.
+func (g *generator) pseudoClass(node *ast.PseudoClass) (string, error) {
+	switch node.Name {
+	case "not":
+		f, err := g.function(node.Selectors)
+		if err != nil {
+			return "", err
+		}
+		return "!" + f + "(n)", nil
+
+	case "has", "haschild":
+		f, err := g.function(node.Selectors)
+		if err != nil {
+			return "", err
+		}
+		name := g.newName()
+		recurse := ""
+		if node.Name == "has" {
+			recurse = fmt.Sprintf(" || c.Type == html.ElementNode && c.FirstChild != nil && %s(c)", name)
+		}
+		what := "a descendant"
+		if node.Name == "haschild" {
+			what = "a child"
+		}
+		g.emit(name, fmt.Sprintf("reports whether n has %s that matches %s.", what, describe(node.Selectors)), fmt.Sprintf("\tfor c := n.FirstChild; c != nil; c = c.NextSibling {\n\t\tif %s(c)%s {\n\t\t\treturn true\n\t\t}\n\t}\n\treturn false\n", f, recurse))
+		return name + "(n)", nil
+
+	case "first-child", "last-child", "first-of-type", "last-of-type":
+		last := strings.HasPrefix(node.Name, "last")
+		ofType := strings.HasSuffix(node.Name, "of-type")
+		return fmt.Sprintf("%s(n, 0, 1, %v, %v)", g.helper("Nth"), last, ofType), nil
+
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		var a, b int
+		if _, err := fmt.Sscanf(node.Argument, "%dn%d", &a, &b); err != nil {
+			return "", fmt.Errorf("invalid argument to :%s: %s", node.Name, node.Argument)
+		}
+		last := strings.Contains(node.Name, "last")
+		ofType := strings.HasSuffix(node.Name, "of-type")
+		return fmt.Sprintf("%s(n, %d, %d, %v, %v)", g.helper("Nth"), a, b, last, ofType), nil
+
+	case "only-child", "only-of-type":
+		return fmt.Sprintf("%s(n, %v)", g.helper("Only"), node.Name == "only-of-type"), nil
+
+	case "empty":
+		return g.helper("Empty") + "(n)", nil
+	case "parent":
+		return "!" + g.helper("Empty") + "(n)", nil
+
+	case "root", "scope":
+		return "n.Parent != nil && n.Parent.Type == html.DocumentNode", nil
+	}
+	return "", fmt.Errorf("unsupported pseudo-class :%s", node.Name)
+}
+
+// describe returns a description of node, for a comment.
+func describe(node ast.Node) string {
+	s := strings.ReplaceAll(node.String(), "\n", " ")
+	if s == "" {
+		s = "*"
+	}
+	return s
+}
+
+// helperCode returns the source code of a helper function.
+func (g *generator) helperCode(name string) string {
+	p := g.prefix
+	switch name {
+	case "Include":
+		return fmt.Sprintf(`
+// %[1]sInclude returns whether n has an attribute named key whose value is a
+// whitespace-separated list that includes val.
+func %[1]sInclude(n *html.Node, key, val string, fold bool) bool {
+	for _, a := range n.Attr {
+		if a.Key != key {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(a.Val, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '\f'
+		}) {
+			if word == val || fold && strings.EqualFold(word, val) {
+				return true
+			}
+		}
+	}
+	return false
+}
+`, p)
+	case "Nth":
+		return fmt.Sprintf(`
+// %[1]sNth returns whether n is the an+b'th child of its parent, counting
+// from the end if last is true, and only counting elements of the same
+// type if ofType is true.
+func %[1]sNth(n *html.Node, a, b int, last, ofType bool) bool {
+	if n.Parent == nil {
+		return false
+	}
+	i, count := 0, 0
+	for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || ofType && c.Data != n.Data {
+			continue
+		}
+		count++
+		if c == n {
+			i = count
+		}
+	}
+	if last {
+		i = count - i + 1
+	}
+	i -= b
+	if a == 0 {
+		return i == 0
+	}
+	return i%%a == 0 && i/a >= 0
+}
+`, p)
+	case "Only":
+		return fmt.Sprintf(`
+// %[1]sOnly returns whether n is the only child of its parent that is an
+// element (of the same type, if ofType is true).
+func %[1]sOnly(n *html.Node, ofType bool) bool {
+	if n.Parent == nil {
+		return false
+	}
+	count := 0
+	for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (!ofType || c.Data == n.Data) {
+			count++
+		}
+	}
+	return count == 1
+}
+`, p)
+	case "Number":
+		return fmt.Sprintf(`
+// %[1]sNumber parses s as a decimal number: an optional sign, digits with
+// an optional decimal point, and an optional exponent. It rejects what
+// strconv.ParseFloat accepts beyond that, like "inf" and "0x10", and numbers
+// too large to be finite.
+func %[1]sNumber(s string) (float64, bool) {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return 0, false
+		}
+	}
+	if i != len(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+`, p)
+	case "Name":
+		return fmt.Sprintf(`
+// %[1]sName returns foreign if n is an SVG or MathML element, and lower
+// otherwise.
+func %[1]sName(n *html.Node, lower, foreign string) string {
+	if n.Namespace != "" {
+		return foreign
+	}
+	return lower
+}
+`, p)
+	case "Empty":
+		return fmt.Sprintf(`
+// %[1]sEmpty returns whether n has no child elements or non-whitespace text.
+func %[1]sEmpty(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.ElementNode:
+			return false
+		case html.TextNode:
+			if strings.TrimSpace(c.Data) != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+`, p)
+	}
+	panic("unknown helper " + name)
+}
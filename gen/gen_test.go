@@ -0,0 +1,141 @@
+package gen
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/andybalholm/cascadia/gen/internal/generated"
+	"golang.org/x/net/html"
+)
+
+var update = flag.Bool("update", false, "update the generated test package")
+
+// testSelectors are the selectors in the generated package. The functions
+// field must list the same functions, in the same order.
+var testSelectors = []Selector{
+	{"Links", `a[href]`},
+	{"LocalLinks", `a[href^="/" i]:not([href$=".pdf"])`},
+	{"Items", `ul > li:nth-child(2n+1):not(.x), ol li:last-child`},
+	{"Siblings", `h1 + p, h1 ~ ul`},
+	{"WithSpan", `div:has(span), section:haschild(p)`},
+	{"Attributes", `[lang|=en], [class~=y], [data-n>=2.5], [title*=bc], [title!=abc]`},
+	{"Regexp", `[href#=(^https?:)]`},
+	{"Structure", `:root, :empty, li:only-child, p:only-of-type, li:nth-last-of-type(2), #main > :first-of-type`},
+	{"Foreign", `clipPath > rect, [viewBox], marker[refX>=1], [preserveAspectRatio~=xMidYMid], svg > :not(clipPath)`},
+}
+
+var functions = []func(*html.Node) bool{
+	generated.Links,
+	generated.LocalLinks,
+	generated.Items,
+	generated.Siblings,
+	generated.WithSpan,
+	generated.Attributes,
+	generated.Regexp,
+	generated.Structure,
+	generated.Foreign,
+}
+
+const generatedFile = "internal/generated/selectors.go"
+
+// regexpSupported is false in builds with the cascadia_noregexp tag, which
+// can't parse the Regexp selector.
+var regexpSupported = cascadia.Validate("[a#=(b)]") == nil
+
+func TestGeneratedUpToDate(t *testing.T) {
+	if !regexpSupported {
+		t.Skip("the generated package can't be regenerated without regexp support")
+	}
+	var b bytes.Buffer
+	if err := Generate(&b, Config{Package: "generated"}, testSelectors); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := ioutil.WriteFile(generatedFile, b.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	old, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(old, b.Bytes()) {
+		t.Errorf("%s is out of date; run go test with -update", generatedFile)
+	}
+}
+
+const testPage = `<html lang="en-US"><body>
+<div id="main">
+  <h1>Title</h1>
+  <p title="abc">Intro <span>!</span></p>
+  <ul class="x y">
+    <li><a href="/a">a</a></li>
+    <li class="x"><a href="/b.PDF">b</a></li>
+    <li><a href="HTTPS://example.com">c</a></li>
+    <li data-n="3"></li>
+  </ul>
+  <ol><li>only</li></ol>
+  <section><p title="abcd">x</p></section>
+  <a href="https://example.com/x.pdf">d</a>
+</div>
+<div data-n="2"> </div>
+<div data-n="inf"> </div>
+<svg viewBox="0 0 10 10" preserveAspectRatio="xMidYMid meet">
+  <clipPath id="c"><rect width="5" height="5"/></clipPath>
+  <marker refX="2"><path d="M0 0"/></marker>
+  <foreignObject><div viewBox="x">html</div></foreignObject>
+</svg>
+<clippath><rect></rect></clippath>
+</body></html>`
+
+func TestGeneratedMatches(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range testSelectors {
+		if !regexpSupported && strings.Contains(s.Selector, "#=") {
+			continue
+		}
+		sel, err := cascadia.ParseGroup(s.Selector)
+		if err != nil {
+			t.Fatalf("%s: %v", s.Name, err)
+		}
+		want := cascadia.QueryAll(doc, sel)
+		got := cascadia.QueryAll(doc, cascadia.MatcherFunc(functions[i]))
+		if len(want) == 0 {
+			t.Errorf("%s: %s matches nothing in the test page", s.Name, s.Selector)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d matches, want %d", s.Name, len(got), len(want))
+			continue
+		}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Errorf("%s: match %d is <%s>, want <%s>", s.Name, j, got[j].Data, want[j].Data)
+			}
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, sel := range []string{
+		"p:contains(x)",
+		"li:first",
+		"p::before",
+		"[",
+	} {
+		err := Generate(ioutil.Discard, Config{Package: "x"}, []Selector{{"F", sel}})
+		if err == nil {
+			t.Errorf("Generate(%q) succeeded, want an error", sel)
+		}
+	}
+	if err := Generate(ioutil.Discard, Config{Package: "x"}, []Selector{{"not a name", "p"}}); err == nil {
+		t.Error("Generate succeeded with an invalid function name")
+	}
+}
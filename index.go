@@ -0,0 +1,245 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// An Index records the elements of a document by tag name, ID and class,
+// so that a query can test only the elements that could match the last
+// compound selector, instead of every element in the document.
+//
+// If the document is changed after the Index is built, the Index must be
+// told about the changes with Insert, Remove and Update; otherwise queries
+// may miss elements, or return elements that are no longer in the
+// document.
+//
+// An Index is not safe for concurrent use if it is being updated.
+type Index struct {
+	root    *html.Node
+	byTag   map[string]*indexList
+	byID    map[string]*indexList
+	byClass map[string]*indexList
+
+	// entries holds the keys each element was indexed under, so that it
+	// can be removed even if its attributes have changed since.
+	entries map[*html.Node]indexEntry
+
+	// building is true while NewIndex is adding the elements in document
+	// order.
+	building bool
+}
+
+type indexEntry struct {
+	tag, id string
+	classes []string
+}
+
+// An indexList is a list of elements, which is sorted into document order
+// when it is needed.
+type indexList struct {
+	nodes  []*html.Node
+	sorted bool
+}
+
+// NewIndex builds an Index of the descendants of root.
+func NewIndex(root *html.Node) *Index {
+	x := &Index{
+		root:    root,
+		byTag:   make(map[string]*indexList),
+		byID:    make(map[string]*indexList),
+		byClass: make(map[string]*indexList),
+		entries: make(map[*html.Node]indexEntry),
+	}
+	x.building = true
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		x.Insert(c)
+	}
+	x.building = false
+	return x
+}
+
+// Insert adds n and its descendants to the index. Call it after n has been
+// added to the document. Nodes that are already in the index are updated.
+func (x *Index) Insert(n *html.Node) {
+	x.Update(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		x.Insert(c)
+	}
+}
+
+// Remove removes n and its descendants from the index. Call it when n is
+// removed from the document (before or after it is detached, as long as
+// its own children have not been removed yet).
+func (x *Index) Remove(n *html.Node) {
+	x.remove(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		x.Remove(c)
+	}
+}
+
+// Update re-indexes n alone, after its tag name, id or class attribute has
+// changed.
+func (x *Index) Update(n *html.Node) {
+	x.remove(n)
+	if n.Type != html.ElementNode {
+		return
+	}
+	e := indexEntry{tag: n.Data}
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "id":
+			if e.id == "" {
+				e.id = a.Val
+			}
+		case "class":
+			e.classes = fieldsHTML(a.Val)
+		}
+	}
+	x.entries[n] = e
+	x.add(x.byTag, e.tag, n)
+	if e.id != "" {
+		x.add(x.byID, e.id, n)
+	}
+	for _, class := range e.classes {
+		x.add(x.byClass, class, n)
+	}
+}
+
+func (x *Index) remove(n *html.Node) {
+	e, ok := x.entries[n]
+	if !ok {
+		return
+	}
+	delete(x.entries, n)
+	removeFromIndex(x.byTag, e.tag, n)
+	if e.id != "" {
+		removeFromIndex(x.byID, e.id, n)
+	}
+	for _, class := range e.classes {
+		removeFromIndex(x.byClass, class, n)
+	}
+}
+
+// fieldsHTML splits s at HTML whitespace, dropping duplicates.
+func fieldsHTML(s string) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || isHTMLSpace(rune(s[i])) {
+			if start >= 0 && !seen[s[start:i]] {
+				seen[s[start:i]] = true
+				fields = append(fields, s[start:i])
+			}
+			start = -1
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return fields
+}
+
+func (x *Index) add(m map[string]*indexList, key string, n *html.Node) {
+	l := m[key]
+	if l == nil {
+		l = &indexList{sorted: true}
+		m[key] = l
+	}
+	l.nodes = append(l.nodes, n)
+	if !x.building && len(l.nodes) > 1 {
+		l.sorted = false
+	}
+}
+
+func removeFromIndex(m map[string]*indexList, key string, n *html.Node) {
+	l := m[key]
+	if l == nil {
+		return
+	}
+	for i, node := range l.nodes {
+		if node == n {
+			l.nodes = append(l.nodes[:i], l.nodes[i+1:]...)
+			break
+		}
+	}
+	if len(l.nodes) == 0 {
+		delete(m, key)
+	}
+}
+
+// lookup returns the elements indexed under key, in document order.
+func lookup(m map[string]*indexList, key string) []*html.Node {
+	l := m[key]
+	if l == nil {
+		return nil
+	}
+	if !l.sorted {
+		sortDocumentOrder(l.nodes)
+		l.sorted = true
+	}
+	return l.nodes
+}
+
+// QueryAll returns the descendants of the index's root that match m, in
+// document order, like QueryAll(root, m).
+func (x *Index) QueryAll(m Matcher) []*html.Node {
+	if hasPositional(m) {
+		return QueryAll(x.root, m)
+	}
+	var sels []Sel
+	switch s := m.(type) {
+	case SelectorGroup:
+		sels = s
+	case Sel:
+		sels = []Sel{s}
+	default:
+		return QueryAll(x.root, m)
+	}
+
+	sets := make([][]*html.Node, len(sels))
+	for i, s := range sels {
+		candidates, ok := x.candidates(s)
+		if !ok {
+			return QueryAll(x.root, m)
+		}
+		for _, n := range candidates {
+			if s.Match(n) {
+				sets[i] = append(sets[i], n)
+			}
+		}
+	}
+	if len(sets) == 1 {
+		return sets[0]
+	}
+	return Union(sets...)
+}
+
+// candidates returns the elements that might match s: the ones indexed
+// under the most selective ID, class or tag name in its last compound
+// selector. The boolean result is false if there is no such key.
+func (x *Index) candidates(s Sel) ([]*html.Node, bool) {
+	var tag, class string
+	for _, part := range simpleSelectors(lastCompound(s)) {
+		switch p := part.(type) {
+		case idSelector:
+			if !p.quirks {
+				return lookup(x.byID, p.id), true
+			}
+		case classSelector:
+			if !p.quirks && class == "" {
+				class = p.class
+			}
+		case tagSelector:
+			if p.name == "" {
+				tag = p.tag
+			}
+		}
+	}
+	switch {
+	case class != "":
+		return lookup(x.byClass, class), true
+	case tag != "":
+		return lookup(x.byTag, tag), true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestIndexMatchesQueryAll(t *testing.T) {
+	for _, test := range selectorTests {
+		g, err := ParseGroup(test.selector)
+		if err != nil {
+			continue
+		}
+		doc, err := html.Parse(strings.NewReader(test.HTML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkIndex(t, NewIndex(doc), doc, g)
+	}
+}
+
+// checkIndex checks that x finds the same matches for m as QueryAll.
+func checkIndex(t *testing.T, x *Index, root *html.Node, m Matcher) {
+	t.Helper()
+	want := QueryAll(root, m)
+	got := x.QueryAll(m)
+	if len(got) != len(want) {
+		t.Errorf("%s: index found %d elements, want %d", m, len(got), len(want))
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s: match %d is %s, want %s", m, i, nodeString(got[i]), nodeString(want[i]))
+		}
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	doc := MustParseHTML(`<ul id="list"><li class="a">1</li><li class="b">2</li></ul><p class="a">text</p>`)
+	x := NewIndex(doc)
+	sels := []Matcher{
+		MustParseGroup(t, "li.a"),
+		MustParseGroup(t, ".a"),
+		MustParseGroup(t, "#list > li"),
+		MustParseGroup(t, "li, p"),
+		MustParseGroup(t, "#new"),
+	}
+	check := func() {
+		t.Helper()
+		for _, m := range sels {
+			checkIndex(t, x, doc, m)
+		}
+	}
+	check()
+
+	ul := Query(doc, MustCompile("ul"))
+	first := ul.FirstChild
+
+	// Insert a new element before the first item.
+	li := &html.Node{Type: html.ElementNode, Data: "li", Attr: []html.Attribute{{Key: "class", Val: "a"}, {Key: "id", Val: "new"}}}
+	li.AppendChild(&html.Node{Type: html.TextNode, Data: "0"})
+	ul.InsertBefore(li, first)
+	x.Insert(li)
+	check()
+	if got := x.QueryAll(MustParseGroup(t, "li.a")); len(got) != 2 || got[0] != li {
+		t.Errorf("after Insert, li.a found %d elements, starting with %v", len(got), got)
+	}
+
+	// Change a class.
+	first.Attr[0].Val = "b c"
+	x.Update(first)
+	check()
+
+	// Remove the list.
+	ul.Parent.RemoveChild(ul)
+	x.Remove(ul)
+	check()
+	if got := x.QueryAll(MustParseGroup(t, "li")); len(got) != 0 {
+		t.Errorf("after Remove, li found %d elements", len(got))
+	}
+
+	// Put it back, inside the paragraph.
+	p := Query(doc, MustCompile("p"))
+	p.AppendChild(ul)
+	x.Insert(ul)
+	check()
+}
@@ -0,0 +1,39 @@
+// Package cssesc escapes text for CSS selectors. It is shared by cascadia's
+// selectors and its syntax tree package, so that both serialize names and
+// strings the same way.
+package cssesc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ident escapes special CSS characters, so that s can be used as an
+// identifier.
+func Ident(s string) string {
+	var b strings.Builder
+	dashes := len(s) - len(strings.TrimLeft(s, "-"))
+	for i, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7f || i == dashes && '0' <= r && r <= '9':
+			// These can't be escaped with a backslash alone.
+			fmt.Fprintf(&b, "\\%x ", r)
+		case dashes == len(s) && i == len(s)-1:
+			// An identifier can't be only hyphens.
+			b.WriteString(`\-`)
+		case r > 127 || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Quote returns s as a CSS string.
+func Quote(s string) string {
+	return `"` + stringEscaper.Replace(s) + `"`
+}
+
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `, "\r", `\d `, "\f", `\c `)
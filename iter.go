@@ -0,0 +1,23 @@
+//go:build go1.23
+
+package cascadia
+
+import (
+	"iter"
+
+	"golang.org/x/net/html"
+)
+
+// All returns an iterator over the nodes that match the selector, from n and
+// its children, in the same order as MatchAll. The nodes are found as the
+// iteration proceeds, so stopping early skips the rest of the search.
+func (s Selector) All(n *html.Node) iter.Seq[*html.Node] {
+	return func(yield func(*html.Node) bool) {
+		w := walk(n, true)
+		for c := w.next(); c != nil; c = w.next() {
+			if s(c) && !yield(c) {
+				return
+			}
+		}
+	}
+}
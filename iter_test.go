@@ -0,0 +1,53 @@
+//go:build go1.23
+
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestAll(t *testing.T) {
+	for _, test := range selectorTests {
+		s, err := Compile(test.selector)
+		if err != nil {
+			continue
+		}
+		doc, err := html.Parse(strings.NewReader(test.HTML))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := s.MatchAll(doc)
+		i := 0
+		for n := range s.All(doc) {
+			if i >= len(want) || n != want[i] {
+				t.Errorf("selector %s: All differs from MatchAll at match %d", test.selector, i)
+				break
+			}
+			i++
+		}
+		if i != len(want) {
+			t.Errorf("selector %s: All returned %d matches, MatchAll returned %d", test.selector, i, len(want))
+		}
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p id=1><p id=2><p id=3>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*html.Node
+	for n := range MustCompile("p").All(doc) {
+		got = append(got, n)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if len(got) != 2 || got[1].Attr[0].Val != "2" {
+		t.Errorf("expected the first two p elements, got %d nodes", len(got))
+	}
+}
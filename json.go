@@ -0,0 +1,219 @@
+package cascadia
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/andybalholm/cascadia/ast"
+)
+
+// jsonNode is the JSON representation of a node in a selector's syntax tree.
+type jsonNode struct {
+	Kind          string       `json:"kind"` // "group", "combined", "compound", "type", "class", "id", "attribute" or "pseudo-class"
+	Name          string       `json:"name,omitempty"`
+	Operator      string       `json:"operator,omitempty"`
+	Value         string       `json:"value,omitempty"`
+	Insensitive   bool         `json:"insensitive,omitempty"`
+	Argument      string       `json:"argument,omitempty"`
+	Combinator    string       `json:"combinator,omitempty"`
+	First         *jsonNode    `json:"first,omitempty"`
+	Second        *jsonNode    `json:"second,omitempty"`
+	Parts         []jsonNode   `json:"parts,omitempty"`
+	PseudoElement string       `json:"pseudoElement,omitempty"`
+	Selectors     []jsonNode   `json:"selectors,omitempty"`
+	Specificity   *Specificity `json:"specificity,omitempty"`
+}
+
+// MarshalJSON encodes the group as a JSON object describing its syntax tree:
+// each node has a "kind" field, and fields for its arguments. The
+// selectors in the group also have a "specificity" field.
+//
+// To encode a single Sel, put it in a SelectorGroup. Attribute selectors
+// parsed with StrictAttributes can't be encoded, since the syntax tree
+// doesn't record it.
+func (s SelectorGroup) MarshalJSON() ([]byte, error) {
+	for _, sel := range s {
+		if bytes.IndexByte(strictFlags(nil, sel), '1') != -1 {
+			return nil, fmt.Errorf("can't encode %s: it has attribute selectors parsed with StrictAttributes", sel)
+		}
+	}
+	root := newJSONNode(ToAST(s))
+	for i, sel := range s {
+		spec := sel.Specificity()
+		root.Selectors[i].Specificity = &spec
+	}
+	// Selectors are full of > characters, so don't escape them unless the
+	// caller's encoder asks for it.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSON decodes a selector group encoded by MarshalJSON. It also
+// accepts a JSON string containing a selector in CSS syntax.
+func (s *SelectorGroup) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		g, err := ParseGroupWithPseudoElements(text)
+		if err != nil {
+			return err
+		}
+		*s = g
+		return nil
+	}
+
+	var root jsonNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	node, err := root.astNode()
+	if err != nil {
+		return err
+	}
+	g, err := FromAST(node)
+	if err != nil {
+		return err
+	}
+	*s = g
+	return nil
+}
+
+func newJSONNode(n ast.Node) jsonNode {
+	switch n := n.(type) {
+	case *ast.Group:
+		return jsonNode{Kind: "group", Selectors: newJSONList(n.Selectors)}
+	case *ast.Combined:
+		j := jsonNode{Kind: "combined", Combinator: string(n.Combinator)}
+		if n.First != nil {
+			first := newJSONNode(n.First)
+			j.First = &first
+		}
+		second := newJSONNode(n.Second)
+		j.Second = &second
+		return j
+	case *ast.Compound:
+		return jsonNode{Kind: "compound", Parts: newJSONList(n.Parts), PseudoElement: n.PseudoElement}
+	case *ast.Type:
+		return jsonNode{Kind: "type", Name: n.Name}
+	case *ast.Class:
+		return jsonNode{Kind: "class", Name: n.Name}
+	case *ast.ID:
+		return jsonNode{Kind: "id", Name: n.Name}
+	case *ast.Attribute:
+		return jsonNode{Kind: "attribute", Name: n.Name, Operator: n.Operator, Value: n.Value, Insensitive: n.Insensitive}
+	case *ast.PseudoClass:
+		j := jsonNode{Kind: "pseudo-class", Name: n.Name, Argument: n.Argument}
+		if n.Selectors != nil {
+			j.Selectors = newJSONList(n.Selectors.Selectors)
+		}
+		return j
+	}
+	panic(fmt.Sprintf("unsupported syntax tree node %T", n))
+}
+
+func newJSONList(nodes []ast.Node) []jsonNode {
+	list := make([]jsonNode, len(nodes))
+	for i, n := range nodes {
+		list[i] = newJSONNode(n)
+	}
+	return list
+}
+
+// astNode converts j back to a syntax tree node.
+func (j jsonNode) astNode() (ast.Node, error) {
+	switch j.Kind {
+	case "group":
+		selectors, err := astList(j.Selectors)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Group{Selectors: selectors}, nil
+	case "combined":
+		switch j.Combinator {
+		case " ", ">", "+", "~":
+		default:
+			return nil, fmt.Errorf("invalid combinator %q", j.Combinator)
+		}
+		if j.Second == nil {
+			return nil, fmt.Errorf("combined selector without a second selector")
+		}
+		c := &ast.Combined{Combinator: j.Combinator[0]}
+		var err error
+		if j.First != nil {
+			if c.First, err = j.First.astNode(); err != nil {
+				return nil, err
+			}
+		}
+		if c.Second, err = j.Second.astNode(); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case "compound":
+		parts, err := astList(j.Parts)
+		if err != nil {
+			return nil, err
+		}
+		if j.PseudoElement != "" && !isPseudoElementName(j.PseudoElement) {
+			return nil, fmt.Errorf("invalid pseudo-element %q", j.PseudoElement)
+		}
+		return &ast.Compound{Parts: parts, PseudoElement: j.PseudoElement}, nil
+	case "type":
+		return &ast.Type{Name: j.Name}, nil
+	case "class":
+		return &ast.Class{Name: j.Name}, nil
+	case "id":
+		return &ast.ID{Name: j.Name}, nil
+	case "attribute":
+		switch j.Operator {
+		case "", "=", "!=", "~=", "|=", "^=", "$=", "*=", "#=", "<", "<=", ">", ">=":
+		default:
+			return nil, fmt.Errorf("invalid attribute operator %q", j.Operator)
+		}
+		a := &ast.Attribute{Name: j.Name, Operator: j.Operator, Value: j.Value, Insensitive: j.Insensitive}
+		return a, checkSingle(a)
+	case "pseudo-class":
+		p := &ast.PseudoClass{Name: j.Name, Argument: j.Argument}
+		if j.Selectors != nil {
+			selectors, err := astList(j.Selectors)
+			if err != nil {
+				return nil, err
+			}
+			p.Selectors = &ast.Group{Selectors: selectors}
+		}
+		return p, checkSingle(p)
+	}
+	return nil, fmt.Errorf("unknown selector kind %q", j.Kind)
+}
+
+// checkSingle returns an error unless the text of n, an attribute selector
+// or a pseudo-class, parses back as a single node of the same kind. Their
+// values and arguments are written out as they are, so otherwise a crafted
+// value could close the selector early and add others.
+func checkSingle(n ast.Node) error {
+	g, err := ParseGroupWithPseudoElements(n.String())
+	if err != nil {
+		return err
+	}
+	if len(g) != 1 || reflect.TypeOf(ToAST(g[0])) != reflect.TypeOf(n) {
+		return fmt.Errorf("%s is not a single selector of kind %T", n, n)
+	}
+	return nil
+}
+
+func astList(list []jsonNode) ([]ast.Node, error) {
+	nodes := make([]ast.Node, len(list))
+	for i, j := range list {
+		n, err := j.astNode()
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
@@ -0,0 +1,97 @@
+package cascadia
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	var tests []string
+	for _, test := range selectorTests {
+		tests = append(tests, test.selector)
+	}
+	for _, test := range testsPseudo {
+		tests = append(tests, test.selector)
+	}
+	for _, test := range positionalTests {
+		tests = append(tests, test.selector)
+	}
+
+	for _, test := range tests {
+		if skipRegexp(test) {
+			continue
+		}
+		s, err := ParseGroupWithPseudoElements(test)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test, err)
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("error encoding %s: %s", test, err)
+			continue
+		}
+		var s2 SelectorGroup
+		if err := json.Unmarshal(data, &s2); err != nil {
+			t.Errorf("error decoding %s: %s", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(s, s2) {
+			t.Errorf("can't retrieve selector from JSON: %s (original : %s)", data, test)
+		}
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	s := MustParseGroup(t, `ul > li:not(.a), [href^="/p" i]`)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.TrimSpace(buf.Bytes())
+	want := `{"kind":"group","selectors":[` +
+		`{"kind":"combined","combinator":">","first":{"kind":"type","name":"ul"},"second":{"kind":"compound","parts":[{"kind":"type","name":"li"},{"kind":"pseudo-class","name":"not","selectors":[{"kind":"class","name":"a"}]}]},"specificity":[0,1,2]},` +
+		`{"kind":"attribute","name":"href","operator":"^=","value":"/p","insensitive":true,"specificity":[0,1,0]}]}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	var fromString SelectorGroup
+	if err := json.Unmarshal([]byte(`"ul > li:not(.a), [href^='/p' i]"`), &fromString); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromString, s) {
+		t.Errorf("decoding a string gave %s, want %s", fromString, s)
+	}
+
+	strict, err := ParseGroupWithOptions(`p[a^=""]`, ParseOptions{StrictAttributes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(strict); err == nil {
+		t.Errorf("encoding %s parsed with StrictAttributes: expected an error", strict)
+	}
+
+	for _, bad := range []string{
+		`{"kind":"widget"}`,
+		`{"kind":"combined","combinator":">>","second":{"kind":"type","name":"p"}}`,
+		`{"kind":"combined","combinator":">","first":{"kind":"type","name":"p"}}`,
+		`{"kind":"pseudo-class","name":"no-such-thing"}`,
+		`{"kind":"combined","combinator":",","first":{"kind":"type","name":"p"},"second":{"kind":"type","name":"q"}}`,
+		`{"kind":"attribute","name":"x","operator":"]*,[y","value":"z"}`,
+		`{"kind":"attribute","name":"x","operator":"<","value":"1],q[y"}`,
+		`{"kind":"pseudo-class","name":"is","argument":"p), q:has(b"}`,
+		`{"kind":"pseudo-class","name":"not","argument":"p) q:has(b"}`,
+		`{"kind":"compound","parts":[{"kind":"type","name":"p"}],"pseudoElement":"before, q"}`,
+		`"p >"`,
+		`[1, 2]`,
+	} {
+		var g SelectorGroup
+		if err := json.Unmarshal([]byte(bad), &g); err == nil {
+			t.Errorf("decoding %s: expected an error", bad)
+		}
+	}
+}
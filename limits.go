@@ -0,0 +1,139 @@
+package cascadia
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// Limits restricts the work a query may do, to protect services that match
+// untrusted selectors or documents from adversarial input, like very deeply
+// nested HTML combined with :has(). See QueryAllLimited.
+type Limits struct {
+	// MaxNodes is the most nodes the query may visit, counting both the
+	// nodes it tests and the ancestors, siblings and descendants that
+	// combinators and pseudo-classes like :has() look at. If it is zero,
+	// there is no limit.
+	MaxNodes int
+
+	// MaxDepth is how deeply the search may be nested below the node the
+	// query starts from, including the searches done by :has(). If it is
+	// zero, there is no limit.
+	MaxDepth int
+
+	// MaxHasDepth, if it is greater than zero, limits how many levels below
+	// an element :has() searches, like ParseOptions.MaxHasDepth, but for
+	// this query only. Deeper descendants are treated as not matching,
+	// instead of stopping the query with an error.
+	MaxHasDepth int
+}
+
+// ErrLimitExceeded is the error returned when a query is stopped because it
+// reached one of its Limits. The error returned may wrap it with more
+// details, so check for it with errors.Is.
+var ErrLimitExceeded = errors.New("cascadia: query limit exceeded")
+
+// A limiter keeps track of the work done by a query, and stops it when it
+// goes over its limits, by panicking with a limitError.
+type limiter struct {
+	limits  Limits
+	visited int
+	depth   int
+
+	// matches holds the results found so far, so that they can be
+	// returned if the query is stopped.
+	matches []*html.Node
+}
+
+type limitError struct {
+	err error
+}
+
+func (l *limiter) visit() {
+	l.visited++
+	if l.limits.MaxNodes > 0 && l.visited > l.limits.MaxNodes {
+		panic(limitError{fmt.Errorf("%w: visited more than %d nodes", ErrLimitExceeded, l.limits.MaxNodes)})
+	}
+}
+
+func (l *limiter) enter() {
+	l.depth++
+	if l.limits.MaxDepth > 0 && l.depth > l.limits.MaxDepth {
+		panic(limitError{fmt.Errorf("%w: searched deeper than %d levels", ErrLimitExceeded, l.limits.MaxDepth)})
+	}
+}
+
+// hasDepth returns how many levels :has() should search, given the
+// selector's own limit (or 0 for none) and the limit for the query.
+func (c *matchContext) hasDepth(depth int) int {
+	if c == nil || c.limiter == nil {
+		return depth
+	}
+	if max := c.limiter.limits.MaxHasDepth; max > 0 && (depth <= 0 || max < depth) {
+		return max
+	}
+	return depth
+}
+
+// query adds the descendants of n that match m to l.matches, until there
+// are limit of them (if limit is positive). It returns whether it reached
+// limit.
+func (l *limiter) query(c *matchContext, n *html.Node, m Matcher, limit int) bool {
+	c.enter()
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		l.visit()
+		if c.match(m, child) {
+			l.matches = append(l.matches, child)
+			if len(l.matches) == limit {
+				return true
+			}
+		}
+		if child.FirstChild != nil && l.query(c, child, m, limit) {
+			return true
+		}
+	}
+	c.leave()
+	return false
+}
+
+// run runs a query for up to limit nodes, and returns what it found. If it
+// was stopped by l's Limits, it also returns an error.
+func (l *limiter) run(n *html.Node, m Matcher, limit int) (matches []*html.Node, err error) {
+	c := &matchContext{limiter: l, results: true}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(limitError)
+			if !ok {
+				panic(r)
+			}
+			matches, err = l.matches, e.err
+		}
+	}()
+	l.query(c, n, m, limit)
+	return filterResults(c, m, l.matches), nil
+}
+
+// QueryAllLimited is like QueryAll, but it stops when the query reaches one
+// of the limits, and returns the nodes it found so far along with an error
+// wrapping ErrLimitExceeded. Result-set pseudo-classes like :last aren't
+// applied to the incomplete results.
+func QueryAllLimited(n *html.Node, m Matcher, limits Limits) ([]*html.Node, error) {
+	l := &limiter{limits: limits}
+	return l.run(n, m, -1)
+}
+
+// QueryLimited is like Query, but it stops when the query reaches one of
+// the limits, and returns an error wrapping ErrLimitExceeded.
+func QueryLimited(n *html.Node, m Matcher, limits Limits) (*html.Node, error) {
+	l := &limiter{limits: limits}
+	limit := 1
+	if hasPositional(m) {
+		limit = -1
+	}
+	matches, err := l.run(n, m, limit)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	return matches[0], nil
+}
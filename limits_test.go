@@ -0,0 +1,107 @@
+package cascadia
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestQueryLimits(t *testing.T) {
+	page := `<ul><li>1</li><li>2</li><li>3</li></ul>` + strings.Repeat("<div>", 200) + "<p>deep</p>" + strings.Repeat("</div>", 200)
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sel := range []string{"li", "li:last", "div:has(p) > div", "p"} {
+		m := MustParseGroup(t, sel)
+		got, err := QueryAllLimited(doc, m, Limits{})
+		if err != nil {
+			t.Errorf("%s without limits: %s", sel, err)
+		}
+		if want := QueryAll(doc, m); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s without limits: got %d nodes, want %d", sel, len(got), len(want))
+		}
+		first, err := QueryLimited(doc, m, Limits{})
+		if err != nil || first != Query(doc, m) {
+			t.Errorf("QueryLimited(%s) = %v, %v", sel, first, err)
+		}
+	}
+
+	for _, test := range []struct {
+		sel     string
+		limits  Limits
+		partial int // the number of nodes returned with the error
+	}{
+		{"li", Limits{MaxNodes: 8}, 2},
+		{"li", Limits{MaxDepth: 3}, 0},
+		{"li", Limits{MaxDepth: 5}, 3},
+		{"p", Limits{MaxDepth: 50}, 0},
+		// Each div's :has() looks at all the divs inside it.
+		{"div:has(p)", Limits{MaxNodes: 1000}, 4},
+		{"div:has(p)", Limits{MaxDepth: 100}, 0},
+	} {
+		got, err := QueryAllLimited(doc, MustParseGroup(t, test.sel), test.limits)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s with %+v: got error %v, want ErrLimitExceeded", test.sel, test.limits, err)
+		}
+		if len(got) != test.partial {
+			t.Errorf("%s with %+v: got %d nodes, want %d", test.sel, test.limits, len(got), test.partial)
+		}
+	}
+
+	if n, err := QueryLimited(doc, MustParseGroup(t, "li"), Limits{MaxNodes: 8}); err != nil || n == nil {
+		t.Errorf("QueryLimited(li) = %v, %v", n, err)
+	}
+	if _, err := QueryLimited(doc, MustParseGroup(t, "p"), Limits{MaxNodes: 20}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("QueryLimited(p): got error %v, want ErrLimitExceeded", err)
+	}
+}
+
+func TestHasDepth(t *testing.T) {
+	page := strings.Repeat("<div>", 200) + "<p>deep</p>" + strings.Repeat("</div>", 200)
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(depth int) SelectorGroup {
+		g, err := ParseGroupWithOptions("div:has(p)", ParseOptions{MaxHasDepth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+
+	for _, test := range []struct {
+		parseDepth, queryDepth int
+		want                   int
+	}{
+		{0, 0, 200},
+		{1, 0, 1},
+		{3, 0, 3},
+		{0, 3, 3},
+		{5, 3, 3},
+		{2, 3, 2},
+	} {
+		m := parse(test.parseDepth)
+		got, err := QueryAllLimited(doc, m, Limits{MaxHasDepth: test.queryDepth})
+		if err != nil {
+			t.Errorf("parse depth %d, query depth %d: %v", test.parseDepth, test.queryDepth, err)
+		}
+		if len(got) != test.want {
+			t.Errorf("parse depth %d, query depth %d: got %d nodes, want %d", test.parseDepth, test.queryDepth, len(got), test.want)
+		}
+		if test.queryDepth == 0 {
+			if got := QueryAll(doc, m); len(got) != test.want {
+				t.Errorf("QueryAll with parse depth %d: got %d nodes, want %d", test.parseDepth, len(got), test.want)
+			}
+		}
+	}
+
+	if got, want := parse(3).String(), "div:has(p)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+package cascadia
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html/atom"
+)
+
+// A LintWarning describes something suspicious in a selector: it is valid,
+// but probably doesn't do what was intended, or could be simpler.
+type LintWarning struct {
+	// Check is a short name for the kind of problem, such as
+	// "unknown-element". The checks are listed in the documentation for
+	// Lint.
+	Check string
+
+	// Selector is the part of the selector with the problem.
+	Selector string
+
+	Message string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("%s: %s (%s)", w.Selector, w.Message, w.Check)
+}
+
+// Lint parses sel (which may be a group of selectors, and may contain
+// pseudo-elements), and returns warnings about the parts of it that are
+// suspicious. It returns an error if sel can't be parsed.
+//
+// The checks are:
+//
+//   - unknown-element: a type selector for a name that isn't an HTML, SVG
+//     or MathML element, and isn't a custom element (which must contain a
+//     hyphen).
+//   - redundant-universal: a * followed by other simple selectors, as in
+//     *.item, where it has no effect.
+//   - qualified-id: an ID selector combined with a type, class or
+//     attribute selector. IDs are unique, so the extra conditions only make
+//     the selector slower and more fragile.
+//   - root-descendant: a selector starting with ":root " or "html ", which
+//     is true of every element but the root.
+//   - generic-chain: three or more bare type selectors joined by descendant
+//     combinators, with a repeated name, like "div div div", which usually
+//     matches far more than intended.
+//   - never-matches: a pseudo-class that depends on user interaction, like
+//     :hover, which never matches in a static document.
+//   - pseudo-element: pseudo-elements are ignored when matching, and are
+//     a syntax error unless ParseOptions.PseudoElements is set.
+func Lint(sel string) ([]LintWarning, error) {
+	g, err := ParseGroupWithPseudoElements(sel)
+	if err != nil {
+		return nil, err
+	}
+	l := new(linter)
+	l.universals(sel)
+	for _, s := range g {
+		l.selector(s)
+	}
+	return l.warnings, nil
+}
+
+type linter struct {
+	warnings []LintWarning
+}
+
+// warn records a warning about the part of the selector written as text.
+func (l *linter) warn(check string, text string, format string, args ...interface{}) {
+	l.warnings = append(l.warnings, LintWarning{
+		Check:    check,
+		Selector: text,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// universals looks for redundant universal selectors in the source text,
+// since the parser discards them.
+func (l *linter) universals(sel string) {
+	inBrackets := false
+	var quote byte
+	for i := 0; i < len(sel); i++ {
+		c := sel[i]
+		switch {
+		case c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case c == '*' && !inBrackets && i+1 < len(sel):
+			switch sel[i+1] {
+			case '.', '#', '[', ':':
+				end := i + 1
+				for end < len(sel) && !strings.ContainsRune(" \t\n\r\f,>+~()", rune(sel[end])) {
+					end++
+				}
+				l.warn("redundant-universal", sel[i:end], "the * is unnecessary")
+			}
+		}
+	}
+}
+
+// steps returns the compound selectors in s, from left to right, and the
+// combinators before each one (0 for the first).
+func steps(s Sel) (compounds []Sel, combinators []byte) {
+	if b, ok := s.(Builder); ok {
+		s = b.Sel()
+	}
+	c, ok := s.(combinedSelector)
+	if !ok {
+		return []Sel{s}, []byte{0}
+	}
+	if c.second == nil {
+		return steps(c.first)
+	}
+	compounds, combinators = steps(c.first)
+	return append(compounds, c.second), append(combinators, c.combinator)
+}
+
+// simpleSelectors returns the simple selectors that make up a compound
+// selector.
+func simpleSelectors(s Sel) []Sel {
+	if c, ok := s.(compoundSelector); ok {
+		return c.selectors
+	}
+	return []Sel{s}
+}
+
+func (l *linter) selector(s Sel) {
+	compounds, combinators := steps(s)
+
+	if len(compounds) > 1 && combinators[1] == ' ' {
+		parts := simpleSelectors(compounds[0])
+		if len(parts) == 1 {
+			_, isRoot := parts[0].(rootPseudoClassSelector)
+			if t, ok := parts[0].(tagSelector); isRoot || ok && t.tag == "html" {
+				l.warn("root-descendant", ToAST(s).String(), "%s matches every element except the root; it can be left out", compounds[0])
+			}
+		}
+	}
+
+	chain, repeated := 0, false
+	seen := make(map[string]bool)
+	for i, c := range compounds {
+		var tag string
+		if parts := simpleSelectors(c); len(parts) == 1 {
+			if t, ok := parts[0].(tagSelector); ok {
+				tag = t.tag
+			}
+		}
+		if tag == "" || i > 0 && combinators[i] != ' ' {
+			if chain >= 3 && repeated {
+				break
+			}
+			chain, repeated = 0, false
+			seen = make(map[string]bool)
+			if tag == "" {
+				continue
+			}
+		}
+		chain++
+		if seen[tag] {
+			repeated = true
+		}
+		seen[tag] = true
+	}
+	if chain >= 3 && repeated {
+		l.warn("generic-chain", ToAST(s).String(), "a chain of generic descendant selectors matches more than intended, and is slow to match")
+	}
+
+	for _, c := range compounds {
+		l.compound(c)
+	}
+}
+
+func (l *linter) compound(s Sel) {
+	if c, ok := s.(compoundSelector); ok && c.pseudoElement != "" {
+		l.warn("pseudo-element", c.String(), "the pseudo-element ::%s is ignored when matching", c.pseudoElement)
+	}
+
+	parts := simpleSelectors(s)
+	hasID, qualified := false, false
+	for _, part := range parts {
+		switch p := part.(type) {
+		case idSelector:
+			hasID = true
+		case tagSelector:
+			qualified = true
+			if !knownElement(p.tag) {
+				l.warn("unknown-element", p.tag, "<%s> is not a known element", p.tag)
+			}
+		case classSelector, attrSelector:
+			qualified = true
+		case neverMatchSelector:
+			l.warn("never-matches", p.value, "%s never matches in a static document", p.value)
+		case relativePseudoClassSelector:
+			for _, sel := range p.match {
+				l.selector(sel)
+			}
+		}
+	}
+	if hasID && qualified {
+		l.warn("qualified-id", s.String(), "an ID selector doesn't need other qualifiers")
+	}
+}
+
+// knownElement returns whether tag is the name of an HTML, SVG or MathML
+// element, or of a custom element.
+func knownElement(tag string) bool {
+	tag = toLowerASCII(tag)
+	if atom.Lookup([]byte(tag)) != 0 || strings.Contains(tag, "-") {
+		return true
+	}
+	_, ok := svgTagNames[tag]
+	return ok || foreignElements[tag]
+}
+
+// foreignElements holds the names of the SVG and MathML elements that are
+// all lower case, and aren't HTML elements too. (svgTagNames has the
+// others.)
+var foreignElements = map[string]bool{
+	// SVG
+	"animate":  true,
+	"circle":   true,
+	"cursor":   true,
+	"defs":     true,
+	"desc":     true,
+	"discard":  true,
+	"ellipse":  true,
+	"filter":   true,
+	"g":        true,
+	"glyph":    true,
+	"hkern":    true,
+	"line":     true,
+	"marker":   true,
+	"mask":     true,
+	"metadata": true,
+	"mpath":    true,
+	"path":     true,
+	"pattern":  true,
+	"polygon":  true,
+	"polyline": true,
+	"rect":     true,
+	"set":      true,
+	"stop":     true,
+	"switch":   true,
+	"symbol":   true,
+	"text":     true,
+	"tref":     true,
+	"tspan":    true,
+	"use":      true,
+	"view":     true,
+	"vkern":    true,
+
+	// MathML
+	"annotation":    true,
+	"maction":       true,
+	"maligngroup":   true,
+	"malignmark":    true,
+	"menclose":      true,
+	"merror":        true,
+	"mfenced":       true,
+	"mfrac":         true,
+	"mmultiscripts": true,
+	"mover":         true,
+	"mpadded":       true,
+	"mphantom":      true,
+	"mprescripts":   true,
+	"mroot":         true,
+	"mrow":          true,
+	"mspace":        true,
+	"msqrt":         true,
+	"mstyle":        true,
+	"msub":          true,
+	"msubsup":       true,
+	"msup":          true,
+	"mtable":        true,
+	"mtd":           true,
+	"mtr":           true,
+	"munder":        true,
+	"munderover":    true,
+	"none":          true,
+	"semantics":     true,
+}
@@ -0,0 +1,53 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		sel    string
+		checks []string // the Check field of each warning
+		parts  []string // the Selector field of each warning
+	}{
+		{"div.item > a[href]", nil, nil},
+		{"*.a", []string{"redundant-universal"}, []string{"*.a"}},
+		{"[a*=b] *, [title='*.a']", nil, nil},
+		{"foo-bar, blink, custom", []string{"unknown-element"}, []string{"custom"}},
+		{"svg circle, rect, path, g, clipPath, linearGradient, feGaussianBlur", nil, nil},
+		{"math mrow, mfrac, mi, msubsup, annotation-xml, mrows", []string{"unknown-element"}, []string{"mrows"}},
+		{"div#main", []string{"qualified-id"}, []string{"div#main"}},
+		{"#main:first-child", nil, nil},
+		{":root p", []string{"root-descendant"}, []string{":root p"}},
+		{"html p", []string{"root-descendant"}, []string{"html p"}},
+		{"html > body", nil, nil},
+		{"div div div", []string{"generic-chain"}, []string{"div div div"}},
+		{"ul li a", nil, nil},
+		{"div div > p", nil, nil},
+		{"a:hover", []string{"never-matches"}, []string{":hover"}},
+		{"p::before", []string{"pseudo-element"}, []string{"p::before"}},
+		{"div:not(*.x, spam)", []string{"redundant-universal", "unknown-element"}, []string{"*.x", "spam"}},
+	}
+	for _, test := range tests {
+		warnings, err := Lint(test.sel)
+		if err != nil {
+			t.Errorf("%s: %v", test.sel, err)
+			continue
+		}
+		var checks, parts []string
+		for _, w := range warnings {
+			checks = append(checks, w.Check)
+			parts = append(parts, w.Selector)
+		}
+		if !reflect.DeepEqual(checks, test.checks) || !reflect.DeepEqual(parts, test.parts) {
+			t.Errorf("%s: got warnings %v, want checks %v for %q", test.sel, warnings, test.checks, test.parts)
+		}
+	}
+}
+
+func TestLintInvalid(t *testing.T) {
+	if _, err := Lint("p["); err == nil {
+		t.Error("Lint of an invalid selector didn't return an error")
+	}
+}
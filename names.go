@@ -0,0 +1,65 @@
+package cascadia
+
+import (
+	"sort"
+
+	"github.com/andybalholm/cascadia/ast"
+)
+
+// Names lists the identifiers that a selector refers to. Each list is
+// sorted, without duplicates.
+type Names struct {
+	Tags       []string
+	IDs        []string
+	Classes    []string
+	Attributes []string
+}
+
+// ReferencedNames returns the tag names, IDs, classes and attribute names
+// used in the type, ID, class and attribute selectors in m, which should be
+// a Sel or SelectorGroup from this package. It can be used to build
+// prefilters or indexes, or to check selectors for names that a set of
+// templates never uses.
+//
+// The names in the arguments of pseudo-classes like :not() and :has() are
+// included, even though they don't need to be present for m to match.
+// Pseudo-classes that test attributes, like :data() and :link, don't add to
+// Attributes.
+func ReferencedNames(m Matcher) Names {
+	root := ToAST(m)
+	if root == nil {
+		return Names{}
+	}
+	sets := [4]map[string]bool{{}, {}, {}, {}}
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Type:
+			sets[0][toLowerASCII(n.Name)] = true
+		case *ast.ID:
+			sets[1][n.Name] = true
+		case *ast.Class:
+			sets[2][n.Name] = true
+		case *ast.Attribute:
+			sets[3][n.Name] = true
+		}
+		return true
+	})
+	return Names{
+		Tags:       sortedKeys(sets[0]),
+		IDs:        sortedKeys(sets[1]),
+		Classes:    sortedKeys(sets[2]),
+		Attributes: sortedKeys(sets[3]),
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,33 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferencedNames(t *testing.T) {
+	for _, test := range []struct {
+		sel  string
+		want Names
+	}{
+		{"*", Names{}},
+		{"div#main.a.b", Names{Tags: []string{"div"}, IDs: []string{"main"}, Classes: []string{"a", "b"}}},
+		{"UL > li.item, li.item[data-x], a[href^=http]:not(.b, #skip)", Names{
+			Tags:       []string{"a", "li", "ul"},
+			IDs:        []string{"skip"},
+			Classes:    []string{"b", "item"},
+			Attributes: []string{"data-x", "href"},
+		}},
+		{"section:has(h1, .title) ~ p:contains(x)", Names{Tags: []string{"h1", "p", "section"}, Classes: []string{"title"}}},
+		{"p:data(x)", Names{Tags: []string{"p"}}},
+	} {
+		if got := ReferencedNames(MustParseGroup(t, test.sel)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ReferencedNames(%s) = %+v, want %+v", test.sel, got, test.want)
+		}
+	}
+
+	// Other kinds of Matcher have no syntax tree to look at.
+	if got := ReferencedNames(Trace(MustParseGroup(t, "p"), nil)); !reflect.DeepEqual(got, Names{}) {
+		t.Errorf("ReferencedNames of a traced selector = %+v", got)
+	}
+}
@@ -0,0 +1,198 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A Node is a node in a document tree. It lets selectors be matched against
+// document implementations other than golang.org/x/net/html, through
+// MatchNode, QueryNode and QueryAllNodes. The matching code works on
+// html.Nodes, so those functions convert the document into html.Nodes
+// first (see NodeTree), except for nodes made by WrapNode.
+//
+// Methods that return a Node return nil when there is no such node. Node
+// values must be comparable with ==, and the same node must always be
+// represented by equal values; implementations that use pointers satisfy
+// this.
+type Node interface {
+	Parent() Node
+	FirstChild() Node
+	NextSibling() Node
+
+	// Type returns the kind of node, using the constants from
+	// golang.org/x/net/html.
+	Type() html.NodeType
+
+	// Data returns the tag name of an element (in lowercase for HTML
+	// elements), or the content of a text or comment node.
+	Data() string
+
+	// Attr returns the attributes of an element.
+	Attr() []html.Attribute
+}
+
+// WrapNode returns a Node for n, or nil if n is nil.
+func WrapNode(n *html.Node) Node {
+	if n == nil {
+		return nil
+	}
+	return htmlNode{n}
+}
+
+// UnwrapNode returns the *html.Node that n was made from by WrapNode, or nil
+// if n is from a different document implementation.
+func UnwrapNode(n Node) *html.Node {
+	if h, ok := n.(htmlNode); ok {
+		return h.n
+	}
+	return nil
+}
+
+// htmlNode is the Node implementation for *html.Node.
+type htmlNode struct {
+	n *html.Node
+}
+
+func (h htmlNode) Parent() Node           { return WrapNode(h.n.Parent) }
+func (h htmlNode) FirstChild() Node       { return WrapNode(h.n.FirstChild) }
+func (h htmlNode) NextSibling() Node      { return WrapNode(h.n.NextSibling) }
+func (h htmlNode) Type() html.NodeType    { return h.n.Type }
+func (h htmlNode) Data() string           { return h.n.Data }
+func (h htmlNode) Attr() []html.Attribute { return h.n.Attr }
+
+func (h htmlNode) Namespace() string { return h.n.Namespace }
+
+// A NamespacedNode is a Node that reports the namespace of elements, like
+// html.Node's Namespace field: "svg" or "math" for foreign elements, and ""
+// for HTML elements. Implementing it lets selectors match the foreign
+// elements whose names keep upper-case letters, like SVG's clipPath, the
+// way they do in an html.Node tree.
+type NamespacedNode interface {
+	Node
+	Namespace() string
+}
+
+// A NodeTree is a copy of a document from another implementation, made of
+// html.Nodes so that the matching code can run on it. Making the copy takes
+// time in proportion to the size of the document, so to match many nodes in
+// the same document, make one NodeTree and use its methods, instead of
+// calling MatchNode for each node. The copy doesn't see later changes to the
+// document.
+//
+// For nodes made by WrapNode, nothing needs to be copied.
+type NodeTree struct {
+	toHTML   map[Node]*html.Node
+	fromHTML map[*html.Node]Node
+}
+
+// NewNodeTree returns a NodeTree for the whole document that n belongs to.
+// If n is nil, the tree is empty.
+func NewNodeTree(n Node) *NodeTree {
+	t := new(NodeTree)
+	if n == nil || UnwrapNode(n) != nil {
+		return t
+	}
+	root := n
+	for p := root.Parent(); p != nil; p = p.Parent() {
+		root = p
+	}
+	t.toHTML = make(map[Node]*html.Node)
+	t.fromHTML = make(map[*html.Node]Node)
+	t.copy(root, nil)
+	return t
+}
+
+func (t *NodeTree) copy(n Node, parent *html.Node) {
+	h := &html.Node{
+		Type: n.Type(),
+		Data: n.Data(),
+		Attr: n.Attr(),
+	}
+	if h.Type == html.ElementNode {
+		if ns, ok := n.(NamespacedNode); ok {
+			h.Namespace = ns.Namespace()
+		}
+		h.DataAtom = atom.Lookup([]byte(h.Data))
+	}
+	if parent != nil {
+		parent.AppendChild(h)
+	}
+	t.toHTML[n] = h
+	t.fromHTML[h] = n
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		t.copy(c, h)
+	}
+}
+
+// htmlNode returns the copy of n, or nil if n isn't in the tree.
+func (t *NodeTree) htmlNode(n Node) *html.Node {
+	if h := UnwrapNode(n); h != nil {
+		return h
+	}
+	return t.toHTML[n]
+}
+
+// node returns the original of h.
+func (t *NodeTree) node(h *html.Node) Node {
+	if t.fromHTML == nil {
+		return WrapNode(h)
+	}
+	return t.fromHTML[h]
+}
+
+// Match returns whether m matches n, which must be in the tree.
+func (t *NodeTree) Match(m Matcher, n Node) bool {
+	h := t.htmlNode(n)
+	return h != nil && m.Match(h)
+}
+
+// Query is like the Query function, for n in the tree.
+func (t *NodeTree) Query(n Node, m Matcher) Node {
+	h := t.htmlNode(n)
+	if h == nil {
+		return nil
+	}
+	if found := Query(h, m); found != nil {
+		return t.node(found)
+	}
+	return nil
+}
+
+// QueryAll is like the QueryAll function, for n in the tree.
+func (t *NodeTree) QueryAll(n Node, m Matcher) []Node {
+	h := t.htmlNode(n)
+	if h == nil {
+		return nil
+	}
+	list := QueryAll(h, m)
+	if list == nil {
+		return nil
+	}
+	result := make([]Node, len(list))
+	for i, h := range list {
+		result[i] = t.node(h)
+	}
+	return result
+}
+
+// MatchNode returns whether m matches n. It returns false if n is nil.
+//
+// If n was not made by WrapNode, the whole document containing it is copied
+// into html.Nodes first. To match many nodes in the same document, use a
+// NodeTree so that the copy is only made once.
+func MatchNode(m Matcher, n Node) bool {
+	return NewNodeTree(n).Match(m, n)
+}
+
+// QueryNode is like Query, but for any document implementation. Like
+// MatchNode, it copies the document unless n was made by WrapNode.
+func QueryNode(n Node, m Matcher) Node {
+	return NewNodeTree(n).Query(n, m)
+}
+
+// QueryAllNodes is like QueryAll, but for any document implementation. Like
+// MatchNode, it copies the document unless n was made by WrapNode.
+func QueryAllNodes(n Node, m Matcher) []Node {
+	return NewNodeTree(n).QueryAll(n, m)
+}
@@ -0,0 +1,138 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// testNode is a minimal document implementation, to check that selectors
+// work on DOMs other than golang.org/x/net/html.
+type testNode struct {
+	parent, firstChild, nextSibling *testNode
+	typ                             html.NodeType
+	data, namespace                 string
+	attr                            []html.Attribute
+}
+
+func (n *testNode) Parent() Node           { return n.parent.node() }
+func (n *testNode) FirstChild() Node       { return n.firstChild.node() }
+func (n *testNode) NextSibling() Node      { return n.nextSibling.node() }
+func (n *testNode) Type() html.NodeType    { return n.typ }
+func (n *testNode) Data() string           { return n.data }
+func (n *testNode) Attr() []html.Attribute { return n.attr }
+func (n *testNode) Namespace() string      { return n.namespace }
+
+// node avoids returning a non-nil Node holding a nil pointer.
+func (n *testNode) node() Node {
+	if n == nil {
+		return nil
+	}
+	return n
+}
+
+func newTestTree(h *html.Node, parent *testNode) *testNode {
+	n := &testNode{parent: parent, typ: h.Type, data: h.Data, namespace: h.Namespace, attr: h.Attr}
+	var last *testNode
+	for c := h.FirstChild; c != nil; c = c.NextSibling {
+		child := newTestTree(c, n)
+		if last == nil {
+			n.firstChild = child
+		} else {
+			last.nextSibling = child
+		}
+		last = child
+	}
+	return n
+}
+
+func TestNodeInterface(t *testing.T) {
+	const doc = `<div id="a"><h1>Title</h1><p class="x">one</p><p>two</p><ul><li>a</li><li>b</li></ul></div>`
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := newTestTree(root, nil)
+
+	for _, sel := range []string{"p", "h1 + p", "div > p:last-of-type", "li:first", "#a :not(p)", "body *:empty"} {
+		m := MustParseGroup(t, sel)
+		want := QueryAll(root, m)
+		got := QueryAllNodes(tree, m)
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d nodes, want %d", sel, len(got), len(want))
+			continue
+		}
+		for i, n := range got {
+			tn := n.(*testNode)
+			if tn.data != want[i].Data || len(tn.attr) != len(want[i].Attr) {
+				t.Errorf("%s: result %d is <%s>, want %s", sel, i, tn.data, nodeString(want[i]))
+			}
+		}
+
+		wrapped := QueryAllNodes(WrapNode(root), m)
+		if len(wrapped) != len(want) {
+			t.Errorf("%s: got %d wrapped nodes, want %d", sel, len(wrapped), len(want))
+			continue
+		}
+		for i, n := range wrapped {
+			if UnwrapNode(n) != want[i] {
+				t.Errorf("%s: wrapped result %d is %s, want %s", sel, i, nodeString(UnwrapNode(n)), nodeString(want[i]))
+			}
+		}
+	}
+
+	p := QueryNode(tree, MustParse(t, "p.x"))
+	if p == nil {
+		t.Fatal("QueryNode did not find p.x")
+	}
+	if !MatchNode(MustParse(t, "h1 ~ p"), p) {
+		t.Error("h1 ~ p does not match p.x")
+	}
+	if MatchNode(MustParse(t, "li p"), p) {
+		t.Error("li p matches p.x")
+	}
+	if QueryNode(tree, MustParse(t, "table")) != nil {
+		t.Error("QueryNode found a table")
+	}
+}
+
+func TestNodeTree(t *testing.T) {
+	root := MustParseHTML(`<p id=a>x</p><svg><clipPath id=c><rect viewBox="0 0 1 1"/></clipPath></svg><p id=b>y</p>`)
+	tree := newTestTree(root, nil)
+	nt := NewNodeTree(tree)
+
+	for _, test := range []struct {
+		sel  string
+		want int
+	}{
+		{"clipPath", 1},
+		{"[viewBox]", 1},
+		{"svg > clipPath > rect", 1},
+		{"p", 2},
+	} {
+		m := MustParseGroup(t, test.sel)
+		if got := len(QueryAllNodes(tree, m)); got != test.want {
+			t.Errorf("QueryAllNodes(%s) found %d nodes, want %d", test.sel, got, test.want)
+		}
+		matches := nt.QueryAll(tree, m)
+		if len(matches) != test.want {
+			t.Errorf("NodeTree.QueryAll(%s) found %d nodes, want %d", test.sel, len(matches), test.want)
+		}
+		for _, n := range matches {
+			if !nt.Match(m, n) || !MatchNode(m, n) {
+				t.Errorf("%s doesn't match a node that QueryAll returned", test.sel)
+			}
+		}
+	}
+
+	if nt.Match(MustParse(t, "p"), &testNode{typ: html.ElementNode, data: "p"}) {
+		t.Error("a node from another document matched")
+	}
+	if MatchNode(MustParse(t, "*"), nil) || QueryNode(nil, MustParse(t, "*")) != nil || QueryAllNodes(nil, MustParse(t, "*")) != nil {
+		t.Error("a selector matched a nil node")
+	}
+	if nt.Query(tree, MustParse(t, "#b")).(*testNode).attr[0].Val != "b" {
+		t.Error("NodeTree.Query found the wrong node")
+	}
+}
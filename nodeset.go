@@ -0,0 +1,120 @@
+package cascadia
+
+import (
+	"sort"
+
+	"golang.org/x/net/html"
+)
+
+// Union returns the nodes that are in any of sets, in document order,
+// without duplicates.
+func Union(sets ...[]*html.Node) []*html.Node {
+	seen := make(map[*html.Node]bool)
+	var result []*html.Node
+	for _, set := range sets {
+		for _, n := range set {
+			if !seen[n] {
+				seen[n] = true
+				result = append(result, n)
+			}
+		}
+	}
+	sortDocumentOrder(result)
+	return result
+}
+
+// Intersect returns the nodes that are in both a and b, in document order,
+// without duplicates.
+func Intersect(a, b []*html.Node) []*html.Node {
+	inB := make(map[*html.Node]bool, len(b))
+	for _, n := range b {
+		inB[n] = true
+	}
+	var result []*html.Node
+	for _, n := range a {
+		if inB[n] {
+			result = append(result, n)
+			delete(inB, n)
+		}
+	}
+	sortDocumentOrder(result)
+	return result
+}
+
+// Difference returns the nodes that are in a but not in b, in document order,
+// without duplicates.
+func Difference(a, b []*html.Node) []*html.Node {
+	exclude := make(map[*html.Node]bool, len(b))
+	for _, n := range b {
+		exclude[n] = true
+	}
+	var result []*html.Node
+	for _, n := range a {
+		if !exclude[n] {
+			result = append(result, n)
+			exclude[n] = true
+		}
+	}
+	sortDocumentOrder(result)
+	return result
+}
+
+// sortDocumentOrder sorts nodes into document order. Nodes from different
+// trees are kept together, with the trees in the order they are first seen.
+func sortDocumentOrder(nodes []*html.Node) {
+	type position struct {
+		tree int
+		path []int // the index of each ancestor among its siblings, from the root down
+	}
+	trees := make(map[*html.Node]int)
+	positions := make(map[*html.Node]position, len(nodes))
+
+	// siblingIndex returns the index of n among its parent's children. The
+	// children of each parent are numbered all at once, the first time one
+	// of them is needed, so that wide parents aren't counted again for
+	// every node.
+	indexes := make(map[*html.Node]int)
+	siblingIndex := func(n *html.Node) int {
+		i, ok := indexes[n]
+		if !ok {
+			j := 0
+			for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+				indexes[c] = j
+				j++
+			}
+			i = indexes[n]
+		}
+		return i
+	}
+
+	for _, n := range nodes {
+		var path []int
+		root := n
+		for ; root.Parent != nil; root = root.Parent {
+			path = append(path, siblingIndex(root))
+		}
+		for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
+		}
+		tree, ok := trees[root]
+		if !ok {
+			tree = len(trees)
+			trees[root] = tree
+		}
+		positions[n] = position{tree, path}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := positions[nodes[i]], positions[nodes[j]]
+		if a.tree != b.tree {
+			return a.tree < b.tree
+		}
+		for k := 0; k < len(a.path) && k < len(b.path); k++ {
+			if a.path[k] != b.path[k] {
+				return a.path[k] < b.path[k]
+			}
+		}
+		// An ancestor comes before its descendants.
+		return len(a.path) < len(b.path)
+	})
+}
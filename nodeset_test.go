@@ -0,0 +1,57 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestNodeSets(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id=1 class=a><p id=2 class=a></p><p id=3></p></div><p id=4 class=a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := html.Parse(strings.NewReader(`<p id=5>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := func(sel string) []*html.Node {
+		return QueryAll(doc, MustParseGroup(t, sel))
+	}
+	ids := func(nodes []*html.Node) []string {
+		result := []string{}
+		for _, n := range nodes {
+			result = append(result, n.Attr[0].Val)
+		}
+		return result
+	}
+	reversed := func(nodes []*html.Node) []*html.Node {
+		var result []*html.Node
+		for i := len(nodes) - 1; i >= 0; i-- {
+			result = append(result, nodes[i])
+		}
+		return result
+	}
+
+	for _, test := range []struct {
+		name string
+		got  []*html.Node
+		want []string
+	}{
+		{"Union", Union(query("p"), query(".a")), []string{"1", "2", "3", "4"}},
+		{"Union reversed", Union(reversed(query("p")), reversed(query("div"))), []string{"1", "2", "3", "4"}},
+		{"Union duplicates", Union(append(query("#2"), query("#2")...)), []string{"2"}},
+		{"Union empty", Union(), []string{}},
+		{"Union trees", Union(query("#4"), QueryAll(other, MustParseGroup(t, "p")), query("#1")), []string{"1", "4", "5"}},
+		{"Intersect", Intersect(reversed(query("p")), query(".a")), []string{"2", "4"}},
+		{"Intersect none", Intersect(query("div"), query("p")), []string{}},
+		{"Difference", Difference(reversed(query(".a")), query("p")), []string{"1"}},
+		{"Difference all", Difference(query("p"), query("*")), []string{}},
+	} {
+		if got := ids(test.got); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
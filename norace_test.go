@@ -0,0 +1,7 @@
+//go:build !race
+
+package cascadia
+
+// raceEnabled is true in builds with the race detector, which makes
+// allocation counts unreliable.
+const raceEnabled = false
@@ -0,0 +1,154 @@
+package cascadia
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+)
+
+// Normalize returns a canonical form of s, so that selectors that are
+// written differently but have the same meaning compare equal: the simple
+// selectors in each compound selector are sorted (with the type selector
+// first), and so are the selector lists in pseudo-classes like :not(),
+// without duplicates. Tag names are lowercase.
+//
+// Repeated simple selectors in a compound selector, like the second .a in
+// p.a.a, are kept, since they count towards the specificity.
+//
+// Result-set pseudo-classes like :eq() are kept in their original order,
+// since it affects which elements they select.
+func Normalize(s Sel) Sel {
+	switch s := s.(type) {
+	case Builder:
+		return Normalize(s.Sel())
+	case tagSelector:
+		tag := toLowerASCII(s.tag)
+		return tagSelector{tag: tag, name: svgTagNames[tag]}
+	case compoundSelector:
+		return normalizeCompound(s)
+	case combinedSelector:
+		s.first = Normalize(s.first)
+		if s.second != nil {
+			s.second = Normalize(s.second)
+		}
+		return s
+	case relativePseudoClassSelector:
+		s.match = normalizeGroup(s.match)
+		return s
+	}
+	return s
+}
+
+// Equal returns whether a and b are the same selector, after they are
+// normalized: whether they have the same Key. Selectors are compared by
+// their text rather than their structure, since they hold caches, like
+// compiled regular expressions, that don't affect their meaning.
+func Equal(a, b Sel) bool {
+	return Key(a) == Key(b)
+}
+
+// Key returns a canonical string for s: the String of its normalized form.
+// Selectors that are Equal have the same key, so it can be used to
+// deduplicate selectors, or as a map key.
+//
+// Attribute selectors parsed with StrictAttributes match differently, but
+// their String is the same, so if s has any, the key ends with a comment
+// that lists which attribute selectors are strict.
+func Key(s Sel) string {
+	return selectorKey(Normalize(s))
+}
+
+// selectorKey returns the String of s, followed by a comment listing its strict
+// attribute selectors if it has any.
+func selectorKey(s Sel) string {
+	flags := strictFlags(nil, s)
+	if bytes.IndexByte(flags, '1') == -1 {
+		return s.String()
+	}
+	return s.String() + " /* strict attributes: " + string(flags) + " */"
+}
+
+// strictFlags appends a 1 or 0 to b for each attribute selector in s, in the
+// order they are serialized, telling whether it is strict.
+func strictFlags(b []byte, s Sel) []byte {
+	switch s := s.(type) {
+	case attrSelector:
+		if s.strict {
+			return append(b, '1')
+		}
+		return append(b, '0')
+	case compoundSelector:
+		for _, sel := range s.selectors {
+			b = strictFlags(b, sel)
+		}
+	case combinedSelector:
+		b = strictFlags(b, s.first)
+		if s.second != nil {
+			b = strictFlags(b, s.second)
+		}
+	case relativePseudoClassSelector:
+		for _, sel := range s.match {
+			b = strictFlags(b, sel)
+		}
+	}
+	return b
+}
+
+// Hash returns a hash of the Key for s. It is the same in every run of a
+// program, so it can be stored.
+func Hash(s Sel) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(Key(s)))
+	return h.Sum64()
+}
+
+func normalizeCompound(c compoundSelector) Sel {
+	var tag, parts, positional []Sel
+	for _, sel := range c.selectors {
+		sel = Normalize(sel)
+		switch sel.(type) {
+		case tagSelector:
+			tag = append(tag, sel)
+		case positionalPseudoClassSelector:
+			positional = append(positional, sel)
+		default:
+			parts = append(parts, sel)
+		}
+	}
+	sortSelectors(parts)
+
+	selectors := append(append(tag, parts...), positional...)
+	if len(selectors) == 1 && c.pseudoElement == "" {
+		return selectors[0]
+	}
+	return newCompoundSelector(selectors, c.pseudoElement)
+}
+
+func normalizeGroup(g SelectorGroup) SelectorGroup {
+	result := make(SelectorGroup, len(g))
+	for i, sel := range g {
+		result[i] = Normalize(sel)
+	}
+	sortSelectors(result)
+	return dedupe(result)
+}
+
+// sortSelectors sorts sels by their string form.
+func sortSelectors(sels []Sel) {
+	sort.SliceStable(sels, func(i, j int) bool {
+		return selectorKey(sels[i]) < selectorKey(sels[j])
+	})
+}
+
+// dedupe removes repeated selectors from a sorted list, and returns the
+// shortened slice.
+func dedupe(sorted SelectorGroup) SelectorGroup {
+	result := sorted[:0]
+	for _, sel := range sorted {
+		if len(result) > 0 && selectorKey(sel) == selectorKey(result[len(result)-1]) {
+			continue
+		}
+		result = append(result, sel)
+	}
+	return result
+}
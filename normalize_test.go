@@ -0,0 +1,118 @@
+package cascadia
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"div.a.b", "div.b.a", true},
+		{".b#x.a", "#x.a.b", true},
+		{"p.a.a", "p.a", false},
+		{"p.a.b.a", "p.b.a.a", true},
+		{"a[href][title]", "a[title][href]", true},
+		{"ul   >   li.x:first-child", "ul > li:first-child.x", true},
+		{"DIV.a", "div.a", true},
+		{":not(.b, .a, .a)", ":not(.a, .b)", true},
+		{"div:has(p.y.x) span", "div:has(p.x.y) span", true},
+		{"p.a::before", "p.a::before", true},
+		{"li:gt(0):lt(1)", "li:lt(1):gt(0)", false},
+		{"li.a:eq(1)", "li:eq(1).a", true},
+		{"div.a", "div.A", false},
+		{"div > p", "div p", false},
+		{"a.b c", "a c.b", false},
+	} {
+		a, err := ParseWithPseudoElement(test.a)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.a, err)
+		}
+		b, err := ParseWithPseudoElement(test.b)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.b, err)
+		}
+		if got := Equal(a, b); got != test.equal {
+			t.Errorf("Equal(%s, %s) = %v, want %v", test.a, test.b, got, test.equal)
+		}
+	}
+
+	// Normalizing doesn't change the meaning.
+	for _, test := range append(append([]selectorTest(nil), selectorTests...), positionalTests...) {
+		if skipRegexp(test.selector) {
+			continue
+		}
+		s, doc, err := setupMatcher(test.selector, test.HTML)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var normalized SelectorGroup
+		for _, sel := range s.(SelectorGroup) {
+			normalized = append(normalized, Normalize(sel))
+		}
+		if got, want := len(QueryAll(doc, normalized)), len(test.results); got != want {
+			t.Errorf("normalized %s (%s) found %d nodes, want %d", test.selector, normalized, got, want)
+		}
+		if again := Normalize(normalized[0]); !Equal(again, normalized[0]) || again.String() != normalized[0].String() {
+			t.Errorf("normalizing %s twice changed it", test.selector)
+		}
+	}
+
+	if got := Normalize(Tag("p").Class("b").Class("a")).String(); got != "p.a.b" {
+		t.Errorf("normalizing a Builder gave %s", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, test := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"p.b.a", "P.a.b", true},
+		{"div:not(.b, .a) > p", "div:not(.a,.b)>p", true},
+		{"p.a", "p.a.a", false},
+		{"p", "div", false},
+		{"ul li", "ul > li", false},
+	} {
+		a, b := MustParse(t, test.a), MustParse(t, test.b)
+		if got := Key(a) == Key(b); got != test.equal {
+			t.Errorf("Key(%s) = %q, Key(%s) = %q; want equal: %v", test.a, Key(a), test.b, Key(b), test.equal)
+		}
+		if got := Hash(a) == Hash(b); got != test.equal {
+			t.Errorf("Hash(%s) == Hash(%s): %v, want %v", test.a, test.b, got, test.equal)
+		}
+	}
+
+	// The fields that newCompoundSelector precomputes for matching don't
+	// take part in comparisons.
+	plain := compoundSelector{selectors: []Sel{tagSelector{tag: "p"}, attrSelector{key: "y"}, attrSelector{key: "x"}}}
+	if parsed := MustParse(t, "p[x][y]"); !Equal(plain, parsed) || Key(plain) != Key(parsed) {
+		t.Errorf("%s without precomputed fields isn't equal to %s", plain, parsed)
+	}
+	if got := Optimize(compoundSelector{selectors: []Sel{plain, MustParse(t, "p[y]")}}).String(); got != "p[y][x]" {
+		t.Errorf("Optimize kept repeated parts: %s", got)
+	}
+
+	// StrictAttributes isn't part of the text, but it changes the meaning.
+	loose := MustParse(t, `p:not([a^=""])`)
+	strict, err := ParseWithOptions(`p:not([a^=""])`, ParseOptions{StrictAttributes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Equal(loose, strict) || Hash(loose) == Hash(strict) {
+		t.Errorf("strict and loose %s are equal: key %q", loose, Key(strict))
+	}
+	if !Equal(strict, Normalize(strict)) {
+		t.Errorf("%s isn't equal to its normalized form", strict)
+	}
+	if got := Optimize(compoundSelector{selectors: []Sel{loose, strict}}); Equal(got, loose) {
+		t.Errorf("Optimize merged strict and loose selectors: %s", got)
+	}
+
+	// The hash must not change between versions or runs. This is FNV-1a of "p".
+	if got, want := Hash(MustParse(t, "p")), uint64(0xaf63ed4c8602096f); got != want {
+		t.Errorf("Hash(p) = %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package cascadia
+
+// Optimize returns a simplified version of s that matches the same
+// elements, but may be faster to match:
+//
+//   - :not(:not(x)) becomes x, when x is a compound selector.
+//   - Repeated simple selectors in a compound selector, like the second .a
+//     in p.a.a, are removed.
+//   - Compound selectors nested inside other compound selectors are merged
+//     into them, and empty ones (the universal selector) are dropped there.
+//   - Selectors that can never match anything, like div#a#b or p:hover, are
+//     replaced by a placeholder that doesn't match any element, without
+//     testing the rest of the selector. Selectors that can't match are
+//     also removed from lists in pseudo-classes like :not().
+//
+// The specificity of the optimized selector may be lower than the original.
+// The placeholder for a selector that can never match keeps the original
+// text as its String.
+func Optimize(s Sel) Sel {
+	switch s := s.(type) {
+	case Builder:
+		return Optimize(s.Sel())
+	case compoundSelector:
+		return optimizeCompound(s)
+	case combinedSelector:
+		s.first = Optimize(s.first)
+		if s.second != nil {
+			s.second = Optimize(s.second)
+		}
+		if neverMatches(s.first) || s.second != nil && neverMatches(s.second) {
+			return neverMatchSelector{value: s.String()}
+		}
+		return s
+	case relativePseudoClassSelector:
+		original := s.String()
+		s.match = optimizeGroup(s.match)
+		if len(s.match) == 1 && neverMatches(s.match[0]) {
+			if s.name == "not" {
+				// It matches every element.
+				return compoundSelector{}
+			}
+			return neverMatchSelector{value: original}
+		}
+		if s.name == "not" && len(s.match) == 1 {
+			if inner, ok := s.match[0].(relativePseudoClassSelector); ok && inner.name == "not" && len(inner.match) == 1 {
+				switch x := inner.match[0].(type) {
+				case combinedSelector:
+				case compoundSelector:
+					if x.pseudoElement == "" {
+						return x
+					}
+				default:
+					return x
+				}
+			}
+		}
+		return s
+	}
+	return s
+}
+
+// optimizeGroup optimizes each selector in g, and removes the ones that
+// can never match, unless none of them can.
+func optimizeGroup(g SelectorGroup) SelectorGroup {
+	var result SelectorGroup
+	for _, sel := range g {
+		sel = Optimize(sel)
+		if neverMatches(sel) {
+			continue
+		}
+		result = append(result, sel)
+	}
+	if len(result) == 0 && len(g) > 0 {
+		return SelectorGroup{Optimize(g[0])}
+	}
+	return result
+}
+
+func optimizeCompound(c compoundSelector) Sel {
+	var parts []Sel
+	for _, sel := range c.selectors {
+		sel = Optimize(sel)
+		if inner, ok := sel.(compoundSelector); ok && inner.pseudoElement == "" {
+			// It has already been optimized, so its parts aren't compound
+			// selectors.
+			parts = append(parts, inner.selectors...)
+			continue
+		}
+		parts = append(parts, sel)
+	}
+
+	var selectors []Sel
+	for _, sel := range parts {
+		// Applying a result-set pseudo-class twice can give a different
+		// result from applying it once, so those aren't removed.
+		if _, ok := sel.(positionalPseudoClassSelector); !ok && containsSel(selectors, sel) {
+			continue
+		}
+		selectors = append(selectors, sel)
+	}
+
+	if contradictory(selectors) {
+		return neverMatchSelector{value: c.String()}
+	}
+	if len(selectors) == 1 && c.pseudoElement == "" {
+		return selectors[0]
+	}
+	return newCompoundSelector(selectors, c.pseudoElement)
+}
+
+// contradictory returns whether no element can match all of sels.
+func contradictory(sels []Sel) bool {
+	var tag, id *string
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case neverMatchSelector:
+			return true
+		case tagSelector:
+			if tag != nil && *tag != sel.tag {
+				return true
+			}
+			tag = &sel.tag
+		case idSelector:
+			if id != nil && *id != sel.id {
+				return true
+			}
+			id = &sel.id
+		}
+	}
+	return false
+}
+
+func containsSel(list []Sel, s Sel) bool {
+	key := selectorKey(s)
+	for _, sel := range list {
+		if selectorKey(sel) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func neverMatches(s Sel) bool {
+	_, ok := s.(neverMatchSelector)
+	return ok
+}
@@ -0,0 +1,71 @@
+package cascadia
+
+import (
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	for _, test := range []struct {
+		sel, want string
+		never     bool
+	}{
+		{"p.a.a", "p.a", false},
+		{"p.a.b.a", "p.a.b", false},
+		{"li:gt(0):gt(0)", "li:gt(0):gt(0)", false},
+		{":not(:not(.a))", ".a", false},
+		{"p:not(:not(.a.b))", "p.a.b", false},
+		{"p:not(:not(.a, .b))", "p:not(:not(.a, .b))", false},
+		{"p:not(:not(div .a))", "p:not(:not(div .a))", false},
+		{"p:not(:not(:not(.a)))", "p:not(.a)", false},
+		{"div#a#b", "div#a#b", true},
+		{"div#a#a", "div#a", false},
+		{"p:hover span", "p:hover span", true},
+		{"ul > li:hover", "ul > li:hover", true},
+		{"p:not(:hover)", "p", false},
+		{":not(:hover, .a)", ":not(.a)", false},
+		{"div:has(p:visited, span)", "div:has(span)", false},
+		{"div:has(p:visited)", "div:has(p:visited)", true},
+		{"p:not(span#a#b)", "p", false},
+	} {
+		s, err := Parse(test.sel)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.sel, err)
+		}
+		opt := Optimize(s)
+		if got, want := opt.String(), MustParse(t, test.want).String(); got != want {
+			t.Errorf("Optimize(%s) = %s, want %s", test.sel, got, test.want)
+		}
+		if got, want := MustParse(t, ToAST(opt).String()).String(), opt.String(); got != want {
+			t.Errorf("ToAST(Optimize(%s)) = %s, want %s", test.sel, got, want)
+		}
+		if _, got := opt.(neverMatchSelector); got != test.never {
+			t.Errorf("Optimize(%s): never-matching is %v, want %v", test.sel, got, test.never)
+		}
+	}
+
+	// Optimizing doesn't change which elements match.
+	for _, test := range append(append([]selectorTest(nil), selectorTests...), positionalTests...) {
+		if skipRegexp(test.selector) {
+			continue
+		}
+		s, err := ParseGroupWithOptions(test.selector, ParseOptions{Optimize: true})
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.selector, err)
+		}
+		_, doc, err := setupMatcher(test.selector, test.HTML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(QueryAll(doc, s)), len(test.results); got != want {
+			t.Errorf("optimized %s (%s) found %d nodes, want %d", test.selector, s, got, want)
+		}
+	}
+
+	g, err := ParseGroupWithOptions("a:hover, p.x.x, #a#b", ParseOptions{Optimize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := g.String(); got != "p.x" {
+		t.Errorf("optimized group is %s, want p.x", got)
+	}
+}
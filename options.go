@@ -0,0 +1,48 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MatchOptions controls optional matching features. The zero value gives
+// the same behavior as Match, Query and QueryAll.
+type MatchOptions struct {
+	// InertTemplates treats the content of <template> elements as inert,
+	// as browsers do. The html package parses the content as the
+	// template's children, but browsers keep it in a separate document
+	// fragment, so querySelectorAll doesn't find it. With this option,
+	// queries don't search inside templates, :has() doesn't look inside
+	// them, and descendant and child combinators don't look past a
+	// template to its ancestors.
+	InertTemplates bool
+}
+
+// MatchWithOptions returns whether m matches n, with the options in opts.
+func MatchWithOptions(m Matcher, n *html.Node, opts MatchOptions) bool {
+	return (&matchContext{inertTemplates: opts.InertTemplates}).match(m, n)
+}
+
+// QueryAllWithOptions is like QueryAll, but it matches with the options in
+// opts.
+func QueryAllWithOptions(n *html.Node, m Matcher, opts MatchOptions) []*html.Node {
+	c := newQueryContext(m)
+	c.inertTemplates = opts.InertTemplates
+	return filterResults(c, m, queryInto(c, n, dispatchMatcher(m), nil))
+}
+
+// QueryWithOptions is like Query, but it matches with the options in opts.
+func QueryWithOptions(n *html.Node, m Matcher, opts MatchOptions) *html.Node {
+	if hasPositional(m) {
+		if matches := QueryAllWithOptions(n, m, opts); len(matches) > 0 {
+			return matches[0]
+		}
+		return nil
+	}
+	return queryFirst(&matchContext{inertTemplates: opts.InertTemplates}, n, dispatchMatcher(m))
+}
+
+// isTemplate returns whether n is an HTML template element.
+func isTemplate(n *html.Node) bool {
+	return n != nil && n.Type == html.ElementNode && n.DataAtom == atom.Template && n.Namespace == ""
+}
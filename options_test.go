@@ -0,0 +1,62 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInertTemplates(t *testing.T) {
+	doc := MustParseHTML(`<div id=a><template id=t><p id=p1><span id=s1></span></p><section id=c1></section></template><p id=p2><span id=s2></span></p></div>`)
+	opts := MatchOptions{InertTemplates: true}
+	for _, test := range []struct {
+		sel        string
+		want, full []string
+	}{
+		{"p", []string{"p2"}, []string{"p1", "p2"}},
+		{"div span", []string{"s2"}, []string{"s1", "s2"}},
+		{"template", []string{"t"}, []string{"t"}},
+		{"template > p", nil, []string{"p1"}},
+		{"div:has(section)", nil, []string{"a"}},
+		{"template:haschild(p)", nil, []string{"t"}},
+		{"p:first-child, section", nil, []string{"p1", "c1"}},
+		{"p:last", []string{"p2"}, []string{"p2"}},
+	} {
+		s := MustParseGroup(t, test.sel)
+		var got []string
+		for _, n := range QueryAllWithOptions(doc, s, opts) {
+			got = append(got, getId(n))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.sel, got, test.want)
+		}
+		var sessionGot []string
+		for _, n := range NewSessionWithOptions(opts).QueryAll(doc, s) {
+			sessionGot = append(sessionGot, getId(n))
+		}
+		if !reflect.DeepEqual(sessionGot, test.want) {
+			t.Errorf("%s in a session: got %q, want %q", test.sel, sessionGot, test.want)
+		}
+		first := QueryWithOptions(doc, s, opts)
+		if len(test.want) == 0 && first != nil || len(test.want) > 0 && (first == nil || getId(first) != test.want[0]) {
+			t.Errorf("QueryWithOptions(%s) returned the wrong node", test.sel)
+		}
+
+		got = nil
+		for _, n := range QueryAllWithOptions(doc, s, MatchOptions{}) {
+			got = append(got, getId(n))
+		}
+		if !reflect.DeepEqual(got, test.full) {
+			t.Errorf("%s without InertTemplates: got %q, want %q", test.sel, got, test.full)
+		}
+	}
+
+	// Matching a node inside a template directly still works, but its
+	// ancestors outside the template are hidden.
+	s1 := Query(doc, MustParseGroup(t, "#s1"))
+	if !MatchWithOptions(MustParseGroup(t, "p > span"), s1, opts) {
+		t.Error("p > span should match inside the template")
+	}
+	if MatchWithOptions(MustParseGroup(t, "div span"), s1, opts) {
+		t.Error("div span should not see the template's ancestors")
+	}
+}
@@ -4,19 +4,43 @@ package cascadia
 import (
 	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 )
 
+// checkLength returns an error if the source text is longer than
+// opts.MaxLength, so that an overly long selector is rejected before any
+// work is done on it.
+func (p *parser) checkLength() error {
+	if p.opts.MaxLength > 0 && len(p.s) > p.opts.MaxLength {
+		return fmt.Errorf("selector is too long (%d bytes, maximum %d)", len(p.s), p.opts.MaxLength)
+	}
+	return nil
+}
+
 // a parser for CSS selectors
 type parser struct {
 	s string // the source text
 	i int    // the current position
 
-	// if `false`, parsing a pseudo-element
-	// returns an error.
-	acceptPseudoElements bool
+	opts ParseOptions // optional features
+
+	// the nesting depth of functional pseudo-classes like :not()
+	depth int
+
+	// whether the parser is inside the argument of :has() or :haschild()
+	inHas bool
+
+	// the number of escapes parsed so far, for opts.MaxEscapes
+	escapes int
+
+	// noCloseAfter, if it isn't zero, is an index in s after which there is
+	// no "*/", so a comment that starts after it can't be closed.
+	noCloseAfter int
+
+	// validateOnly means the selectors won't be used, so regular
+	// expressions are checked without being compiled.
+	validateOnly bool
 }
 
 // parseEscape parses a backslash escape.
@@ -24,6 +48,10 @@ func (p *parser) parseEscape() (result string, err error) {
 	if len(p.s) < p.i+2 || p.s[p.i] != '\\' {
 		return "", errors.New("invalid escape sequence")
 	}
+	p.escapes++
+	if p.opts.MaxEscapes > 0 && p.escapes > p.opts.MaxEscapes {
+		return "", fmt.Errorf("selector has more than %d escapes", p.opts.MaxEscapes)
+	}
 
 	start := p.i + 1
 	c := p.s[start]
@@ -124,6 +152,7 @@ func (p *parser) parseIdentifier() (result string, err error) {
 // extra restrictions on the first character).
 func (p *parser) parseName() (result string, err error) {
 	i := p.i
+	var b strings.Builder
 loop:
 	for i < len(p.s) {
 		c := p.s[i]
@@ -133,7 +162,7 @@ loop:
 			for i < len(p.s) && nameChar(p.s[i]) {
 				i++
 			}
-			result += p.s[start:i]
+			b.WriteString(p.s[start:i])
 		case c == '\\':
 			p.i = i
 			val, err := p.parseEscape()
@@ -141,12 +170,13 @@ loop:
 				return "", err
 			}
 			i = p.i
-			result += val
+			b.WriteString(val)
 		default:
 			break loop
 		}
 	}
 
+	result = b.String()
 	if result == "" {
 		return "", errors.New("expected name, found EOF instead")
 	}
@@ -164,6 +194,7 @@ func (p *parser) parseString() (result string, err error) {
 
 	quote := p.s[i]
 	i++
+	var b strings.Builder
 
 loop:
 	for i < len(p.s) {
@@ -188,7 +219,7 @@ loop:
 				return "", err
 			}
 			i = p.i
-			result += val
+			b.WriteString(val)
 		case quote:
 			break loop
 		case '\r', '\n', '\f':
@@ -201,7 +232,7 @@ loop:
 				}
 				i++
 			}
-			result += p.s[start:i]
+			b.WriteString(p.s[start:i])
 		}
 	}
 
@@ -213,12 +244,12 @@ loop:
 	i++
 
 	p.i = i
-	return result, nil
+	return b.String(), nil
 }
 
 // parseRegex parses a regular expression; the end is defined by encountering an
 // unmatched closing ')' or ']' which is not consumed
-func (p *parser) parseRegex() (rx *regexp.Regexp, err error) {
+func (p *parser) parseRegex() (rx pattern, err error) {
 	i := p.i
 	if len(p.s) < i+2 {
 		return nil, errors.New("expected regular expression, found EOF instead")
@@ -245,7 +276,11 @@ loop:
 	if i >= len(p.s) {
 		return nil, errors.New("EOF in regular expression")
 	}
-	rx, err = regexp.Compile(p.s[p.i:i])
+	if p.validateOnly {
+		err = checkPattern(p.s[p.i:i])
+	} else {
+		rx, err = compilePattern(p.s[p.i:i])
+	}
 	p.i = i
 	return rx, err
 }
@@ -260,12 +295,9 @@ func (p *parser) skipWhitespace() bool {
 			i++
 			continue
 		case '/':
-			if strings.HasPrefix(p.s[i:], "/*") {
-				end := strings.Index(p.s[i+len("/*"):], "*/")
-				if end != -1 {
-					i += end + len("/**/")
-					continue
-				}
+			if end := p.commentEnd(i); end != -1 {
+				i = end
+				continue
 			}
 		}
 		break
@@ -279,6 +311,22 @@ func (p *parser) skipWhitespace() bool {
 	return false
 }
 
+// commentEnd returns the index just after the comment that starts at
+// p.s[i], or -1 if there isn't a complete comment there.
+func (p *parser) commentEnd(i int) int {
+	if !strings.HasPrefix(p.s[i:], "/*") || p.noCloseAfter > 0 && i >= p.noCloseAfter {
+		return -1
+	}
+	end := strings.Index(p.s[i+len("/*"):], "*/")
+	if end == -1 {
+		// Remember, so that a string of unclosed comments isn't searched
+		// again for each one.
+		p.noCloseAfter = i
+		return -1
+	}
+	return i + end + len("/**/")
+}
+
 // consumeParenthesis consumes an opening parenthesis and any following
 // whitespace. It returns true if there was actually a parenthesis to skip.
 func (p *parser) consumeParenthesis() bool {
@@ -309,7 +357,8 @@ func (p *parser) parseTypeSelector() (result tagSelector, err error) {
 	if err != nil {
 		return
 	}
-	return tagSelector{tag: toLowerASCII(tag)}, nil
+	tag = toLowerASCII(tag)
+	return tagSelector{tag: tag, name: svgTagNames[tag]}, nil
 }
 
 // parseIDSelector parses a selector that matches by id attribute.
@@ -327,6 +376,9 @@ func (p *parser) parseIDSelector() (idSelector, error) {
 		return idSelector{}, err
 	}
 
+	if p.opts.QuirksMode {
+		return idSelector{id: toLowerASCII(id), quirks: true}, nil
+	}
 	return idSelector{id: id}, nil
 }
 
@@ -345,6 +397,9 @@ func (p *parser) parseClassSelector() (classSelector, error) {
 		return classSelector{}, err
 	}
 
+	if p.opts.QuirksMode {
+		return classSelector{class: toLowerASCII(class), quirks: true}, nil
+	}
 	return classSelector{class: class}, nil
 }
 
@@ -364,6 +419,7 @@ func (p *parser) parseAttributeSelector() (attrSelector, error) {
 		return attrSelector{}, err
 	}
 	key = toLowerASCII(key)
+	name := foreignAttributeNames[key]
 
 	p.skipWhitespace()
 	if p.i >= len(p.s) {
@@ -372,7 +428,7 @@ func (p *parser) parseAttributeSelector() (attrSelector, error) {
 
 	if p.s[p.i] == ']' {
 		p.i++
-		return attrSelector{key: key, operation: ""}, nil
+		return attrSelector{key: key, name: name, operation: ""}, nil
 	}
 
 	if p.i+2 >= len(p.s) {
@@ -380,9 +436,12 @@ func (p *parser) parseAttributeSelector() (attrSelector, error) {
 	}
 
 	op := p.s[p.i : p.i+2]
-	if op[0] == '=' {
+	switch {
+	case op[0] == '=':
 		op = "="
-	} else if op[1] != '=' {
+	case (op[0] == '<' || op[0] == '>') && op[1] != '=':
+		op = op[:1]
+	case op[1] != '=':
 		return attrSelector{}, fmt.Errorf(`expected equality operator, found "%s" instead`, op)
 	}
 	p.i += len(op)
@@ -392,10 +451,14 @@ func (p *parser) parseAttributeSelector() (attrSelector, error) {
 		return attrSelector{}, errors.New("unexpected EOF in attribute selector")
 	}
 	var val string
-	var rx *regexp.Regexp
-	if op == "#=" {
+	var rx pattern
+	var number float64
+	switch op {
+	case "#=":
 		rx, err = p.parseRegex()
-	} else {
+	case "<", "<=", ">", ">=":
+		val, number, err = p.parseNumber()
+	default:
 		switch p.s[p.i] {
 		case '\'', '"':
 			val, err = p.parseString()
@@ -430,8 +493,8 @@ func (p *parser) parseAttributeSelector() (attrSelector, error) {
 	p.i++
 
 	switch op {
-	case "=", "!=", "~=", "|=", "^=", "$=", "*=", "#=":
-		return attrSelector{key: key, val: val, operation: op, regexp: rx, insensitive: ignoreCase}, nil
+	case "=", "!=", "~=", "|=", "^=", "$=", "*=", "#=", "<", "<=", ">", ">=":
+		return attrSelector{key: key, name: name, val: val, operation: op, regexp: rx, number: number, insensitive: ignoreCase, strict: p.opts.StrictAttributes}, nil
 	default:
 		return attrSelector{}, fmt.Errorf("attribute operator %q is not supported", op)
 	}
@@ -443,6 +506,14 @@ var (
 	errUnmatchedParenthesis       = errors.New("unmatched '('")
 )
 
+// unknownPseudoClassError is the error for a pseudo-class that is neither
+// built in nor registered with RegisterPseudoClass.
+type unknownPseudoClassError string
+
+func (e unknownPseudoClassError) Error() string {
+	return "unknown pseudoclass or pseudoelement :" + string(e)
+}
+
 // parsePseudoclassSelector parses a pseudoclass selector like :not(p) or a pseudo-element
 // For backwards compatibility, both ':' and '::' prefix are allowed for pseudo-elements.
 // https://drafts.csswg.org/selectors-3/#pseudo-elements
@@ -470,18 +541,33 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 		return
 	}
 	name = toLowerASCII(name)
-	if mustBePseudoElement && (name != "after" && name != "backdrop" && name != "before" &&
-		name != "cue" && name != "first-letter" && name != "first-line" && name != "grammar-error" &&
-		name != "marker" && name != "placeholder" && name != "selection" && name != "spelling-error") {
+	if mustBePseudoElement && !isPseudoElementName(name) {
 		return out, "", fmt.Errorf("unknown pseudoelement :%s", name)
 	}
+	// Check the allowlist before the argument is parsed, so that nothing in
+	// a forbidden pseudo-class (like a regular expression) is compiled.
+	if !isPseudoElementName(name) && !p.pseudoClassAllowed(name) {
+		return nil, "", fmt.Errorf("pseudo-class :%s is not allowed", name)
+	}
 
 	switch name {
 	case "not", "has", "haschild":
 		if !p.consumeParenthesis() {
 			return out, "", errExpectedParenthesis
 		}
+		isHas := name == "has" || name == "haschild"
+		if isHas && p.inHas && p.opts.NoNestedHas {
+			return out, "", fmt.Errorf(":%s may not be used inside :has()", name)
+		}
+		p.depth++
+		if p.opts.MaxNesting > 0 && p.depth > p.opts.MaxNesting {
+			return out, "", fmt.Errorf("pseudo-classes are nested more than %d deep", p.opts.MaxNesting)
+		}
+		outerHas := p.inHas
+		p.inHas = p.inHas || isHas
 		sel, parseErr := p.parseSelectorGroup()
+		p.inHas = outerHas
+		p.depth--
 		if parseErr != nil {
 			return out, "", parseErr
 		}
@@ -489,9 +575,13 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 			return out, "", errExpectedClosingParenthesis
 		}
 
-		out = relativePseudoClassSelector{name: name, match: sel}
+		rel := relativePseudoClassSelector{name: name, match: sel}
+		if name == "has" {
+			rel.depth = p.opts.MaxHasDepth
+		}
+		out = rel
 
-	case "contains", "containsown":
+	case "contains", "containsown", "icontains", "contains-word":
 		if !p.consumeParenthesis() {
 			return out, "", errExpectedParenthesis
 		}
@@ -508,7 +598,12 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 		if err != nil {
 			return out, "", err
 		}
-		val = strings.ToLower(val)
+		fold := name == "icontains"
+		if fold {
+			val = foldString(val)
+		} else {
+			val = strings.ToLower(val)
+		}
 		p.skipWhitespace()
 		if p.i >= len(p.s) {
 			return out, "", errors.New("unexpected EOF in pseudo selector")
@@ -517,7 +612,30 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 			return out, "", errExpectedClosingParenthesis
 		}
 
-		out = containsPseudoClassSelector{own: name == "containsown", value: val}
+		out = containsPseudoClassSelector{own: name == "containsown", fold: fold, word: name == "contains-word", value: val, text: p.opts.Text}
+
+	case "text-is":
+		if !p.consumeParenthesis() {
+			return out, "", errExpectedParenthesis
+		}
+		if p.i == len(p.s) {
+			return out, "", errUnmatchedParenthesis
+		}
+		var val string
+		switch p.s[p.i] {
+		case '\'', '"':
+			val, err = p.parseString()
+		default:
+			val, err = p.parseIdentifier()
+		}
+		if err != nil {
+			return out, "", err
+		}
+		if !p.consumeClosingParenthesis() {
+			return out, "", errExpectedClosingParenthesis
+		}
+
+		out = textIsPseudoClassSelector{value: collapseWhitespace(val), text: p.opts.Text}
 
 	case "matches", "matchesown":
 		if !p.consumeParenthesis() {
@@ -534,7 +652,115 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 			return out, "", errExpectedClosingParenthesis
 		}
 
-		out = regexpPseudoClassSelector{own: name == "matchesown", regexp: rx}
+		out = regexpPseudoClassSelector{own: name == "matchesown", regexp: rx, text: p.opts.Text}
+
+	case "data":
+		if !p.consumeParenthesis() {
+			return out, "", errExpectedParenthesis
+		}
+		key, err := p.parseIdentifier()
+		if err != nil {
+			return out, "", err
+		}
+		sel := dataPseudoClassSelector{key: dataAttributeName(key)}
+		p.skipWhitespace()
+		if p.i < len(p.s) && p.s[p.i] == '=' {
+			p.i++
+			p.skipWhitespace()
+			if p.i >= len(p.s) {
+				return out, "", errors.New("unexpected EOF in pseudo selector")
+			}
+			switch p.s[p.i] {
+			case '\'', '"':
+				sel.value, err = p.parseString()
+			default:
+				sel.value, err = p.parseIdentifier()
+			}
+			if err != nil {
+				return out, "", err
+			}
+			sel.hasValue = true
+		}
+		if !p.consumeClosingParenthesis() {
+			return out, "", errExpectedClosingParenthesis
+		}
+		out = sel
+
+	case "aria":
+		if !p.consumeParenthesis() {
+			return out, "", errExpectedParenthesis
+		}
+		key, err := p.parseIdentifier()
+		if err != nil {
+			return out, "", err
+		}
+		key = toLowerASCII(key)
+		p.skipWhitespace()
+		if p.i < len(p.s) && p.s[p.i] == '=' {
+			p.i++
+			p.skipWhitespace()
+			if p.i >= len(p.s) {
+				return out, "", errors.New("unexpected EOF in pseudo selector")
+			}
+			var val string
+			switch p.s[p.i] {
+			case '\'', '"':
+				val, err = p.parseString()
+			default:
+				val, err = p.parseName()
+			}
+			if err != nil {
+				return out, "", err
+			}
+			key = "aria-" + strings.TrimPrefix(key, "aria-")
+			out = ariaPseudoClassSelector{attr: key, value: toLowerASCII(strings.TrimSpace(val))}
+		} else {
+			out = ariaPseudoClassSelector{role: key}
+		}
+		if !p.consumeClosingParenthesis() {
+			return out, "", errExpectedClosingParenthesis
+		}
+
+	case "heading":
+		if !p.consumeParenthesis() {
+			out = headingPseudoClassSelector{}
+			break
+		}
+		min, err := p.parseInteger()
+		if err != nil {
+			return out, "", err
+		}
+		max := min
+		p.skipWhitespace()
+		if p.i < len(p.s) && p.s[p.i] == '-' {
+			p.i++
+			p.skipWhitespace()
+			max, err = p.parseInteger()
+			if err != nil {
+				return out, "", err
+			}
+		}
+		if min < 1 || max < min {
+			return out, "", fmt.Errorf("invalid heading level range %d-%d", min, max)
+		}
+		if !p.consumeClosingParenthesis() {
+			return out, "", errExpectedClosingParenthesis
+		}
+		out = headingPseudoClassSelector{min: min, max: max}
+
+	case "tag-matches":
+		if !p.consumeParenthesis() {
+			return out, "", errExpectedParenthesis
+		}
+		rx, err := p.parseRegex()
+		if err != nil {
+			return out, "", err
+		}
+		if !p.consumeClosingParenthesis() {
+			return out, "", errExpectedClosingParenthesis
+		}
+
+		out = tagRegexpPseudoClassSelector{regexp: rx}
 
 	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
 		if !p.consumeParenthesis() {
@@ -565,10 +791,20 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 		out = onlyChildPseudoClassSelector{ofType: true}
 	case "input":
 		out = inputPseudoClassSelector{}
+	case "text", "checkbox", "radio", "password", "file", "submit", "reset", "image":
+		out = inputTypePseudoClassSelector{inputType: name}
+	case "button":
+		out = buttonPseudoClassSelector{}
+	case "header":
+		out = headerPseudoClassSelector{}
 	case "empty":
 		out = emptyElementPseudoClassSelector{}
+	case "parent":
+		out = parentPseudoClassSelector{}
 	case "root":
 		out = rootPseudoClassSelector{}
+	case "scope":
+		out = scopePseudoClassSelector{}
 	case "link":
 		out = linkPseudoClassSelector{}
 	case "lang":
@@ -597,17 +833,190 @@ func (p *parser) parsePseudoclassSelector() (out Sel, pseudoElement string, err
 		out = disabledPseudoClassSelector{}
 	case "checked":
 		out = checkedPseudoClassSelector{}
+	case "hidden":
+		out = visibilityPseudoClassSelector{visible: false}
+	case "visible":
+		out = visibilityPseudoClassSelector{visible: true}
+	case "eq", "gt", "lt", "first", "last", "even", "odd", "nth-match":
+		if p.depth > 0 {
+			return out, "", fmt.Errorf("result-set pseudo-class :%s is not allowed inside another pseudo-class", name)
+		}
+		if name == "first" || name == "last" || name == "even" || name == "odd" {
+			out = positionalPseudoClassSelector{name: name}
+			break
+		}
+		if !p.consumeParenthesis() {
+			return out, "", errExpectedParenthesis
+		}
+		if name == "nth-match" {
+			a, b, err := p.parseNth()
+			if err != nil {
+				return out, "", err
+			}
+			if !p.consumeClosingParenthesis() {
+				return out, "", errExpectedClosingParenthesis
+			}
+			out = positionalPseudoClassSelector{name: name, a: a, b: b}
+			break
+		}
+		negative := false
+		if p.i < len(p.s) && p.s[p.i] == '-' {
+			negative = true
+			p.i++
+		}
+		index, err := p.parseInteger()
+		if err != nil {
+			return out, "", err
+		}
+		if negative {
+			index = -index
+		}
+		if !p.consumeClosingParenthesis() {
+			return out, "", errExpectedClosingParenthesis
+		}
+		out = positionalPseudoClassSelector{name: name, index: index}
 	case "visited", "hover", "active", "focus", "target":
 		// Not applicable in a static context: never match.
 		out = neverMatchSelector{value: ":" + name}
 	case "after", "backdrop", "before", "cue", "first-letter", "first-line", "grammar-error", "marker", "placeholder", "selection", "spelling-error":
+		// Only the pseudo-elements from CSS 2 may be written with one
+		// colon.
+		if !mustBePseudoElement && !p.opts.QuirksMode && name != "after" && name != "before" &&
+			name != "first-letter" && name != "first-line" {
+			return nil, "", fmt.Errorf("pseudo-element ::%s must be written with two colons", name)
+		}
 		return nil, name, nil
 	default:
-		return out, "", fmt.Errorf("unknown pseudoclass or pseudoelement :%s", name)
+		c := lookupPseudoClass(name)
+		if c == nil {
+			return out, "", unknownPseudoClassError(name)
+		}
+		out, err = p.parseCustomPseudoClass(c)
+		if err != nil {
+			return nil, "", err
+		}
 	}
 	return
 }
 
+// isPseudoElementName returns whether name is one of the pseudo-elements
+// that the parser accepts.
+func isPseudoElementName(name string) bool {
+	switch name {
+	case "after", "backdrop", "before", "cue", "first-letter", "first-line", "grammar-error", "marker", "placeholder", "selection", "spelling-error":
+		return true
+	}
+	return false
+}
+
+// pseudoClassAllowed returns whether the pseudo-class called name is in the
+// list of allowed pseudo-classes (if any) in p's options.
+func (p *parser) pseudoClassAllowed(name string) bool {
+	if p.opts.PseudoClasses == nil {
+		return true
+	}
+	for _, allowed := range p.opts.PseudoClasses {
+		if toLowerASCII(allowed) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCustomPseudoClass parses the argument (if any) of a pseudo-class
+// registered with RegisterPseudoClass.
+func (p *parser) parseCustomPseudoClass(c *customPseudoClass) (Sel, error) {
+	if c.parseArg == nil {
+		return customPseudoClassSelector{class: c}, nil
+	}
+	if !p.consumeParenthesis() {
+		return nil, errExpectedParenthesis
+	}
+	text, err := p.parseRawArgument()
+	if err != nil {
+		return nil, err
+	}
+	arg, err := c.parseArg(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid argument for :%s: %s", c.name, err)
+	}
+	return customPseudoClassSelector{class: c, argument: text, arg: arg}, nil
+}
+
+// parseRawArgument returns the text up to the parenthesis that closes a
+// functional pseudo-class, without comments or surrounding whitespace, and
+// consumes the closing parenthesis. Parentheses and brackets in the text
+// must be balanced, except inside strings and comments.
+func (p *parser) parseRawArgument() (string, error) {
+	start := p.i
+	depth := 0
+	var quote byte
+	var b strings.Builder // the text before the last comment, if there is one
+	for ; p.i < len(p.s); p.i++ {
+		c := p.s[p.i]
+		switch {
+		case c == '\\':
+			p.i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/':
+			if end := p.commentEnd(p.i); end != -1 {
+				b.WriteString(p.s[start:p.i])
+				start = end
+				p.i = end - 1
+			}
+		case c == '(' || c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ')':
+			if depth == 0 {
+				b.WriteString(p.s[start:p.i])
+				p.i++
+				return strings.TrimSpace(b.String()), nil
+			}
+			depth--
+		}
+	}
+	return "", errUnmatchedParenthesis
+}
+
+// parseNumber parses a decimal number (possibly quoted), returning both the
+// text of the number and its value.
+func (p *parser) parseNumber() (text string, value float64, err error) {
+	if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+		text, err = p.parseString()
+		if err != nil {
+			return "", 0, err
+		}
+		text = strings.TrimSpace(text)
+	} else {
+		start := p.i
+		i := p.i
+		for i < len(p.s) {
+			c := p.s[i]
+			if '0' <= c && c <= '9' || c == '.' || c == 'e' || c == 'E' ||
+				(c == '+' || c == '-') && (i == start || p.s[i-1] == 'e' || p.s[i-1] == 'E') {
+				i++
+				continue
+			}
+			break
+		}
+		text = p.s[start:i]
+		p.i = i
+	}
+
+	value, ok := parseDecimal(text)
+	if !ok {
+		return "", 0, fmt.Errorf("expected number, found %q instead", text)
+	}
+	return text, value, nil
+}
+
 // parseInteger parses a  decimal integer.
 func (p *parser) parseInteger() (int, error) {
 	i := p.i
@@ -752,7 +1161,10 @@ invalid:
 // parseSimpleSelectorSequence parses a selector sequence that applies to
 // a single element.
 func (p *parser) parseSimpleSelectorSequence() (Sel, error) {
-	var selectors []Sel
+	// Most compound selectors have only a few parts, so room for four
+	// saves growing the slice one element at a time.
+	var buf [4]Sel
+	selectors := buf[:0]
 
 	if p.i >= len(p.s) {
 		return nil, errors.New("expected selector, found EOF instead")
@@ -806,9 +1218,12 @@ loop:
 			if pseudoElement != "" {
 				return nil, fmt.Errorf("only one pseudo-element is accepted per selector, got %s and %s", pseudoElement, newPseudoElement)
 			}
-			if !p.acceptPseudoElements {
+			if !p.opts.PseudoElements {
 				return nil, fmt.Errorf("pseudo-element %s found, but pseudo-elements support is disabled", newPseudoElement)
 			}
+			if p.depth > 0 && !p.opts.QuirksMode {
+				return nil, fmt.Errorf("pseudo-element %s is not allowed inside another pseudo-class", newPseudoElement)
+			}
 			pseudoElement = newPseudoElement
 		} else {
 			if pseudoElement != "" {
@@ -821,7 +1236,12 @@ loop:
 	if len(selectors) == 1 && pseudoElement == "" { // no need wrap the selectors in compoundSelector
 		return selectors[0], nil
 	}
-	return compoundSelector{selectors: selectors, pseudoElement: pseudoElement}, nil
+	// Copy the selectors out of buf, with no spare capacity.
+	var parts []Sel
+	if len(selectors) > 0 {
+		parts = append(make([]Sel, 0, len(selectors)), selectors...)
+	}
+	return newCompoundSelector(parts, pseudoElement), nil
 }
 
 // parseSelector parses a selector that may include combinators.
@@ -858,6 +1278,13 @@ func (p *parser) parseSelector() (Sel, error) {
 			return result, nil
 		}
 
+		if f := positionalFilters(result); len(f) > 0 {
+			return nil, fmt.Errorf("result-set pseudo-class %s must be in the last compound selector", f[0])
+		}
+		if pe := result.PseudoElement(); pe != "" && !p.opts.QuirksMode {
+			return nil, fmt.Errorf("pseudo-element %s must be at the end of selector", pe)
+		}
+
 		c, err = p.parseSimpleSelectorSequence()
 		if err != nil {
 			return nil, err
@@ -866,9 +1293,114 @@ func (p *parser) parseSelector() (Sel, error) {
 	}
 }
 
+// parseRelativeSelector parses a selector that may begin with a combinator,
+// and anchors it at the :scope element. If there is no combinator at the
+// start, the descendant combinator is used, unless the selector already
+// contains :scope.
+func (p *parser) parseRelativeSelector() (Sel, error) {
+	p.skipWhitespace()
+	var combinator byte
+	if p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '+', '>', '~':
+			combinator = p.s[p.i]
+			p.i++
+		}
+	}
+
+	result, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+	if combinator == 0 {
+		if containsScope(result) {
+			return result, nil
+		}
+		combinator = ' '
+	}
+	return prependSelector(scopePseudoClassSelector{implicit: true}, combinator, result), nil
+}
+
+// parseSelectorGroupRecover parses a group of selectors, separated by
+// commas, skipping the ones that are invalid.
+func (p *parser) parseSelectorGroupRecover() (SelectorGroup, ParseErrors) {
+	parse := p.parseSelector
+	if p.opts.Relative {
+		parse = p.parseRelativeSelector
+	}
+
+	var (
+		result SelectorGroup
+		errs   ParseErrors
+	)
+	for {
+		start := p.i
+		sel, err := parse()
+		if err == nil && p.i < len(p.s) && p.s[p.i] != ',' {
+			err = fmt.Errorf("expected ',', found '%c' instead", p.s[p.i])
+		}
+		if err != nil {
+			offset := p.i
+			p.i = start
+			p.skipSelector()
+			errs = append(errs, &ParseError{
+				Offset:   offset,
+				Selector: strings.TrimSpace(p.s[start:p.i]),
+				Err:      err,
+			})
+		} else {
+			result = append(result, sel)
+		}
+
+		if p.i >= len(p.s) {
+			return result, errs
+		}
+		p.i++ // the comma
+	}
+}
+
+// skipSelector moves p.i to the end of the current selector in a list: the
+// next comma that isn't inside brackets, parentheses, a string or a
+// comment, or the end of the input.
+func (p *parser) skipSelector() {
+	depth := 0
+	var quote byte
+	for ; p.i < len(p.s); p.i++ {
+		c := p.s[p.i]
+		switch {
+		case c == '\\':
+			p.i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '/':
+			if end := p.commentEnd(p.i); end != -1 {
+				p.i = end - 1
+			}
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			if depth > 0 {
+				depth--
+			}
+		case c == ',' && depth == 0:
+			return
+		}
+	}
+	p.i = len(p.s)
+}
+
 // parseSelectorGroup parses a group of selectors, separated by commas.
 func (p *parser) parseSelectorGroup() (SelectorGroup, error) {
-	current, err := p.parseSelector()
+	parse := p.parseSelector
+	if p.opts.Relative && p.depth == 0 {
+		parse = p.parseRelativeSelector
+	}
+
+	current, err := parse()
 	if err != nil {
 		return nil, err
 	}
@@ -879,7 +1411,7 @@ func (p *parser) parseSelectorGroup() (SelectorGroup, error) {
 			break
 		}
 		p.i++
-		c, err := p.parseSelector()
+		c, err := parse()
 		if err != nil {
 			return nil, err
 		}
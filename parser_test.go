@@ -1,6 +1,7 @@
 package cascadia
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -86,3 +87,201 @@ func TestParseString(t *testing.T) {
 		}
 	}
 }
+
+func TestParseGroupRecover(t *testing.T) {
+	for _, test := range []struct {
+		source  string
+		valid   string   // the valid selectors, serialized
+		invalid []string // the invalid selectors
+	}{
+		{"p, div", "p, div", nil},
+		{"p, div:bogus, span", "p, span", []string{"div:bogus"}},
+		{"a[x=','), b, c", "b, c", []string{"a[x=',')"}},
+		{":not(a, #), b", "b", []string{":not(a, #)"}},
+		{`p:contains("a, b"), q[`, `p:contains("a, b")`, []string{"q["}},
+		{"p,, div", "p, div", []string{""}},
+		{"p > , :first()", "", []string{"p >", ":first()"}},
+		{`\,x, y`, `\,x, y`, nil},
+		{"p:bogus /* , */ q, div", "div", []string{"p:bogus /* , */ q"}},
+	} {
+		got, err := ParseGroupRecover(test.source, ParseOptions{})
+		if s := got.String(); s != test.valid {
+			t.Errorf("ParseGroupRecover(%q) returned %q, want %q", test.source, s, test.valid)
+		}
+		if test.invalid == nil {
+			if err != nil {
+				t.Errorf("ParseGroupRecover(%q): unexpected error %s", test.source, err)
+			}
+			continue
+		}
+		errs, ok := err.(ParseErrors)
+		if !ok {
+			t.Errorf("ParseGroupRecover(%q) returned %T, want ParseErrors", test.source, err)
+			continue
+		}
+		if len(errs) != len(test.invalid) {
+			t.Errorf("ParseGroupRecover(%q) returned %d errors, want %d: %s", test.source, len(errs), len(test.invalid), err)
+			continue
+		}
+		for i, e := range errs {
+			if e.Selector != test.invalid[i] {
+				t.Errorf("ParseGroupRecover(%q): error %d is for %q, want %q", test.source, i, e.Selector, test.invalid[i])
+			}
+			if e.Offset < 0 || e.Offset > len(test.source) {
+				t.Errorf("ParseGroupRecover(%q): error offset %d is out of range", test.source, e.Offset)
+			}
+		}
+	}
+
+	_, err := ParseGroupRecover("p, div:bogus", ParseOptions{})
+	if want := `invalid selector "div:bogus" at offset 12: unknown pseudoclass or pseudoelement :bogus`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+}
+
+func TestPseudoClassAllowlist(t *testing.T) {
+	opts := ParseOptions{PseudoClasses: []string{"first-child", "not", "Empty"}}
+	for _, sel := range []string{
+		"li:first-child",
+		"p:not(.a)",
+		"div:EMPTY",
+		"p::before",
+	} {
+		o := opts
+		o.PseudoElements = true
+		if _, err := ParseGroupWithOptions(sel, o); err != nil {
+			t.Errorf("%s: %s", sel, err)
+		}
+	}
+	for _, sel := range []string{
+		"div:has(p)",
+		"p:matches(a)",
+		"p:not(:last-child)",
+		"a, b:root",
+		// The argument of a forbidden pseudo-class isn't looked at.
+		"p:matches(a(b)",
+		"div:has(p:has(b)",
+	} {
+		_, err := ParseGroupWithOptions(sel, opts)
+		if err == nil || !strings.Contains(err.Error(), "is not allowed") {
+			t.Errorf("%s: got error %v, want one saying the pseudo-class is not allowed", sel, err)
+		}
+	}
+	if err := ValidateWithOptions("div:has(p)", opts); err == nil {
+		t.Error("Validate(div:has(p)): expected an error")
+	}
+	if _, err := Parse("div:has(p)"); err != nil {
+		t.Errorf("without an allowlist: %s", err)
+	}
+}
+
+func TestComments(t *testing.T) {
+	for source, want := range map[string]string{
+		"/* a */ p /* b */":                            "p",
+		"[/*a*/ x /*b*/ = /*c*/ y /*d*/ i /*e*/]":      `[x="y" i]`,
+		"p /* > */ > /* , */ q":                        "p > q",
+		"p/*a*/+/*b*/q":                                "p + q",
+		"li:nth-child(/*a*/ 2n /*b*/ + /*c*/ 1 /*d*/)": "li:nth-child(2n+1)",
+		"li:nth-last-of-type(/*a*/odd/*b*/)":           "li:nth-last-of-type(2n+1)",
+		":not(/*a*/ p /*b*/, /*c*/ q /*d*/)":           ":not(p, q)",
+		"p:has(/* ) */ q)":                             "p:has(q)",
+		`p:contains(/*a*/ "x" /*b*/)`:                  `p:contains("x")`,
+		":lang(/*a*/ en /*b*/)":                        ":lang(en)",
+		"p/*a*/,/*b*/q":                                "p, q",
+	} {
+		g, err := ParseGroup(source)
+		if err != nil {
+			t.Errorf("%s: %s", source, err)
+			continue
+		}
+		if got := g.String(); got != want {
+			t.Errorf("%s: got %s, want %s", source, got, want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	for _, test := range []struct {
+		sel  string
+		opts ParseOptions
+		ok   bool
+	}{
+		{"div p", ParseOptions{MaxLength: 5}, true},
+		{"div p ", ParseOptions{MaxLength: 5}, false},
+		{":not(:has(p))", ParseOptions{MaxNesting: 2}, true},
+		{":not(:has(:not(p)))", ParseOptions{MaxNesting: 2}, false},
+		{":not(p):has(q)", ParseOptions{MaxNesting: 1}, true},
+		{`#\31 \32`, ParseOptions{MaxEscapes: 2}, true},
+		{`#\31 \32\33`, ParseOptions{MaxEscapes: 2}, false},
+		{`[a="\31\32"][b=\33]`, ParseOptions{MaxEscapes: 2}, false},
+	} {
+		_, err := ParseGroupWithOptions(test.sel, test.opts)
+		if test.ok && err != nil {
+			t.Errorf("%s: %s", test.sel, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%s: expected an error", test.sel)
+		}
+		if err2 := ValidateWithOptions(test.sel, test.opts); (err2 == nil) != (err == nil) {
+			t.Errorf("%s: Validate returned %v, but ParseGroup returned %v", test.sel, err2, err)
+		}
+		if _, err := ParseGroupRecover(test.sel, test.opts); !test.ok && err == nil {
+			t.Errorf("ParseGroupRecover(%s): expected an error", test.sel)
+		}
+	}
+}
+
+// pathologicalSelectors are inputs that used to take quadratic time to
+// parse.
+var pathologicalSelectors = map[string]func(n int) string{
+	"UnclosedComments": func(n int) string { return "a" + strings.Repeat(" /*", n) },
+	"Escapes":          func(n int) string { return "#" + strings.Repeat(`\61 `, n) },
+	"StringEscapes":    func(n int) string { return `[a="` + strings.Repeat(`\61`, n) + `"]` },
+	"Nesting":          func(n int) string { return strings.Repeat(":not(", n) + "p" + strings.Repeat(")", n) },
+}
+
+func BenchmarkParsePathological(b *testing.B) {
+	for name, f := range pathologicalSelectors {
+		sel := f(20000)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ParseGroupRecover(sel, ParseOptions{})
+			}
+		})
+	}
+}
+
+func TestNoNestedHas(t *testing.T) {
+	for sel, ok := range map[string]bool{
+		"div:has(p)":                 true,
+		"div:has(p):has(span)":       true,
+		":not(:has(p))":              true,
+		"div:has(p:not(.a)) :has(q)": true,
+		"div:has(p:has(span))":       false,
+		"div:has(:not(:has(span)))":  false,
+		"div:has(p, q:haschild(a))":  false,
+		"div:haschild(:has(a))":      false,
+	} {
+		_, err := ParseGroupWithOptions(sel, ParseOptions{NoNestedHas: true})
+		if ok && err != nil {
+			t.Errorf("%s: %s", sel, err)
+		} else if !ok && err == nil {
+			t.Errorf("%s: expected an error", sel)
+		}
+		if _, err := ParseGroup(sel); err != nil {
+			t.Errorf("%s without NoNestedHas: %s", sel, err)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	for _, sel := range []string{`[n>1]`, `[n<=-2.5]`, `[n>="1e3"]`, `[n<.5]`, `[n>+3e-2]`} {
+		if _, err := Parse(sel); err != nil {
+			t.Errorf("Parse(%s): %v", sel, err)
+		}
+	}
+	for _, sel := range []string{`[n>"inf"]`, `[n<"Infinity"]`, `[n>"NaN"]`, `[n<"0x10"]`, `[n>"1_000"]`, `[n>"1e999"]`, `[n>"."]`, `[n<1e]`} {
+		if _, err := Parse(sel); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", sel)
+		}
+	}
+}
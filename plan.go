@@ -0,0 +1,183 @@
+package cascadia
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A Plan is a strategy for finding the elements that match a selector.
+// QueryAll tests every element in the tree against the whole selector. A
+// Plan does the same by default, but when a compound selector to the left
+// is more selective than the rightmost one, as in ".rare-class span", it
+// finds the elements that match the left part first, and then searches
+// only their descendants.
+//
+// The choice is made from the selector alone: ID selectors are assumed to
+// be more selective than class and attribute selectors, and those more
+// than type selectors.
+type Plan struct {
+	m Matcher
+
+	// anchor, if it is not nil, finds the elements whose descendants may
+	// match m.
+	anchor *Plan
+
+	// group has a Plan for each selector in a group.
+	group []*Plan
+}
+
+// NewPlan returns a Plan for finding the matches of m, which should be a
+// Sel or a SelectorGroup from this package.
+func NewPlan(m Matcher) *Plan {
+	p := &Plan{m: m}
+	if hasPositional(m) {
+		// The positions depend on the whole result set.
+		return p
+	}
+	switch s := m.(type) {
+	case SelectorGroup:
+		if len(s) == 1 {
+			return NewPlan(s[0])
+		}
+		for _, sel := range s {
+			p.group = append(p.group, NewPlan(sel))
+		}
+	case Sel:
+		if k := anchorIndex(s); k >= 0 {
+			p.anchor = NewPlan(prefix(s, k))
+		}
+	}
+	return p
+}
+
+// anchorIndex returns the index of the compound selector in s that a plan
+// should find first, or -1 if it should just test the whole selector on
+// every element.
+func anchorIndex(s Sel) int {
+	compounds, combinators := steps(s)
+	last := len(compounds) - 1
+	best, bestScore := -1, selectivity(compounds[last])
+	for k := last - 1; k >= 0; k-- {
+		if c := combinators[k+1]; c != ' ' && c != '>' {
+			// Sibling combinators would need the anchor's siblings
+			// searched too.
+			break
+		}
+		if score := selectivity(compounds[k]); score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// selectivity ranks compound selectors by how few elements they are likely
+// to match.
+func selectivity(s Sel) int {
+	score := 0
+	for _, part := range simpleSelectors(s) {
+		var partScore int
+		switch part.(type) {
+		case idSelector:
+			partScore = 3
+		case classSelector, attrSelector:
+			partScore = 2
+		case tagSelector:
+			partScore = 1
+		}
+		if partScore > score {
+			score = partScore
+		}
+	}
+	return score
+}
+
+// prefix returns the part of s up to and including its compound selector
+// with index k.
+func prefix(s Sel, k int) Sel {
+	compounds, _ := steps(s)
+	for drop := len(compounds) - 1 - k; drop > 0; {
+		switch c := s.(type) {
+		case Builder:
+			s = c.Sel()
+		case combinedSelector:
+			s = c.first
+			if c.second != nil {
+				drop--
+			}
+		default:
+			panic("cascadia: unexpected selector type in prefix")
+		}
+	}
+	return s
+}
+
+// QueryAll returns the nodes that match the plan's selector, from the
+// descendants of root, in document order. The results are the same as
+// those of QueryAll(root, m).
+func (p *Plan) QueryAll(root *html.Node) []*html.Node {
+	if p.group != nil {
+		sets := make([][]*html.Node, len(p.group))
+		for i, sub := range p.group {
+			sets[i] = sub.QueryAll(root)
+		}
+		return Union(sets...)
+	}
+	if p.anchor == nil {
+		return QueryAll(root, p.m)
+	}
+
+	for a := root; a != nil; a = a.Parent {
+		if p.anchor.m.Match(a) {
+			// The whole tree is in an anchor.
+			return QueryAll(root, p.m)
+		}
+	}
+
+	var result []*html.Node
+	var searched *html.Node
+	for _, a := range p.anchor.QueryAll(root) {
+		if searched != nil && isAncestor(searched, a) {
+			// Its descendants have already been searched.
+			continue
+		}
+		searched = a
+		result = queryInto(nil, a, p.m, result)
+	}
+	return result
+}
+
+// isAncestor returns whether a is a proper ancestor of n.
+func isAncestor(a, n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == a {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the plan.
+func (p *Plan) String() string {
+	if p.group != nil {
+		parts := make([]string, len(p.group))
+		for i, sub := range p.group {
+			parts[i] = sub.String()
+		}
+		return strings.Join(parts, "; ")
+	}
+	if p.anchor == nil {
+		return "test every element against " + planSelectorString(p.m)
+	}
+	return p.anchor.String() + ", then test their descendants against " + planSelectorString(p.m)
+}
+
+func planSelectorString(m Matcher) string {
+	if s, ok := m.(Sel); ok {
+		return ToAST(s).String()
+	}
+	if s, ok := m.(interface{ String() string }); ok {
+		return s.String()
+	}
+	return "the matcher"
+}
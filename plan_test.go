@@ -0,0 +1,99 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestPlanMatchesQueryAll(t *testing.T) {
+	for _, test := range selectorTests {
+		g, err := ParseGroup(test.selector)
+		if err != nil {
+			continue
+		}
+		doc, err := html.Parse(strings.NewReader(test.HTML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := QueryAll(doc, g)
+		got := NewPlan(g).QueryAll(doc)
+		if len(got) != len(want) {
+			t.Errorf("%s: plan found %d elements, want %d (%s)", test.selector, len(got), len(want), NewPlan(g))
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: match %d is %s, want %s", test.selector, i, nodeString(got[i]), nodeString(want[i]))
+			}
+		}
+	}
+}
+
+func TestPlanShakespeare(t *testing.T) {
+	doc := parseReference("test_resources/shakespeare.html")
+	body := doc.FirstChild.NextSibling.LastChild
+	for _, sel := range []string{
+		"#scene1 div",
+		"#scene1 > div.dialog div",
+		"div.dialog .dialog .direction",
+		"body div",
+		"html div",
+		".scene div + div",
+		"div#speech5 *, .dialog div",
+	} {
+		g := MustParseGroup(t, sel)
+		for _, root := range []*html.Node{doc, body} {
+			if got, want := len(NewPlan(g).QueryAll(root)), len(QueryAll(root, g)); got != want {
+				t.Errorf("%s: plan found %d elements, want %d", sel, got, want)
+			}
+		}
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	tests := []struct {
+		sel, plan string
+	}{
+		{"div p", "test every element against div p"},
+		{".rare span", "test every element against .rare, then test their descendants against .rare span"},
+		{"#main ul > li", "test every element against #main, then test their descendants against #main ul > li"},
+		{"a.x + span", "test every element against a.x + span"},
+		{"li:first", "test every element against li:first"},
+		{"p, .a b", "test every element against p; test every element against .a, then test their descendants against .a b"},
+	}
+	for _, test := range tests {
+		if got := NewPlan(MustParseGroup(t, test.sel)).String(); got != test.plan {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.sel, got, test.plan)
+		}
+	}
+}
+
+func BenchmarkPlan(b *testing.B) {
+	var page strings.Builder
+	page.WriteString("<body>")
+	for i := 0; i < 1000; i++ {
+		page.WriteString("<div><p><span>text</span> <span>more</span></p></div>")
+	}
+	page.WriteString(`<div class="rare-class"><span>found</span></div></body>`)
+	doc, err := html.Parse(strings.NewReader(page.String()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	g, err := ParseGroup(".rare-class span")
+	if err != nil {
+		b.Fatal(err)
+	}
+	plan := NewPlan(g)
+	b.Run("QueryAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			QueryAll(doc, g)
+		}
+	})
+	b.Run("Plan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			plan.QueryAll(doc)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package cascadia
+
+import (
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Results holds the nodes found by QueryAllPooled or
+// Selector.MatchAllPooled. The slice of nodes, and the scratch space used
+// by the search, come from a pool, and go back to it when Release is
+// called, so a server running many queries doesn't allocate them for each
+// one.
+type Results struct {
+	// Nodes are the matching nodes, in document order. The slice is reused
+	// after Release is called, so it must not be kept.
+	Nodes []*html.Node
+
+	c      matchContext
+	search chainSearch
+}
+
+var resultsPool = sync.Pool{
+	New: func() interface{} { return new(Results) },
+}
+
+// maxPooledResults is the capacity of the largest slice of nodes that
+// Release keeps for reuse. Bigger ones are left for the garbage collector,
+// so that one huge query doesn't keep its memory in the pool.
+const maxPooledResults = 1 << 16
+
+// QueryAllPooled is like QueryAll, but it returns the nodes in a Results
+// from a pool. Call Release when finished with them.
+func QueryAllPooled(n *html.Node, m Matcher) *Results {
+	r := resultsPool.Get().(*Results)
+	if q, ok := newSimpleQuery(m); ok {
+		r.Nodes = q.queryAll(n, r.Nodes[:0], -1)
+		return r
+	}
+	r.c.reset(m)
+	if q := newChainQuery(m); q != nil {
+		r.Nodes = q.queryInto(&r.search, &r.c, n, r.Nodes[:0])
+	} else {
+		r.Nodes = filterResults(&r.c, m, queryInto(&r.c, n, dispatchMatcher(m), r.Nodes[:0]))
+	}
+	return r
+}
+
+// MatchAllPooled is like MatchAll, but it returns the nodes in a Results
+// from a pool. Call Release when finished with them.
+func (s Selector) MatchAllPooled(n *html.Node) *Results {
+	r := resultsPool.Get().(*Results)
+	r.Nodes = s.matchAllInto(n, r.Nodes[:0])
+	return r
+}
+
+// Release returns r to the pool. Neither r nor its Nodes may be used
+// afterwards.
+func (r *Results) Release() {
+	// Don't let the pool keep the document alive.
+	for i := range r.Nodes {
+		r.Nodes[i] = nil
+	}
+	if cap(r.Nodes) > maxPooledResults {
+		r.Nodes = nil
+	} else {
+		r.Nodes = r.Nodes[:0]
+	}
+	r.c.reset(nil)
+	resultsPool.Put(r)
+}
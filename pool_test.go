@@ -0,0 +1,70 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueryAllPooled(t *testing.T) {
+	doc := MustParseHTML(`<ul><li class="a">1</li><li>2</li><li class="a">3</li></ul><p>x</p>`)
+	for _, sel := range []string{"li", "ul > .a", "li:nth-child(2n+1)", "li:last", `p:contains("x"), li:contains("2")`} {
+		g := MustParseGroup(t, sel)
+		want := QueryAll(doc, g)
+		for i := 0; i < 3; i++ {
+			r := QueryAllPooled(doc, g)
+			if !reflect.DeepEqual(r.Nodes, want) {
+				t.Errorf("%s: QueryAllPooled found %d nodes, want %d", sel, len(r.Nodes), len(want))
+			}
+			r.Release()
+		}
+	}
+
+	s := MustCompile("li.a")
+	r := s.MatchAllPooled(doc)
+	if want := s.MatchAll(doc); !reflect.DeepEqual(r.Nodes, want) {
+		t.Errorf("MatchAllPooled found %d nodes, want %d", len(r.Nodes), len(want))
+	}
+	r.Release()
+}
+
+func TestQueryAllPooledAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector changes allocation counts")
+	}
+	small := MustParseHTML(strings.Repeat(`<ul><li class="a">1</li><li>2</li></ul>`, 2))
+	large := MustParseHTML(strings.Repeat(`<ul><li class="a">1</li><li>2</li></ul>`, 500))
+	for _, sel := range []string{"li.a", "ul > li.a", "li:nth-child(2)"} {
+		var m Matcher = MustParseGroup(t, sel)
+		// Fill the pool.
+		QueryAllPooled(large, m).Release()
+		want := testing.AllocsPerRun(50, func() {
+			QueryAllPooled(small, m).Release()
+		})
+		got := testing.AllocsPerRun(50, func() {
+			QueryAllPooled(large, m).Release()
+		})
+		if got > want {
+			t.Errorf("%s: %v allocations per query on a large document, and %v on a small one", sel, got, want)
+		}
+	}
+}
+
+func BenchmarkQueryAllPooled(b *testing.B) {
+	s, err := ParseGroup("div.matched")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("QueryAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			QueryAll(dom, s)
+		}
+	})
+	b.Run("QueryAllPooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			QueryAllPooled(dom, s).Release()
+		}
+	})
+}
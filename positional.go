@@ -0,0 +1,183 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// This file implements the result-set pseudo-classes (like jQuery's :eq()),
+// which select nodes by their position among all the nodes that a selector
+// matches, rather than by looking at each node on its own.
+//
+// A selector's Match method can't check these, so it returns false for a
+// selector that has them. QueryAll, Query and Filter test each node against
+// the rest of the selector, and then apply the result-set pseudo-classes to
+// the list of matches, in document order.
+
+type positionalPseudoClassSelector struct {
+	abstractPseudoClass
+	name  string // one of "eq", "gt", "lt", "first", "last", "even", "odd", "nth-match"
+	index int
+	a, b  int // for :nth-match(an+b)
+}
+
+// Match returns false, since the position can only be checked once all the
+// matches are known. Use QueryAll, Query or Filter instead.
+func (s positionalPseudoClassSelector) Match(n *html.Node) bool {
+	return false
+}
+
+// matchIn matches any element during a query whose results will be
+// filtered by filterResults.
+func (s positionalPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	return c != nil && c.results && n.Type == html.ElementNode
+}
+
+// filter returns the nodes from matches that are selected by s.
+func (s positionalPseudoClassSelector) filter(matches []*html.Node) []*html.Node {
+	// As in jQuery, a negative index counts back from the end.
+	index := s.index
+	if index < 0 {
+		index += len(matches)
+	}
+
+	switch s.name {
+	case "first":
+		if len(matches) == 0 {
+			return nil
+		}
+		return matches[:1]
+	case "last":
+		if len(matches) == 0 {
+			return nil
+		}
+		return matches[len(matches)-1:]
+	case "nth-match":
+		var result []*html.Node
+		for i, n := range matches {
+			if nthIndexMatch(s.a, s.b, i+1) {
+				result = append(result, n)
+			}
+		}
+		return result
+	case "even", "odd":
+		// Like jQuery, count from 0, so :even selects the first, third,
+		// fifth... matches.
+		start := 0
+		if s.name == "odd" {
+			start = 1
+		}
+		var result []*html.Node
+		for i := start; i < len(matches); i += 2 {
+			result = append(result, matches[i])
+		}
+		return result
+	case "eq":
+		if index < 0 || index >= len(matches) {
+			return nil
+		}
+		return matches[index : index+1]
+	case "gt":
+		if index < -1 {
+			index = -1
+		}
+		if index+1 >= len(matches) {
+			return nil
+		}
+		return matches[index+1:]
+	case "lt":
+		if index <= 0 {
+			return nil
+		}
+		if index > len(matches) {
+			index = len(matches)
+		}
+		return matches[:index]
+	default:
+		panic("unsupported result-set pseudo-class: " + s.name)
+	}
+}
+
+// positionalFilters returns the result-set pseudo-classes in s.
+// They can only occur in the last compound selector.
+func positionalFilters(s Sel) []positionalPseudoClassSelector {
+	switch s := s.(type) {
+	case positionalPseudoClassSelector:
+		return []positionalPseudoClassSelector{s}
+	case compoundSelector:
+		var filters []positionalPseudoClassSelector
+		for _, sel := range s.selectors {
+			if f, ok := sel.(positionalPseudoClassSelector); ok {
+				filters = append(filters, f)
+			}
+		}
+		return filters
+	case combinedSelector:
+		if s.second == nil {
+			return nil
+		}
+		return positionalFilters(s.second)
+	}
+	return nil
+}
+
+// hasPositional returns whether m contains any result-set pseudo-classes.
+func hasPositional(m Matcher) bool {
+	switch m := m.(type) {
+	case contextWrapper:
+		_, inner := m.unwrap(nil)
+		return hasPositional(inner)
+	case SelectorGroup:
+		for _, sel := range m {
+			if len(positionalFilters(sel)) > 0 {
+				return true
+			}
+		}
+	case Sel:
+		return len(positionalFilters(m)) > 0
+	}
+	return false
+}
+
+// filterResults applies the result-set pseudo-classes in m to matches,
+// which should be the nodes that m matched in the context c, in document order.
+func filterResults(c *matchContext, m Matcher, matches []*html.Node) []*html.Node {
+	if !hasPositional(m) {
+		return matches
+	}
+	if c == nil {
+		c = &matchContext{results: true}
+	}
+
+	switch m := m.(type) {
+	case contextWrapper:
+		c, inner := m.unwrap(c)
+		return filterResults(c, inner, matches)
+	case SelectorGroup:
+		// Each selector in the group has its own result set.
+		keep := make(map[*html.Node]bool)
+		for _, sel := range m {
+			var own []*html.Node
+			for _, n := range matches {
+				if c.match(sel, n) {
+					own = append(own, n)
+				}
+			}
+			for _, n := range filterResults(c, sel, own) {
+				keep[n] = true
+			}
+		}
+		var result []*html.Node
+		for _, n := range matches {
+			if keep[n] {
+				result = append(result, n)
+			}
+		}
+		return result
+	case Sel:
+		for _, f := range positionalFilters(m) {
+			matches = f.filter(matches)
+		}
+		return append([]*html.Node(nil), matches...)
+	}
+	return matches
+}
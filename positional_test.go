@@ -0,0 +1,264 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var positionalTests = []selectorTest{
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:eq(1)`,
+		[]string{
+			`<li id="2"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:eq(-1)`,
+		[]string{
+			`<li id="4"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:eq(4)`,
+		[]string{},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:gt(1)`,
+		[]string{
+			`<li id="3"></li>`,
+			`<li id="4"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:gt(-2)`,
+		[]string{
+			`<li id="4"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:lt(2)`,
+		[]string{
+			`<li id="1"></li>`,
+			`<li id="2"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`ul > li:gt(0):lt(1)`,
+		[]string{
+			`<li id="2"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2><li id=3></ul><ol><li id=4></ol>`,
+		`li:eq(0), ol li, ul :eq(2)`,
+		[]string{
+			`<li id="1"></li>`,
+			`<li id="3"></li>`,
+			`<li id="4"></li>`,
+		},
+	},
+	{
+		`<div><p id=1><p id=2></div><div><p id=3></div>`,
+		`div p:first, p:last`,
+		[]string{
+			`<p id="1"></p>`,
+			`<p id="3"></p>`,
+		},
+	},
+	{
+		`<div><p id=1><p id=2></div><div><p id=3></div>`,
+		`:first`,
+		[]string{
+			`<html><head></head><body><div><p id="1"></p><p id="2"></p></div><div><p id="3"></p></div></body></html>`,
+		},
+	},
+	{
+		`<div><p id=1><p id=2></div>`,
+		`span:first`,
+		[]string{},
+	},
+	{
+		`<ul><li id=1><li id=2></ul><ul><li id=3><li id=4><li id=5></ul>`,
+		`li:even`,
+		[]string{
+			`<li id="1"></li>`,
+			`<li id="3"></li>`,
+			`<li id="5"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2></ul><ul><li id=3><li id=4><li id=5></ul>`,
+		`li:odd`,
+		[]string{
+			`<li id="2"></li>`,
+			`<li id="4"></li>`,
+		},
+	},
+	{
+		`<ul><li id=1><li id=2></ul><ul><li id=3><li id=4><li id=5></ul>`,
+		`li:gt(0):odd`,
+		[]string{
+			`<li id="3"></li>`,
+			`<li id="5"></li>`,
+		},
+	},
+	{
+		`<p><a id=1 href=x><a id=2><a id=3 href=x></p><p><a id=4 href=x><a id=5 href=x></p>`,
+		`a[href]:nth-match(3)`,
+		[]string{
+			`<a id="4" href="x"></a>`,
+		},
+	},
+	{
+		`<p><a id=1 href=x><a id=2><a id=3 href=x></p><p><a id=4 href=x><a id=5 href=x></p>`,
+		`p a:nth-match(2n+1)`,
+		[]string{
+			`<a id="1" href="x"></a>`,
+			`<a id="3" href="x"></a>`,
+			`<a id="5" href="x"></a>`,
+		},
+	},
+	{
+		`<p><a id=1 href=x><a id=2><a id=3 href=x></p><p><a id=4 href=x><a id=5 href=x></p>`,
+		`a:nth-match(-n+2)`,
+		[]string{
+			`<a id="1" href="x"></a>`,
+			`<a id="2"></a>`,
+		},
+	},
+}
+
+func TestPositional(t *testing.T) {
+	for _, test := range positionalTests {
+		s, doc, err := setupMatcher(test.selector, test.HTML)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		matches := QueryAll(doc, s)
+		if len(matches) != len(test.results) {
+			t.Errorf("selector %s wanted %d elements, got %d instead", test.selector, len(test.results), len(matches))
+			continue
+		}
+		for i, m := range matches {
+			got := nodeString(m)
+			if got != test.results[i] {
+				t.Errorf("selector %s wanted %s, got %s instead", test.selector, test.results[i], got)
+			}
+		}
+
+		firstMatch := Query(doc, s)
+		if len(test.results) == 0 {
+			if firstMatch != nil {
+				t.Errorf("Query: selector %s want nil, got %s", test.selector, nodeString(firstMatch))
+			}
+		} else if got := nodeString(firstMatch); got != test.results[0] {
+			t.Errorf("Query: selector %s want %s, got %s", test.selector, test.results[0], got)
+		}
+
+		// Match can't know the positions, so the selectors that depend on
+		// them never match.
+		for _, sel := range s.(SelectorGroup) {
+			if !hasPositional(sel) {
+				continue
+			}
+			for _, m := range matches {
+				if sel.Match(m) {
+					t.Errorf("Match: selector %s matched %s", sel, nodeString(m))
+				}
+			}
+		}
+
+		all := QueryAll(doc, SelectorGroup{compoundSelector{}})
+		if filtered := Filter(all, s); !reflect.DeepEqual(filtered, matches) {
+			t.Errorf("Filter: selector %s got %d elements, want %d", test.selector, len(filtered), len(matches))
+		}
+
+		serialized := s.(SelectorGroup).String()
+		s2, err := ParseGroup(serialized)
+		if err != nil {
+			t.Errorf("error compiling %q: %s (original : %s)", serialized, err, test.selector)
+		} else if !reflect.DeepEqual(s, s2) {
+			t.Errorf("can't retrieve selector from serialized : %s (original : %s)", serialized, test.selector)
+		}
+	}
+}
+
+func TestPositionalPlacement(t *testing.T) {
+	for _, sel := range []string{
+		`li:eq(1) a`,
+		`div:last p:last`,
+		`p:first()`,
+		`:not(a:nth-match(1))`,
+		`div:not(:eq(1))`,
+		`div:has(p:first-child:lt(2))`,
+		`li:eq()`,
+		`li:eq(a)`,
+	} {
+		if _, err := ParseGroup(sel); err == nil {
+			t.Errorf("%s: expected an error", sel)
+		}
+	}
+
+	if _, err := Compile(`li:eq(1)`); err == nil {
+		t.Error("Compile should reject result-set pseudo-classes")
+	}
+}
+
+func TestFilterPositional(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p id=1><p id=2><p id=3>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := QueryAll(doc, MustParseGroup(t, "p"))
+	// Positions count from the start of the slice passed to Filter.
+	got := Filter(ps[1:], MustParseGroup(t, "p:eq(0)"))
+	if len(got) != 1 || got[0] != ps[1] {
+		t.Errorf("Filter with :eq(0) got %v, want the second p", got)
+	}
+}
+
+func TestFilterN(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p id=1 class=a><p id=2><p id=3 class=a><p id=4 class=a><p id=5>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := QueryAll(doc, MustParseGroup(t, "p"))
+	for _, test := range []struct {
+		sel   string
+		limit int
+		want  []*html.Node
+	}{
+		{".a", 2, []*html.Node{ps[0], ps[2]}},
+		{".a", -1, []*html.Node{ps[0], ps[2], ps[3]}},
+		{".a", 0, nil},
+		{":odd", 5, []*html.Node{ps[1], ps[3]}},
+		{":even", 2, []*html.Node{ps[0], ps[2]}},
+		{".a:last", 1, []*html.Node{ps[3]}},
+		{":gt(0)", -1, ps[1:]},
+	} {
+		got := FilterN(ps, MustParseGroup(t, test.sel), test.limit)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FilterN(%s, %d) returned %d nodes, want %d", test.sel, test.limit, len(got), len(test.want))
+		}
+	}
+}
+
+func MustParseGroup(t *testing.T, sel string) SelectorGroup {
+	s, err := ParseGroup(sel)
+	if err != nil {
+		t.Fatalf("error compiling %q: %s", sel, err)
+	}
+	return s
+}
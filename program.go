@@ -0,0 +1,357 @@
+package cascadia
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A Program is a selector compiled to a flat list of instructions, which a
+// small virtual machine runs to match nodes. It matches the same nodes as
+// the selector it was compiled from, but the common simple selectors (type,
+// class, ID and attribute) and the combinators are evaluated by the
+// machine directly, instead of by a tree of Matchers calling each other
+// through interfaces. Other pseudo-classes are run by their usual Matcher.
+//
+// A Program may be used by more than one goroutine at a time.
+type Program struct {
+	code     []instruction
+	strs     []string
+	attrs    []attrSelector
+	matchers []Matcher
+	source   string
+}
+
+type opcode uint8
+
+const (
+	opAccept      opcode = iota // the node matches
+	opSplit                     // try arg first, and if that fails, go on to the next instruction
+	opElement                   // the node is an element
+	opTag                       // the node is an element named strs[arg]
+	opID                        // the node's id is strs[arg]
+	opIDFold                    // the node's id is strs[arg], ignoring ASCII case
+	opClass                     // the node has the class strs[arg]
+	opClassFold                 // the node has the class strs[arg], ignoring ASCII case
+	opAttr                      // the node matches attrs[arg]
+	opMatch                     // the node matches matchers[arg]
+	opParent                    // move to the parent
+	opAncestor                  // move to an ancestor, trying each in turn
+	opPrevElement               // move to the previous sibling that isn't text or a comment
+	opPrevSibling               // move to a previous sibling, trying each in turn
+)
+
+var opcodeNames = [...]string{
+	opAccept:      "accept",
+	opSplit:       "split",
+	opElement:     "element",
+	opTag:         "tag",
+	opID:          "id",
+	opIDFold:      "id-fold",
+	opClass:       "class",
+	opClassFold:   "class-fold",
+	opAttr:        "attr",
+	opMatch:       "match",
+	opParent:      "parent",
+	opAncestor:    "ancestor",
+	opPrevElement: "prev-element",
+	opPrevSibling: "prev-sibling",
+}
+
+type instruction struct {
+	op  opcode
+	arg int
+}
+
+// A backtrack is a point that the machine returns to when a test fails:
+// the instruction to resume at, and the node to resume with.
+type backtrack struct {
+	pc   int
+	node *html.Node
+}
+
+// CompileProgram parses a selector (or group of selectors) and compiles it
+// to a Program.
+func CompileProgram(sel string) (*Program, error) {
+	g, err := ParseGroup(sel)
+	if err != nil {
+		return nil, err
+	}
+	return NewProgram(g)
+}
+
+// MustCompileProgram is like CompileProgram, but panics instead of
+// returning an error.
+func MustCompileProgram(sel string) *Program {
+	p, err := CompileProgram(sel)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// NewProgram compiles m, which should be a Sel or a SelectorGroup from this
+// package, to a Program. Other Matchers are run as a single instruction.
+// Result-set pseudo-classes like :first are not supported, since they
+// don't depend on the node alone.
+func NewProgram(m Matcher) (*Program, error) {
+	if hasPositional(m) {
+		return nil, fmt.Errorf("compiling %s: result-set pseudo-classes are not supported by Program", m)
+	}
+	p := new(Program)
+	if s, ok := m.(fmt.Stringer); ok {
+		p.source = s.String()
+	}
+	switch m := m.(type) {
+	case SelectorGroup:
+		var splits []int
+		for i, s := range m {
+			if i < len(m)-1 {
+				splits = append(splits, p.emit(opSplit, 0))
+			}
+			p.compile(s)
+			p.emit(opAccept, 0)
+			if i < len(m)-1 {
+				p.code[splits[i]].arg = len(p.code)
+			}
+		}
+		if len(m) == 0 {
+			// An empty group matches nothing.
+			p.emit(opMatch, p.addMatcher(neverMatchSelector{}))
+		}
+	case Sel:
+		p.compile(m)
+		p.emit(opAccept, 0)
+	default:
+		p.emit(opMatch, p.addMatcher(m))
+		p.emit(opAccept, 0)
+	}
+	return p, nil
+}
+
+func (p *Program) emit(op opcode, arg int) int {
+	p.code = append(p.code, instruction{op, arg})
+	return len(p.code) - 1
+}
+
+func (p *Program) addString(s string) int {
+	p.strs = append(p.strs, s)
+	return len(p.strs) - 1
+}
+
+func (p *Program) addMatcher(m Matcher) int {
+	p.matchers = append(p.matchers, m)
+	return len(p.matchers) - 1
+}
+
+// compile emits the instructions for s. Like combinedSelector.Match, it
+// works from right to left: it tests the node, then moves to a parent or
+// sibling and tests that.
+func (p *Program) compile(s Sel) {
+	switch s := s.(type) {
+	case Builder:
+		p.compile(s.Sel())
+	case combinedSelector:
+		if s.first == nil {
+			break
+		}
+		if s.second == nil || s.combinator == 0 {
+			p.compile(s.first)
+			return
+		}
+		var move opcode
+		switch s.combinator {
+		case ' ':
+			move = opAncestor
+		case '>':
+			move = opParent
+		case '+':
+			move = opPrevElement
+		case '~':
+			move = opPrevSibling
+		default:
+			panic("unknown combinator")
+		}
+		p.compile(s.second)
+		p.emit(move, 0)
+		p.compile(s.first)
+		return
+	case compoundSelector:
+		if len(s.selectors) == 0 {
+			p.emit(opElement, 0)
+			return
+		}
+		// The cheap tests go first, so that most nodes are rejected
+		// quickly.
+		parts := append([]Sel(nil), s.selectors...)
+		sort.SliceStable(parts, func(i, j int) bool {
+			return simpleCost(parts[i]) < simpleCost(parts[j])
+		})
+		for _, part := range parts {
+			p.compileSimple(part)
+		}
+		return
+	default:
+		p.compileSimple(s)
+		return
+	}
+	p.emit(opMatch, p.addMatcher(s))
+}
+
+func (p *Program) compileSimple(s Sel) {
+	switch s := s.(type) {
+	case tagSelector:
+		if s.name != "" {
+			p.emit(opMatch, p.addMatcher(s))
+			break
+		}
+		p.emit(opTag, p.addString(s.tag))
+	case idSelector:
+		if s.quirks {
+			p.emit(opIDFold, p.addString(s.id))
+		} else {
+			p.emit(opID, p.addString(s.id))
+		}
+	case classSelector:
+		if s.quirks {
+			p.emit(opClassFold, p.addString(s.class))
+		} else {
+			p.emit(opClass, p.addString(s.class))
+		}
+	case attrSelector:
+		p.attrs = append(p.attrs, s)
+		p.emit(opAttr, len(p.attrs)-1)
+	case compoundSelector, combinedSelector, Builder:
+		p.compile(s)
+	default:
+		p.emit(opMatch, p.addMatcher(s))
+	}
+}
+
+// Match returns whether n matches the program's selector.
+func (p *Program) Match(n *html.Node) bool {
+	return p.matchIn(nil, n)
+}
+
+func (p *Program) matchIn(c *matchContext, n *html.Node) bool {
+	var buf [8]backtrack
+	stack := buf[:0]
+	pc, cur := 0, n
+	for {
+		in := p.code[pc]
+		ok := true
+		switch in.op {
+		case opAccept:
+			return true
+		case opSplit:
+			stack = append(stack, backtrack{in.arg, cur})
+		case opElement:
+			ok = cur.Type == html.ElementNode
+		case opTag:
+			ok = cur.Type == html.ElementNode && cur.Data == p.strs[in.arg]
+		case opID, opIDFold, opClass, opClassFold:
+			ok = p.matchName(in, cur)
+		case opAttr:
+			ok = p.attrs[in.arg].matchIn(c, cur)
+		case opMatch:
+			ok = c.match(p.matchers[in.arg], cur)
+		case opParent:
+			cur = c.parent(cur)
+			ok = cur != nil
+			if ok {
+				c.step()
+			}
+		case opAncestor:
+			cur = c.parent(cur)
+			ok = cur != nil
+			if ok {
+				c.step()
+				// If the rest of the selector fails, try the next
+				// ancestor.
+				stack = append(stack, backtrack{pc, cur})
+			}
+		case opPrevElement:
+			cur = c.prevSibling(cur)
+			for cur != nil && (cur.Type == html.TextNode || cur.Type == html.CommentNode) {
+				c.step()
+				cur = cur.PrevSibling
+			}
+			ok = cur != nil
+			if ok {
+				c.step()
+			}
+		case opPrevSibling:
+			cur = c.prevSibling(cur)
+			ok = cur != nil
+			if ok {
+				c.step()
+				stack = append(stack, backtrack{pc, cur})
+			}
+		}
+
+		if ok {
+			pc++
+			continue
+		}
+		if len(stack) == 0 {
+			return false
+		}
+		b := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		pc, cur = b.pc, b.node
+	}
+}
+
+// matchName runs one of the instructions that test an element's id or
+// class.
+func (p *Program) matchName(in instruction, n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	key := "class"
+	if in.op == opID || in.op == opIDFold {
+		key = "id"
+	}
+	want := p.strs[in.arg]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			continue
+		}
+		v := a.Val
+		if in.op == opIDFold || in.op == opClassFold {
+			v = toLowerASCII(v)
+		}
+		if key == "id" && v == want || key == "class" && matchInclude(want, v, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the selector that p was compiled from.
+func (p *Program) String() string {
+	return p.source
+}
+
+// Disassemble returns a listing of the program's instructions, one per
+// line, for debugging.
+func (p *Program) Disassemble() string {
+	var b strings.Builder
+	for pc, in := range p.code {
+		fmt.Fprintf(&b, "%d\t%s", pc, opcodeNames[in.op])
+		switch in.op {
+		case opSplit:
+			fmt.Fprintf(&b, " %d", in.arg)
+		case opTag, opID, opIDFold, opClass, opClassFold:
+			fmt.Fprintf(&b, " %s", p.strs[in.arg])
+		case opAttr:
+			fmt.Fprintf(&b, " %s", p.attrs[in.arg])
+		case opMatch:
+			fmt.Fprintf(&b, " %v", p.matchers[in.arg])
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
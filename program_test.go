@@ -0,0 +1,131 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestProgramMatchesSelector(t *testing.T) {
+	for _, test := range selectorTests {
+		g, err := ParseGroup(test.selector)
+		if err != nil || hasPositional(g) {
+			continue
+		}
+		p, err := NewProgram(g)
+		if err != nil {
+			t.Errorf("%s: %v", test.selector, err)
+			continue
+		}
+		doc, err := html.Parse(strings.NewReader(test.HTML))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := QueryAll(doc, g)
+		got := QueryAll(doc, p)
+		if len(got) != len(want) {
+			t.Errorf("%s: program matched %d elements, want %d\n%s", test.selector, len(got), len(want), p.Disassemble())
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: match %d is %s, want %s", test.selector, i, nodeString(got[i]), nodeString(want[i]))
+			}
+		}
+	}
+}
+
+func TestProgramShakespeare(t *testing.T) {
+	doc := parseReference("test_resources/shakespeare.html")
+	for _, sel := range []string{
+		"div div div",
+		"div.dialog .dialog .direction",
+		"div#scene1 div.dialog div",
+		"div + div",
+		"div ~ div > div",
+		"div.character, div.dialog",
+		"div:nth-child(2n+1) > div:not(.dialog)",
+		"body > div[class] ~ div",
+	} {
+		g := MustParseGroup(t, sel)
+		p, err := NewProgram(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(QueryAll(doc, p)), len(QueryAll(doc, g)); got != want {
+			t.Errorf("%s: program matched %d elements, want %d", sel, got, want)
+		}
+	}
+}
+
+func TestProgramDisassemble(t *testing.T) {
+	p := MustCompileProgram("ul > li.item[title], p:empty")
+	want := `0	split 7
+1	tag li
+2	class item
+3	attr [title]
+4	parent
+5	tag ul
+6	accept
+7	tag p
+8	match :empty
+9	accept
+`
+	if got := p.Disassemble(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := p.String(); got != "ul > li.item[title], p:empty" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestProgramPositional(t *testing.T) {
+	if _, err := CompileProgram("li:first"); err == nil {
+		t.Error("CompileProgram accepted a result-set pseudo-class")
+	}
+}
+
+func TestProgramContext(t *testing.T) {
+	doc := MustParseHTML(`<div id="outer"><section><p>a</p></section></div>`)
+	section := Query(doc, MustCompile("section"))
+	p := MustCompileProgram("div p")
+	if got := QueryContext(section, p); got != nil {
+		t.Errorf("QueryContext matched %s, but the div is outside the section", nodeString(got))
+	}
+	if got := Query(section, p); got == nil {
+		t.Error("Query didn't match the p")
+	}
+}
+
+// BenchmarkProgram compares a large group of selectors, compiled to a
+// Program, with the same group matched directly.
+func BenchmarkProgram(b *testing.B) {
+	var selectors []string
+	for i := 0; i < 200; i++ {
+		selectors = append(selectors, "div.c"+string(rune('a'+i%26))+string(rune('a'+i/26))+" p")
+	}
+	selectors = append(selectors, "li", "#main", ".intro")
+	g, err := ParseGroup(strings.Join(selectors, ", "))
+	if err != nil {
+		b.Fatal(err)
+	}
+	p, err := NewProgram(g)
+	if err != nil {
+		b.Fatal(err)
+	}
+	doc, err := html.Parse(strings.NewReader(selectorSetHTML))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("group", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			QueryAll(doc, g)
+		}
+	})
+	b.Run("program", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			QueryAll(doc, p)
+		}
+	})
+}
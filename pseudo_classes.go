@@ -3,8 +3,9 @@ package cascadia
 import (
 	"bytes"
 	"fmt"
-	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -26,61 +27,73 @@ func (c abstractPseudoClass) PseudoElement() string {
 type relativePseudoClassSelector struct {
 	name  string // one of "not", "has", "haschild"
 	match SelectorGroup
+
+	// depth is how many levels below the element :has() searches, or 0
+	// for no limit. See ParseOptions.MaxHasDepth.
+	depth int
 }
 
 func (s relativePseudoClassSelector) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s relativePseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
 	if n.Type != html.ElementNode {
 		return false
 	}
 	switch s.name {
 	case "not":
 		// matches elements that do not match a.
-		return !s.match.Match(n)
+		return !s.match.matchIn(c, n)
 	case "has":
 		//  matches elements with any descendant that matches a.
-		return hasDescendantMatch(n, s.match)
+		return hasDescendantMatch(c, n, s.match, c.hasDepth(s.depth))
 	case "haschild":
 		// matches elements with a child that matches a.
-		return hasChildMatch(n, s.match)
+		return hasChildMatch(c, n, s.match)
 	default:
 		panic(fmt.Sprintf("unsupported relative pseudo class selector : %s", s.name))
 	}
 }
 
 // hasChildMatch returns whether n has any child that matches a.
-func hasChildMatch(n *html.Node, a Matcher) bool {
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if a.Match(c) {
-			return true
-		}
+func hasChildMatch(ctx *matchContext, n *html.Node, a Matcher) bool {
+	if ctx.inert(n) {
+		return false
 	}
-	return false
+	ctx.enter()
+	found := false
+	for c := n.FirstChild; c != nil && !found; c = c.NextSibling {
+		ctx.step()
+		found = ctx.match(a, c)
+	}
+	ctx.leave()
+	return found
 }
 
 // hasDescendantMatch performs a depth-first search of n's descendants,
 // testing whether any of them match a. It returns true as soon as a match is
-// found, or false if no match is found.
-func hasDescendantMatch(n *html.Node, a Matcher) bool {
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if a.Match(c) || (c.Type == html.ElementNode && hasDescendantMatch(c, a)) {
-			return true
-		}
+// found, or false if no match is found. If depth is positive, only that many
+// levels below n are searched.
+func hasDescendantMatch(ctx *matchContext, n *html.Node, a Matcher, depth int) bool {
+	if ctx.inert(n) {
+		return false
 	}
-	return false
+	ctx.enter()
+	found := false
+	for c := n.FirstChild; c != nil && !found; c = c.NextSibling {
+		ctx.step()
+		found = ctx.match(a, c) || (depth != 1 && c.Type == html.ElementNode && c.FirstChild != nil && hasDescendantMatch(ctx, c, a, depth-1))
+	}
+	ctx.leave()
+	return found
 }
 
 // Specificity returns the specificity of the most specific selectors
 // in the pseudo-class arguments.
 // See https://www.w3.org/TR/selectors/#specificity-rules
 func (s relativePseudoClassSelector) Specificity() Specificity {
-	var max Specificity
-	for _, sel := range s.match {
-		newSpe := sel.Specificity()
-		if max.Less(newSpe) {
-			max = newSpe
-		}
-	}
-	return max
+	return s.match.MaxSpecificity()
 }
 
 func (c relativePseudoClassSelector) PseudoElement() string {
@@ -91,36 +104,156 @@ type containsPseudoClassSelector struct {
 	abstractPseudoClass
 	value string
 	own   bool
+	fold  bool // use full Unicode case folding instead of lowercasing
+	word  bool // only match value as a whole word
+	text  TextFunc
 }
 
 func (s containsPseudoClassSelector) Match(n *html.Node) bool {
-	var text string
-	if s.own {
-		// matches nodes that directly contain the given text
-		text = strings.ToLower(nodeOwnText(n))
-	} else {
-		// matches nodes that contain the given text.
-		text = strings.ToLower(nodeText(n))
+	return s.matchIn(nil, n)
+}
+
+func (s containsPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	// With s.own, matches nodes that directly contain the given text.
+	if !s.fold && !s.word && (c == nil || c.texts == nil) {
+		if found, ok := c.containsLower(n, s.own, s.text, s.value); ok {
+			return found
+		}
+	}
+	text := c.lowerText(n, s.own, s.text, s.fold)
+	if s.word && !s.fold {
+		return containsWord(text, s.value)
 	}
 	return strings.Contains(text, s.value)
 }
 
+// containsWord returns whether word occurs in text with no letter or digit
+// immediately before or after it.
+func containsWord(text, word string) bool {
+	if word == "" {
+		return false
+	}
+	for i := 0; i < len(text); {
+		j := strings.Index(text[i:], word)
+		if j == -1 {
+			return false
+		}
+		start, end := i+j, i+j+len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(text) || !isWordRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		i = start + size
+	}
+	return false
+}
+
+// isWordRune returns whether r can be part of a word for :contains-word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// fullCaseFolds lists the characters whose Unicode case folding expands to
+// more than one character, and so can't be handled rune by rune.
+var fullCaseFolds = map[rune]string{
+	'ß': "ss",
+	'ẞ': "ss",
+	'ŉ': "ʼn",
+	'ﬀ': "ff",
+	'ﬁ': "fi",
+	'ﬂ': "fl",
+	'ﬃ': "ffi",
+	'ﬄ': "ffl",
+	'ﬅ': "st",
+	'ﬆ': "st",
+}
+
+// foldString returns s with Unicode case folding applied, so that strings
+// which differ only in case (including "straße" and "STRASSE", or the
+// Turkish dotted and dotless i) fold to the same string.
+func foldString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if f, ok := fullCaseFolds[r]; ok {
+			b.WriteString(f)
+			continue
+		}
+		// Going through upper case first maps variants like the final
+		// sigma and the Kelvin sign to the same lower-case letter.
+		b.WriteRune(unicode.ToLower(unicode.ToUpper(r)))
+	}
+	return b.String()
+}
+
+type textIsPseudoClassSelector struct {
+	abstractPseudoClass
+	value string
+	text  TextFunc
+}
+
+// Matches elements whose text, with whitespace trimmed and collapsed, is
+// exactly s.value.
+func (s textIsPseudoClassSelector) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s textIsPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	return c.textMatches(n, s.text, s.value)
+}
+
+// collapseWhitespace trims leading and trailing whitespace from s, and
+// replaces each other run of whitespace with a single space.
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 type regexpPseudoClassSelector struct {
 	abstractPseudoClass
-	regexp *regexp.Regexp
+	regexp pattern
 	own    bool
+	text   TextFunc
 }
 
 func (s regexpPseudoClassSelector) Match(n *html.Node) bool {
-	var text string
-	if s.own {
-		// matches nodes whose text directly matches the specified regular expression
-		text = nodeOwnText(n)
-	} else {
-		// matches nodes whose text matches the specified regular expression
-		text = nodeText(n)
+	return s.matchIn(nil, n)
+}
+
+func (s regexpPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	// With s.own, matches nodes whose own text matches the regular
+	// expression.
+	if (s.own || s.text == nil) && (c == nil || c.texts == nil) {
+		return c.matchTextRegexp(s.regexp, n, s.own)
 	}
-	return s.regexp.MatchString(text)
+	return c.matchRegexp(s.regexp, c.text(n, s.own, s.text))
+}
+
+type tagRegexpPseudoClassSelector struct {
+	regexp pattern
+}
+
+// Matches elements whose tag name matches the regular expression.
+func (s tagRegexpPseudoClassSelector) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s tagRegexpPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	return n.Type == html.ElementNode && c.matchRegexp(s.regexp, n.Data)
+}
+
+// Specificity is the same as a type selector's, since :tag-matches() takes
+// the place of one.
+func (s tagRegexpPseudoClassSelector) Specificity() Specificity {
+	return Specificity{0, 0, 1}
+}
+
+func (s tagRegexpPseudoClassSelector) PseudoElement() string {
+	return ""
 }
 
 // writeNodeText writes the text contained in n and its descendants to b.
@@ -142,6 +275,43 @@ func nodeText(n *html.Node) string {
 	return b.String()
 }
 
+// A TextFunc returns the text of an element, for the pseudo-classes that
+// match text, like :contains() and :matches(). See ParseOptions.Text.
+type TextFunc func(n *html.Node) string
+
+// of returns the text of n, using f if it is not nil, or else all of the
+// text in n and its descendants.
+func (f TextFunc) of(n *html.Node) string {
+	if f == nil {
+		return nodeText(n)
+	}
+	return f(n)
+}
+
+// VisibleText is a TextFunc that returns the text contained in n and its
+// descendants, skipping the contents of script, style and template
+// elements, which aren't displayed as text.
+func VisibleText(n *html.Node) string {
+	var b bytes.Buffer
+	writeVisibleText(n, &b)
+	return b.String()
+}
+
+func writeVisibleText(n *html.Node, b *bytes.Buffer) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Template:
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeVisibleText(c, b)
+		}
+	}
+}
+
 // nodeOwnText returns the contents of the text nodes that are direct
 // children of n.
 func nodeOwnText(n *html.Node) string {
@@ -161,6 +331,17 @@ type nthPseudoClassSelector struct {
 }
 
 func (s nthPseudoClassSelector) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s nthPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	if n == c.rootNode() {
+		// The root is the only child of its tree.
+		return n.Type == html.ElementNode && nthIndexMatch(s.a, s.b, 1)
+	}
+	if c != nil && n.Type == html.ElementNode && (n.Parent != nil || c.inFragment(n)) {
+		return nthIndexMatch(s.a, s.b, c.childIndex(n, s.last, s.ofType))
+	}
 	if s.a == 0 {
 		if s.last {
 			return simpleNthLastChildMatch(s.b, s.ofType, n)
@@ -208,6 +389,12 @@ func nthChildMatch(a, b int, last, ofType bool, n *html.Node) bool {
 		i = count - i + 1
 	}
 
+	return nthIndexMatch(a, b, i)
+}
+
+// nthIndexMatch returns whether the 1-based index i is of the form an+b,
+// for some n >= 0.
+func nthIndexMatch(a, b, i int) bool {
 	i -= b
 	if a == 0 {
 		return i == 0
@@ -272,6 +459,38 @@ func simpleNthLastChildMatch(b int, ofType bool, n *html.Node) bool {
 	return false
 }
 
+type dataPseudoClassSelector struct {
+	abstractPseudoClass
+	key      string // the full attribute name, including "data-"
+	value    string
+	hasValue bool
+}
+
+// Matches elements that have the data attribute, with the given value if
+// there is one.
+func (s dataPseudoClassSelector) Match(n *html.Node) bool {
+	return matchAttribute(n, s.key, func(val string) bool {
+		return !s.hasValue || val == s.value
+	})
+}
+
+// dataAttributeName converts the key of a data attribute, as it would be
+// used with the dataset property in JavaScript, to an attribute name. For
+// example, "fooBar" becomes "data-foo-bar".
+func dataAttributeName(key string) string {
+	var b strings.Builder
+	b.WriteString("data-")
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' {
+			b.WriteByte('-')
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 type onlyChildPseudoClassSelector struct {
 	abstractPseudoClass
 	ofType bool
@@ -303,6 +522,16 @@ func (s onlyChildPseudoClassSelector) Match(n *html.Node) bool {
 	return count == 1
 }
 
+func (s onlyChildPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	if n == c.rootNode() {
+		return n.Type == html.ElementNode
+	}
+	if n.Type == html.ElementNode && c.inFragment(n) {
+		return c.childIndex(n, false, s.ofType) == 1 && c.childIndex(n, true, s.ofType) == 1
+	}
+	return s.Match(n)
+}
+
 type inputPseudoClassSelector struct {
 	abstractPseudoClass
 }
@@ -312,6 +541,101 @@ func (s inputPseudoClassSelector) Match(n *html.Node) bool {
 	return n.Type == html.ElementNode && (n.Data == "input" || n.Data == "select" || n.Data == "textarea" || n.Data == "button")
 }
 
+type inputTypePseudoClassSelector struct {
+	abstractPseudoClass
+	inputType string
+}
+
+// Matches input elements by type (like :checkbox or :submit). For :submit
+// and :reset, button elements of that type match too.
+func (s inputTypePseudoClassSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Input:
+		return inputType(n) == s.inputType
+	case atom.Button:
+		return buttonType(n) == s.inputType
+	}
+	return false
+}
+
+// inputTypes lists the valid values of the type attribute of an input element.
+var inputTypes = map[string]bool{
+	"hidden": true, "text": true, "search": true, "tel": true, "url": true,
+	"email": true, "password": true, "date": true, "month": true, "week": true,
+	"time": true, "datetime-local": true, "number": true, "range": true,
+	"color": true, "checkbox": true, "radio": true, "file": true,
+	"submit": true, "image": true, "reset": true, "button": true,
+}
+
+// inputType returns the (lowercased) type of the input element n.
+// As in HTML, a missing or invalid type attribute means "text".
+func inputType(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "type" {
+			if t := toLowerASCII(strings.TrimSpace(a.Val)); inputTypes[t] {
+				return t
+			}
+			break
+		}
+	}
+	return "text"
+}
+
+// buttonType returns the (lowercased) type of the button element n.
+// As in HTML, a missing or invalid type attribute means "submit".
+func buttonType(n *html.Node) string {
+	for _, a := range n.Attr {
+		if a.Key == "type" {
+			switch t := toLowerASCII(strings.TrimSpace(a.Val)); t {
+			case "submit", "reset", "button":
+				return t
+			}
+			break
+		}
+	}
+	return "submit"
+}
+
+type buttonPseudoClassSelector struct {
+	abstractPseudoClass
+}
+
+// Matches button elements, and input elements that are displayed as buttons.
+func (s buttonPseudoClassSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Button:
+		return true
+	case atom.Input:
+		switch inputType(n) {
+		case "button", "submit", "reset":
+			return true
+		}
+	}
+	return false
+}
+
+type headerPseudoClassSelector struct {
+	abstractPseudoClass
+}
+
+// Matches heading elements (h1 to h6).
+func (s headerPseudoClassSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
 type emptyElementPseudoClassSelector struct {
 	abstractPseudoClass
 }
@@ -338,6 +662,15 @@ func (s emptyElementPseudoClassSelector) Match(n *html.Node) bool {
 	return true
 }
 
+type parentPseudoClassSelector struct {
+	abstractPseudoClass
+}
+
+// Matches elements that are not empty: the inverse of :empty.
+func (s parentPseudoClassSelector) Match(n *html.Node) bool {
+	return n.Type == html.ElementNode && !emptyElementPseudoClassSelector{}.Match(n)
+}
+
 type rootPseudoClassSelector struct {
 	abstractPseudoClass
 }
@@ -353,6 +686,75 @@ func (s rootPseudoClassSelector) Match(n *html.Node) bool {
 	return n.Parent.Type == html.DocumentNode
 }
 
+func (s rootPseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	if root := c.rootNode(); root != nil {
+		return n == root && n.Type == html.ElementNode
+	}
+	return s.Match(n)
+}
+
+type scopePseudoClassSelector struct {
+	abstractPseudoClass
+	implicit bool // added to anchor a relative selector, rather than written
+}
+
+// Match implements :scope when there is no scoping element, so that it is
+// the same as :root.
+func (s scopePseudoClassSelector) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s scopePseudoClassSelector) matchIn(c *matchContext, n *html.Node) bool {
+	if scope := c.scopeNode(); scope != nil {
+		return n == scope
+	}
+	return rootPseudoClassSelector{}.matchIn(c, n)
+}
+
+// An implicit :scope doesn't add to the specificity.
+func (s scopePseudoClassSelector) Specificity() Specificity {
+	if s.implicit {
+		return Specificity{0, 0, 0}
+	}
+	return Specificity{0, 1, 0}
+}
+
+// containsScope returns whether s uses :scope explicitly.
+func containsScope(s Sel) bool {
+	switch s := s.(type) {
+	case scopePseudoClassSelector:
+		return !s.implicit
+	case compoundSelector:
+		for _, sel := range s.selectors {
+			if containsScope(sel) {
+				return true
+			}
+		}
+	case combinedSelector:
+		return containsScope(s.first) || s.second != nil && containsScope(s.second)
+	case relativePseudoClassSelector:
+		for _, sel := range s.match {
+			if containsScope(sel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopeCombinator returns the combinator that follows :scope at the start of
+// s, or 0 if s doesn't start with :scope.
+func scopeCombinator(s Sel) byte {
+	c, ok := s.(combinedSelector)
+	if !ok {
+		return 0
+	}
+	if _, ok := c.first.(scopePseudoClassSelector); ok {
+		return c.combinator
+	}
+	return scopeCombinator(c.first)
+}
+
 func hasAttr(n *html.Node, attr string) bool {
 	return matchAttribute(n, attr, func(string) bool { return true })
 }
@@ -456,3 +858,71 @@ func (s checkedPseudoClassSelector) Match(n *html.Node) bool {
 	}
 	return false
 }
+
+type visibilityPseudoClassSelector struct {
+	abstractPseudoClass
+	visible bool
+}
+
+// Match implements :hidden, or :visible if s.visible is true.
+// Since there is no layout information, it just looks for markup that hides
+// an element or one of its ancestors.
+func (s visibilityPseudoClassSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	return isHidden(n) != s.visible
+}
+
+// isHidden returns whether n would probably not be displayed.
+func isHidden(n *html.Node) bool {
+	if n.DataAtom == atom.Input && inputType(n) == "hidden" {
+		return true
+	}
+	// The visibility property is inherited, but a descendant can set it
+	// back to visible, so only the nearest element that sets it counts.
+	visibilitySet := false
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		switch n.DataAtom {
+		case atom.Head, atom.Script, atom.Style, atom.Template:
+			// never rendered
+			return true
+		}
+		if hasAttr(n, "hidden") {
+			return true
+		}
+		if matchAttribute(n, "aria-hidden", func(val string) bool { return toLowerASCII(val) == "true" }) {
+			return true
+		}
+		if display := inlineStyle(n, "display"); display == "none" {
+			return true
+		}
+		if visibility := inlineStyle(n, "visibility"); visibility != "" && !visibilitySet {
+			visibilitySet = true
+			if visibility == "hidden" || visibility == "collapse" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inlineStyle returns the lowercased value of the CSS property prop from n's
+// style attribute, or "" if it isn't set there.
+func inlineStyle(n *html.Node, prop string) string {
+	var value string
+	matchAttribute(n, "style", func(style string) bool {
+		for _, decl := range strings.Split(style, ";") {
+			colon := strings.IndexByte(decl, ':')
+			if colon == -1 || toLowerASCII(strings.TrimSpace(decl[:colon])) != prop {
+				continue
+			}
+			v := toLowerASCII(strings.TrimSpace(decl[colon+1:]))
+			v = strings.TrimSpace(strings.TrimSuffix(v, "!important"))
+			// Later declarations override earlier ones.
+			value = v
+		}
+		return true
+	})
+	return value
+}
@@ -0,0 +1,109 @@
+//go:build !cascadia_noregexp
+
+package cascadia
+
+import (
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+)
+
+// A pattern is a compiled regular expression, as used by the #= attribute
+// operator and by pseudo-classes like :matches(). The regexp package is
+// fairly large, so programs that need to be small (for example with TinyGo
+// or WebAssembly) can leave it out by building with the cascadia_noregexp
+// tag. Then selectors that use regular expressions fail to parse.
+type pattern = *lazyPattern
+
+// A lazyPattern is a regular expression that has been checked for syntax
+// errors, but is only compiled the first time it is used, so that loading
+// a large set of rules doesn't pay for compiling the ones that never run.
+type lazyPattern struct {
+	expr string
+	once sync.Once
+	rx   *regexp.Regexp
+}
+
+// MatchString returns whether p matches s.
+func (p *lazyPattern) MatchString(s string) bool {
+	rx := p.compiled()
+	return rx != nil && rx.MatchString(s)
+}
+
+// MatchReader returns whether p matches the text read from r. It stops
+// reading once it finds a match.
+func (p *lazyPattern) MatchReader(r io.RuneReader) bool {
+	rx := p.compiled()
+	return rx != nil && rx.MatchReader(r)
+}
+
+// FindStringSubmatch returns the leftmost match of p in s and the matches of
+// its subexpressions, as regexp.Regexp's method does, or nil if there is
+// none.
+func (p *lazyPattern) FindStringSubmatch(s string) []string {
+	rx := p.compiled()
+	if rx == nil {
+		return nil
+	}
+	return rx.FindStringSubmatch(s)
+}
+
+// compiled returns the compiled expression, compiling it the first time.
+func (p *lazyPattern) compiled() *regexp.Regexp {
+	p.once.Do(func() {
+		// The syntax was checked when p was created, so this only fails
+		// if the expression is too large to compile. Then it matches
+		// nothing.
+		p.rx, _ = regexp.Compile(p.expr)
+	})
+	return p.rx
+}
+
+// String returns the source text of p.
+func (p *lazyPattern) String() string {
+	return p.expr
+}
+
+// maxCachedPatterns limits the number of patterns that are kept to be
+// shared between selectors, so that a program that parses selectors from
+// untrusted input doesn't keep them all.
+const maxCachedPatterns = 1000
+
+var patternCache = struct {
+	sync.Mutex
+	m map[string]*lazyPattern
+}{m: make(map[string]*lazyPattern)}
+
+// compilePattern returns the pattern for expr, sharing it with the other
+// selectors that use the same expression.
+func compilePattern(expr string) (pattern, error) {
+	patternCache.Lock()
+	p := patternCache.m[expr]
+	patternCache.Unlock()
+	if p != nil {
+		return p, nil
+	}
+
+	if err := checkPattern(expr); err != nil {
+		return nil, err
+	}
+	p = &lazyPattern{expr: expr}
+
+	patternCache.Lock()
+	defer patternCache.Unlock()
+	if cached := patternCache.m[expr]; cached != nil {
+		return cached, nil
+	}
+	if len(patternCache.m) < maxCachedPatterns {
+		patternCache.m[expr] = p
+	}
+	return p, nil
+}
+
+// checkPattern returns an error if expr is not a valid regular expression,
+// without compiling it.
+func checkPattern(expr string) error {
+	_, err := syntax.Parse(expr, syntax.Perl)
+	return err
+}
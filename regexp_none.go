@@ -0,0 +1,44 @@
+//go:build cascadia_noregexp
+
+package cascadia
+
+import (
+	"errors"
+	"io"
+)
+
+// errNoRegexp is returned when parsing a selector that uses a regular
+// expression in a build without regexp support.
+var errNoRegexp = errors.New("regular expressions are not supported in this build (cascadia_noregexp)")
+
+// A pattern would be a compiled regular expression, but this build doesn't
+// support them, so none is ever created.
+type pattern = *noPattern
+
+type noPattern struct {
+	expr string
+}
+
+func (p *noPattern) MatchString(s string) bool {
+	return false
+}
+
+func (p *noPattern) MatchReader(r io.RuneReader) bool {
+	return false
+}
+
+func (p *noPattern) FindStringSubmatch(s string) []string {
+	return nil
+}
+
+func (p *noPattern) String() string {
+	return p.expr
+}
+
+func compilePattern(expr string) (pattern, error) {
+	return nil, errNoRegexp
+}
+
+func checkPattern(expr string) error {
+	return errNoRegexp
+}
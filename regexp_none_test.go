@@ -0,0 +1,27 @@
+//go:build cascadia_noregexp
+
+package cascadia
+
+import "testing"
+
+// regexpSupported is false in builds with the cascadia_noregexp tag.
+const regexpSupported = false
+
+func TestNoRegexp(t *testing.T) {
+	for _, sel := range []string{
+		"[href#=(^https:)]",
+		"p:matches(^a)",
+		"p:matchesOwn(a+)",
+		":tag-matches(h[1-6])",
+	} {
+		if _, err := Compile(sel); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", sel)
+		}
+		if err := Validate(sel); err == nil {
+			t.Errorf("Validate(%q) succeeded, want an error", sel)
+		}
+	}
+	if _, err := Compile("a[href^=https]:contains(x)"); err != nil {
+		t.Errorf("selector without regexp: %v", err)
+	}
+}
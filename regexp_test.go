@@ -0,0 +1,111 @@
+//go:build !cascadia_noregexp
+
+package cascadia
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// regexpSupported is false in builds with the cascadia_noregexp tag.
+const regexpSupported = true
+
+func TestPatternCache(t *testing.T) {
+	a := MustParseGroup(t, `p:matches(^lazy-\d+$)`)[0]
+	b := MustParseGroup(t, `p:matchesOwn(^lazy-\d+$)`)[0]
+	rxA := simpleSelectors(a)[1].(regexpPseudoClassSelector).regexp
+	rxB := simpleSelectors(b)[1].(regexpPseudoClassSelector).regexp
+	if rxA != rxB {
+		t.Error("identical patterns were not shared")
+	}
+	if rxA.rx != nil {
+		t.Error("pattern was compiled before it was used")
+	}
+
+	doc := MustParseHTML(`<p>lazy-1</p><p>lazy-x</p><p>lazy-<b>2</b></p>`)
+	if got := len(QueryAll(doc, a)); got != 2 {
+		t.Errorf("got %d matches for %s, want 2", got, a)
+	}
+	if got := len(QueryAll(doc, b)); got != 1 {
+		t.Errorf("got %d matches for %s, want 1", got, b)
+	}
+	if rxA.rx == nil {
+		t.Error("pattern was not compiled when it was used")
+	}
+
+	if _, err := ParseGroup(`p:matches(lazy-(\d+)`); err == nil {
+		t.Error("invalid pattern was accepted")
+	}
+}
+
+func TestEqualIgnoresCompiledPattern(t *testing.T) {
+	a := MustParse(t, `a[href#=(^/x)]`)
+	QueryAll(MustParseHTML(`<a href="/x">x</a>`), a)
+	// A pattern that isn't shared with a, and hasn't been compiled.
+	b := attrSelector{key: "href", operation: "#=", regexp: &lazyPattern{expr: "(^/x)"}}
+	if !Equal(a, Tag("a").And(b)) {
+		t.Errorf("Equal(%s, %s) = false after %s was used", a, b, a)
+	}
+}
+
+func TestRegexpCachesText(t *testing.T) {
+	// :matches() counts as a text pseudo-class.
+	if c := newQueryContext(MustParseGroup(t, "p:contains(a), li:not(:matches(b))")); c.texts == nil {
+		t.Error("no text cache for a selector with two text pseudo-classes")
+	}
+}
+
+func BenchmarkParseRegexpRules(b *testing.B) {
+	rules := make([]string, 100)
+	for i := range rules {
+		rules[i] = fmt.Sprintf(`a[href#=(^https?://(www\.)?example%d\.(com|org)/[a-z]+/\d+$)]`, i%10)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, r := range rules {
+			if _, err := ParseGroup(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkMatchesText(b *testing.B) {
+	var page strings.Builder
+	page.WriteString("<body>")
+	for i := 0; i < 200; i++ {
+		page.WriteString("<div><p>Some <b>Text</b> in a paragraph</p><p>More TEXT here</p></div>")
+	}
+	doc := MustParseHTML(page.String())
+	s, err := ParseGroup(`div:matches(^Some)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		QueryAll(doc, s)
+	}
+}
+
+func TestExtractCapture(t *testing.T) {
+	doc := MustParseHTML(`<p class=price> $12.50 </p><p class=price>free</p><p class=price>USD 3</p><a href="/item?id=42">x</a>`)
+	prices, err := Extract(MustParseGroup(t, ".price")).Trim().Capture(`[\d.]+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := prices.All(doc), []string{"12.50", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	ids, err := Extract(MustParseGroup(t, "a")).Attr("href").Capture(`id=(\d+)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := ids.First(doc); !ok || v != "42" {
+		t.Errorf("capture group: got %q, %v", v, ok)
+	}
+	if _, err := Extract(MustParseGroup(t, "a")).Capture(`(`); err == nil {
+		t.Error("an invalid expression should be an error")
+	}
+}
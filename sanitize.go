@@ -0,0 +1,144 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A Sanitizer removes elements from a document by selector, for content
+// filters and readability extractors: it removes the elements that match a
+// deny list, or unwraps them (replacing them with their children), and it
+// can keep only the content of the elements on an allow list.
+//
+//	deny, err := cascadia.ParseGroup("script, style, .ad")
+//	if err != nil {
+//		// ...
+//	}
+//	var s cascadia.Sanitizer
+//	s.Remove(deny)
+//	s.Sanitize(doc)
+//
+// The zero value is an empty Sanitizer, which doesn't change anything. Like
+// a SelectorSet, it must not be changed while it is being used.
+type Sanitizer struct {
+	set     SelectorSet
+	actions []sanitizeAction // indexed by the rules' indexes in set
+	allow   bool             // whether there are any allow rules
+}
+
+type sanitizeAction int
+
+const (
+	sanitizeAllow sanitizeAction = iota
+	sanitizeUnwrap
+	sanitizeRemove
+)
+
+// Remove adds a rule that removes the elements that match g, along with
+// their content.
+func (s *Sanitizer) Remove(g SelectorGroup) {
+	s.add(g, sanitizeRemove)
+}
+
+// Unwrap adds a rule that replaces the elements that match g with their
+// children. If an element matches both Remove and Unwrap rules, it is
+// removed.
+func (s *Sanitizer) Unwrap(g SelectorGroup) {
+	s.add(g, sanitizeUnwrap)
+}
+
+// Allow adds a rule that keeps the elements that match g. Once there is an
+// allow rule, everything else is removed, except for the descendants of
+// allowed elements and the ancestors that contain them. The Remove and
+// Unwrap rules still apply inside allowed elements.
+func (s *Sanitizer) Allow(g SelectorGroup) {
+	s.add(g, sanitizeAllow)
+	s.allow = true
+}
+
+func (s *Sanitizer) add(g SelectorGroup, action sanitizeAction) {
+	s.set.AddGroup(g)
+	s.actions = append(s.actions, action)
+}
+
+// Sanitize applies the rules to the descendants of n. All the selectors
+// are matched in a single walk of the tree, before anything is changed, so
+// a selector like "div > span" matches the same elements whether or not the
+// div is unwrapped.
+func (s *Sanitizer) Sanitize(n *html.Node) {
+	matches := s.set.QueryAll(n)
+
+	var denied []SetMatch
+	var allowed map[*html.Node]bool
+	if s.allow {
+		allowed = make(map[*html.Node]bool)
+	}
+	for _, m := range matches {
+		deny := false
+		for _, rule := range m.Selectors {
+			if s.actions[rule] == sanitizeAllow {
+				allowed[m.Node] = true
+			} else {
+				deny = true
+			}
+		}
+		if deny {
+			denied = append(denied, m)
+		}
+	}
+
+	if s.allow {
+		// Mark the ancestors of the allowed elements, which are kept to
+		// hold them.
+		containers := make(map[*html.Node]bool)
+		for a := range allowed {
+			for p := a.Parent; p != nil && p != n && !containers[p]; p = p.Parent {
+				containers[p] = true
+			}
+		}
+		pruneExcept(n, allowed, containers)
+	}
+
+	for _, m := range denied {
+		if !isAncestor(n, m.Node) {
+			// It was in something that was removed already.
+			continue
+		}
+		action := sanitizeAllow
+		for _, rule := range m.Selectors {
+			if s.actions[rule] > action {
+				action = s.actions[rule]
+			}
+		}
+		switch action {
+		case sanitizeRemove:
+			m.Node.Parent.RemoveChild(m.Node)
+		case sanitizeUnwrap:
+			unwrapNode(m.Node)
+		}
+	}
+}
+
+// pruneExcept removes the children of n that aren't allowed elements or
+// containers of them, and does the same for the containers' children.
+func pruneExcept(n *html.Node, allowed, containers map[*html.Node]bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case allowed[c]:
+		case containers[c]:
+			pruneExcept(c, allowed, containers)
+		default:
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// unwrapNode replaces n with its children.
+func unwrapNode(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		n.Parent.InsertBefore(c, n)
+	}
+	n.Parent.RemoveChild(n)
+}
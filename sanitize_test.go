@@ -0,0 +1,69 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSanitizer(t *testing.T) {
+	for _, test := range []struct {
+		allow, remove, unwrap string
+		want                  string
+	}{
+		{
+			remove: "script, .ad",
+			want:   `<header>Site</header><article><h1>Title</h1><p>One <span>two</span> <font>three</font></p><p>Four</p></article><footer>Copyright</footer>`,
+		},
+		{
+			unwrap: "font, span, p",
+			want:   `<header>Site</header><article><h1>Title</h1>One two three<div class="ad">Buy now</div>Four<script>x()</script></article><footer>Copyright</footer>`,
+		},
+		{
+			// Removing wins over unwrapping, and the span inside the
+			// removed div is skipped.
+			remove: ".ad",
+			unwrap: "div, span",
+			want:   `<header>Site</header><article><h1>Title</h1><p>One two <font>three</font></p><p>Four</p><script>x()</script></article><footer>Copyright</footer>`,
+		},
+		{
+			allow:  "article",
+			remove: "script, .ad",
+			unwrap: "article > p",
+			want:   `<article><h1>Title</h1>One <span>two</span> <font>three</font>Four</article>`,
+		},
+		{
+			allow: "h1, footer",
+			want:  `<article><h1>Title</h1></article><footer>Copyright</footer>`,
+		},
+		{
+			allow: "nav",
+			want:  ``,
+		},
+	} {
+		doc := MustParseHTML(`<header>Site</header><article><h1>Title</h1><p>One <span>two</span> <font>three</font></p><div class=ad>Buy <span>now</span></div><p>Four</p><script>x()</script></article><footer>Copyright</footer>`)
+		body := Query(doc, Tag("body"))
+
+		var s Sanitizer
+		for _, rule := range []struct {
+			sel string
+			add func(SelectorGroup)
+		}{{test.allow, s.Allow}, {test.remove, s.Remove}, {test.unwrap, s.Unwrap}} {
+			if rule.sel != "" {
+				rule.add(MustParseGroup(t, rule.sel))
+			}
+		}
+		s.Sanitize(body)
+
+		var b strings.Builder
+		for c := body.FirstChild; c != nil; c = c.NextSibling {
+			if err := html.Render(&b, c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("allow %q, remove %q, unwrap %q:\ngot  %s\nwant %s", test.allow, test.remove, test.unwrap, got, test.want)
+		}
+	}
+}
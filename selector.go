@@ -2,10 +2,13 @@ package cascadia
 
 import (
 	"fmt"
-	"regexp"
+	"math/bits"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // Matcher is the interface for basic selector functionality.
@@ -14,6 +17,15 @@ type Matcher interface {
 	Match(n *html.Node) bool
 }
 
+// MatcherFunc is an adapter that allows an ordinary function, such as one
+// generated by the gen package, to be used as a Matcher.
+type MatcherFunc func(n *html.Node) bool
+
+// Match calls f(n).
+func (f MatcherFunc) Match(n *html.Node) bool {
+	return f(n)
+}
+
 // Sel is the interface for all the functionality provided by selectors.
 type Sel interface {
 	Matcher
@@ -26,27 +38,113 @@ type Sel interface {
 	PseudoElement() string
 }
 
+// ParseOptions controls optional parser features. The zero value gives the
+// same behavior as Parse and ParseGroup.
+type ParseOptions struct {
+	// PseudoElements enables support for pseudo-elements.
+	PseudoElements bool
+
+	// QuirksMode makes class and ID selectors ASCII case-insensitive,
+	// as browsers do for documents in quirks mode. It also relaxes the
+	// placement rules for pseudo-elements: without it, a pseudo-element
+	// must be in the last compound selector and not inside a pseudo-class
+	// like :not(), and only ::before, ::after, ::first-line and
+	// ::first-letter may be written with a single colon.
+	//
+	// Compatibility note: earlier versions didn't check those rules, so
+	// selectors like "p::before > span" and ":selection" that used to
+	// parse are now errors unless QuirksMode is set.
+	QuirksMode bool
+
+	// Relative allows selectors to begin with a combinator, like "> p" or
+	// "+ ul", and anchors them at the :scope element. Use QueryRelative or
+	// QueryAllRelative to set the :scope element.
+	Relative bool
+
+	// Optimize simplifies the selectors after parsing them, as Optimize
+	// does.
+	Optimize bool
+
+	// MaxComplexity, if it is greater than zero, is the highest Complexity
+	// allowed. For a group of selectors, it is the limit for the total of
+	// their complexities.
+	MaxComplexity int
+
+	// PseudoClasses, if it is not nil, lists the pseudo-classes that may be
+	// used, by name without the colon, like "first-child" or "has". Using
+	// any other pseudo-class is a parse error. Pseudo-elements aren't
+	// affected.
+	PseudoClasses []string
+
+	// Text, if it is not nil, is used to get the text of elements for
+	// :contains(), :icontains(), :contains-word(), :text-is() and
+	// :matches(), instead of concatenating all the text nodes they contain.
+	// For example, VisibleText skips the contents of scripts and
+	// stylesheets. The pseudo-classes that only look at an element's own
+	// text nodes, like :containsown(), aren't affected. It isn't recorded
+	// in the selectors' String output.
+	Text TextFunc
+
+	// MaxHasDepth, if it is greater than zero, limits how many levels below
+	// an element :has() searches for a match: with 1, it only looks at the
+	// children. Deeper descendants are treated as not matching. This caps
+	// the cost of :has() on deeply nested documents. Like Text, it isn't
+	// recorded in the selectors' String output.
+	MaxHasDepth int
+
+	// StrictAttributes makes the attribute operators follow the Selectors
+	// specification in edge cases: ~= never matches if its value is empty
+	// or contains whitespace, and ^=, $= and *= never match if their value
+	// is empty. Without it, ^=, $= and *= never match an attribute that is
+	// empty or all whitespace instead, so [a^=" "] doesn't match a=" ",
+	// and [a~=""] matches a="x  y". Like Text, it isn't recorded in the
+	// selectors' String output, but it is part of their Key, and they can't
+	// be encoded as JSON.
+	StrictAttributes bool
+
+	// NoNestedHas rejects :has() (or :haschild()) anywhere inside the
+	// argument of another :has(), as Selectors Level 4 requires. Without
+	// it, nesting is allowed as an extension, though each level multiplies
+	// the cost of matching.
+	NoNestedHas bool
+
+	// MaxLength, MaxNesting and MaxEscapes, if they are greater than zero,
+	// limit the selector text that is accepted, for services that parse
+	// selectors from untrusted input. MaxLength is the most bytes of text,
+	// and longer text is rejected before it is parsed. MaxNesting is how
+	// deeply pseudo-classes that take selectors, like :not() and :has(),
+	// may be nested: with 1, they can't contain each other. MaxEscapes is
+	// the most backslash escapes, in identifiers and strings.
+	MaxLength  int
+	MaxNesting int
+	MaxEscapes int
+}
+
 // Parse parses a selector. Use `ParseWithPseudoElement`
 // if you need support for pseudo-elements.
 func Parse(sel string) (Sel, error) {
-	p := &parser{s: sel}
-	compiled, err := p.parseSelector()
-	if err != nil {
-		return nil, err
-	}
-
-	if p.i < len(sel) {
-		return nil, fmt.Errorf("parsing %q: %d bytes left over", sel, len(sel)-p.i)
-	}
-
-	return compiled, nil
+	return ParseWithOptions(sel, ParseOptions{})
 }
 
 // ParseWithPseudoElement parses a single selector,
-// with support for pseudo-element.
+// with support for pseudo-element. A misplaced pseudo-element is an error;
+// see ParseOptions.QuirksMode.
 func ParseWithPseudoElement(sel string) (Sel, error) {
-	p := &parser{s: sel, acceptPseudoElements: true}
-	compiled, err := p.parseSelector()
+	return ParseWithOptions(sel, ParseOptions{PseudoElements: true})
+}
+
+// ParseWithOptions parses a single selector, with the features
+// specified in opts.
+func ParseWithOptions(sel string, opts ParseOptions) (Sel, error) {
+	p := &parser{s: sel, opts: opts}
+	if err := p.checkLength(); err != nil {
+		return nil, err
+	}
+	parse := p.parseSelector
+	if opts.Relative {
+		parse = p.parseRelativeSelector
+	}
+	compiled, err := parse()
 	if err != nil {
 		return nil, err
 	}
@@ -55,6 +153,12 @@ func ParseWithPseudoElement(sel string) (Sel, error) {
 		return nil, fmt.Errorf("parsing %q: %d bytes left over", sel, len(sel)-p.i)
 	}
 
+	if err := opts.checkComplexity(SelectorGroup{compiled}); err != nil {
+		return nil, err
+	}
+	if opts.Optimize {
+		compiled = Optimize(compiled)
+	}
 	return compiled, nil
 }
 
@@ -62,7 +166,22 @@ func ParseWithPseudoElement(sel string) (Sel, error) {
 // Use `ParseGroupWithPseudoElements`
 // if you need support for pseudo-elements.
 func ParseGroup(sel string) (SelectorGroup, error) {
-	p := &parser{s: sel}
+	return ParseGroupWithOptions(sel, ParseOptions{})
+}
+
+// ParseGroupWithPseudoElements parses a selector, or a group of selectors separated by commas.
+// It supports pseudo-elements.
+func ParseGroupWithPseudoElements(sel string) (SelectorGroup, error) {
+	return ParseGroupWithOptions(sel, ParseOptions{PseudoElements: true})
+}
+
+// ParseGroupWithOptions parses a selector, or a group of selectors separated
+// by commas, with the features specified in opts.
+func ParseGroupWithOptions(sel string, opts ParseOptions) (SelectorGroup, error) {
+	p := &parser{s: sel, opts: opts}
+	if err := p.checkLength(); err != nil {
+		return nil, err
+	}
 	compiled, err := p.parseSelectorGroup()
 	if err != nil {
 		return nil, err
@@ -72,25 +191,92 @@ func ParseGroup(sel string) (SelectorGroup, error) {
 		return nil, fmt.Errorf("parsing %q: %d bytes left over", sel, len(sel)-p.i)
 	}
 
+	if err := opts.checkComplexity(compiled); err != nil {
+		return nil, err
+	}
+	if opts.Optimize {
+		compiled = optimizeGroup(compiled)
+	}
 	return compiled, nil
 }
 
-// ParseGroupWithPseudoElements parses a selector, or a group of selectors separated by commas.
-// It supports pseudo-elements.
-func ParseGroupWithPseudoElements(sel string) (SelectorGroup, error) {
-	p := &parser{s: sel, acceptPseudoElements: true}
-	compiled, err := p.parseSelectorGroup()
+// Validate checks whether sel is a valid selector or group of selectors, as
+// ParseGroup would accept. It is faster than ParseGroup, since it doesn't
+// prepare the selectors for matching.
+func Validate(sel string) error {
+	return ValidateWithOptions(sel, ParseOptions{})
+}
+
+// ValidateWithOptions is like Validate, but it checks sel with the
+// features specified in opts, as ParseGroupWithOptions would.
+func ValidateWithOptions(sel string, opts ParseOptions) error {
+	p := &parser{s: sel, opts: opts, validateOnly: true}
+	if err := p.checkLength(); err != nil {
+		return err
+	}
+	g, err := p.parseSelectorGroup()
 	if err != nil {
-		return nil, err
+		return err
 	}
-
 	if p.i < len(sel) {
-		return nil, fmt.Errorf("parsing %q: %d bytes left over", sel, len(sel)-p.i)
+		return fmt.Errorf("parsing %q: %d bytes left over", sel, len(sel)-p.i)
 	}
+	return opts.checkComplexity(g)
+}
 
+// ParseGroupRecover parses a group of selectors separated by commas, like
+// ParseGroupWithOptions, but it doesn't give up when one of them is invalid.
+// It returns the valid selectors, and if any were invalid, a ParseErrors
+// listing them.
+func ParseGroupRecover(sel string, opts ParseOptions) (SelectorGroup, error) {
+	p := &parser{s: sel, opts: opts}
+	if err := p.checkLength(); err != nil {
+		return nil, err
+	}
+	compiled, errs := p.parseSelectorGroupRecover()
+	if err := opts.checkComplexity(compiled); err != nil {
+		return nil, err
+	}
+	if opts.Optimize {
+		compiled = optimizeGroup(compiled)
+	}
+	if len(errs) > 0 {
+		return compiled, errs
+	}
 	return compiled, nil
 }
 
+// A ParseError describes an invalid selector in a group.
+type ParseError struct {
+	// Offset is the position in the input, in bytes, where the error was
+	// found.
+	Offset int
+
+	// Selector is the text of the invalid selector.
+	Selector string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid selector %q at offset %d: %s", e.Selector, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is the list of errors returned by ParseGroupRecover.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 // A Selector is a function which tells whether a node matches or not.
 //
 // This type is maintained for compatibility; I recommend using the newer and
@@ -99,12 +285,24 @@ type Selector func(*html.Node) bool
 
 // Compile parses a selector and returns, if successful, a Selector object
 // that can be used to match against html.Node objects.
+//
+// A Selector tests each node on its own, so it can't handle result-set
+// pseudo-classes like :eq(); use ParseGroup and QueryAll for those.
 func Compile(sel string) (Selector, error) {
 	compiled, err := ParseGroup(sel)
 	if err != nil {
 		return nil, err
 	}
+	if hasPositional(compiled) {
+		return nil, fmt.Errorf("parsing %q: result-set pseudo-classes are not supported by Compile", sel)
+	}
 
+	if q, ok := newSimpleQuery(compiled); ok {
+		return Selector(q.match), nil
+	}
+	if d := newTagDispatch(compiled); d != nil {
+		return Selector(d.Match), nil
+	}
 	return Selector(compiled.Match), nil
 }
 
@@ -118,38 +316,134 @@ func MustCompile(sel string) Selector {
 }
 
 // MatchAll returns a slice of the nodes that match the selector,
-// from n and its children.
+// from n and its children, in document order.
 func (s Selector) MatchAll(n *html.Node) []*html.Node {
 	return s.matchAllInto(n, nil)
 }
 
 func (s Selector) matchAllInto(n *html.Node, storage []*html.Node) []*html.Node {
-	if s(n) {
-		storage = append(storage, n)
-	}
+	return s.matchAllIntoN(n, storage, -1)
+}
 
-	for child := n.FirstChild; child != nil; child = child.NextSibling {
-		storage = s.matchAllInto(child, storage)
+// MatchAllN is like MatchAll, but it stops searching once it has found limit
+// matches. If limit is negative, there is no limit.
+func (s Selector) MatchAllN(n *html.Node, limit int) []*html.Node {
+	if limit == 0 {
+		return nil
 	}
+	return s.matchAllIntoN(n, nil, limit)
+}
 
+// matchAllIntoN is like matchAllInto, but it stops when storage has limit
+// nodes (unless limit is negative).
+func (s Selector) matchAllIntoN(n *html.Node, storage []*html.Node, limit int) []*html.Node {
+	for w := walk(n, true); len(storage) != limit; {
+		node := w.next()
+		if node == nil {
+			break
+		}
+		if s(node) {
+			storage = append(storage, node)
+		}
+	}
 	return storage
 }
 
-func queryInto(n *html.Node, m Matcher, storage []*html.Node) []*html.Node {
-	for child := n.FirstChild; child != nil; child = child.NextSibling {
-		if m.Match(child) {
-			storage = append(storage, child)
+func queryInto(c *matchContext, n *html.Node, m Matcher, storage []*html.Node) []*html.Node {
+	return queryIntoN(c, n, m, storage, -1)
+}
+
+// queryIntoN is like queryInto, but it stops when storage has limit nodes
+// (unless limit is negative).
+func queryIntoN(c *matchContext, n *html.Node, m Matcher, storage []*html.Node, limit int) []*html.Node {
+	for w := c.walk(n, false); len(storage) != limit; {
+		node := w.next()
+		if node == nil {
+			break
+		}
+		if c.match(m, node) {
+			storage = append(storage, node)
 		}
-		storage = queryInto(child, m, storage)
 	}
-
 	return storage
 }
 
 // QueryAll returns a slice of all the nodes that match m, from the descendants
-// of n.
+// of n, in document order.
 func QueryAll(n *html.Node, m Matcher) []*html.Node {
-	return queryInto(n, m, nil)
+	if q, ok := newSimpleQuery(m); ok {
+		return q.queryAll(n, nil, -1)
+	}
+	c := newQueryContext(m)
+	if q := newChainQuery(m); q != nil {
+		return q.queryAll(c, n)
+	}
+	return filterResults(c, m, queryInto(c, n, dispatchMatcher(m), nil))
+}
+
+// AppendMatches appends the nodes that match m, from n and its descendants,
+// to dst and returns the extended slice. Reusing dst across calls avoids
+// allocating a new slice for each query.
+func AppendMatches(dst []*html.Node, n *html.Node, m Matcher) []*html.Node {
+	start := len(dst)
+	var c *matchContext
+	if hasPositional(m) {
+		c = &matchContext{results: true}
+	}
+	if c.match(m, n) {
+		dst = append(dst, n)
+	}
+	dst = queryInto(c, n, m, dst)
+	if hasPositional(m) {
+		dst = append(dst[:start], filterResults(c, m, dst[start:])...)
+	}
+	return dst
+}
+
+// QueryAllFrom returns the nodes that match m from several roots and their
+// descendants, such as the nodes returned by html.ParseFragment. Unlike
+// QueryAll, it tests the roots themselves, not just their descendants.
+//
+// The result is in document order, without duplicates, even if some of the
+// roots contain others. Nodes from separate trees are in the order of their
+// roots. Result-set pseudo-classes like :first apply to the combined result.
+//
+// The roots that have no parent are treated as the children of a common
+// parent, in order, so the first of them matches :first-child, the second
+// matches :nth-child(2), and so on.
+func QueryAllFrom(roots []*html.Node, m Matcher) []*html.Node {
+	c := &matchContext{fragment: roots, results: true}
+	sets := make([][]*html.Node, len(roots))
+	for i, root := range roots {
+		var matches []*html.Node
+		if c.match(m, root) {
+			matches = append(matches, root)
+		}
+		sets[i] = queryInto(c, root, m, matches)
+	}
+	return filterResults(c, m, Union(sets...))
+}
+
+// QueryAllN is like QueryAll, but it returns at most limit nodes, and stops
+// searching once it has found them. If limit is negative, there is no limit.
+//
+// If m contains result-set pseudo-classes like :last, the whole document
+// still needs to be searched.
+func QueryAllN(n *html.Node, m Matcher, limit int) []*html.Node {
+	if limit == 0 {
+		return nil
+	}
+	if q, ok := newSimpleQuery(m); ok {
+		return q.queryAll(n, nil, limit)
+	}
+	if hasPositional(m) {
+		matches := QueryAll(n, m)
+		if limit > 0 && len(matches) > limit {
+			matches = matches[:limit]
+		}
+		return matches
+	}
+	return queryIntoN(nil, n, dispatchMatcher(m), nil, limit)
 }
 
 // Match returns true if the node matches the selector.
@@ -159,14 +453,10 @@ func (s Selector) Match(n *html.Node) bool {
 
 // MatchFirst returns the first node that matches s, from n and its children.
 func (s Selector) MatchFirst(n *html.Node) *html.Node {
-	if s.Match(n) {
-		return n
-	}
-
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		m := s.MatchFirst(c)
-		if m != nil {
-			return m
+	w := walk(n, true)
+	for c := w.next(); c != nil; c = w.next() {
+		if s(c) {
+			return c
 		}
 	}
 	return nil
@@ -175,15 +465,177 @@ func (s Selector) MatchFirst(n *html.Node) *html.Node {
 // Query returns the first node that matches m, from the descendants of n.
 // If none matches, it returns nil.
 func Query(n *html.Node, m Matcher) *html.Node {
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if m.Match(c) {
+	if q, ok := newSimpleQuery(m); ok {
+		return q.queryFirst(n)
+	}
+	if hasPositional(m) {
+		if matches := QueryAll(n, m); len(matches) > 0 {
+			return matches[0]
+		}
+		return nil
+	}
+
+	return queryFirst(nil, n, dispatchMatcher(m))
+}
+
+func queryFirst(ctx *matchContext, n *html.Node, m Matcher) *html.Node {
+	w := ctx.walk(n, false)
+	for c := w.next(); c != nil; c = w.next() {
+		if ctx.match(m, c) {
 			return c
 		}
-		if matched := Query(c, m); matched != nil {
-			return matched
+	}
+	return nil
+}
+
+// MatchContext returns whether m matches n, without looking outside the
+// subtree rooted at scope: combinators don't look at the ancestors or
+// siblings of scope. So .start td matches a td element in scope only if it is
+// inside an element with the class "start" that is scope itself or a
+// descendant of it.
+func MatchContext(m Matcher, n, scope *html.Node) bool {
+	return (&matchContext{bound: scope}).match(m, n)
+}
+
+// QueryAllContext is like QueryAll, but it matches in the context of n, as
+// MatchContext does, instead of the whole document.
+func QueryAllContext(n *html.Node, m Matcher) []*html.Node {
+	c := &matchContext{bound: n, results: true}
+	return filterResults(c, m, queryInto(c, n, m, nil))
+}
+
+// QueryContext is like Query, but it matches in the context of n, as
+// MatchContext does, instead of the whole document.
+func QueryContext(n *html.Node, m Matcher) *html.Node {
+	if hasPositional(m) {
+		if matches := QueryAllContext(n, m); len(matches) > 0 {
+			return matches[0]
+		}
+		return nil
+	}
+
+	return queryFirst(&matchContext{bound: n}, n, m)
+}
+
+// MatchRoot returns whether m matches n, treating the subtree rooted at root
+// as a separate document with root as its root element. root matches :root,
+// and it counts as an only child for :first-child and the like. Combinators
+// don't look at the ancestors or siblings of root, as with MatchContext.
+// This is useful for matching in detached subtrees and fragments.
+func MatchRoot(m Matcher, n, root *html.Node) bool {
+	return (&matchContext{root: root, bound: root}).match(m, n)
+}
+
+// QueryAllRoot returns the nodes that match m from root and its
+// descendants, in document order, treating them as a separate document as
+// MatchRoot does.
+func QueryAllRoot(root *html.Node, m Matcher) []*html.Node {
+	c := &matchContext{root: root, bound: root, results: true}
+	var matches []*html.Node
+	if c.match(m, root) {
+		matches = append(matches, root)
+	}
+	return filterResults(c, m, queryInto(c, root, m, matches))
+}
+
+// QueryRoot is like QueryAllRoot, but it returns only the first match, or
+// nil if none matches.
+func QueryRoot(root *html.Node, m Matcher) *html.Node {
+	if hasPositional(m) {
+		if matches := QueryAllRoot(root, m); len(matches) > 0 {
+			return matches[0]
+		}
+		return nil
+	}
+
+	c := &matchContext{root: root, bound: root}
+	if c.match(m, root) {
+		return root
+	}
+	return queryFirst(c, root, m)
+}
+
+// QueryAllRelative returns the nodes that match m, with n as the :scope
+// element. This is mostly useful with selectors parsed with the Relative
+// option: "> li" finds the children of n that are li elements.
+//
+// Normally the nodes are taken from the descendants of n, but if m begins
+// with a sibling combinator ("+ p" or "~ p"), n's later siblings and their
+// descendants are included too.
+func QueryAllRelative(n *html.Node, m Matcher) []*html.Node {
+	c := &matchContext{scope: n, results: true}
+	return filterResults(c, m, queryInto(c, relativeSearchRoot(n, m), m, nil))
+}
+
+// QueryRelative returns the first node that matches m, with n as the :scope
+// element. See QueryAllRelative for which nodes are searched.
+// If none matches, it returns nil.
+func QueryRelative(n *html.Node, m Matcher) *html.Node {
+	if hasPositional(m) {
+		if matches := QueryAllRelative(n, m); len(matches) > 0 {
+			return matches[0]
+		}
+		return nil
+	}
+
+	c := &matchContext{scope: n}
+	return queryFirst(c, relativeSearchRoot(n, m), m)
+}
+
+// relativeSearchRoot returns the node whose descendants may match m when n
+// is the :scope element.
+func relativeSearchRoot(n *html.Node, m Matcher) *html.Node {
+	if n.Parent == nil {
+		return n
+	}
+	sels, ok := m.(SelectorGroup)
+	if !ok {
+		if sel, isSel := m.(Sel); isSel {
+			sels = SelectorGroup{sel}
+		}
+	}
+	for _, sel := range sels {
+		if c := scopeCombinator(sel); c == '+' || c == '~' {
+			return n.Parent
+		}
+	}
+	return n
+}
+
+// Closest returns the nearest element that matches the selector,
+// starting with n itself and then going up through its ancestors,
+// like the DOM's Element.closest(). If none matches, it returns nil.
+func (s Selector) Closest(n *html.Node) *html.Node {
+	return Closest(n, s)
+}
+
+// Closest returns the nearest element that matches m, starting with n itself
+// and then going up through its ancestors, like the DOM's Element.closest().
+// If none matches, it returns nil.
+func Closest(n *html.Node, m Matcher) *html.Node {
+	if hasPositional(m) {
+		// Whether an element is selected depends on the rest of the document.
+		root := n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		selected := make(map[*html.Node]bool)
+		for _, match := range QueryAll(root, m) {
+			selected[match] = true
 		}
+		for ; n != nil; n = n.Parent {
+			if selected[n] {
+				return n
+			}
+		}
+		return nil
 	}
 
+	for ; n != nil; n = n.Parent {
+		if n.Type == html.ElementNode && m.Match(n) {
+			return n
+		}
+	}
 	return nil
 }
 
@@ -198,22 +650,56 @@ func (s Selector) Filter(nodes []*html.Node) (result []*html.Node) {
 }
 
 // Filter returns the nodes that match m.
+// Result-set pseudo-classes like :eq() are applied to the matching nodes in
+// the order they appear in nodes. So the results of a query can be narrowed
+// down without searching the document again, as in jQuery:
+//
+//	items := cascadia.QueryAll(doc, cascadia.MustCompile("li"))
+//	odd, _ := cascadia.ParseGroup(":odd")
+//	oddItems := cascadia.Filter(items, odd)
 func Filter(nodes []*html.Node, m Matcher) (result []*html.Node) {
+	c := &matchContext{results: true}
+	for _, n := range nodes {
+		if c.match(m, n) {
+			result = append(result, n)
+		}
+	}
+	return filterResults(c, m, result)
+}
+
+// FilterN is like Filter, but it returns at most limit nodes. If limit is
+// negative, there is no limit.
+func FilterN(nodes []*html.Node, m Matcher, limit int) []*html.Node {
+	if limit == 0 {
+		return nil
+	}
+	if hasPositional(m) {
+		result := Filter(nodes, m)
+		if limit > 0 && len(result) > limit {
+			result = result[:limit]
+		}
+		return result
+	}
+	var result []*html.Node
 	for _, n := range nodes {
 		if m.Match(n) {
 			result = append(result, n)
+			if len(result) == limit {
+				break
+			}
 		}
 	}
 	return result
 }
 
 type tagSelector struct {
-	tag string
+	tag  string
+	name string // the name for SVG elements, from svgTagNames
 }
 
 // Matches elements with a given tag name.
 func (t tagSelector) Match(n *html.Node) bool {
-	return n.Type == html.ElementNode && n.Data == t.tag
+	return n.Type == html.ElementNode && n.Data == nameFor(n, t.tag, t.name)
 }
 
 func (c tagSelector) Specificity() Specificity {
@@ -225,14 +711,36 @@ func (c tagSelector) PseudoElement() string {
 }
 
 type classSelector struct {
-	class string
+	class  string
+	quirks bool // class is lowercase, and matched ASCII case-insensitively
 }
 
 // Matches elements by class attribute.
 func (t classSelector) Match(n *html.Node) bool {
-	return matchAttribute(n, "class", func(s string) bool {
-		return matchInclude(t.class, s, false)
-	})
+	return t.matchIn(nil, n)
+}
+
+func (t classSelector) matchIn(c *matchContext, n *html.Node) bool {
+	return matchAttribute(n, "class", func(s string) bool { return t.matchValue(c, s) })
+}
+
+func (t classSelector) attrKey() string {
+	return "class"
+}
+
+func (t classSelector) matchValue(c *matchContext, s string) bool {
+	if c != nil && c.cacheClasses && !t.quirks {
+		for _, class := range c.classList(s) {
+			if class == t.class {
+				return true
+			}
+		}
+		return false
+	}
+	if t.quirks {
+		s = toLowerASCII(s)
+	}
+	return matchInclude(t.class, s, false)
 }
 
 func (c classSelector) Specificity() Specificity {
@@ -244,14 +752,24 @@ func (c classSelector) PseudoElement() string {
 }
 
 type idSelector struct {
-	id string
+	id     string
+	quirks bool // id is lowercase, and matched ASCII case-insensitively
 }
 
 // Matches elements by id attribute.
 func (t idSelector) Match(n *html.Node) bool {
-	return matchAttribute(n, "id", func(s string) bool {
-		return s == t.id
-	})
+	return matchAttribute(n, "id", func(s string) bool { return t.matchValue(nil, s) })
+}
+
+func (t idSelector) attrKey() string {
+	return "id"
+}
+
+func (t idSelector) matchValue(c *matchContext, s string) bool {
+	if t.quirks {
+		return toLowerASCII(s) == t.id
+	}
+	return s == t.id
 }
 
 func (c idSelector) Specificity() Specificity {
@@ -264,37 +782,73 @@ func (c idSelector) PseudoElement() string {
 
 type attrSelector struct {
 	key, val, operation string
-	regexp              *regexp.Regexp
+	name                string // the key for foreign elements, from foreignAttributeNames
+	regexp              pattern
+	number              float64 // for numeric comparisons
 	insensitive         bool
+	strict              bool // see ParseOptions.StrictAttributes
 }
 
 // Matches elements by attribute value.
 func (t attrSelector) Match(n *html.Node) bool {
+	return t.matchIn(nil, n)
+}
+
+func (t attrSelector) matchIn(c *matchContext, n *html.Node) bool {
+	key := nameFor(n, t.key, t.name)
+	if t.operation == "!=" {
+		return attributeNotEqualMatch(key, t.val, n, t.insensitive)
+	}
+	return matchAttribute(n, key, func(s string) bool { return t.matchValue(c, s) })
+}
+
+func (t attrSelector) attrKey() string {
+	return t.key
+}
+
+// matchValue returns whether s, the value of the attribute t.key, matches
+// t. It isn't used for the != operator, which matches elements that don't
+// have the attribute at all.
+func (t attrSelector) matchValue(c *matchContext, s string) bool {
 	switch t.operation {
 	case "":
-		return matchAttribute(n, t.key, func(string) bool { return true })
+		return true
 	case "=":
-		return matchAttribute(n, t.key, func(s string) bool { return matchInsensitiveValue(s, t.val, t.insensitive) })
-	case "!=":
-		return attributeNotEqualMatch(t.key, t.val, n, t.insensitive)
+		return matchInsensitiveValue(s, t.val, t.insensitive)
 	case "~=":
 		// matches elements where the attribute named key is a whitespace-separated list that includes val.
-		return matchAttribute(n, t.key, func(s string) bool { return matchInclude(t.val, s, t.insensitive) })
+		if t.strict && (t.val == "" || strings.ContainsAny(t.val, " \t\r\n\f")) {
+			return false
+		}
+		return matchInclude(t.val, s, t.insensitive)
 	case "|=":
-		return attributeDashMatch(t.key, t.val, n, t.insensitive)
+		return dashMatch(s, t.val, t.insensitive)
 	case "^=":
-		return attributePrefixMatch(t.key, t.val, n, t.insensitive)
+		return t.affixAllowed(s) && prefixMatch(s, t.val, t.insensitive)
 	case "$=":
-		return attributeSuffixMatch(t.key, t.val, n, t.insensitive)
+		return t.affixAllowed(s) && suffixMatch(s, t.val, t.insensitive)
 	case "*=":
-		return attributeSubstringMatch(t.key, t.val, n, t.insensitive)
+		return t.affixAllowed(s) && substringMatch(s, t.val, t.insensitive)
 	case "#=":
-		return attributeRegexMatch(t.key, t.regexp, n)
+		return c.matchRegexp(t.regexp, s)
+	case "<", "<=", ">", ">=":
+		return numberMatch(s, t.operation, t.number)
 	default:
 		panic(fmt.Sprintf("unsuported operation : %s", t.operation))
 	}
 }
 
+// affixAllowed returns whether the ^=, $= and *= operators may match s at
+// all. The Selectors specification says that they never match if t.val is
+// empty. Unless t.strict is set, they never match if s is empty or all
+// whitespace instead.
+func (t attrSelector) affixAllowed(s string) bool {
+	if t.strict {
+		return t.val != ""
+	}
+	return strings.TrimSpace(s) != ""
+}
+
 // matches elements where we ignore (or not) the case of the attribute value
 // the user attribute is the value set by the user to match elements
 // the real attribute is the attribute value found in the code parsed
@@ -348,75 +902,135 @@ func matchInclude(val string, s string, ignoreCase bool) bool {
 	return false
 }
 
-//  matches elements where the attribute named key equals val or starts with val plus a hyphen.
-func attributeDashMatch(key, val string, n *html.Node, ignoreCase bool) bool {
-	return matchAttribute(n, key,
-		func(s string) bool {
-			if matchInsensitiveValue(s, val, ignoreCase) {
-				return true
-			}
-			if len(s) <= len(val) {
-				return false
-			}
-			if matchInsensitiveValue(s[:len(val)], val, ignoreCase) && s[len(val)] == '-' {
-				return true
-			}
-			return false
-		})
+// dashMatch returns whether s equals val or starts with val plus a hyphen.
+func dashMatch(s, val string, ignoreCase bool) bool {
+	if matchInsensitiveValue(s, val, ignoreCase) {
+		return true
+	}
+	if len(s) <= len(val) {
+		return false
+	}
+	if matchInsensitiveValue(s[:len(val)], val, ignoreCase) && s[len(val)] == '-' {
+		return true
+	}
+	return false
 }
 
-// attributePrefixMatch returns a Selector that matches elements where
-// the attribute named key starts with val.
-func attributePrefixMatch(key, val string, n *html.Node, ignoreCase bool) bool {
-	return matchAttribute(n, key,
-		func(s string) bool {
-			if strings.TrimSpace(s) == "" {
-				return false
-			}
-			if ignoreCase {
-				return strings.HasPrefix(strings.ToLower(s), strings.ToLower(val))
-			}
-			return strings.HasPrefix(s, val)
-		})
+// prefixMatch returns whether s starts with val.
+func prefixMatch(s, val string, ignoreCase bool) bool {
+	if ignoreCase {
+		return strings.HasPrefix(strings.ToLower(s), strings.ToLower(val))
+	}
+	return strings.HasPrefix(s, val)
 }
 
-// attributeSuffixMatch matches elements where
-// the attribute named key ends with val.
-func attributeSuffixMatch(key, val string, n *html.Node, ignoreCase bool) bool {
-	return matchAttribute(n, key,
-		func(s string) bool {
-			if strings.TrimSpace(s) == "" {
-				return false
-			}
-			if ignoreCase {
-				return strings.HasSuffix(strings.ToLower(s), strings.ToLower(val))
-			}
-			return strings.HasSuffix(s, val)
-		})
+// suffixMatch returns whether s ends with val.
+func suffixMatch(s, val string, ignoreCase bool) bool {
+	if ignoreCase {
+		return strings.HasSuffix(strings.ToLower(s), strings.ToLower(val))
+	}
+	return strings.HasSuffix(s, val)
 }
 
-// attributeSubstringMatch matches nodes where
-// the attribute named key contains val.
-func attributeSubstringMatch(key, val string, n *html.Node, ignoreCase bool) bool {
-	return matchAttribute(n, key,
-		func(s string) bool {
-			if strings.TrimSpace(s) == "" {
-				return false
-			}
-			if ignoreCase {
-				return strings.Contains(strings.ToLower(s), strings.ToLower(val))
-			}
-			return strings.Contains(s, val)
-		})
+// substringMatch returns whether s contains val.
+func substringMatch(s, val string, ignoreCase bool) bool {
+	if ignoreCase {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(val))
+	}
+	return strings.Contains(s, val)
 }
 
-// attributeRegexMatch  matches nodes where
-// the attribute named key matches the regular expression rx
-func attributeRegexMatch(key string, rx *regexp.Regexp, n *html.Node) bool {
-	return matchAttribute(n, key,
-		func(s string) bool {
-			return rx.MatchString(s)
-		})
+// numberMatch returns whether s is a number that compares to val as
+// specified by op.
+func numberMatch(s, op string, val float64) bool {
+	f, ok := parseDecimal(strings.TrimSpace(s))
+	if !ok {
+		return false
+	}
+	switch op {
+	case "<":
+		return f < val
+	case "<=":
+		return f <= val
+	case ">":
+		return f > val
+	case ">=":
+		return f >= val
+	}
+	return false
+}
+
+// parseDecimal parses s as a decimal number: an optional sign, digits with
+// an optional decimal point, and an optional exponent. Unlike
+// strconv.ParseFloat, it rejects "inf", "NaN", hexadecimal numbers,
+// underscores, and numbers too large to be finite.
+func parseDecimal(s string) (float64, bool) {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && '0' <= s[i] && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return 0, false
+		}
+	}
+	if i != len(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// An attrCondition is a simple selector that tests the value of a single
+// attribute. An element matches it if any of its attributes named attrKey
+// has a value that matchValue accepts.
+type attrCondition interface {
+	attrKey() string
+	matchValue(c *matchContext, s string) bool
+}
+
+// An attrTest is an attrCondition in a compound selector, with its key.
+type attrTest struct {
+	key  string
+	cond attrCondition
+}
+
+// matchAttributes returns whether n matches all of tests (at most 64),
+// looking through its attributes only once.
+func matchAttributes(c *matchContext, n *html.Node, tests []attrTest) bool {
+	left := uint64(1)<<len(tests) - 1
+	for _, a := range n.Attr {
+		for m := left; m != 0; m &= m - 1 {
+			i := bits.TrailingZeros64(m)
+			if a.Key == tests[i].key && tests[i].cond.matchValue(c, a.Val) {
+				left &^= 1 << i
+			}
+		}
+		if left == 0 {
+			return true
+		}
+	}
+	return false
 }
 
 func (c attrSelector) Specificity() Specificity {
@@ -449,22 +1063,153 @@ func (c neverMatchSelector) PseudoElement() string {
 type compoundSelector struct {
 	selectors     []Sel
 	pseudoElement string
+
+	// tag is the tag name from the compound's type selector, if it has one,
+	// and tagAtom is its atom, so that elements of other types can be
+	// rejected before the other selectors are tried.
+	tag     string
+	tagAtom atom.Atom
+
+	// order holds the parts in the order they are tested: the cheapest
+	// first, so that the expensive ones only run on elements that pass the
+	// others. selectors keeps the order they were written in, for String.
+	order []Sel
+
+	// attrs holds the parts that test the value of an attribute, if there
+	// are at least two of them, so that they can all be checked in one pass
+	// over the element's attributes; rest holds the other parts, in order.
+	attrs []attrTest
+	rest  []Sel
+}
+
+// newCompoundSelector returns a compoundSelector for selectors, with its
+// tag name filled in.
+func newCompoundSelector(selectors []Sel, pseudoElement string) compoundSelector {
+	c := compoundSelector{selectors: selectors, pseudoElement: pseudoElement}
+	c.order = append([]Sel(nil), selectors...)
+	sort.SliceStable(c.order, func(i, j int) bool {
+		return simpleCost(c.order[i]) < simpleCost(c.order[j])
+	})
+
+	var attrs []attrTest
+	var rest []Sel
+	for _, sel := range c.order {
+		if t, ok := sel.(tagSelector); ok && c.tag == "" && t.name == "" {
+			c.tag = t.tag
+			c.tagAtom = atom.Lookup([]byte(t.tag))
+		}
+		if a, ok := sel.(attrCondition); ok && !isNotEqual(sel) && !hasForeignName(sel) {
+			attrs = append(attrs, attrTest{a.attrKey(), a})
+		} else {
+			rest = append(rest, sel)
+		}
+	}
+	if len(attrs) >= 2 && len(attrs) <= 64 {
+		c.attrs, c.rest = attrs, rest
+	}
+	return c
+}
+
+// isNotEqual returns whether s is an attribute selector with the !=
+// operator, which also matches elements that don't have the attribute.
+func isNotEqual(s Sel) bool {
+	a, ok := s.(attrSelector)
+	return ok && a.operation == "!="
+}
+
+// hasTag returns whether n's tag name is t.tag.
+func (t compoundSelector) hasTag(n *html.Node) bool {
+	if t.tagAtom != 0 && n.DataAtom != 0 {
+		return n.DataAtom == t.tagAtom
+	}
+	return n.Data == t.tag
 }
 
 // Matches elements if each sub-selectors matches.
 func (t compoundSelector) Match(n *html.Node) bool {
+	return t.matchIn(nil, n)
+}
+
+func (t compoundSelector) matchIn(c *matchContext, n *html.Node) bool {
 	if len(t.selectors) == 0 {
 		return n.Type == html.ElementNode
 	}
+	if t.tag != "" && (n.Type != html.ElementNode || !t.hasTag(n)) {
+		return false
+	}
+	if t.attrs != nil && !c.observed() {
+		if n.Type != html.ElementNode || !matchAttributes(c, n, t.attrs) {
+			return false
+		}
+		for _, sel := range t.rest {
+			if !c.match(sel, n) {
+				return false
+			}
+		}
+		return true
+	}
 
-	for _, sel := range t.selectors {
-		if !sel.Match(n) {
+	for _, sel := range t.order {
+		if !c.match(sel, n) {
 			return false
 		}
 	}
 	return true
 }
 
+// simpleCost ranks simple selectors by how expensive they are to test.
+func simpleCost(s Sel) int {
+	switch s := s.(type) {
+	case tagSelector:
+		return 0
+	case idSelector:
+		return 1
+	case classSelector:
+		return 2
+	case attrSelector:
+		if s.operation == "#=" {
+			return 4
+		}
+		return 3
+	case containsPseudoClassSelector, textIsPseudoClassSelector, regexpPseudoClassSelector, customPseudoClassSelector:
+		return 6
+	case relativePseudoClassSelector:
+		if s.name != "not" {
+			// :has() searches the element's descendants or children.
+			return 7
+		}
+		cost := 0
+		for _, sel := range s.match {
+			if c := selectorCost(sel); c > cost {
+				cost = c
+			}
+		}
+		return cost
+	}
+	// Structural pseudo-classes like :nth-child(), and the others, which
+	// look at the element's neighbors or several attributes.
+	return 5
+}
+
+// selectorCost is like simpleCost, for a selector that may be compound or
+// have combinators: it is the cost of its most expensive part, and at least
+// the cost of a structural pseudo-class if it has combinators.
+func selectorCost(s Sel) int {
+	compounds, _ := steps(s)
+	cost := 0
+	if len(compounds) > 1 {
+		cost = 5
+	}
+	for _, compound := range compounds {
+		for _, part := range simpleSelectors(compound) {
+			if c := simpleCost(part); c > cost {
+				cost = c
+			}
+		}
+	}
+	return cost
+}
+
 func (s compoundSelector) Specificity() Specificity {
 	var out Specificity
 	for _, sel := range s.selectors {
@@ -488,33 +1233,38 @@ type combinedSelector struct {
 }
 
 func (t combinedSelector) Match(n *html.Node) bool {
+	return t.matchIn(nil, n)
+}
+
+func (t combinedSelector) matchIn(c *matchContext, n *html.Node) bool {
 	if t.first == nil {
 		return false // maybe we should panic
 	}
 	switch t.combinator {
 	case 0:
-		return t.first.Match(n)
+		return c.match(t.first, n)
 	case ' ':
-		return descendantMatch(t.first, t.second, n)
+		return descendantMatch(c, t.first, t.second, n)
 	case '>':
-		return childMatch(t.first, t.second, n)
+		return childMatch(c, t.first, t.second, n)
 	case '+':
-		return siblingMatch(t.first, t.second, true, n)
+		return siblingMatch(c, t.first, t.second, true, n)
 	case '~':
-		return siblingMatch(t.first, t.second, false, n)
+		return siblingMatch(c, t.first, t.second, false, n)
 	default:
 		panic("unknown combinator")
 	}
 }
 
 // matches an element if it matches d and has an ancestor that matches a.
-func descendantMatch(a, d Matcher, n *html.Node) bool {
-	if !d.Match(n) {
+func descendantMatch(c *matchContext, a, d Matcher, n *html.Node) bool {
+	if !c.match(d, n) {
 		return false
 	}
 
-	for p := n.Parent; p != nil; p = p.Parent {
-		if a.Match(p) {
+	for p := c.parent(n); p != nil; p = c.parent(p) {
+		c.step()
+		if c.match(a, p) {
 			return true
 		}
 	}
@@ -523,30 +1273,40 @@ func descendantMatch(a, d Matcher, n *html.Node) bool {
 }
 
 // matches an element if it matches d and its parent matches a.
-func childMatch(a, d Matcher, n *html.Node) bool {
-	return d.Match(n) && n.Parent != nil && a.Match(n.Parent)
+func childMatch(c *matchContext, a, d Matcher, n *html.Node) bool {
+	if !c.match(d, n) {
+		return false
+	}
+	p := c.parent(n)
+	if p == nil {
+		return false
+	}
+	c.step()
+	return c.match(a, p)
 }
 
 // matches an element if it matches s2 and is preceded by an element that matches s1.
 // If adjacent is true, the sibling must be immediately before the element.
-func siblingMatch(s1, s2 Matcher, adjacent bool, n *html.Node) bool {
-	if !s2.Match(n) {
+func siblingMatch(c *matchContext, s1, s2 Matcher, adjacent bool, n *html.Node) bool {
+	if !c.match(s2, n) {
 		return false
 	}
 
 	if adjacent {
-		for n = n.PrevSibling; n != nil; n = n.PrevSibling {
+		for n = c.prevSibling(n); n != nil; n = n.PrevSibling {
+			c.step()
 			if n.Type == html.TextNode || n.Type == html.CommentNode {
 				continue
 			}
-			return s1.Match(n)
+			return c.match(s1, n)
 		}
 		return false
 	}
 
 	// Walk backwards looking for element that matches s1
-	for c := n.PrevSibling; c != nil; c = c.PrevSibling {
-		if s1.Match(c) {
+	for sib := c.prevSibling(n); sib != nil; sib = sib.PrevSibling {
+		c.step()
+		if c.match(s1, sib) {
 			return true
 		}
 	}
@@ -573,12 +1333,20 @@ func (c combinedSelector) PseudoElement() string {
 
 // A SelectorGroup is a list of selectors, which matches if any of the
 // individual selectors matches.
+//
+// Like querySelectorAll, QueryAll and the other functions that return
+// several nodes list each node once, in document order, even when it
+// matches more than one of the selectors.
 type SelectorGroup []Sel
 
 // Match returns true if the node matches one of the single selectors.
 func (s SelectorGroup) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s SelectorGroup) matchIn(c *matchContext, n *html.Node) bool {
 	for _, sel := range s {
-		if sel.Match(n) {
+		if c.match(sel, n) {
 			return true
 		}
 	}
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 var validSelectors []validSelector
@@ -336,6 +337,45 @@ var selectorTests = []selectorTest{
 			`<table border="0" cellpadding="0" cellspacing="0" style="table-layout: fixed; width: 100%; border: 0 dashed; border-color: #FFFFFF"><tbody><tr style="height:64px"></tr></tbody></table>`,
 		},
 	},
+	{
+		`<img id="1" width="50"><img id="2" width=" 100 "><img id="3" width="200"><img id="4" width="wide"><img id="5">`,
+		`img[width>=100]`,
+		[]string{
+			`<img id="2" width=" 100 "/>`,
+			`<img id="3" width="200"/>`,
+		},
+	},
+	{
+		`<img id="1" width="50"><img id="2" width=" 100 "><img id="3" width="200"><img id="4" width="wide"><img id="5">`,
+		`img[width > 100], img[width<"1e2"]`,
+		[]string{
+			`<img id="1" width="50"/>`,
+			`<img id="3" width="200"/>`,
+		},
+	},
+	{
+		`<li data-price="9.99"></li><li data-price="10"></li><li data-price="-1.5"></li>`,
+		`li[data-price<9.99]`,
+		[]string{
+			`<li data-price="-1.5"></li>`,
+		},
+	},
+	{
+		`<li data-price="9.99"></li><li data-price="10"></li><li data-price="-1.5"></li>`,
+		`li[data-price<=9.99][data-price>-1]`,
+		[]string{
+			`<li data-price="9.99"></li>`,
+		},
+	},
+	{
+		`<li id="1" data-n="inf"></li><li id="2" data-n="Infinity"></li><li id="3" data-n="NaN"></li><li id="4" data-n="0x10"></li>` +
+			`<li id="5" data-n="1_000"></li><li id="6" data-n="1e999"></li><li id="7" data-n=".5e1"></li><li id="8" data-n="5."></li>`,
+		`li[data-n>1], li[data-n<1]`,
+		[]string{
+			`<li id="7" data-n=".5e1"></li>`,
+			`<li id="8" data-n="5."></li>`,
+		},
+	},
 	{
 		`<p class="t1 t2">`,
 		".t1:not(.t2)",
@@ -483,6 +523,14 @@ var selectorTests = []selectorTest{
 			`<span></span>`,
 		},
 	},
+	{
+		`<p id="1"><!-- --><p id="2">Hello<p id="3"><span>`,
+		`p:parent`,
+		[]string{
+			`<p id="2">Hello</p>`,
+			`<p id="3"><span></span></p>`,
+		},
+	},
 	{
 		`<div><p id="1"><table><tr><td><p id="2"></table></div><p id="3">`,
 		`div p`,
@@ -565,6 +613,55 @@ var selectorTests = []selectorTest{
 			`<p>Text block that <span>wraps inner text</span> and continues</p>`,
 		},
 	},
+	{
+		`<p id="1">Hauptstraße 5</p><p id="2">ПРИВЕТ, мир</p><p id="3">İstanbul</p>`,
+		`p:icontains("STRASSE")`,
+		[]string{
+			`<p id="1">Hauptstraße 5</p>`,
+		},
+	},
+	{
+		`<p id="1">Hauptstraße 5</p><p id="2">ПРИВЕТ, мир</p><p id="3">İstanbul</p>`,
+		`p:icontains("привет"), p:icontains(ıstanbul)`,
+		[]string{
+			`<p id="2">ПРИВЕТ, мир</p>`,
+			`<p id="3">İstanbul</p>`,
+		},
+	},
+	{
+		`<p id="1">Add to cart</p><p id="2">Modern Art.</p><p id="3">art-deco</p><p id="4">artist</p>`,
+		`p:contains-word(art)`,
+		[]string{
+			`<p id="2">Modern Art.</p>`,
+			`<p id="3">art-deco</p>`,
+		},
+	},
+	{
+		`<p id="1">cart, then art</p><p id="2">in <b>the</b> news</p>`,
+		`p:contains-word("art"), p:contains-word("he new")`,
+		[]string{
+			`<p id="1">cart, then art</p>`,
+		},
+	},
+	{
+		`<a id="1">Next</a><a id="2">  Next
+			</a><a id="3">Next page</a><a id="4">next</a><a id="5"><b>Ne</b>xt</a>`,
+		`a:text-is(Next)`,
+		[]string{
+			`<a id="1">Next</a>`,
+			`<a id="2">  Next
+			</a>`,
+			`<a id="5"><b>Ne</b>xt</a>`,
+		},
+	},
+	{
+		`<a id="1">Next</a><a id="2">  Next
+			</a><a id="3">Next page</a><a id="4">next</a><a id="5"><b>Ne</b>xt</a>`,
+		`a:text-is(" Next   page")`,
+		[]string{
+			`<a id="3">Next page</a>`,
+		},
+	},
 	{
 		`<div id="d1"><p id="p1"><span>text content</span></p></div><div id="d2"/>`,
 		`div:has(#p1)`,
@@ -660,6 +757,86 @@ var selectorTests = []selectorTest{
 			`<em>567</em>`,
 		},
 	},
+	{
+		`<h1>Title</h1><p>Intro</p><h3>Part</h3><header>Banner</header><my-widget></my-widget><my-card></my-card>`,
+		`:tag-matches(^h[1-6]$), :tag-matches(^my-)`,
+		[]string{
+			`<h1>Title</h1>`,
+			`<h3>Part</h3>`,
+			`<my-widget></my-widget>`,
+			`<my-card></my-card>`,
+		},
+	},
+	{
+		`<div id="1" data-user-id="7"></div><div id="2" data-user-id="8" data-role="admin"></div><div id="3" data-userid="7"></div>`,
+		`:data(userId)`,
+		[]string{
+			`<div id="1" data-user-id="7"></div>`,
+			`<div id="2" data-user-id="8" data-role="admin"></div>`,
+		},
+	},
+	{
+		`<div id="1" data-user-id="7"></div><div id="2" data-user-id="8" data-role="admin"></div><div id="3" data-userid="7"></div>`,
+		`:data(user-id = "7"), div:data(role=admin)`,
+		[]string{
+			`<div id="1" data-user-id="7"></div>`,
+			`<div id="2" data-user-id="8" data-role="admin"></div>`,
+		},
+	},
+	{
+		`<nav><a id="1" href="/">Home</a><a id="2">No link</a><span id="3" role="link button">JS link</span></nav>` +
+			`<div id="4" role="navigation"></div><button id="5" role="LINK"></button>`,
+		`:aria(link), :aria(navigation)`,
+		[]string{
+			`<nav><a id="1" href="/">Home</a><a id="2">No link</a><span id="3" role="link button">JS link</span></nav>`,
+			`<a id="1" href="/">Home</a>`,
+			`<span id="3" role="link button">JS link</span>`,
+			`<div id="4" role="navigation"></div>`,
+			`<button id="5" role="LINK"></button>`,
+		},
+	},
+	{
+		`<header id="1"></header><article><header id="2"></header></article><input id="3" type="submit"><input id="4">` +
+			`<input id="5" list="l"><select id="6" multiple></select><img id="7" alt="">`,
+		`:aria(banner), :aria(button), :aria(textbox), :aria(combobox), :aria(listbox), :aria(presentation)`,
+		[]string{
+			`<header id="1"></header>`,
+			`<input id="3" type="submit"/>`,
+			`<input id="4"/>`,
+			`<input id="5" list="l"/>`,
+			`<select id="6" multiple=""></select>`,
+			`<img id="7" alt=""/>`,
+		},
+	},
+	{
+		`<button id="1" aria-expanded="true"></button><button id="2" aria-expanded="false"></button><div id="3" aria-level="2"></div>`,
+		`:aria(expanded=TRUE), :aria(aria-level="2")`,
+		[]string{
+			`<button id="1" aria-expanded="true"></button>`,
+			`<div id="3" aria-level="2"></div>`,
+		},
+	},
+	{
+		`<h1 id="1"></h1><h2 id="2"></h2><h4 id="3"></h4><h5 id="4"></h5><div id="5" role="heading"></div>` +
+			`<div id="6" role="heading" aria-level="4"></div><h2 id="7" role="tab"></h2><h6 id="8" aria-level="3"></h6>`,
+		`:heading(2-4)`,
+		[]string{
+			`<h2 id="2"></h2>`,
+			`<h4 id="3"></h4>`,
+			`<div id="5" role="heading"></div>`,
+			`<div id="6" role="heading" aria-level="4"></div>`,
+			`<h6 id="8" aria-level="3"></h6>`,
+		},
+	},
+	{
+		`<h1 id="1"></h1><h2 id="2"></h2><h4 id="3"></h4><h5 id="4"></h5><div id="5" role="heading"></div>` +
+			`<div id="6" role="heading" aria-level="4"></div><h2 id="7" role="tab"></h2><h6 id="8" aria-level="3"></h6>`,
+		`:heading(1), h5:heading`,
+		[]string{
+			`<h1 id="1"></h1>`,
+			`<h5 id="4"></h5>`,
+		},
+	},
 	{
 		`<ul>
 			<li><a id="a1" href="http://www.google.com/finance"></a>
@@ -711,6 +888,66 @@ var selectorTests = []selectorTest{
 			`<button>Sign up</button>`,
 		},
 	},
+	{
+		`<form><input id="1"><input id="2" type="TEXT"><input id="3" type="bogus"><input id="4" type="password">` +
+			`<input id="5" type="checkbox"><textarea></textarea></form>`,
+		`:text`,
+		[]string{
+			`<input id="1"/>`,
+			`<input id="2" type="TEXT"/>`,
+			`<input id="3" type="bogus"/>`,
+		},
+	},
+	{
+		`<form><input id="1" type="checkbox"><input id="2" type="radio"><input id="3" type="password"><input id="4" type="file">` +
+			`<input id="5" type="image"></form>`,
+		`:checkbox, :radio, :password, :file, :image`,
+		[]string{
+			`<input id="1" type="checkbox"/>`,
+			`<input id="2" type="radio"/>`,
+			`<input id="3" type="password"/>`,
+			`<input id="4" type="file"/>`,
+			`<input id="5" type="image"/>`,
+		},
+	},
+	{
+		`<form><input id="1" type="submit"><button id="2">Go</button><button id="3" type="reset">Clear</button>` +
+			`<button id="4" type="button">Help</button><input id="5" type="reset"></form>`,
+		`:submit`,
+		[]string{
+			`<input id="1" type="submit"/>`,
+			`<button id="2">Go</button>`,
+		},
+	},
+	{
+		`<form><input id="1" type="submit"><button id="2">Go</button><button id="3" type="reset">Clear</button>` +
+			`<button id="4" type="button">Help</button><input id="5" type="reset"></form>`,
+		`:reset`,
+		[]string{
+			`<button id="3" type="reset">Clear</button>`,
+			`<input id="5" type="reset"/>`,
+		},
+	},
+	{
+		`<form><input id="1" type="submit"><button id="2">Go</button><input id="3" type="button">` +
+			`<input id="4" type="Reset"><input id="5" type="image"><input id="6"></form>`,
+		`:button`,
+		[]string{
+			`<input id="1" type="submit"/>`,
+			`<button id="2">Go</button>`,
+			`<input id="3" type="button"/>`,
+			`<input id="4" type="Reset"/>`,
+		},
+	},
+	{
+		`<h1>Title</h1><p>Intro</p><h3>Part</h3><header>Banner</header><h6>Note</h6>`,
+		`:header`,
+		[]string{
+			`<h1>Title</h1>`,
+			`<h3>Part</h3>`,
+			`<h6>Note</h6>`,
+		},
+	},
 	{
 		`<html><head></head><body></body></html>`,
 		":root",
@@ -746,6 +983,13 @@ var selectorTests = []selectorTest{
 			`<html><head></head><body></body></html>`,
 		},
 	},
+	{
+		`<html><head></head><body></body></html>`,
+		":scope > body",
+		[]string{
+			"<body></body>",
+		},
+	},
 	{
 		`<html><head></head><body><a href="http://www.foo.com"></a></body></html>`,
 		"a:not(:root)",
@@ -782,6 +1026,37 @@ var selectorTests = []selectorTest{
 			`<fieldset></fieldset>`,
 		},
 	},
+	{
+		`<div id="1" hidden><p id="2"></p></div><p id="3" style="color: red; DISPLAY : none !important">` +
+			`<p id="4" aria-hidden="true"><p id="5" style="visibility:hidden"><input id="6" type="hidden"><p id="7">`,
+		`body :hidden`,
+		[]string{
+			`<div id="1" hidden=""><p id="2"></p></div>`,
+			`<p id="2"></p>`,
+			`<p id="3" style="color: red; DISPLAY : none !important"></p>`,
+			`<p id="4" aria-hidden="true"></p>`,
+			`<p id="5" style="visibility:hidden"><input id="6" type="hidden"/></p>`,
+			`<input id="6" type="hidden"/>`,
+		},
+	},
+	{
+		`<div id="1" hidden><p id="2"></p></div><p id="3" style="display: block; display: none"><p id="4" aria-hidden="false">` +
+			`<input id="5" type="text"><script></script>`,
+		`body :visible`,
+		[]string{
+			`<p id="4" aria-hidden="false"><input id="5" type="text"/><script></script></p>`,
+			`<input id="5" type="text"/>`,
+		},
+	},
+	{
+		`<div id="1" style="visibility: hidden"><p id="2" style="visibility: visible"><b id="3"></b></p><p id="4"></p></div>` +
+			`<div id="5" style="display: none"><p id="6" style="visibility: visible"></p></div>`,
+		`body :visible`,
+		[]string{
+			`<p id="2" style="visibility: visible"><b id="3"></b></p>`,
+			`<b id="3"></b>`,
+		},
+	},
 	{
 		`<div class=class1></div><div class=class2></div><div class=class3></div>`,
 		"div.class1, div.class2",
@@ -807,6 +1082,9 @@ func setup(selector, testHTML string) (Selector, *html.Node, error) {
 
 func TestSelectors(t *testing.T) {
 	for _, test := range selectorTests {
+		if skipRegexp(test.selector) {
+			continue
+		}
 		s, doc, err := setup(test.selector, test.HTML)
 		if err != nil {
 			t.Error(err)
@@ -840,6 +1118,240 @@ func TestSelectors(t *testing.T) {
 	}
 }
 
+func TestStrictAttributes(t *testing.T) {
+	doc := MustParseHTML(`<p id=1 a=" "></p><p id=2 a="x  y"></p><p id=3 a=""></p><p id=4 a="x y"></p>`)
+	for _, test := range []struct {
+		sel           string
+		strict, loose []string
+	}{
+		{`p[a^=" "]`, []string{"1"}, nil},
+		{`p[a$=" "]`, []string{"1"}, nil},
+		{`p[a*=" "]`, []string{"1", "2", "4"}, []string{"2", "4"}},
+		{`p[a^=""]`, nil, []string{"2", "4"}},
+		{`p[a$=""]`, nil, []string{"2", "4"}},
+		{`p[a*=""]`, nil, []string{"2", "4"}},
+		{`p[a~=""]`, nil, []string{"1", "2"}},
+		{`p[a~="x y"]`, nil, nil},
+		{`p[a~=y]`, []string{"2", "4"}, []string{"2", "4"}},
+		{`p:not([a^=""])`, []string{"1", "2", "3", "4"}, []string{"1", "3"}},
+	} {
+		for _, mode := range []struct {
+			strict bool
+			want   []string
+		}{{true, test.strict}, {false, test.loose}} {
+			s, err := ParseGroupWithOptions(test.sel, ParseOptions{StrictAttributes: mode.strict})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, n := range QueryAll(doc, s) {
+				got = append(got, getId(n))
+			}
+			if !reflect.DeepEqual(got, mode.want) {
+				t.Errorf("%s (strict %v): got %q, want %q", test.sel, mode.strict, got, mode.want)
+			}
+		}
+	}
+}
+
+func TestQuirksMode(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id="Main" class="Item FEATURED"><p class="item">`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		selector        string
+		standard, quirk int
+	}{
+		{"#main", 0, 1},
+		{"#Main", 1, 1},
+		{".item", 1, 2},
+		{".featured.ITEM", 0, 1},
+		{"[class~=item]", 1, 1},
+	} {
+		standard, err := ParseGroup(test.selector)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.selector, err)
+		}
+		quirk, err := ParseGroupWithOptions(test.selector, ParseOptions{QuirksMode: true})
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.selector, err)
+		}
+		if got := len(QueryAll(doc, standard)); got != test.standard {
+			t.Errorf("%s: got %d matches in standards mode, want %d", test.selector, got, test.standard)
+		}
+		if got := len(QueryAll(doc, quirk)); got != test.quirk {
+			t.Errorf("%s: got %d matches in quirks mode, want %d", test.selector, got, test.quirk)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id=outer class=box><div id=inner class=box><p><a id=link>text</a></p></div></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := Query(doc, MustParseGroup(t, "#link"))
+	text := link.FirstChild
+	for _, test := range []struct {
+		start    *html.Node
+		selector string
+		want     string // the id of the expected element, or "" for none
+	}{
+		{link, "a", "link"},
+		{link, ".box", "inner"},
+		{text, ".box", "inner"},
+		{link, "body > .box", "outer"},
+		{link, "div:not(:haschild(p))", "outer"},
+		{link, "span", ""},
+		{link, ".box:first", "outer"},
+		{link, ".box:last", "inner"},
+	} {
+		s := MustParseGroup(t, test.selector)
+		got := Closest(test.start, s)
+		var id string
+		if got != nil {
+			for _, a := range got.Attr {
+				if a.Key == "id" {
+					id = a.Val
+				}
+			}
+		}
+		if id != test.want {
+			t.Errorf("Closest(%s) = %q, want %q", test.selector, id, test.want)
+		}
+	}
+
+	if got := MustCompile(".box").Closest(link); got == nil || got.Attr[0].Val != "inner" {
+		t.Errorf("Selector.Closest returned the wrong element")
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := append(append([]selectorTest(nil), selectorTests...), positionalTests...)
+	for _, test := range tests {
+		if skipRegexp(test.selector) {
+			continue
+		}
+		s, doc, err := setupMatcher(test.selector, test.HTML)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		all := QueryAll(doc, s)
+		for _, limit := range []int{-1, 0, 1, 2} {
+			want := all
+			if limit >= 0 && len(want) > limit {
+				want = want[:limit]
+			}
+			if got := QueryAllN(doc, s, limit); len(got) != len(want) || len(want) > 0 && !reflect.DeepEqual(got, want) {
+				t.Errorf("QueryAllN(%s, %d) returned %d nodes, want %d", test.selector, limit, len(got), len(want))
+			}
+		}
+
+		compiled, err := Compile(test.selector)
+		if err != nil {
+			continue
+		}
+		all = compiled.MatchAll(doc)
+		for _, limit := range []int{-1, 0, 1, 2} {
+			want := all
+			if limit >= 0 && len(want) > limit {
+				want = want[:limit]
+			}
+			if got := compiled.MatchAllN(doc, limit); len(got) != len(want) || len(want) > 0 && !reflect.DeepEqual(got, want) {
+				t.Errorf("MatchAllN(%s, %d) returned %d nodes, want %d", test.selector, limit, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestAppendMatches(t *testing.T) {
+	var buf []*html.Node
+	for _, test := range selectorTests {
+		if skipRegexp(test.selector) {
+			continue
+		}
+		s, doc, err := setupMatcher(test.selector, test.HTML)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		compiled := MustCompile(test.selector)
+		buf = AppendMatches(buf[:0], doc, s)
+		if want := compiled.MatchAll(doc); len(buf) != len(want) || len(want) > 0 && !reflect.DeepEqual(buf, want) {
+			t.Errorf("AppendMatches(%s) returned %d nodes, want %d", test.selector, len(buf), len(want))
+		}
+	}
+
+	doc, err := html.Parse(strings.NewReader(`<p id=1><p id=2><p id=3>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := []*html.Node{doc}
+	dst = AppendMatches(dst, doc, MustParseGroup(t, "p:gt(0)"))
+	if len(dst) != 3 || dst[0] != doc || dst[1].Attr[0].Val != "2" {
+		t.Errorf("AppendMatches didn't keep dst and filter the new matches: got %d nodes", len(dst))
+	}
+
+	var p Matcher = MustParseGroup(t, "p")
+	allocs := testing.AllocsPerRun(10, func() {
+		dst = AppendMatches(dst[:0], doc, p)
+	})
+	if allocs > 0 {
+		t.Errorf("AppendMatches with enough capacity made %v allocations", allocs)
+	}
+}
+
+func TestGroupOrder(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id=1 class=a><p id=2 class=a></p><span id=3></span></div><p id=4>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sel, want := range map[string][]string{
+		"p, div":            {"1", "2", "4"},
+		"span, p.a, .a, p":  {"1", "2", "3", "4"},
+		"#4, #3, #2, #1":    {"1", "2", "3", "4"},
+		".a:last, p, .a":    {"1", "2", "4"},
+		"p:first, .a:eq(1)": {"2"},
+	} {
+		s := MustParseGroup(t, sel)
+		results := map[string][]*html.Node{
+			"QueryAll":        QueryAll(doc, s),
+			"QueryAllContext": QueryAllContext(doc, s),
+			"QueryAllN":       QueryAllN(doc, s, -1),
+			"Filter":          Filter(QueryAll(doc, MustParseGroup(t, "*")), s),
+		}
+		if compiled, err := Compile(sel); err == nil {
+			results["MatchAll"] = compiled.MatchAll(doc)
+		}
+		for name, nodes := range results {
+			var ids []string
+			for _, n := range nodes {
+				for _, a := range n.Attr {
+					if a.Key == "id" {
+						ids = append(ids, a.Val)
+					}
+				}
+			}
+			if !reflect.DeepEqual(ids, want) {
+				t.Errorf("%s(%s) = %v, want %v", name, sel, ids, want)
+			}
+		}
+	}
+}
+
+// skipRegexp returns whether sel uses a regular expression in a build
+// without regexp support, so that tests which share tables of selectors
+// should leave it out.
+func skipRegexp(sel string) bool {
+	if regexpSupported {
+		return false
+	}
+	sel = toLowerASCII(sel)
+	return strings.Contains(sel, "#=") || strings.Contains(sel, ":matches") || strings.Contains(sel, ":tag-matches")
+}
+
 func setupMatcher(selector, testHTML string) (Matcher, *html.Node, error) {
 	s, err := ParseGroup(selector)
 	if err != nil {
@@ -855,6 +1367,9 @@ func setupMatcher(selector, testHTML string) (Matcher, *html.Node, error) {
 
 func TestMatchers(t *testing.T) {
 	for _, test := range selectorTests {
+		if skipRegexp(test.selector) {
+			continue
+		}
 		s, doc, err := setupMatcher(test.selector, test.HTML)
 		if err != nil {
 			t.Error(err)
@@ -962,6 +1477,34 @@ func TestPseudoElement(t *testing.T) {
 	}
 }
 
+func TestPseudoElementPlacement(t *testing.T) {
+	for _, test := range []struct {
+		sel    string
+		strict bool // whether it parses without QuirksMode
+	}{
+		{"p::before", true},
+		{"p:before", true},
+		{"p:first-line", true},
+		{"p::selection", true},
+		{"p:selection", false},
+		{"input:placeholder", false},
+		{"div, p::after", true},
+		{"p::before > span", false},
+		{"p::before span", false},
+		{"div p::before + span", false},
+		{":not(p::before)", false},
+		{"div:has(p::after)", false},
+	} {
+		_, err := ParseGroupWithOptions(test.sel, ParseOptions{PseudoElements: true})
+		if (err == nil) != test.strict {
+			t.Errorf("%s: got error %v, want error: %v", test.sel, err, !test.strict)
+		}
+		if _, err := ParseGroupWithOptions(test.sel, ParseOptions{PseudoElements: true, QuirksMode: true}); err != nil {
+			t.Errorf("%s in quirks mode: %s", test.sel, err)
+		}
+	}
+}
+
 type invalidSelector struct {
 	Name     string `json:"name,omitempty"`
 	Selector string `json:"selector,omitempty"`
@@ -1034,3 +1577,231 @@ func TestShakespeare(t *testing.T) {
 	assertCount("div[class|=dialog]", 50)
 	assertCount("div[class~=dialog]", 51)
 }
+
+func TestTextFunc(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id=a>Price<script>var price = 1;</script><style>p{}</style></div><div id=b>price list</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		sel        string
+		all, shown []string
+	}{
+		{"div:contains(price)", []string{"a", "b"}, []string{"a", "b"}},
+		{"div:contains(var)", []string{"a"}, nil},
+		{"div:matches(\\{\\})", []string{"a"}, nil},
+		{`div:text-is("Price")`, nil, []string{"a"}},
+		{"div:contains-word(list)", []string{"b"}, []string{"b"}},
+	} {
+		if skipRegexp(test.sel) {
+			continue
+		}
+		for _, c := range []struct {
+			opts ParseOptions
+			want []string
+		}{
+			{ParseOptions{}, test.all},
+			{ParseOptions{Text: VisibleText}, test.shown},
+		} {
+			s, err := ParseGroupWithOptions(test.sel, c.opts)
+			if err != nil {
+				t.Fatalf("error compiling %q: %s", test.sel, err)
+			}
+			var got []string
+			for _, n := range QueryAll(doc, s) {
+				got = append(got, n.Attr[0].Val)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("%s (Text set: %v): got %q, want %q", test.sel, c.opts.Text != nil, got, c.want)
+			}
+		}
+	}
+}
+
+func TestQueryAllFrom(t *testing.T) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	fragments, err := html.ParseFragment(strings.NewReader(`<p id=a>one <b id=b>two</b></p><p id=c>three</p><b id=d>four</b>`), context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := html.Parse(strings.NewReader(`<div id=e><p id=f></p></div><p id=g></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	div := Query(doc, MustParseGroup(t, "div"))
+	p := Query(doc, MustParseGroup(t, "#f"))
+
+	for _, test := range []struct {
+		roots []*html.Node
+		sel   string
+		want  []string
+	}{
+		{fragments, "p", []string{"a", "c"}},
+		{fragments, "b", []string{"b", "d"}},
+		{fragments, "p > b", []string{"b"}},
+		{fragments, "p:last, b:first", []string{"b", "c"}},
+		{fragments, ":odd", []string{"b", "d"}},
+		// #f is inside #e, and the roots are out of order.
+		{[]*html.Node{p, div, doc}, "p", []string{"f", "g"}},
+		{[]*html.Node{fragments[2], div}, "*", []string{"d", "e", "f"}},
+		{nil, "p", nil},
+	} {
+		var got []string
+		for _, n := range QueryAllFrom(test.roots, MustParseGroup(t, test.sel)) {
+			got = append(got, n.Attr[0].Val)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.sel, got, test.want)
+		}
+	}
+}
+
+func TestStructuralFragments(t *testing.T) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	fragments, err := html.ParseFragment(strings.NewReader(`<p id=a><b id=b>one</b><b id=c>two</b></p><p id=d>three</p>`), context)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The nodes from ParseFragment have no parent, so QueryAllFrom treats
+	// them as the children of a common parent.
+	for _, test := range []struct {
+		sel  string
+		want []string
+	}{
+		{":first-child", []string{"a", "b"}},
+		{":last-child", []string{"c", "d"}},
+		{":only-child", nil},
+		{":first-of-type", []string{"a", "b"}},
+		{":only-of-type", nil},
+		{":nth-child(1)", []string{"a", "b"}},
+		{":nth-child(2)", []string{"c", "d"}},
+		{":nth-child(odd)", []string{"a", "b"}},
+		{":nth-last-child(n+2)", []string{"a", "b"}},
+		{"p:first-child > b:last-child", []string{"c"}},
+		{"p:last-child", []string{"d"}},
+	} {
+		var got []string
+		for _, n := range QueryAllFrom(fragments, MustParseGroup(t, test.sel)) {
+			got = append(got, n.Attr[0].Val)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.sel, got, test.want)
+		}
+	}
+
+	// A lone root is an only child.
+	if got := QueryAllFrom(fragments[1:], MustParseGroup(t, "p:only-child")); len(got) != 1 || got[0] != fragments[1] {
+		t.Errorf("p:only-child found %d nodes in a fragment with one root", len(got))
+	}
+
+	// Outside QueryAllFrom, an element without a parent isn't anyone's
+	// child.
+	for _, sel := range []string{":first-child", ":last-child", ":only-child", ":nth-child(n)", ":nth-last-of-type(1)"} {
+		if MustParse(t, sel).Match(fragments[0]) {
+			t.Errorf("%s matches an element without a parent", sel)
+		}
+	}
+}
+
+func TestCompoundTagFilter(t *testing.T) {
+	doc := MustParseHTML(`<p class="a">1</p><div class="a">2</div><my-el class="a">3</my-el>`)
+	// A node built by hand, without its DataAtom set.
+	doc.LastChild.LastChild.AppendChild(&html.Node{
+		Type: html.ElementNode,
+		Data: "p",
+		Attr: []html.Attribute{{Key: "class", Val: "a"}},
+	})
+	for _, test := range []struct {
+		name string
+		sel  Sel
+		want int
+	}{
+		{"p.a", MustParseGroup(t, "p.a")[0], 2},
+		{"div.a", MustParseGroup(t, "div.a")[0], 1},
+		{"my-el.a", MustParseGroup(t, "my-el.a")[0], 1},
+		{"span.a", MustParseGroup(t, "span.a")[0], 0},
+		{"Builder", Tag("p").Class("a").Sel(), 2},
+		{"Normalize", Normalize(MustParseGroup(t, "p:not(.b).a")[0]), 2},
+	} {
+		if got := len(QueryAll(doc, test.sel)); got != test.want {
+			t.Errorf("%s: got %d matches, want %d", test.name, got, test.want)
+		}
+		if c, ok := test.sel.(compoundSelector); !ok || c.tag == "" {
+			t.Errorf("%s: compound selector has no tag filter", test.name)
+		}
+	}
+}
+
+func TestCompoundAttributes(t *testing.T) {
+	doc := MustParseHTML(`<form>
+		<input name="q" type="text" required class="big box" id="search">
+		<input name="q" type="text">
+		<input name="q" name="x" type="TEXT" required>
+		<input type="number" value="12" lang="en-US">
+		<input value="7">
+	</form>`)
+	for _, sel := range []string{
+		`input[name=q][type=text][required]`,
+		`[name=q][type=text i]`,
+		`[name="q"][type][type!=text]`,
+		`input.big[required]#search`,
+		`.box[name^=q][type$=xt][type*=ex]`,
+		`[value>10][type~=number][lang|=en]`,
+		`[value][value<10]`,
+		`[name=x][name=q]`,
+	} {
+		c, ok := MustParseGroup(t, sel)[0].(compoundSelector)
+		if !ok {
+			t.Errorf("%s: not a compound selector", sel)
+			continue
+		}
+		if c.attrs == nil {
+			t.Errorf("%s: attributes aren't checked together", sel)
+		}
+		for n := doc; n != nil; n = nextNode(n, doc) {
+			want := true
+			for _, part := range c.selectors {
+				want = want && part.Match(n)
+			}
+			if got := c.Match(n); got != want {
+				t.Errorf("%s on %s: got %v, want %v", sel, nodeString(n), got, want)
+			}
+		}
+	}
+}
+
+func TestCompoundOrder(t *testing.T) {
+	doc := MustParseHTML(`<p>one</p><p class="a">two</p><div class="a">three</div><p>four</p>`)
+	for _, test := range []struct {
+		sel   string
+		order string
+	}{
+		{`:contains("t").a`, `.a :contains("t")`},
+		{`p:has(b):first-child[title]#x`, `p #x [title] :first-child :has(b)`},
+		{`:not(:contains("x")):not(.b).c`, `:not(.b) .c :not(:contains("x"))`},
+	} {
+		c := MustParseGroup(t, test.sel)[0].(compoundSelector)
+		if got := c.String(); got != test.sel {
+			t.Errorf("String() = %q, want %q", got, test.sel)
+		}
+		parts := make([]string, len(c.order))
+		for i, part := range c.order {
+			parts[i] = part.String()
+		}
+		if got := strings.Join(parts, " "); got != test.order {
+			t.Errorf("%s: parts tested in the order %s, want %s", test.sel, got, test.order)
+		}
+	}
+
+	// The text of the elements without the class isn't looked at, and the
+	// search stops at the first "t" in the others.
+	var stats Stats
+	m := CollectStats(MustParseGroup(t, `:contains("t").a`), &stats)
+	if got := len(QueryAll(doc, m)); got != 2 {
+		t.Errorf("found %d elements, want 2", got)
+	}
+	if want := len("t") + len("t"); stats.TextBytes != want {
+		t.Errorf("scanned %d bytes of text, want %d", stats.TextBytes, want)
+	}
+}
@@ -0,0 +1,322 @@
+package cascadia
+
+import (
+	"sort"
+
+	"golang.org/x/net/html"
+)
+
+// A SelectorSet holds many selectors, and finds the elements that each of
+// them matches in a single walk of the document. Each selector is indexed
+// by the ID, class or tag name that its last compound selector requires, so
+// that for each element only the selectors that could match it are tested.
+// This is much faster than running the selectors one at a time, when there
+// are many of them.
+//
+// A SelectorSet must not be modified while it is being used to match, but
+// otherwise it is safe for concurrent use.
+type SelectorSet struct {
+	members []setMember
+	count   int // the number of selectors added
+
+	byID    map[string][]int // indexes into members
+	byClass map[string][]int
+	byTag   map[string][]int
+	other   []int
+
+	positional []int // the members with result-set pseudo-classes
+}
+
+// A setMember is one Sel in a SelectorSet. A SelectorGroup that is added
+// to a set becomes several members, with the same id.
+type setMember struct {
+	sel Sel
+	id  int
+}
+
+// A SetMatch is a node found by SelectorSet.QueryAll, and the selectors
+// that matched it.
+type SetMatch struct {
+	Node *html.Node
+
+	// Selectors holds the indexes (as returned by Add) of the selectors
+	// that matched Node, in increasing order.
+	Selectors []int
+}
+
+// NewSelectorSet returns a SelectorSet containing sels. Their indexes are
+// their positions in sels.
+func NewSelectorSet(sels ...Sel) *SelectorSet {
+	s := new(SelectorSet)
+	for _, sel := range sels {
+		s.Add(sel)
+	}
+	return s
+}
+
+// CompileSelectorSet parses each of the selectors (which may be groups of
+// selectors separated by commas) and returns a SelectorSet containing them.
+// Their indexes are their positions in selectors.
+func CompileSelectorSet(selectors []string) (*SelectorSet, error) {
+	s := new(SelectorSet)
+	for _, sel := range selectors {
+		g, err := ParseGroup(sel)
+		if err != nil {
+			return nil, err
+		}
+		s.AddGroup(g)
+	}
+	return s, nil
+}
+
+// Add adds sel to the set, and returns its index, which identifies it in
+// the results of matching.
+func (s *SelectorSet) Add(sel Sel) int {
+	id := s.count
+	s.count++
+	s.addMember(sel, id)
+	return id
+}
+
+// AddGroup adds a group of selectors to the set, and returns its index. The
+// group matches a node if any of its selectors do.
+func (s *SelectorSet) AddGroup(g SelectorGroup) int {
+	id := s.count
+	s.count++
+	for _, sel := range g {
+		s.addMember(sel, id)
+	}
+	return id
+}
+
+// Len returns the number of selectors (and groups) in the set.
+func (s *SelectorSet) Len() int {
+	return s.count
+}
+
+func (s *SelectorSet) addMember(sel Sel, id int) {
+	i := len(s.members)
+	s.members = append(s.members, setMember{sel: sel, id: id})
+	if hasPositional(sel) {
+		s.positional = append(s.positional, i)
+	}
+
+	var tag, class, elementID string
+	switch last := lastCompound(sel).(type) {
+	case compoundSelector:
+		for _, part := range last.selectors {
+			switch part := part.(type) {
+			case tagSelector:
+				if part.name == "" {
+					tag = part.tag
+				}
+			case classSelector:
+				if !part.quirks && class == "" {
+					class = part.class
+				}
+			case idSelector:
+				if !part.quirks {
+					elementID = part.id
+				}
+			}
+		}
+	case tagSelector:
+		if last.name == "" {
+			tag = last.tag
+		}
+	case classSelector:
+		if !last.quirks {
+			class = last.class
+		}
+	case idSelector:
+		if !last.quirks {
+			elementID = last.id
+		}
+	}
+
+	switch {
+	case elementID != "":
+		if s.byID == nil {
+			s.byID = make(map[string][]int)
+		}
+		s.byID[elementID] = append(s.byID[elementID], i)
+	case class != "":
+		if s.byClass == nil {
+			s.byClass = make(map[string][]int)
+		}
+		s.byClass[class] = append(s.byClass[class], i)
+	case tag != "":
+		if s.byTag == nil {
+			s.byTag = make(map[string][]int)
+		}
+		s.byTag[tag] = append(s.byTag[tag], i)
+	default:
+		s.other = append(s.other, i)
+	}
+}
+
+// candidates appends the indexes of the members that might match n to
+// dst.
+func (s *SelectorSet) candidates(dst []int, n *html.Node) []int {
+	dst = append(dst, s.other...)
+	if n.Type != html.ElementNode {
+		return dst
+	}
+	dst = append(dst, s.byTag[n.Data]...)
+	if s.byID == nil && s.byClass == nil {
+		return dst
+	}
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "id":
+			dst = append(dst, s.byID[a.Val]...)
+		case "class":
+			if s.byClass == nil {
+				continue
+			}
+			// Without duplicates, so that no member is a candidate twice.
+			for _, class := range fieldsHTML(a.Val) {
+				dst = append(dst, s.byClass[class]...)
+			}
+		}
+	}
+	return dst
+}
+
+func isHTMLSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '\f'
+}
+
+// Match returns the indexes of the selectors in the set that match n, in
+// increasing order. Selectors with result-set pseudo-classes like :first
+// never match, as with Sel.Match.
+func (s *SelectorSet) Match(n *html.Node) []int {
+	var ids []int
+	for _, i := range s.candidates(nil, n) {
+		m := s.members[i]
+		if m.sel.Match(n) {
+			ids = append(ids, m.id)
+		}
+	}
+	return sortedUnique(ids)
+}
+
+// QueryAll finds the descendants of n that match any of the selectors in the
+// set, in a single walk of the tree, and returns them in document order,
+// along with the selectors that matched each one.
+func (s *SelectorSet) QueryAll(n *html.Node) []SetMatch {
+	var (
+		results []SetMatch
+		index   = make(map[*html.Node]int) // positions in results
+		buf     []int
+	)
+	isPositional := make(map[int]bool, len(s.positional))
+	for _, i := range s.positional {
+		isPositional[i] = true
+	}
+	positionalMatches := make(map[int][]*html.Node)
+	filtered := &matchContext{results: true}
+
+	record := func(n *html.Node, id int) {
+		j, ok := index[n]
+		if !ok {
+			j = len(results)
+			index[n] = j
+			results = append(results, SetMatch{Node: n})
+		}
+		results[j].Selectors = append(results[j].Selectors, id)
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			buf = s.candidates(buf[:0], c)
+			for _, i := range buf {
+				m := s.members[i]
+				if isPositional[i] {
+					if filtered.match(m.sel, c) {
+						positionalMatches[i] = append(positionalMatches[i], c)
+					}
+					continue
+				}
+				if !m.sel.Match(c) {
+					continue
+				}
+				record(c, m.id)
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+
+	if len(positionalMatches) > 0 {
+		for _, i := range s.positional {
+			m := s.members[i]
+			for _, node := range filterResults(filtered, m.sel, positionalMatches[i]) {
+				record(node, m.id)
+			}
+		}
+		nodes := make([]*html.Node, len(results))
+		for i, r := range results {
+			nodes[i] = r.Node
+		}
+		sortDocumentOrder(nodes)
+		sorted := make([]SetMatch, len(nodes))
+		for i, node := range nodes {
+			sorted[i] = results[index[node]]
+		}
+		results = sorted
+	}
+
+	for i := range results {
+		results[i].Selectors = sortedUnique(results[i].Selectors)
+	}
+	return results
+}
+
+// Unused returns the indexes (as returned by Add) of the selectors in the
+// set that don't match any of the descendants of the nodes in docs, in
+// increasing order. With the selectors from a stylesheet and the pages that
+// use it, these are the dead rules that could be removed. Keep in mind that
+// a selector for a state like :hover may not match in a static document.
+func (s *SelectorSet) Unused(docs ...*html.Node) []int {
+	used := make([]bool, s.count)
+	remaining := s.count
+	for _, doc := range docs {
+		if remaining == 0 {
+			break
+		}
+		for _, m := range s.QueryAll(doc) {
+			for _, id := range m.Selectors {
+				if !used[id] {
+					used[id] = true
+					remaining--
+				}
+			}
+		}
+	}
+
+	var unused []int
+	for id, u := range used {
+		if !u {
+			unused = append(unused, id)
+		}
+	}
+	return unused
+}
+
+// sortedUnique sorts ids and removes duplicates.
+func sortedUnique(ids []int) []int {
+	if len(ids) < 2 {
+		return ids
+	}
+	sort.Ints(ids)
+	j := 1
+	for _, id := range ids[1:] {
+		if id != ids[j-1] {
+			ids[j] = id
+			j++
+		}
+	}
+	return ids[:j]
+}
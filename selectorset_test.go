@@ -0,0 +1,187 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const selectorSetHTML = `<html><head><title>Set</title></head><body>
+<div id="main" class="content wide">
+  <p class="intro">One</p>
+  <p>Two <a href="/x">link</a></p>
+  <ul><li>a</li><li class="x">b</li><li>c</li></ul>
+</div>
+<p class="intro	outro">Three</p>
+</body></html>`
+
+func TestSelectorSet(t *testing.T) {
+	selectors := []string{
+		"p",
+		".intro",
+		"#main",
+		"div > p",
+		"li:first",
+		"li.x, a[href]",
+		"*:not(p)",
+		"ul li:nth-child(odd)",
+		"[class~=outro]",
+		"section",
+	}
+	doc, err := html.Parse(strings.NewReader(selectorSetHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := CompileSelectorSet(selectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != len(selectors) {
+		t.Errorf("Len() = %d, want %d", set.Len(), len(selectors))
+	}
+
+	// Build the expected results by running each selector separately.
+	want := make(map[*html.Node][]int)
+	for i, s := range selectors {
+		for _, n := range QueryAll(doc, MustParseGroup(t, s)) {
+			want[n] = append(want[n], i)
+		}
+	}
+
+	results := set.QueryAll(doc)
+	if len(results) != len(want) {
+		t.Errorf("got %d matching nodes, want %d", len(results), len(want))
+	}
+	var nodes []*html.Node
+	for _, r := range results {
+		nodes = append(nodes, r.Node)
+		if !reflect.DeepEqual(r.Selectors, want[r.Node]) {
+			t.Errorf("%s: got selectors %v, want %v", nodeString(r.Node), r.Selectors, want[r.Node])
+		}
+	}
+	sorted := append([]*html.Node(nil), nodes...)
+	sortDocumentOrder(sorted)
+	if !reflect.DeepEqual(nodes, sorted) {
+		t.Error("results are not in document order")
+	}
+
+	for n, ids := range want {
+		got := set.Match(n)
+		for _, id := range ids {
+			if hasPositional(MustParseGroup(t, selectors[id])) {
+				// Match can't check positions, so it never matches li:first.
+				if containsInt(got, id) {
+					t.Errorf("Match(%s) = %v, including %s", nodeString(n), got, selectors[id])
+				}
+				continue
+			}
+			if !containsInt(got, id) {
+				t.Errorf("Match(%s) = %v, missing %d", nodeString(n), got, id)
+			}
+		}
+	}
+}
+
+func TestSelectorSetAdd(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(selectorSetHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := NewSelectorSet(MustParse(t, "li"), MustParse(t, ".x"))
+	g := set.AddGroup(MustParseGroup(t, "#main, .intro"))
+	if g != 2 {
+		t.Errorf("AddGroup returned %d, want 2", g)
+	}
+
+	li := Query(doc, MustParse(t, "li.x"))
+	if got, want := set.Match(li), []int{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Match(li.x) = %v, want %v", got, want)
+	}
+	div := Query(doc, MustParse(t, "div"))
+	if got, want := set.Match(div), []int{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Match(div) = %v, want %v", got, want)
+	}
+	body := Query(doc, MustParse(t, "body"))
+	if got := set.Match(body); len(got) != 0 {
+		t.Errorf("Match(body) = %v, want none", got)
+	}
+}
+
+func TestSelectorSetDuplicateClasses(t *testing.T) {
+	set, err := CompileSelectorSet([]string{".a:eq(1)", "p.a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := MustParseHTML(`<p id="1" class="a a">1</p><p id="2" class="a">2</p>`)
+	want := QueryAll(doc, MustParseGroup(t, ".a:eq(1)"))
+	var got []string
+	for _, m := range set.QueryAll(doc) {
+		if containsInt(m.Selectors, 0) {
+			got = append(got, nodeString(m.Node))
+		}
+		if !reflect.DeepEqual(m.Selectors, sortedUnique(append([]int(nil), m.Selectors...))) {
+			t.Errorf("%s: selectors %v are repeated", nodeString(m.Node), m.Selectors)
+		}
+	}
+	if len(got) != 1 || got[0] != nodeString(want[0]) {
+		t.Errorf("set found .a:eq(1) at %v, want %s", got, nodeString(want[0]))
+	}
+}
+
+func TestSelectorSetUnused(t *testing.T) {
+	set, err := CompileSelectorSet([]string{
+		"p.intro",
+		".sidebar",
+		"li:first",
+		"#main > a",
+		"nav a, ul .x",
+		"footer, aside",
+		"li:nth-child(4)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc1 := MustParseHTML(selectorSetHTML)
+	doc2 := MustParseHTML(`<aside class=sidebar>x</aside>`)
+
+	if got, want := set.Unused(doc1), []int{1, 3, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with one document: got %v, want %v", got, want)
+	}
+	if got, want := set.Unused(doc1, doc2), []int{3, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with two documents: got %v, want %v", got, want)
+	}
+	if got, want := set.Unused(), []int{0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with no documents: got %v, want %v", got, want)
+	}
+}
+
+func containsInt(list []int, x int) bool {
+	for _, y := range list {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+func BenchmarkSelectorSet(b *testing.B) {
+	var selectors []string
+	for i := 0; i < 200; i++ {
+		selectors = append(selectors, "div.c"+string(rune('a'+i%26))+string(rune('a'+i/26))+" p")
+	}
+	selectors = append(selectors, "li", "#main", ".intro")
+	doc, err := html.Parse(strings.NewReader(selectorSetHTML))
+	if err != nil {
+		b.Fatal(err)
+	}
+	set, err := CompileSelectorSet(selectors)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		set.QueryAll(doc)
+	}
+}
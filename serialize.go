@@ -4,41 +4,33 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/andybalholm/cascadia/internal/cssesc"
 )
 
 // implements the reverse operation Sel -> string
 
-var specialCharReplacer *strings.Replacer
-
-func init() {
-	var pairs []string
-	for _, s := range ",!\"#$%&'()*+ -./:;<=>?@[\\]^`{|}~" {
-		pairs = append(pairs, string(s), "\\"+string(s))
-	}
-	specialCharReplacer = strings.NewReplacer(pairs...)
-}
-
-// espace special CSS char
-func escape(s string) string { return specialCharReplacer.Replace(s) }
-
 func (c tagSelector) String() string {
-	return c.tag
+	return cssesc.Ident(c.tag)
 }
 
 func (c idSelector) String() string {
-	return "#" + escape(c.id)
+	return "#" + cssesc.Ident(c.id)
 }
 
 func (c classSelector) String() string {
-	return "." + escape(c.class)
+	return "." + cssesc.Ident(c.class)
 }
 
 func (c attrSelector) String() string {
 	val := c.val
-	if c.operation == "#=" {
+	switch c.operation {
+	case "#=":
 		val = c.regexp.String()
-	} else if c.operation != "" {
-		val = fmt.Sprintf(`"%s"`, val)
+	case "", "<", "<=", ">", ">=":
+		// numbers don't need quoting
+	default:
+		val = cssesc.Quote(val)
 	}
 
 	ignoreCase := ""
@@ -47,7 +39,7 @@ func (c attrSelector) String() string {
 		ignoreCase = " i"
 	}
 
-	return fmt.Sprintf(`[%s%s%s%s]`, c.key, c.operation, val, ignoreCase)
+	return fmt.Sprintf(`[%s%s%s%s]`, cssesc.Ident(c.key), c.operation, val, ignoreCase)
 }
 
 func (c relativePseudoClassSelector) String() string {
@@ -59,7 +51,17 @@ func (c containsPseudoClassSelector) String() string {
 	if c.own {
 		s += "Own"
 	}
-	return fmt.Sprintf(`:%s("%s")`, s, c.value)
+	if c.fold {
+		s = "icontains"
+	}
+	if c.word {
+		s = "contains-word"
+	}
+	return fmt.Sprintf(":%s(%s)", s, cssesc.Quote(c.value))
+}
+
+func (c textIsPseudoClassSelector) String() string {
+	return fmt.Sprintf(":text-is(%s)", cssesc.Quote(c.value))
 }
 
 func (c regexpPseudoClassSelector) String() string {
@@ -70,6 +72,10 @@ func (c regexpPseudoClassSelector) String() string {
 	return fmt.Sprintf(":%s(%s)", s, c.regexp.String())
 }
 
+func (c tagRegexpPseudoClassSelector) String() string {
+	return fmt.Sprintf(":tag-matches(%s)", c.regexp.String())
+}
+
 func (c nthPseudoClassSelector) String() string {
 	if c.a == 0 && c.b == 1 { // special cases
 		s := ":first-"
@@ -94,11 +100,41 @@ func (c nthPseudoClassSelector) String() string {
 	case [2]bool{false, false}:
 		name = "nth-child"
 	}
-	s := fmt.Sprintf("+%d", c.b)
-	if c.b < 0 { // avoid +-8 invalid syntax
-		s = strconv.Itoa(c.b)
+	return fmt.Sprintf(":%s(%s)", name, formatNth(c.a, c.b))
+}
+
+// formatNth returns the an+b argument of an :nth-* pseudo-class.
+func formatNth(a, b int) string {
+	s := fmt.Sprintf("+%d", b)
+	if b < 0 { // avoid +-8 invalid syntax
+		s = strconv.Itoa(b)
+	}
+	return fmt.Sprintf("%dn%s", a, s)
+}
+
+func (c dataPseudoClassSelector) String() string {
+	key := strings.TrimPrefix(c.key, "data-")
+	if c.hasValue {
+		return fmt.Sprintf(":data(%s=%s)", cssesc.Ident(key), cssesc.Quote(c.value))
 	}
-	return fmt.Sprintf(":%s(%dn%s)", name, c.a, s)
+	return fmt.Sprintf(":data(%s)", cssesc.Ident(key))
+}
+
+func (c ariaPseudoClassSelector) String() string {
+	if c.role != "" {
+		return fmt.Sprintf(":aria(%s)", cssesc.Ident(c.role))
+	}
+	return fmt.Sprintf(":aria(%s=%s)", cssesc.Ident(strings.TrimPrefix(c.attr, "aria-")), cssesc.Quote(c.value))
+}
+
+func (c headingPseudoClassSelector) String() string {
+	switch {
+	case c.max == 0:
+		return ":heading"
+	case c.min == c.max:
+		return fmt.Sprintf(":heading(%d)", c.min)
+	}
+	return fmt.Sprintf(":heading(%d-%d)", c.min, c.max)
 }
 
 func (c onlyChildPseudoClassSelector) String() string {
@@ -112,20 +148,50 @@ func (c inputPseudoClassSelector) String() string {
 	return ":input"
 }
 
+func (c inputTypePseudoClassSelector) String() string {
+	return ":" + c.inputType
+}
+
+func (c buttonPseudoClassSelector) String() string {
+	return ":button"
+}
+
+func (c headerPseudoClassSelector) String() string {
+	return ":header"
+}
+
 func (c emptyElementPseudoClassSelector) String() string {
 	return ":empty"
 }
 
+func (c parentPseudoClassSelector) String() string {
+	return ":parent"
+}
+
 func (c rootPseudoClassSelector) String() string {
 	return ":root"
 }
 
+func (c scopePseudoClassSelector) String() string {
+	if c.implicit {
+		return ""
+	}
+	return ":scope"
+}
+
 func (c linkPseudoClassSelector) String() string {
 	return ":link"
 }
 
 func (c langPseudoClassSelector) String() string {
-	return fmt.Sprintf(":lang(%s)", c.lang)
+	return fmt.Sprintf(":lang(%s)", cssesc.Ident(c.lang))
+}
+
+func (c customPseudoClassSelector) String() string {
+	if c.class.parseArg == nil {
+		return ":" + c.class.name
+	}
+	return ":" + c.class.name + "(" + c.argument + ")"
 }
 
 func (c neverMatchSelector) String() string {
@@ -144,6 +210,23 @@ func (c checkedPseudoClassSelector) String() string {
 	return ":checked"
 }
 
+func (c visibilityPseudoClassSelector) String() string {
+	if c.visible {
+		return ":visible"
+	}
+	return ":hidden"
+}
+
+func (c positionalPseudoClassSelector) String() string {
+	switch c.name {
+	case "first", "last", "even", "odd":
+		return ":" + c.name
+	case "nth-match":
+		return fmt.Sprintf(":nth-match(%s)", formatNth(c.a, c.b))
+	}
+	return fmt.Sprintf(":%s(%d)", c.name, c.index)
+}
+
 func (c compoundSelector) String() string {
 	if len(c.selectors) == 0 && c.pseudoElement == "" {
 		return "*"
@@ -3,6 +3,8 @@ package cascadia
 import (
 	"reflect"
 	"testing"
+
+	"github.com/andybalholm/cascadia/internal/cssesc"
 )
 
 func TestSerialize(t *testing.T) {
@@ -21,6 +23,9 @@ func TestSerialize(t *testing.T) {
 	}
 
 	for _, test := range testSer {
+		if skipRegexp(test) {
+			continue
+		}
 		s, err := ParseGroupWithPseudoElements(test)
 		if err != nil {
 			t.Fatalf("error compiling %q: %s", test, err)
@@ -37,3 +42,75 @@ func TestSerialize(t *testing.T) {
 		}
 	}
 }
+
+func TestSerializeEscapes(t *testing.T) {
+	for _, test := range []string{
+		`[title="say \"hi\""]`,
+		`[title='back\\slash']`,
+		`[title="line\a break"]`,
+		`[data\:x=y]`,
+		`p:contains("it's \"quoted\"")`,
+		`p:containsOwn('a\\b')`,
+		`p:icontains("\"")`,
+		`p:text-is("a \"b\" c")`,
+		`:data(x="a\"b")`,
+		`:aria(label="\"x\"")`,
+		`.\31 23`,
+		`#\-1`,
+		`.a\.b\:c`,
+		`.tab\9 x`,
+		`my\:tag`,
+		`:lang(en\.x)`,
+		`p:matches(^"(a|b)"$)`,
+	} {
+		if skipRegexp(test) {
+			continue
+		}
+		s, err := ParseGroupWithPseudoElements(test)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test, err)
+		}
+
+		serialized := s.String()
+		s2, err := ParseGroupWithPseudoElements(serialized)
+		if err != nil {
+			t.Errorf("error compiling %q: %s (original : %s)", serialized, err, test)
+		} else if !reflect.DeepEqual(s, s2) {
+			t.Errorf("can't retrieve selector from serialized : %s (original : %s)", serialized, test)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	for _, test := range []struct {
+		ident, want string
+	}{
+		{"item", "item"},
+		{"my-class_2", "my-class_2"},
+		{"two words", `two\ words`},
+		{"1st", `\31 st`},
+		{"-2", `-\32 `},
+		{"--x", "--x"},
+		{"-", `\-`},
+		{"--", `-\-`},
+		{"a.b:c", `a\.b\:c`},
+		{`say "hi"`, `say\ \"hi\"`},
+		{"tab\tstop", `tab\9 stop`},
+		{"café", "café"},
+	} {
+		if got := cssesc.Ident(test.ident); got != test.want {
+			t.Errorf("cssesc.Ident(%q) = %s, want %s", test.ident, got, test.want)
+		}
+
+		// Classes and IDs with any of these names must survive a round
+		// trip.
+		for _, sel := range []Sel{classSelector{class: test.ident}, idSelector{id: test.ident}} {
+			s, err := Parse(sel.String())
+			if err != nil {
+				t.Errorf("error compiling %q: %s", sel.String(), err)
+			} else if !reflect.DeepEqual(s, sel) {
+				t.Errorf("can't retrieve %#v from serialized %s", sel, sel.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A Session caches information about a document across several queries,
+// so that work done by one query isn't repeated by the next: the text of
+// elements, for pseudo-classes like :contains() and :matches(); the
+// positions of elements among their siblings, for :nth-child() and the
+// like; and the split class attribute of the element that a class selector
+// tested last.
+//
+// QueryAll already caches text for the duration of one query when the
+// selector has more than one text pseudo-class. A Session keeps the cache
+// across queries, and for every selector.
+//
+// The document must not be changed while a Session is in use, and a
+// Session must not be used by more than one goroutine at a time.
+type Session struct {
+	c matchContext
+}
+
+// NewSession returns a new Session with empty caches.
+func NewSession() *Session {
+	return &Session{c: matchContext{texts: make(map[textKey]string), cacheClasses: true}}
+}
+
+// NewSessionWithOptions returns a new Session with empty caches, which
+// matches with the options in opts.
+func NewSessionWithOptions(opts MatchOptions) *Session {
+	s := NewSession()
+	s.c.inertTemplates = opts.InertTemplates
+	return s
+}
+
+// Match returns whether m matches n.
+func (s *Session) Match(m Matcher, n *html.Node) bool {
+	return s.c.match(m, n)
+}
+
+// Query returns the first node that matches m, from the descendants of n,
+// like the Query function.
+func (s *Session) Query(n *html.Node, m Matcher) *html.Node {
+	if hasPositional(m) {
+		if matches := s.QueryAll(n, m); len(matches) > 0 {
+			return matches[0]
+		}
+		return nil
+	}
+	return queryFirst(&s.c, n, m)
+}
+
+// QueryAll returns the nodes that match m, from the descendants of n, in
+// document order, like the QueryAll function.
+func (s *Session) QueryAll(n *html.Node, m Matcher) []*html.Node {
+	if q := newChainQuery(m); q != nil && !s.c.inertTemplates {
+		return q.queryAll(&s.c, n)
+	}
+	s.c.results = true
+	defer func() { s.c.results = false }()
+	return filterResults(&s.c, m, queryInto(&s.c, n, m, nil))
+}
@@ -0,0 +1,81 @@
+package cascadia
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const sessionHTML = `<div><p>Apples and <b>pears</b></p><p>Plums</p>
+<ul><li>One</li><li>Two</li><li>Three apples</li></ul></div>`
+
+func TestSessionMatchesQueryAll(t *testing.T) {
+	doc := MustParseHTML(sessionHTML)
+	s := NewSession()
+	for _, sel := range []string{
+		"p:contains(apples), li:contains(apples)",
+		":containsOwn(pears)",
+		"li:nth-child(2n+1)",
+		"div p:matches(^Plu)",
+		"li:text-is(Two)",
+		"li:first",
+	} {
+		if skipRegexp(sel) {
+			continue
+		}
+		g := MustParseGroup(t, sel)
+		want := QueryAll(doc, g)
+		for i := 0; i < 2; i++ {
+			got := s.QueryAll(doc, g)
+			if len(got) != len(want) {
+				t.Errorf("%s: session found %d elements, want %d", sel, len(got), len(want))
+				continue
+			}
+			for j := range got {
+				if got[j] != want[j] {
+					t.Errorf("%s: match %d is %s, want %s", sel, j, nodeString(got[j]), nodeString(want[j]))
+				}
+			}
+		}
+		var first *html.Node
+		if len(want) > 0 {
+			first = want[0]
+		}
+		if got := s.Query(doc, g); got != first {
+			t.Errorf("%s: Query returned the wrong node", sel)
+		}
+	}
+}
+
+func TestSessionCachesText(t *testing.T) {
+	doc := MustParseHTML(sessionHTML)
+	g := MustParseGroup(t, ":contains(apples), :contains(pears), :text-is(Plums)")
+
+	var cached Stats
+	s := NewSession()
+	s.QueryAll(doc, CollectStats(g, &cached))
+	if cached.TextBytes == 0 {
+		t.Error("no text was extracted")
+	}
+
+	before := cached.TextBytes
+	s.QueryAll(doc, CollectStats(g, &cached))
+	if cached.TextBytes != before {
+		t.Errorf("the second query extracted %d more bytes of text", cached.TextBytes-before)
+	}
+}
+
+func TestQueryAllCachesText(t *testing.T) {
+	doc := MustParseHTML(sessionHTML)
+	if c := newQueryContext(MustParseGroup(t, "p:contains(a)")); c.texts != nil {
+		t.Error("text cache created for a selector with one text pseudo-class")
+	}
+	if c := newQueryContext(MustParseGroup(t, "p:contains(a), li:not(:text-is(b))")); c.texts == nil {
+		t.Error("no text cache for a selector with two text pseudo-classes")
+	}
+	g := MustParseGroup(t, ":contains(apples), :containsOwn(apples), :contains-word(pears)")
+	want := queryInto(nil, doc, g, nil)
+	if got := QueryAll(doc, g); len(got) != len(want) {
+		t.Errorf("QueryAll found %d elements, want %d", len(got), len(want))
+	}
+}
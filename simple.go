@@ -0,0 +1,138 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A simpleQuery is a selector that is just a type, class or ID selector,
+// like "div", ".item" or "#main". Most real-world queries are like that,
+// so QueryAll and Query search for them with loops that test the nodes
+// directly, instead of calling the selector's Match method for each one.
+type simpleQuery struct {
+	kind  byte // 't' for a type selector, '.' for a class, or '#' for an ID
+	value string
+}
+
+// newSimpleQuery returns the simpleQuery for m, if m is a single type,
+// class or ID selector (not in quirks mode, and not empty).
+func newSimpleQuery(m Matcher) (simpleQuery, bool) {
+	if g, ok := m.(SelectorGroup); ok {
+		if len(g) != 1 {
+			return simpleQuery{}, false
+		}
+		m = g[0]
+	}
+	switch s := m.(type) {
+	case tagSelector:
+		if s.name == "" {
+			return simpleQuery{'t', s.tag}, true
+		}
+	case classSelector:
+		if !s.quirks && s.class != "" {
+			return simpleQuery{'.', s.class}, true
+		}
+	case idSelector:
+		if !s.quirks {
+			return simpleQuery{'#', s.id}, true
+		}
+	}
+	return simpleQuery{}, false
+}
+
+// match returns whether n matches q.
+func (q simpleQuery) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch q.kind {
+	case 't':
+		return n.Data == q.value
+	case '.':
+		return hasClass(n, q.value)
+	default:
+		return hasID(n, q.value)
+	}
+}
+
+func hasID(n *html.Node, id string) bool {
+	for _, a := range n.Attr {
+		if a.Key == "id" && a.Val == id {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClass returns whether one of n's class attributes contains class.
+func hasClass(n *html.Node, class string) bool {
+	for _, a := range n.Attr {
+		if a.Key != "class" {
+			continue
+		}
+		s := a.Val
+		for i := 0; i+len(class) <= len(s); {
+			if (i == 0 || isHTMLSpace(rune(s[i-1]))) && s[i:i+len(class)] == class &&
+				(i+len(class) == len(s) || isHTMLSpace(rune(s[i+len(class)]))) {
+				return true
+			}
+			// Skip to the next field.
+			for i < len(s) && !isHTMLSpace(rune(s[i])) {
+				i++
+			}
+			for i < len(s) && isHTMLSpace(rune(s[i])) {
+				i++
+			}
+		}
+	}
+	return false
+}
+
+// queryAll appends the descendants of root that match q to dst, in
+// document order, stopping once dst has limit nodes (unless limit is
+// negative).
+func (q simpleQuery) queryAll(root *html.Node, dst []*html.Node, limit int) []*html.Node {
+	if limit == 0 || root.FirstChild == nil {
+		return dst
+	}
+	start := len(dst)
+	switch q.kind {
+	case 't':
+		for n := root.FirstChild; n != nil; n = nextNode(n, root) {
+			if n.Type == html.ElementNode && n.Data == q.value {
+				dst = append(dst, n)
+				if len(dst)-start == limit {
+					break
+				}
+			}
+		}
+	case '.':
+		for n := root.FirstChild; n != nil; n = nextNode(n, root) {
+			if n.Type == html.ElementNode && hasClass(n, q.value) {
+				dst = append(dst, n)
+				if len(dst)-start == limit {
+					break
+				}
+			}
+		}
+	default:
+		for n := root.FirstChild; n != nil; n = nextNode(n, root) {
+			if n.Type == html.ElementNode && hasID(n, q.value) {
+				dst = append(dst, n)
+				if len(dst)-start == limit {
+					break
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// queryFirst returns the first descendant of root that matches q. For an
+// ID, which should be unique, this stops at the first element with it.
+func (q simpleQuery) queryFirst(root *html.Node) *html.Node {
+	var buf [1]*html.Node
+	if found := q.queryAll(root, buf[:0], 1); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
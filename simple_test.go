@@ -0,0 +1,70 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSimpleQuery(t *testing.T) {
+	doc := MustParseHTML(`<div id="main" class=" a  b	c">
+		<p class="ab a-b" id="x">one</p>
+		<p class="b">two</p>
+		<span id="main">three</span>
+		<p class="">four</p>
+	</div>`)
+	for _, sel := range []string{"p", "span", "div", "em", ".a", ".b", ".c", ".ab", ".a-b", "#main", "#x", "#y"} {
+		g := MustParseGroup(t, sel)
+		if _, ok := newSimpleQuery(g); !ok {
+			t.Errorf("%s: no fast path", sel)
+			continue
+		}
+		want := filterResults(nil, g, queryInto(nil, doc, g, nil))
+		if got := QueryAll(doc, g); !reflect.DeepEqual(got, want) {
+			t.Errorf("QueryAll(%s) = %d nodes, want %d", sel, len(got), len(want))
+		}
+		var first *html.Node
+		if len(want) > 0 {
+			first = want[0]
+		}
+		if got := Query(doc, g); got != first {
+			t.Errorf("Query(%s) didn't return the first node from QueryAll", sel)
+		}
+		if got := QueryAllN(doc, g, 1); len(want) > 0 && (len(got) != 1 || got[0] != want[0]) {
+			t.Errorf("QueryAllN(%s, 1) = %d nodes, want the first of QueryAll", sel, len(got))
+		}
+		compiled := MustCompile(sel)
+		for n := doc; n != nil; n = nextNode(n, doc) {
+			if compiled.Match(n) != g[0].Match(n) {
+				t.Errorf("Compile(%s).Match(%s) = %v, want %v", sel, nodeString(n), compiled.Match(n), g[0].Match(n))
+			}
+		}
+	}
+
+	for _, sel := range []string{"p.a", "div p", "p, span", ":first-child"} {
+		if _, ok := newSimpleQuery(MustParseGroup(t, sel)); ok {
+			t.Errorf("%s: unexpected fast path", sel)
+		}
+	}
+}
+
+func BenchmarkSimpleQuery(b *testing.B) {
+	doc := parseReference("test_resources/shakespeare.html")
+	for _, sel := range []string{"div", ".dialog", "#playwright"} {
+		s, err := ParseGroup(sel)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(sel, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				QueryAll(doc, s)
+			}
+		})
+		b.Run(sel+"/general", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				filterResults(nil, s, queryInto(nil, doc, s, nil))
+			}
+		})
+	}
+}
@@ -1,5 +1,14 @@
 package cascadia
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
 // Specificity is the CSS specificity as defined in
 // https://www.w3.org/TR/selectors/#specificity-rules
 // with the convention Specificity = [A,B,C].
@@ -7,15 +16,21 @@ type Specificity [3]int
 
 // returns `true` if s < other (strictly), false otherwise
 func (s Specificity) Less(other Specificity) bool {
+	return s.Compare(other) < 0
+}
+
+// Compare returns -1 if s is less than other, 0 if they are equal, and +1
+// if s is greater.
+func (s Specificity) Compare(other Specificity) int {
 	for i := range s {
 		if s[i] < other[i] {
-			return true
+			return -1
 		}
 		if s[i] > other[i] {
-			return false
+			return 1
 		}
 	}
-	return false
+	return 0
 }
 
 func (s Specificity) Add(other Specificity) Specificity {
@@ -24,3 +39,82 @@ func (s Specificity) Add(other Specificity) Specificity {
 	}
 	return s
 }
+
+// String formats s as "(A,B,C)", like "(1,0,2)".
+func (s Specificity) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", s[0], s[1], s[2])
+}
+
+// ParseSpecificity parses a specificity in the format produced by
+// Specificity.String, like "(1,0,2)". The parentheses are optional, and
+// whitespace is allowed around the numbers.
+func ParseSpecificity(s string) (Specificity, error) {
+	var spec Specificity
+	text := strings.TrimSpace(s)
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		text = text[1 : len(text)-1]
+	}
+	parts := strings.Split(text, ",")
+	if len(parts) != len(spec) {
+		return Specificity{}, fmt.Errorf("invalid specificity %q: expected 3 numbers", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 {
+			return Specificity{}, fmt.Errorf("invalid specificity %q: %q is not a non-negative integer", s, strings.TrimSpace(p))
+		}
+		spec[i] = n
+	}
+	return spec, nil
+}
+
+// MaxSpecificity returns the highest specificity of the selectors in s. This
+// is the specificity that s has as the argument of a pseudo-class like
+// :not().
+func (s SelectorGroup) MaxSpecificity() Specificity {
+	var max Specificity
+	for _, sel := range s {
+		if spec := sel.Specificity(); max.Less(spec) {
+			max = spec
+		}
+	}
+	return max
+}
+
+// Specificities returns the specificity of each selector in s.
+func (s SelectorGroup) Specificities() []Specificity {
+	specs := make([]Specificity, len(s))
+	for i, sel := range s {
+		specs[i] = sel.Specificity()
+	}
+	return specs
+}
+
+// MatchSpecificity returns the highest specificity of the selectors in s
+// that match n, which is the specificity that counts in the cascade when s
+// is the selector of a style rule. The boolean result reports whether any
+// of them matched.
+func (s SelectorGroup) MatchSpecificity(n *html.Node) (Specificity, bool) {
+	var max Specificity
+	matched := false
+	for _, sel := range s {
+		if !sel.Match(n) {
+			continue
+		}
+		if spec := sel.Specificity(); !matched || max.Less(spec) {
+			max = spec
+		}
+		matched = true
+	}
+	return max, matched
+}
+
+// SortBySpecificity sorts sels in increasing order of specificity. Selectors
+// with the same specificity keep their relative order, so that when sels
+// are in stylesheet order, the one that wins the cascade is the last one
+// that matches.
+func SortBySpecificity(sels []Sel) {
+	sort.SliceStable(sels, func(i, j int) bool {
+		return sels[i].Specificity().Less(sels[j].Specificity())
+	})
+}
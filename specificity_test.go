@@ -2,6 +2,7 @@ package cascadia
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -125,3 +126,102 @@ func TestCompareSpecificity(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestSpecificityString(t *testing.T) {
+	for _, test := range []struct {
+		spec Specificity
+		text string
+	}{
+		{Specificity{0, 0, 0}, "(0,0,0)"},
+		{Specificity{1, 0, 2}, "(1,0,2)"},
+		{Specificity{0, 12, 3}, "(0,12,3)"},
+	} {
+		if got := test.spec.String(); got != test.text {
+			t.Errorf("%v.String() = %s, want %s", [3]int(test.spec), got, test.text)
+		}
+		parsed, err := ParseSpecificity(test.text)
+		if err != nil || parsed != test.spec {
+			t.Errorf("ParseSpecificity(%q) = %v, %v; want %v", test.text, parsed, err, test.spec)
+		}
+	}
+
+	if s, err := ParseSpecificity(" ( 1, 2 ,3 ) "); err != nil || s != (Specificity{1, 2, 3}) {
+		t.Errorf("ParseSpecificity with spaces = %v, %v", s, err)
+	}
+	if s, err := ParseSpecificity("0,1,0"); err != nil || s != (Specificity{0, 1, 0}) {
+		t.Errorf("ParseSpecificity without parentheses = %v, %v", s, err)
+	}
+	for _, bad := range []string{"", "(1,2)", "(1,2,3,4)", "(a,b,c)", "(1,-1,0)", "102", "(1,2,3"} {
+		if _, err := ParseSpecificity(bad); err == nil {
+			t.Errorf("ParseSpecificity(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestSpecificityCompare(t *testing.T) {
+	for _, test := range []struct {
+		a, b Specificity
+		want int
+	}{
+		{Specificity{0, 0, 1}, Specificity{0, 0, 1}, 0},
+		{Specificity{0, 1, 0}, Specificity{0, 0, 9}, 1},
+		{Specificity{0, 9, 9}, Specificity{1, 0, 0}, -1},
+	} {
+		if got := test.a.Compare(test.b); got != test.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSortBySpecificity(t *testing.T) {
+	var sels []Sel
+	for _, s := range []string{"#a", "p", ".b", "div p", "*", ".c", "p.d"} {
+		sels = append(sels, MustParse(t, s))
+	}
+	SortBySpecificity(sels)
+	var got []string
+	for _, s := range sels {
+		got = append(got, s.String())
+	}
+	var want []string
+	for _, s := range []string{"*", "p", "div p", ".b", ".c", "p.d", "#a"} {
+		want = append(want, MustParse(t, s).String())
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGroupSpecificity(t *testing.T) {
+	g := MustParseGroup(t, "p, .a, div #b, li.c")
+	if got, want := g.MaxSpecificity(), (Specificity{1, 0, 1}); got != want {
+		t.Errorf("MaxSpecificity = %v, want %v", got, want)
+	}
+	want := []Specificity{{0, 0, 1}, {0, 1, 0}, {1, 0, 1}, {0, 1, 1}}
+	if got := g.Specificities(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Specificities = %v, want %v", got, want)
+	}
+	if got := (SelectorGroup{}).MaxSpecificity(); got != (Specificity{}) {
+		t.Errorf("MaxSpecificity of an empty group = %v", got)
+	}
+
+	doc, err := html.Parse(strings.NewReader(`<p class=a id=b>x</p><li class=c></li><span></span>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		sel     string
+		spec    Specificity
+		matched bool
+	}{
+		{"p", Specificity{0, 1, 0}, true},
+		{"li", Specificity{0, 1, 1}, true},
+		{"span", Specificity{}, false},
+	} {
+		n := Query(doc, MustParseGroup(t, test.sel))
+		spec, matched := g.MatchSpecificity(n)
+		if spec != test.spec || matched != test.matched {
+			t.Errorf("MatchSpecificity(%s) = %v, %v; want %v, %v", test.sel, spec, matched, test.spec, test.matched)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package cascadia
+
+import (
+	"sort"
+
+	"github.com/andybalholm/cascadia/ast"
+)
+
+// standardPseudoClasses maps the pseudo-classes from the CSS standards that
+// cascadia supports to the level of the Selectors specification that
+// introduced them.
+var standardPseudoClasses = map[string]int{
+	"link":             1,
+	"visited":          1,
+	"active":           1,
+	"first-child":      2,
+	"hover":            2,
+	"focus":            2,
+	"lang":             2,
+	"root":             3,
+	"nth-child":        3,
+	"nth-last-child":   3,
+	"nth-of-type":      3,
+	"nth-last-of-type": 3,
+	"last-child":       3,
+	"first-of-type":    3,
+	"last-of-type":     3,
+	"only-child":       3,
+	"only-of-type":     3,
+	"empty":            3,
+	"target":           3,
+	"enabled":          3,
+	"disabled":         3,
+	"checked":          3,
+	"not":              3,
+	"has":              4,
+	"scope":            4,
+}
+
+// Extensions returns the features used in m that are specific to cascadia
+// rather than part of a CSS standard, such as ":contains" or the "#="
+// attribute operator, sorted and without duplicates. Pseudo-classes are
+// listed with a colon, and attribute operators alone. Pseudo-classes
+// registered with RegisterPseudoClass count as extensions.
+//
+// m should be a Sel or SelectorGroup from this package; for other kinds of
+// Matcher, Extensions returns nil.
+func Extensions(m Matcher) []string {
+	_, ext := analyzeSyntax(m)
+	return ext
+}
+
+// IsStandard reports whether m uses only standard CSS selector syntax, so
+// that it will work in browsers and other selector engines. It returns
+// false for Matchers that aren't a Sel or SelectorGroup from this package.
+func IsStandard(m Matcher) bool {
+	level, ext := analyzeSyntax(m)
+	return level > 0 && len(ext) == 0
+}
+
+// CSSLevel returns the level of the CSS Selectors specification (1 to 4)
+// that introduced the newest standard feature used in m: for example, 2 for
+// "ul > li", 3 for "li:nth-child(2n)", and 4 for "div:has(p)". Extensions
+// don't affect the result. It returns 0 for Matchers that aren't a Sel or
+// SelectorGroup from this package.
+func CSSLevel(m Matcher) int {
+	level, _ := analyzeSyntax(m)
+	return level
+}
+
+// analyzeSyntax returns the CSS level and the extensions used by m.
+func analyzeSyntax(m Matcher) (level int, extensions []string) {
+	root := ToAST(m)
+	if root == nil {
+		return 0, nil
+	}
+	level = 1
+	need := func(l int) {
+		if l > level {
+			level = l
+		}
+	}
+	ext := make(map[string]bool)
+
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Combined:
+			switch {
+			case n.First == nil:
+				need(4) // relative selector
+			case n.Combinator == '>' || n.Combinator == '+':
+				need(2)
+			case n.Combinator == '~':
+				need(3)
+			}
+		case *ast.Compound:
+			if len(n.Parts) == 0 {
+				need(2) // the universal selector
+			}
+			switch n.PseudoElement {
+			case "", "first-line", "first-letter":
+			case "before", "after":
+				need(2)
+			default:
+				need(4)
+			}
+		case *ast.Attribute:
+			switch n.Operator {
+			case "", "=", "~=", "|=":
+				need(2)
+			case "^=", "$=", "*=":
+				need(3)
+			default:
+				ext[n.Operator] = true
+			}
+			if n.Insensitive {
+				need(4)
+			}
+		case *ast.PseudoClass:
+			l, ok := standardPseudoClasses[n.Name]
+			if !ok {
+				ext[":"+n.Name] = true
+				break
+			}
+			need(l)
+			if n.Name == "not" && !simpleNot(n) {
+				need(4)
+			}
+		}
+		return true
+	})
+
+	for e := range ext {
+		extensions = append(extensions, e)
+	}
+	sort.Strings(extensions)
+	return level, extensions
+}
+
+// simpleNot returns whether the argument of the :not() pseudo-class p is a
+// single simple selector, as Selectors Level 3 requires.
+func simpleNot(p *ast.PseudoClass) bool {
+	if p.Selectors == nil || len(p.Selectors.Selectors) != 1 {
+		return false
+	}
+	switch s := p.Selectors.Selectors[0].(type) {
+	case *ast.Compound:
+		return len(s.Parts) <= 1 && s.PseudoElement == ""
+	case *ast.Combined:
+		return false
+	}
+	return true
+}
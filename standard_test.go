@@ -0,0 +1,62 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStandardSyntax(t *testing.T) {
+	for _, test := range []struct {
+		sel        string
+		level      int
+		extensions []string
+	}{
+		{"div p", 1, nil},
+		{"a:visited", 1, nil},
+		{"ul > li, h1 + p", 2, nil},
+		{"*", 2, nil},
+		{"[lang|=en]", 2, nil},
+		{"p::before", 2, nil},
+		{"h1 ~ p", 3, nil},
+		{"li:nth-child(2n+1)", 3, nil},
+		{"a[href^=http]", 3, nil},
+		{"p:not(.a)", 3, nil},
+		{"p:not(.a.b)", 4, nil},
+		{"p:not(.a, .b)", 4, nil},
+		{"div:has(p)", 4, nil},
+		{"[type=a i]", 4, nil},
+		{"p::marker", 4, nil},
+		{"p:contains(x)", 1, []string{":contains"}},
+		{"li:first, div:haschild(p), [data-x#=(a)]", 1, []string{"#=", ":first", ":haschild"}},
+		{"input[size>=10]:not(:hidden)", 3, []string{":hidden", ">="}},
+		{"a:external-link", 1, []string{":external-link"}},
+	} {
+		if skipRegexp(test.sel) {
+			continue
+		}
+		g, err := ParseGroupWithPseudoElements(test.sel)
+		if err != nil {
+			t.Fatalf("error compiling %q: %s", test.sel, err)
+		}
+		if got := CSSLevel(g); got != test.level {
+			t.Errorf("CSSLevel(%s) = %d, want %d", test.sel, got, test.level)
+		}
+		if got := Extensions(g); !reflect.DeepEqual(got, test.extensions) {
+			t.Errorf("Extensions(%s) = %q, want %q", test.sel, got, test.extensions)
+		}
+		if got := IsStandard(g); got != (test.extensions == nil) {
+			t.Errorf("IsStandard(%s) = %v", test.sel, got)
+		}
+	}
+
+	relative, err := ParseGroupWithOptions("> p", ParseOptions{Relative: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := CSSLevel(relative); got != 4 {
+		t.Errorf("CSSLevel(> p) = %d, want 4", got)
+	}
+	if IsStandard(Trace(relative, nil)) {
+		t.Error("IsStandard returned true for a traced selector")
+	}
+}
@@ -0,0 +1,69 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// Stats counts the work done to match a selector, for comparing the cost of
+// alternative selectors. See CollectStats.
+type Stats struct {
+	// NodesVisited is the number of nodes tested against the whole
+	// selector.
+	NodesVisited int
+
+	// Tests is the number of times a node was tested against the selector
+	// or one of its parts.
+	Tests int
+
+	// CombinatorSteps is the number of ancestors, siblings, children and
+	// descendants that combinators and pseudo-classes like :has() moved to
+	// while matching.
+	CombinatorSteps int
+
+	// TextBytes is the total length of the text extracted or scanned for
+	// pseudo-classes like :contains() and :matches().
+	TextBytes int
+
+	// RegexpEvaluations is the number of times a regular expression was
+	// run, for :matches(), :tag-matches() and the #= attribute operator.
+	RegexpEvaluations int
+}
+
+// CollectStats returns a Matcher that matches the same nodes as m, but
+// adds the work done to match it to stats. Counting has a small cost, so
+// it is meant for comparing selectors, rather than for production use:
+//
+//	var stats cascadia.Stats
+//	nodes := cascadia.QueryAll(doc, cascadia.CollectStats(sel, &stats))
+//
+// The returned Matcher must not be used by more than one goroutine at a
+// time.
+func CollectStats(m Matcher, stats *Stats) Matcher {
+	return statsMatcher{m: m, stats: stats}
+}
+
+type statsMatcher struct {
+	m     Matcher
+	stats *Stats
+}
+
+func (s statsMatcher) Match(n *html.Node) bool {
+	return s.matchIn(nil, n)
+}
+
+func (s statsMatcher) matchIn(c *matchContext, n *html.Node) bool {
+	s.stats.NodesVisited++
+	c, m := s.unwrap(c)
+	return c.match(m, n)
+}
+
+// unwrap returns a copy of c that counts the work done in s's Stats, and the
+// Matcher s wraps.
+func (s statsMatcher) unwrap(c *matchContext) (*matchContext, Matcher) {
+	var sc matchContext
+	if c != nil {
+		sc = *c
+	}
+	sc.stats = s.stats
+	return &sc, s.m
+}
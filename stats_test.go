@@ -0,0 +1,68 @@
+package cascadia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestCollectStats(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<ul><li class=a>one</li><li>two</li></ul><p>three</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// html, head, body, ul, li, li, p, and the 3 text nodes.
+	const nodes = 10
+
+	// Tests counts both the SelectorGroup and the selector in it.
+	for _, test := range []struct {
+		sel   string
+		check func(s Stats) bool
+	}{
+		{"li", func(s Stats) bool {
+			return s.NodesVisited == nodes && s.Tests == 2*nodes && s.CombinatorSteps == 0 && s.TextBytes == 0 && s.RegexpEvaluations == 0
+		}},
+		{"ul > li", func(s Stats) bool {
+			return s.NodesVisited == nodes && s.CombinatorSteps == 2
+		}},
+		{"body li", func(s Stats) bool {
+			// Each li looks at ul and then body.
+			return s.CombinatorSteps == 4
+		}},
+		{"li:contains(o)", func(s Stats) bool {
+			// The search stops at the first match.
+			return s.TextBytes == len("o")+len("two")
+		}},
+		{"li:matches(^t)", func(s Stats) bool {
+			// The anchored expression stops reading each text once it fails
+			// or matches, so not all of it is read.
+			return s.RegexpEvaluations == 2 && s.TextBytes > 0 && s.TextBytes < len("one")+len("two")
+		}},
+		{"[class#=a]", func(s Stats) bool {
+			return s.RegexpEvaluations == 1
+		}},
+		{"ul:has(.a)", func(s Stats) bool {
+			// The li.a is the ul's first child.
+			return s.CombinatorSteps == 1
+		}},
+		{"body:has(p)", func(s Stats) bool {
+			// ul, li, "one", li, "two", p
+			return s.CombinatorSteps == 6
+		}},
+	} {
+		if skipRegexp(test.sel) {
+			continue
+		}
+		g := MustParseGroup(t, test.sel)
+		var stats Stats
+		got := QueryAll(doc, CollectStats(g, &stats))
+		if want := QueryAll(doc, g); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: query with stats found %d nodes, want %d", test.sel, len(got), len(want))
+		}
+		if !test.check(stats) {
+			t.Errorf("%s: unexpected stats %+v", test.sel, stats)
+		}
+	}
+}
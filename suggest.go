@@ -0,0 +1,159 @@
+package cascadia
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia/internal/cssesc"
+	"golang.org/x/net/html"
+)
+
+// A Suggestion is a possible completion for a partly typed selector,
+// returned by Suggest.
+type Suggestion struct {
+	// Kind is "tag", "class", "id" or "attribute".
+	Kind string
+
+	// Text is the completed name, escaped if necessary. It replaces the
+	// partial name at the end of the selector, sel[Start:].
+	Text  string
+	Start int
+
+	// Count is the number of elements that the completed selector would
+	// match, counting only elements that match the part of the selector
+	// before it.
+	Count int
+}
+
+// Suggest returns completions for the name being typed at the end of sel,
+// taken from the elements in doc. After a "." it suggests class names,
+// after a "#" IDs, after a "[" attribute names, and at the start of a
+// compound selector tag names.
+//
+// The candidates are limited to the elements that match the rest of the
+// selector: for "ul > li." it suggests the classes of li elements that
+// are children of ul elements. If the rest of the selector isn't valid yet
+// (for example, inside an unclosed :not()), all the elements in doc are
+// candidates.
+//
+// The suggestions are sorted with the ones that match the most elements
+// first. Suggest returns nil if sel doesn't end in one of these contexts.
+func Suggest(doc *html.Node, sel string) []Suggestion {
+	start := len(sel)
+	for start > 0 && nameChar(sel[start-1]) {
+		start--
+	}
+	partial := sel[start:]
+	if p := (parser{s: partial}); partial != "" {
+		if name, err := p.parseName(); err == nil && p.i == len(partial) {
+			partial = name
+		}
+	}
+
+	// before is the index of the character that introduced the name.
+	var kind string
+	before := start - 1
+	switch {
+	case before >= 0 && sel[before] == '.':
+		kind = "class"
+	case before >= 0 && sel[before] == '#':
+		kind = "id"
+	default:
+		before = len(strings.TrimRight(sel[:start], " \t\r\n\f")) - 1
+		switch {
+		case before >= 0 && sel[before] == '[':
+			kind = "attribute"
+		case start == 0 || strings.IndexByte(" \t\r\n\f>+~,(", sel[start-1]) != -1:
+			kind, before = "tag", start
+		default:
+			return nil
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, n := range suggestionCandidates(doc, sel[:before]) {
+		var names []string
+		if kind == "tag" {
+			names = []string{n.Data}
+		}
+		for _, a := range n.Attr {
+			switch {
+			case a.Namespace != "":
+			case kind == "attribute":
+				names = append(names, a.Key)
+			case kind == "class" && a.Key == "class":
+				names = strings.FieldsFunc(a.Val, isHTMLSpace)
+			case kind == "id" && a.Key == "id" && a.Val != "":
+				names = []string{a.Val}
+			}
+		}
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				counts[name]++
+			}
+		}
+	}
+
+	var suggestions []Suggestion
+	for name, count := range counts {
+		if kind == "tag" || kind == "attribute" {
+			if !strings.HasPrefix(toLowerASCII(name), toLowerASCII(partial)) {
+				continue
+			}
+		} else if !strings.HasPrefix(name, partial) {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{
+			Kind:  kind,
+			Text:  cssesc.Ident(name),
+			Start: start,
+			Count: count,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Text < b.Text
+	})
+	return suggestions
+}
+
+// suggestionCandidates returns the elements in doc that the selector being
+// typed could apply to, given the text before the simple selector being
+// completed.
+func suggestionCandidates(doc *html.Node, context string) []*html.Node {
+	// Only the last selector in a group matters.
+	depth, start := 0, 0
+	tokens, _ := Tokenize(context)
+	for _, t := range tokens {
+		switch t.Type {
+		case OpenParenToken:
+			depth++
+		case CloseParenToken:
+			depth--
+		case CommaToken:
+			if depth == 0 {
+				start = t.End
+			}
+		}
+	}
+	context = context[start:]
+
+	// If the simple selector starts a new compound selector, any element
+	// may be a candidate.
+	if trimmed := strings.TrimLeft(context, " \t\r\n\f"); trimmed == "" || strings.IndexByte(" \t\r\n\f>+~(", context[len(context)-1]) != -1 {
+		context += "*"
+	}
+
+	var m Matcher = MatcherFunc(func(n *html.Node) bool {
+		return n.Type == html.ElementNode
+	})
+	if g, err := ParseGroup(context); err == nil {
+		m = g
+	}
+	return QueryAll(doc, m)
+}
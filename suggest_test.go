@@ -0,0 +1,62 @@
+package cascadia
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const suggestPage = `<ul id="menu">
+<li class="item first">One</li>
+<li class="item">Two</li>
+<li class="item-x" data-x="1">Three</li>
+</ul>
+<p class="intro" id="main">Text <a href="/" title="home">home</a></p>
+<section id="items"><p class="item">other</p><b class="w:50">x</b></section>`
+
+func TestSuggest(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(suggestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sel  string
+		want string
+	}{
+		{"", "tag:li(3) p(2) a(1) b(1) body(1) head(1) html(1) section(1) ul(1)"},
+		{".it", "class:item(3) item-x(1)"},
+		{"ul > li.", "class:item(2) first(1) item-x(1)"},
+		{"li.item.f", "class:first(1)"},
+		{"section .", "class:item(1) w\\:50(1)"},
+		{"b.w", "class:w\\:50(1)"},
+		{"#", "id:items(1) main(1) menu(1)"},
+		{"ul#m", "id:menu(1)"},
+		{"a[", "attribute:href(1) title(1)"},
+		{"li[ d", "attribute:data-x(1)"},
+		{"ul > l", "tag:li(3)"},
+		{"div, ul > l", "tag:li(3)"},
+		{"p:not(.i", "class:item(3) intro(1) item-x(1)"},
+		{"p:hover", ""},
+		{"[href=", ""},
+	}
+	for _, test := range tests {
+		got := Suggest(doc, test.sel)
+		var parts []string
+		for _, s := range got {
+			if start := strings.LastIndexAny(test.sel, ".#[ >,(") + 1; s.Start != start {
+				t.Errorf("%q: suggestion %+v should start at %d", test.sel, s, start)
+			}
+			parts = append(parts, fmt.Sprintf("%s(%d)", s.Text, s.Count))
+		}
+		var summary string
+		if len(got) > 0 {
+			summary = got[0].Kind + ":" + strings.Join(parts, " ")
+		}
+		if summary != test.want {
+			t.Errorf("%q: got %s, want %s", test.sel, summary, test.want)
+		}
+	}
+}
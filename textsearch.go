@@ -0,0 +1,252 @@
+package cascadia
+
+import (
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// A lowerScanner looks for a lower-case string in text that is fed to it
+// in pieces, lowering the text a rune at a time as it goes, so that
+// :contains() doesn't need to build the text of an element and a
+// lower-case copy of it. It uses the bitap (shift-and) algorithm, so the
+// string must be no more than 64 runes long.
+type lowerScanner struct {
+	value string
+	ascii [utf8.RuneSelf]uint64 // the positions of each ASCII character in value
+	last  uint64                // the bit for the last rune of value
+	state uint64                // the bits for the prefixes of value that the text so far ends with
+	bytes int                   // the number of bytes of text scanned
+}
+
+// init prepares s to search for value, which must not be empty, and
+// reports whether value is short enough.
+func (s *lowerScanner) init(value string) bool {
+	s.value = value
+	i := 0
+	for _, r := range value {
+		if i == 64 {
+			return false
+		}
+		if r < utf8.RuneSelf {
+			s.ascii[r] |= 1 << i
+		}
+		i++
+	}
+	s.last = 1 << (i - 1)
+	return true
+}
+
+// mask returns the positions of r in the value.
+func (s *lowerScanner) mask(r rune) uint64 {
+	if r < utf8.RuneSelf {
+		return s.ascii[r]
+	}
+	var m uint64
+	i := 0
+	for _, v := range s.value {
+		if v == r {
+			m |= 1 << i
+		}
+		i++
+	}
+	return m
+}
+
+// scan feeds text to s, and reports whether the value has been found.
+func (s *lowerScanner) scan(text string) bool {
+	for i := 0; i < len(text); {
+		r, size := rune(text[i]), 1
+		if r < utf8.RuneSelf {
+			if 'A' <= r && r <= 'Z' {
+				r += 'a' - 'A'
+			}
+		} else {
+			r, size = utf8.DecodeRuneInString(text[i:])
+			r = unicode.ToLower(r)
+		}
+		i += size
+		s.state = (s.state<<1 | 1) & s.mask(r)
+		if s.state&s.last != 0 {
+			s.bytes += i
+			return true
+		}
+	}
+	s.bytes += len(text)
+	return false
+}
+
+// scanNode feeds the text in n and its descendants to s, stopping when the
+// value is found.
+func (s *lowerScanner) scanNode(n *html.Node) bool {
+	switch n.Type {
+	case html.TextNode:
+		return s.scan(n.Data)
+	case html.ElementNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if s.scanNode(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsLower returns whether the lower-case version of n's text (its
+// own text if own is true, or else the text returned by f) contains value,
+// without building the text if it can. The boolean result is false if it
+// can't tell, because value is too long.
+func (c *matchContext) containsLower(n *html.Node, own bool, f TextFunc, value string) (found, ok bool) {
+	if value == "" {
+		return true, true
+	}
+	var s lowerScanner
+	if !s.init(value) {
+		return false, false
+	}
+	switch {
+	case own:
+		for child := n.FirstChild; child != nil && !found; child = child.NextSibling {
+			if child.Type == html.TextNode {
+				found = s.scan(child.Data)
+			}
+		}
+	case f == nil:
+		found = s.scanNode(n)
+	default:
+		found = s.scan(f(n))
+	}
+	c.scanText(s.bytes)
+	return found, true
+}
+
+// A textReader reads the text of a node a rune at a time, one text node
+// after another, so that :matches() and :text-is() can stop as soon as
+// they know the answer, instead of building the whole text first. It
+// implements io.RuneReader, for regexp.MatchReader.
+type textReader struct {
+	w     walker
+	own   bool
+	child *html.Node // with own, the child whose text is being read
+	chunk string     // the rest of the current text node
+	bytes int        // the number of bytes of text read
+}
+
+// newTextReader returns a textReader for the text in n and its
+// descendants, or just in n's own text children if own is true.
+func newTextReader(n *html.Node, own bool) *textReader {
+	r := &textReader{own: own}
+	switch {
+	case own:
+		r.child = n.FirstChild
+	case n.Type == html.TextNode || n.Type == html.ElementNode:
+		r.w = walk(n, true)
+	}
+	return r
+}
+
+// nextText returns the next text node, or nil at the end.
+func (r *textReader) nextText() *html.Node {
+	if r.own {
+		for ; r.child != nil; r.child = r.child.NextSibling {
+			if r.child.Type == html.TextNode {
+				t := r.child
+				r.child = r.child.NextSibling
+				return t
+			}
+		}
+		return nil
+	}
+	if r.w.root == nil {
+		return nil
+	}
+	for n := r.w.next(); n != nil; n = r.w.next() {
+		if n.Type == html.TextNode {
+			return n
+		}
+	}
+	return nil
+}
+
+// next returns the next rune of the text, and the bytes it was decoded
+// from. At the end of the text, raw is empty.
+func (r *textReader) next() (ch rune, raw string) {
+	for r.chunk == "" {
+		t := r.nextText()
+		if t == nil {
+			return 0, ""
+		}
+		r.chunk = t.Data
+	}
+	ch, size := utf8.DecodeRuneInString(r.chunk)
+	raw, r.chunk = r.chunk[:size], r.chunk[size:]
+	r.bytes += size
+	return ch, raw
+}
+
+// ReadRune returns the next rune of the text, or io.EOF at the end.
+func (r *textReader) ReadRune() (ch rune, size int, err error) {
+	ch, raw := r.next()
+	if raw == "" {
+		return 0, 0, io.EOF
+	}
+	return ch, len(raw), nil
+}
+
+// textIs returns whether the text read from r, with whitespace collapsed
+// as by collapseWhitespace, is value. It stops reading at the first rune
+// that doesn't match.
+func textIs(r *textReader, value string) bool {
+	i := 0
+	started, space := false, false
+	for {
+		ch, raw := r.next()
+		if raw == "" {
+			return i == len(value)
+		}
+		if unicode.IsSpace(ch) {
+			space = started
+			continue
+		}
+		if space {
+			if i == len(value) || value[i] != ' ' {
+				return false
+			}
+			i++
+			space = false
+		}
+		started = true
+		if len(value)-i < len(raw) || value[i:i+len(raw)] != raw {
+			return false
+		}
+		i += len(raw)
+	}
+}
+
+// matchTextRegexp returns whether rx matches n's text (its own text if own
+// is true), reading the text nodes one at a time so that the search can
+// stop at the first match.
+func (c *matchContext) matchTextRegexp(rx pattern, n *html.Node, own bool) bool {
+	if c != nil && c.stats != nil {
+		c.stats.RegexpEvaluations++
+	}
+	r := newTextReader(n, own)
+	matched := rx.MatchReader(r)
+	c.scanText(r.bytes)
+	return matched
+}
+
+// textMatches returns whether the collapsed text of n, as returned by f,
+// is value, for :text-is(). With the default f and no text cache, the text
+// is read a rune at a time, and only as far as it matches value.
+func (c *matchContext) textMatches(n *html.Node, f TextFunc, value string) bool {
+	if f != nil || (c != nil && c.texts != nil) {
+		return collapseWhitespace(c.text(n, false, f)) == value
+	}
+	r := newTextReader(n, false)
+	matched := textIs(r, value)
+	c.scanText(r.bytes)
+	return matched
+}
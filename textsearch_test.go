@@ -0,0 +1,116 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestContainsLower(t *testing.T) {
+	doc := MustParseHTML(`<p id="a">Apples and <b>PEARS</b>, aaab</p><p id="b">ÉCOLE <i>Straße</i></p>`)
+	a := Query(doc, MustCompile("#a"))
+	b := Query(doc, MustCompile("#b"))
+	for _, value := range []string{
+		"apples", "and pears", "s, a", "aab", "aaab", "aaaab", "pears,", "x",
+		"école", "e s", "straße", "strasse", strings.Repeat("a", 65),
+	} {
+		value = strings.ToLower(value)
+		for _, n := range []*html.Node{a, b} {
+			for _, own := range []bool{false, true} {
+				var text string
+				if own {
+					text = nodeOwnText(n)
+				} else {
+					text = nodeText(n)
+				}
+				want := strings.Contains(strings.ToLower(text), value)
+				got, ok := (*matchContext)(nil).containsLower(n, own, nil, value)
+				if len(value) > 64 {
+					if ok {
+						t.Errorf("containsLower accepted a value of %d runes", len(value))
+					}
+					continue
+				}
+				if !ok || got != want {
+					t.Errorf("containsLower(%q, own=%v, %q) = %v, %v; want %v", text, own, value, got, ok, want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	var page strings.Builder
+	page.WriteString("<body>")
+	for i := 0; i < 200; i++ {
+		page.WriteString("<div><p>Some <b>Text</b> in a paragraph</p><p>More TEXT here</p></div>")
+	}
+	doc := MustParseHTML(page.String())
+	s, err := ParseGroup(`p:contains("text here")`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		QueryAll(doc, s)
+	}
+}
+
+func TestTextReader(t *testing.T) {
+	doc := MustParseHTML(`<div id="a">  Hello <b>big
+		wide</b>  world <!-- x --><i></i>Ω </div><p id="b">one</p>`)
+	a := Query(doc, MustCompile("#a"))
+	for _, own := range []bool{false, true} {
+		want := nodeText(a)
+		if own {
+			want = nodeOwnText(a)
+		}
+		var got strings.Builder
+		r := newTextReader(a, own)
+		for {
+			ch, _, err := r.ReadRune()
+			if err != nil {
+				break
+			}
+			got.WriteRune(ch)
+		}
+		if got.String() != want || r.bytes != len(want) {
+			t.Errorf("textReader(own=%v) read %q (%d bytes), want %q", own, got.String(), r.bytes, want)
+		}
+
+		for _, value := range []string{
+			collapseWhitespace(want), "Hello big wide world Ω", "Hello big wide world", "Hello big wide world Ω x",
+			"Hello", "Hello world Ω", "", " Hello big wide world Ω",
+		} {
+			if got, want := textIs(newTextReader(a, own), value), collapseWhitespace(want) == value; got != want {
+				t.Errorf("textIs(own=%v, %q) = %v, want %v", own, value, got, want)
+			}
+		}
+	}
+
+	// The comparison stops at the first difference.
+	var stats Stats
+	b := MustParseHTML("<p>abc" + strings.Repeat("x", 1000) + "</p>")
+	QueryAll(b, CollectStats(MustParseGroup(t, `p:text-is("abd")`), &stats))
+	if stats.TextBytes != 3 {
+		t.Errorf(":text-is() read %d bytes, want 3", stats.TextBytes)
+	}
+}
+
+func BenchmarkTextIs(b *testing.B) {
+	var page strings.Builder
+	page.WriteString("<body>")
+	for i := 0; i < 200; i++ {
+		page.WriteString("<div><p>Some <b>Text</b> in a paragraph</p><p>More TEXT here</p></div>")
+	}
+	doc := MustParseHTML(page.String())
+	s, err := ParseGroup(`p:text-is("More TEXT here")`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		QueryAll(doc, s)
+	}
+}
@@ -0,0 +1,161 @@
+package cascadia
+
+import (
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+// A TokenSelector is a selector that can be matched against the tokens
+// produced by an html.Tokenizer, without building a tree of html.Nodes. This
+// is useful for programs that rewrite HTML as a stream of tokens, like
+// proxies.
+//
+// Only selectors that can be evaluated with the information available in a
+// token stream are supported: tag, ID, class and attribute selectors,
+// compound selectors made from them, and the descendant and child
+// combinators.
+type TokenSelector struct {
+	m Matcher
+}
+
+// CompileToken parses a selector (or group of selectors) for matching
+// against tokens.
+func CompileToken(sel string) (TokenSelector, error) {
+	g, err := ParseGroup(sel)
+	if err != nil {
+		return TokenSelector{}, err
+	}
+	return NewTokenSelector(g)
+}
+
+// MustCompileToken is like CompileToken, but panics if the selector cannot
+// be parsed or matched against tokens.
+func MustCompileToken(sel string) TokenSelector {
+	s, err := CompileToken(sel)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewTokenSelector returns a TokenSelector for m, which should be a Sel or a
+// SelectorGroup. It returns an error if m uses something that can't be
+// matched against tokens, like a sibling combinator or a pseudo-class.
+func NewTokenSelector(m Matcher) (TokenSelector, error) {
+	if b, ok := m.(Builder); ok {
+		m = b.Sel()
+	}
+	if err := checkTokenSelector(m); err != nil {
+		return TokenSelector{}, err
+	}
+	return TokenSelector{m: m}, nil
+}
+
+func checkTokenSelector(m Matcher) error {
+	switch s := m.(type) {
+	case SelectorGroup:
+		for _, sel := range s {
+			if err := checkTokenSelector(sel); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tagSelector, classSelector, idSelector, attrSelector, neverMatchSelector:
+		return nil
+	case compoundSelector:
+		if s.pseudoElement != "" {
+			return fmt.Errorf("can't match %s against a token: pseudo-elements are not supported", s)
+		}
+		for _, sel := range s.selectors {
+			if err := checkTokenSelector(sel); err != nil {
+				return err
+			}
+		}
+		return nil
+	case combinedSelector:
+		switch s.combinator {
+		case 0, ' ', '>':
+		default:
+			return fmt.Errorf("can't match %s against a token: the %q combinator is not supported", s, s.combinator)
+		}
+		if err := checkTokenSelector(s.first); err != nil {
+			return err
+		}
+		if s.second != nil {
+			return checkTokenSelector(s.second)
+		}
+		return nil
+	}
+	return fmt.Errorf("can't match %v against a token", m)
+}
+
+// Match returns whether the selector matches the element started by tok.
+// Ancestors holds the start tags of the elements that are open when tok is
+// read, outermost first, so that the last one is the parent of tok. Tokens
+// that don't start an element never match.
+func (s TokenSelector) Match(tok html.Token, ancestors []html.Token) bool {
+	if tok.Type != html.StartTagToken && tok.Type != html.SelfClosingTagToken {
+		return false
+	}
+	return matchToken(s.m, tok, ancestors)
+}
+
+// String returns the text of the selector.
+func (s TokenSelector) String() string {
+	if st, ok := s.m.(fmt.Stringer); ok {
+		return st.String()
+	}
+	return ""
+}
+
+func matchToken(m Matcher, tok html.Token, ancestors []html.Token) bool {
+	switch s := m.(type) {
+	case SelectorGroup:
+		for _, sel := range s {
+			if matchToken(sel, tok, ancestors) {
+				return true
+			}
+		}
+		return false
+	case compoundSelector:
+		for _, sel := range s.selectors {
+			if !matchToken(sel, tok, ancestors) {
+				return false
+			}
+		}
+		return true
+	case combinedSelector:
+		if s.second == nil {
+			return matchToken(s.first, tok, ancestors)
+		}
+		if !matchToken(s.second, tok, ancestors) {
+			return false
+		}
+		switch s.combinator {
+		case '>':
+			if len(ancestors) == 0 {
+				return false
+			}
+			last := len(ancestors) - 1
+			return matchToken(s.first, ancestors[last], ancestors[:last])
+		default:
+			for i := len(ancestors) - 1; i >= 0; i-- {
+				if matchToken(s.first, ancestors[i], ancestors[:i]) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	// The remaining selectors only look at the element itself, so they can
+	// be tested on a detached node made from the token.
+	n := html.Node{
+		Type:     html.ElementNode,
+		Data:     tok.Data,
+		DataAtom: tok.DataAtom,
+		Attr:     tok.Attr,
+	}
+	return m.Match(&n)
+}
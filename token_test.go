@@ -0,0 +1,98 @@
+package cascadia
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// tokenMatches tokenizes doc, and returns the start tags that s matches,
+// rendered as strings.
+func tokenMatches(t *testing.T, s TokenSelector, doc string) []string {
+	z := html.NewTokenizer(strings.NewReader(doc))
+	var (
+		stack   []html.Token
+		matches []string
+	)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				t.Fatal(z.Err())
+			}
+			return matches
+		case html.StartTagToken:
+			tok := z.Token()
+			if s.Match(tok, stack) {
+				matches = append(matches, tok.String())
+			}
+			stack = append(stack, tok)
+		case html.SelfClosingTagToken:
+			tok := z.Token()
+			if s.Match(tok, stack) {
+				matches = append(matches, tok.String())
+			}
+		case html.EndTagToken:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+func TestTokenSelector(t *testing.T) {
+	const doc = `<html><body><div id="main" class="a b"><p class="x">one</p><section><p lang="en-US">two</p></section></div><p>three</p><br/></body></html>`
+	tests := []struct {
+		sel  string
+		want []string
+	}{
+		{"p", []string{`<p class="x">`, `<p lang="en-US">`, `<p>`}},
+		{"#main p", []string{`<p class="x">`, `<p lang="en-US">`}},
+		{"div.b > p", []string{`<p class="x">`}},
+		{"body > p, [lang|=en]", []string{`<p lang="en-US">`, `<p>`}},
+		{"section p.x", nil},
+		{"br", []string{`<br/>`}},
+		{"html div section > p[lang]", []string{`<p lang="en-US">`}},
+	}
+	for _, test := range tests {
+		s, err := CompileToken(test.sel)
+		if err != nil {
+			t.Errorf("CompileToken(%q): %v", test.sel, err)
+			continue
+		}
+		got := tokenMatches(t, s, doc)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%s: got %q, want %q", test.sel, got, test.want)
+		}
+	}
+}
+
+func TestTokenSelectorUnsupported(t *testing.T) {
+	for _, sel := range []string{
+		"p + p",
+		"li ~ li",
+		"p:first-child",
+		"div:not(.x)",
+		"div:has(p)",
+		"p:contains(one)",
+	} {
+		if _, err := CompileToken(sel); err == nil {
+			t.Errorf("CompileToken(%q) succeeded, want an error", sel)
+		}
+	}
+}
+
+func TestTokenSelectorNonElement(t *testing.T) {
+	s := MustCompileToken("*")
+	if s.Match(html.Token{Type: html.TextToken, Data: "p"}, nil) {
+		t.Error("* matched a text token")
+	}
+	if s.Match(html.Token{Type: html.EndTagToken, Data: "p"}, nil) {
+		t.Error("* matched an end tag")
+	}
+	if !s.Match(html.Token{Type: html.StartTagToken, Data: "p"}, nil) {
+		t.Error("* did not match a start tag")
+	}
+}
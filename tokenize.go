@@ -0,0 +1,387 @@
+package cascadia
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A SyntaxTokenType identifies the kind of a SyntaxToken.
+type SyntaxTokenType int
+
+const (
+	InvalidToken       SyntaxTokenType = iota // text that isn't part of any valid token
+	WhitespaceToken                           // spaces, tabs and newlines
+	CommentToken                              // /* a comment */
+	TypeToken                                 // a type selector, like p, or the universal selector, *
+	IDToken                                   // #main
+	ClassToken                                // .item
+	AttrOpenToken                             // the [ at the start of an attribute selector
+	AttrNameToken                             // the name in an attribute selector
+	AttrOperatorToken                         // the operator in an attribute selector, like ^=
+	AttrValueToken                            // an unquoted value in an attribute selector
+	AttrFlagToken                             // the i or s flag at the end of an attribute selector
+	AttrCloseToken                            // the ] at the end of an attribute selector
+	StringToken                               // a quoted string
+	PseudoClassToken                          // :hover, or the name of a functional pseudo-class, like :not
+	PseudoElementToken                        // ::before
+	OpenParenToken                            // the ( after the name of a functional pseudo-class
+	CloseParenToken                           // the ) at the end of a functional pseudo-class
+	ArgumentToken                             // the argument of a pseudo-class, like 2n+1, when it isn't a selector or a string
+	CombinatorToken                           // >, + or ~ (a descendant combinator is whitespace)
+	CommaToken                                // the comma between selectors in a group
+)
+
+var syntaxTokenTypeNames = [...]string{
+	InvalidToken:       "Invalid",
+	WhitespaceToken:    "Whitespace",
+	CommentToken:       "Comment",
+	TypeToken:          "Type",
+	IDToken:            "ID",
+	ClassToken:         "Class",
+	AttrOpenToken:      "AttrOpen",
+	AttrNameToken:      "AttrName",
+	AttrOperatorToken:  "AttrOperator",
+	AttrValueToken:     "AttrValue",
+	AttrFlagToken:      "AttrFlag",
+	AttrCloseToken:     "AttrClose",
+	StringToken:        "String",
+	PseudoClassToken:   "PseudoClass",
+	PseudoElementToken: "PseudoElement",
+	OpenParenToken:     "OpenParen",
+	CloseParenToken:    "CloseParen",
+	ArgumentToken:      "Argument",
+	CombinatorToken:    "Combinator",
+	CommaToken:         "Comma",
+}
+
+func (t SyntaxTokenType) String() string {
+	if t < 0 || int(t) >= len(syntaxTokenTypeNames) {
+		return "SyntaxTokenType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return syntaxTokenTypeNames[t]
+}
+
+// A SyntaxToken is a piece of a selector's source text, as returned by
+// Tokenize.
+type SyntaxToken struct {
+	Type SyntaxTokenType
+
+	// Text is the source text of the token, including any escapes and
+	// quotes. It is sel[Start:End].
+	Text string
+
+	// Start and End are the byte offsets of the token in the selector.
+	Start, End int
+}
+
+// Tokenize splits sel into tokens, for syntax highlighting. The tokens cover
+// the whole input, in order, without gaps; text that can't be tokenized is
+// returned as InvalidToken.
+//
+// Tokenize also checks sel with the parser (allowing pseudo-elements). If it
+// is not valid, the tokens are still returned, along with a ParseErrors
+// value giving the position of each error.
+func Tokenize(sel string) ([]SyntaxToken, error) {
+	l := &lexer{p: parser{s: sel}}
+	l.group(false)
+	if l.p.i < len(sel) {
+		l.emit(InvalidToken, len(sel))
+	}
+
+	check := &parser{s: sel, opts: ParseOptions{PseudoElements: true}}
+	if _, errs := check.parseSelectorGroupRecover(); len(errs) > 0 {
+		return l.tokens, errs
+	}
+	return l.tokens, nil
+}
+
+// A lexer splits a selector into SyntaxTokens, using a parser's methods to
+// find the ends of names and strings.
+type lexer struct {
+	p      parser
+	tokens []SyntaxToken
+}
+
+// emit adds a token running from the current position to end, and moves the
+// position to end.
+func (l *lexer) emit(t SyntaxTokenType, end int) {
+	if end > l.p.i {
+		l.tokens = append(l.tokens, SyntaxToken{Type: t, Text: l.p.s[l.p.i:end], Start: l.p.i, End: end})
+	}
+	l.p.i = end
+}
+
+// fail marks the rest of the input as invalid.
+func (l *lexer) fail() {
+	l.emit(InvalidToken, len(l.p.s))
+}
+
+// try runs one of the parser's methods, and returns where it stopped, and
+// whether it succeeded. The lexer's position is not changed.
+func (l *lexer) try(f func(p *parser) error) (end int, ok bool) {
+	p := l.p
+	if err := f(&p); err != nil {
+		return 0, false
+	}
+	return p.i, true
+}
+
+// whitespace emits any whitespace and comments at the current position.
+func (l *lexer) whitespace() {
+	s := l.p.s
+	for l.p.i < len(s) {
+		i := l.p.i
+		switch {
+		case strings.ContainsRune(" \t\r\n\f", rune(s[i])):
+			end := i
+			for end < len(s) && strings.ContainsRune(" \t\r\n\f", rune(s[end])) {
+				end++
+			}
+			l.emit(WhitespaceToken, end)
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end == -1 {
+				return
+			}
+			l.emit(CommentToken, i+2+end+2)
+		default:
+			return
+		}
+	}
+}
+
+// group emits the tokens for a list of selectors. If inParens is true, it
+// stops at an unmatched closing parenthesis.
+func (l *lexer) group(inParens bool) {
+	s := l.p.s
+	for l.p.i < len(s) {
+		i := l.p.i
+		c := s[i]
+		switch {
+		case c == ')' && inParens:
+			return
+		case strings.ContainsRune(" \t\r\n\f", rune(c)) || strings.HasPrefix(s[i:], "/*"):
+			l.whitespace()
+			if l.p.i == i {
+				l.fail()
+			}
+		case c == ',':
+			l.emit(CommaToken, i+1)
+		case c == '>' || c == '+' || c == '~':
+			l.emit(CombinatorToken, i+1)
+		case c == '*':
+			end := i + 1
+			if strings.HasPrefix(s[end:], "|*") {
+				end += 2
+			}
+			l.emit(TypeToken, end)
+		case c == '#':
+			end, ok := l.try(func(p *parser) error {
+				p.i++
+				_, err := p.parseName()
+				return err
+			})
+			l.emitOrFail(IDToken, end, ok)
+		case c == '.':
+			end, ok := l.try(func(p *parser) error {
+				p.i++
+				_, err := p.parseIdentifier()
+				return err
+			})
+			l.emitOrFail(ClassToken, end, ok)
+		case c == '[':
+			l.attribute()
+		case c == ':':
+			l.pseudo()
+		default:
+			end, ok := l.try(func(p *parser) error {
+				_, err := p.parseIdentifier()
+				return err
+			})
+			if !ok {
+				// Skip one character, and carry on.
+				end = i + 1
+				for end < len(s) && s[end] >= 0x80 && s[end] < 0xc0 {
+					end++
+				}
+				l.emit(InvalidToken, end)
+				continue
+			}
+			l.emit(TypeToken, end)
+		}
+		if l.p.i == i {
+			// No progress was made.
+			l.fail()
+		}
+	}
+}
+
+func (l *lexer) emitOrFail(t SyntaxTokenType, end int, ok bool) {
+	if ok {
+		l.emit(t, end)
+	} else {
+		l.fail()
+	}
+}
+
+func (l *lexer) attribute() {
+	s := l.p.s
+	l.emit(AttrOpenToken, l.p.i+1)
+	l.whitespace()
+	end, ok := l.try(func(p *parser) error {
+		_, err := p.parseIdentifier()
+		return err
+	})
+	if !ok {
+		l.fail()
+		return
+	}
+	l.emit(AttrNameToken, end)
+	l.whitespace()
+
+	if l.p.i < len(s) && s[l.p.i] != ']' {
+		i := l.p.i
+		var op string
+		switch {
+		case strings.HasPrefix(s[i:], "="):
+			op = "="
+		case i+1 < len(s) && s[i+1] == '=':
+			op = s[i : i+2]
+		case s[i] == '<' || s[i] == '>':
+			op = s[i : i+1]
+		default:
+			l.fail()
+			return
+		}
+		l.emit(AttrOperatorToken, i+len(op))
+		l.whitespace()
+
+		if l.p.i >= len(s) {
+			return
+		}
+		switch {
+		case op == "#=":
+			l.emit(ArgumentToken, l.regexpEnd())
+		case s[l.p.i] == '"' || s[l.p.i] == '\'':
+			end, ok := l.try(func(p *parser) error {
+				_, err := p.parseString()
+				return err
+			})
+			if !ok {
+				l.fail()
+				return
+			}
+			l.emit(StringToken, end)
+		default:
+			end := l.p.i
+			for end < len(s) && !strings.ContainsRune(" \t\r\n\f]", rune(s[end])) {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(s) {
+				end = len(s)
+			}
+			l.emit(AttrValueToken, end)
+		}
+		l.whitespace()
+
+		if l.p.i < len(s) && s[l.p.i] != ']' {
+			end, ok := l.try(func(p *parser) error {
+				_, err := p.parseIdentifier()
+				return err
+			})
+			if ok {
+				l.emit(AttrFlagToken, end)
+				l.whitespace()
+			}
+		}
+	}
+
+	if l.p.i < len(s) && s[l.p.i] == ']' {
+		l.emit(AttrCloseToken, l.p.i+1)
+	}
+}
+
+// regexpEnd returns the end of a regular expression starting at the current
+// position: the first unmatched ')' or ']', as in parseRegex.
+func (l *lexer) regexpEnd() int {
+	s := l.p.s
+	open := 0
+	for i := l.p.i; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[':
+			open++
+		case ')', ']':
+			open--
+			if open < 0 {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
+
+func (l *lexer) pseudo() {
+	s := l.p.s
+	start := l.p.i
+	t := PseudoClassToken
+	i := start + 1
+	if i < len(s) && s[i] == ':' {
+		t = PseudoElementToken
+		i++
+	}
+	end, ok := l.try(func(p *parser) error {
+		p.i = i
+		_, err := p.parseIdentifier()
+		return err
+	})
+	if !ok {
+		l.fail()
+		return
+	}
+	l.emit(t, end)
+	name := toLowerASCII(strings.TrimLeft(s[start:end], ":"))
+
+	if l.p.i >= len(s) || s[l.p.i] != '(' {
+		return
+	}
+	l.emit(OpenParenToken, l.p.i+1)
+	l.whitespace()
+
+	switch {
+	case name == "not" || name == "has" || name == "haschild":
+		l.group(true)
+	case name == "matches" || name == "matchesown" || name == "tag-matches":
+		l.emit(ArgumentToken, l.regexpEnd())
+	case l.p.i < len(s) && (s[l.p.i] == '"' || s[l.p.i] == '\''):
+		end, ok := l.try(func(p *parser) error {
+			_, err := p.parseString()
+			return err
+		})
+		if !ok {
+			l.fail()
+			return
+		}
+		l.emit(StringToken, end)
+	default:
+		p := l.p
+		if _, err := p.parseRawArgument(); err != nil {
+			l.fail()
+			return
+		}
+		// p.i is after the closing parenthesis. Leave out any whitespace
+		// before it.
+		end := p.i - 1
+		for end > l.p.i && strings.ContainsRune(" \t\r\n\f", rune(s[end-1])) {
+			end--
+		}
+		l.emit(ArgumentToken, end)
+	}
+	l.whitespace()
+
+	if l.p.i < len(s) && s[l.p.i] == ')' {
+		l.emit(CloseParenToken, l.p.i+1)
+	}
+}
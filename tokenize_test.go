@@ -0,0 +1,84 @@
+package cascadia
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// tokenSummary formats tokens as "Type:text" pairs, separated by spaces.
+func tokenSummary(tokens []SyntaxToken) string {
+	parts := make([]string, len(tokens))
+	for i, t := range tokens {
+		parts[i] = t.Type.String() + ":" + t.Text
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		sel, tokens string
+	}{
+		{`div.a>p#x`, `Type:div Class:.a Combinator:> Type:p ID:#x`},
+		{`[href^="/" i]`, `AttrOpen:[ AttrName:href AttrOperator:^= String:"/" Whitespace:  AttrFlag:i AttrClose:]`},
+		{`[n>=3]`, `AttrOpen:[ AttrName:n AttrOperator:>= AttrValue:3 AttrClose:]`},
+		{`li:nth-child(2n+1),*`, `Type:li PseudoClass::nth-child OpenParen:( Argument:2n+1 CloseParen:) Comma:, Type:*`},
+		{`a:not(.b,[c])`, `Type:a PseudoClass::not OpenParen:( Class:.b Comma:, AttrOpen:[ AttrName:c AttrClose:] CloseParen:)`},
+		{`a /*x*/ b::before`, `Type:a Whitespace:  Comment:/*x*/ Whitespace:  Type:b PseudoElement:::before`},
+		{`[href#=(^h(t)tp)]`, `AttrOpen:[ AttrName:href AttrOperator:#= Argument:(^h(t)tp) AttrClose:]`},
+		{`p:contains('x')`, `Type:p PseudoClass::contains OpenParen:( String:'x' CloseParen:)`},
+		{`p\.q`, `Type:p\.q`},
+	}
+	for _, test := range tests {
+		if skipRegexp(test.sel) {
+			continue
+		}
+		tokens, err := Tokenize(test.sel)
+		if err != nil {
+			t.Errorf("%s: %v", test.sel, err)
+		}
+		if got := tokenSummary(tokens); got != test.tokens {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.sel, got, test.tokens)
+		}
+		checkTokenOffsets(t, test.sel, tokens)
+	}
+}
+
+// checkTokenOffsets checks that tokens cover sel without gaps.
+func checkTokenOffsets(t *testing.T, sel string, tokens []SyntaxToken) {
+	t.Helper()
+	pos := 0
+	for _, tok := range tokens {
+		if tok.Start != pos || tok.End <= tok.Start || sel[tok.Start:tok.End] != tok.Text {
+			t.Errorf("%s: bad offsets for token %+v", sel, tok)
+			return
+		}
+		pos = tok.End
+	}
+	if pos != len(sel) {
+		t.Errorf("%s: tokens end at %d, not %d", sel, pos, len(sel))
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		sel    string
+		offset int
+	}{
+		{"p[", 2},
+		{"p $ q", 2},
+		{"a, b:nope", 9},
+	}
+	for _, test := range tests {
+		tokens, err := Tokenize(test.sel)
+		var errs ParseErrors
+		if !errors.As(err, &errs) || len(errs) == 0 {
+			t.Errorf("%s: got error %v, want ParseErrors", test.sel, err)
+			continue
+		}
+		if errs[0].Offset != test.offset {
+			t.Errorf("%s: error at offset %d, want %d", test.sel, errs[0].Offset, test.offset)
+		}
+		checkTokenOffsets(t, test.sel, tokens)
+	}
+}
@@ -0,0 +1,52 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A TraceFunc is called while matching a selector wrapped with Trace. It
+// receives the node that was tested, the part of the selector it was tested
+// against, and the result. The Matcher is usually a Sel, whose String method
+// shows which part of the selector it is.
+type TraceFunc func(n *html.Node, m Matcher, matched bool)
+
+// Trace returns a Matcher that matches the same nodes as m, but calls trace
+// for every node that is tested against m or one of its parts, such as the
+// simple selectors in a compound selector, or the selectors on each side of
+// a combinator. Calls for the parts of a selector come before the call for
+// the whole selector. It is meant for logging or visualizing how a selector
+// walks the tree, when debugging selectors that are slow or give
+// unexpected results:
+//
+//	traced := cascadia.Trace(sel, func(n *html.Node, m cascadia.Matcher, matched bool) {
+//		log.Printf("%v %s: %v", m, n.Data, matched)
+//	})
+//	nodes := cascadia.QueryAll(doc, traced)
+func Trace(m Matcher, trace TraceFunc) Matcher {
+	return tracedMatcher{m: m, trace: trace}
+}
+
+type tracedMatcher struct {
+	m     Matcher
+	trace TraceFunc
+}
+
+func (t tracedMatcher) Match(n *html.Node) bool {
+	return t.matchIn(nil, n)
+}
+
+func (t tracedMatcher) matchIn(c *matchContext, n *html.Node) bool {
+	c, m := t.unwrap(c)
+	return c.match(m, n)
+}
+
+// unwrap returns a copy of c that calls t's TraceFunc, and the Matcher t
+// wraps.
+func (t tracedMatcher) unwrap(c *matchContext) (*matchContext, Matcher) {
+	var tc matchContext
+	if c != nil {
+		tc = *c
+	}
+	tc.trace = t.trace
+	return &tc, t.m
+}
@@ -0,0 +1,55 @@
+package cascadia
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestTrace(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<ul><li class=a>1</li><li>2</li></ul><p class=a>3</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log []string
+	trace := func(n *html.Node, m Matcher, matched bool) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		log = append(log, fmt.Sprintf("%s %v %v", n.Data, m, matched))
+	}
+	li := Query(doc, MustParseGroup(t, "li"))
+	sel := MustParse(t, "ul > li.a")
+	if !Trace(sel, trace).Match(li) {
+		t.Fatal("traced selector didn't match")
+	}
+	want := []string{
+		"li li true",
+		"li .a true",
+		"li li.a true",
+		"ul ul true",
+		fmt.Sprintf("li %v true", sel),
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("got trace\n%s\nwant\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, s := range []string{"ul > li.a", ".a", "li:first", "li:last, p", ":not(p) > li"} {
+		g := MustParseGroup(t, s)
+		log = nil
+		got := QueryAll(doc, Trace(g, trace))
+		if want := QueryAll(doc, g); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: traced query found %d nodes, want %d", s, len(got), len(want))
+		}
+		if len(log) == 0 {
+			t.Errorf("%s: trace function wasn't called", s)
+		}
+		if got, want := Query(doc, Trace(g, trace)), Query(doc, g); got != want {
+			t.Errorf("%s: traced Query returned a different node", s)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A Transformer applies functions to the elements that selectors match, in
+// the style of templating libraries like Enlive: each rule pairs a selector
+// with a function that changes the nodes it matches, and all the rules run
+// in a single walk of the document.
+//
+//	var t cascadia.Transformer
+//	t.Add(cascadia.Class("title"), func(n *html.Node) {
+//		n.AppendChild(&html.Node{Type: html.TextNode, Data: title})
+//	})
+//	t.Add(cascadia.Class("draft"), func(n *html.Node) {
+//		n.Parent.RemoveChild(n)
+//	})
+//	t.Apply(doc)
+//
+// The zero value is an empty Transformer, ready to use. Like a SelectorSet,
+// it must not be changed while it is being applied.
+type Transformer struct {
+	set   SelectorSet
+	funcs []func(*html.Node) // indexed by the rules' indexes in set
+}
+
+// Add adds a rule that calls f for each element that sel matches.
+func (t *Transformer) Add(sel Sel, f func(*html.Node)) {
+	t.set.Add(sel)
+	t.funcs = append(t.funcs, f)
+}
+
+// AddGroup adds a rule that calls f for each element that any of the
+// selectors in g matches. f is called once for each element, even if more
+// than one of the selectors matches it.
+func (t *Transformer) AddGroup(g SelectorGroup, f func(*html.Node)) {
+	t.set.AddGroup(g)
+	t.funcs = append(t.funcs, f)
+}
+
+// Apply runs the rules on the descendants of n. The matches for all the
+// rules are found before any function is called, so the functions may
+// change the tree without affecting which nodes are matched. Then the
+// functions are called in document order, and for each node in the order
+// the rules were added. A node that an earlier function removed from n's
+// subtree is skipped.
+func (t *Transformer) Apply(n *html.Node) {
+	for _, m := range t.set.QueryAll(n) {
+		for _, rule := range m.Selectors {
+			if !isAncestor(n, m.Node) {
+				break
+			}
+			t.funcs[rule](m.Node)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestTransformer(t *testing.T) {
+	doc := MustParseHTML(`<div class=post><h1 class=title></h1><p class=draft>x <b>secret</b></p><p class=body>text</p></div><ul><li>a</li><li>b</li><li>c</li></ul>`)
+
+	var order []string
+	var tr Transformer
+	tr.Add(Class("title"), func(n *html.Node) {
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: "Hello"})
+	})
+	tr.Add(Class("draft"), func(n *html.Node) {
+		n.Parent.RemoveChild(n)
+	})
+	tr.Add(Tag("b"), func(n *html.Node) {
+		t.Error("called for a node inside a removed one")
+	})
+	tr.AddGroup(MustParseGroup(t, "p, .body"), func(n *html.Node) {
+		n.Attr = append(n.Attr, html.Attribute{Key: "data-seen", Val: "1"})
+		order = append(order, "group")
+	})
+	tr.Add(Class("body"), func(n *html.Node) {
+		order = append(order, "body")
+	})
+	tr.AddGroup(MustParseGroup(t, "li:last, li:first"), func(n *html.Node) {
+		n.Parent.RemoveChild(n)
+	})
+	tr.Apply(doc)
+
+	var b strings.Builder
+	if err := html.Render(&b, Query(doc, Tag("body"))); err != nil {
+		t.Fatal(err)
+	}
+	want := `<body><div class="post"><h1 class="title">Hello</h1><p class="body" data-seen="1">text</p></div><ul><li>b</li></ul></body>`
+	if got := b.String(); got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	if got := strings.Join(order, " "); got != "group body" {
+		t.Errorf("rules ran in the order %s, want group body", got)
+	}
+}
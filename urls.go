@@ -0,0 +1,87 @@
+package cascadia
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A URLFunc returns the URL to use instead of url, which is from the
+// attribute key of n. See RewriteURLs.
+type URLFunc func(n *html.Node, key, url string) string
+
+// RewriteURLs passes the URLs in the href, src, srcset and action
+// attributes of the descendants of n that match m through f, and replaces
+// them with what it returns. This is the core of proxies and static-site
+// post-processors that make links absolute or point them somewhere else.
+//
+// Leading and trailing whitespace is removed from the URLs before f sees
+// them. A srcset attribute holds several URLs, each followed by optional
+// descriptors like "2x"; f is called for each URL, and the descriptors are
+// kept.
+func RewriteURLs(n *html.Node, m Matcher, f URLFunc) {
+	for _, match := range QueryAll(n, m) {
+		for i := range match.Attr {
+			a := &match.Attr[i]
+			switch a.Key {
+			case "href", "src", "action":
+				a.Val = f(match, a.Key, strings.TrimSpace(a.Val))
+			case "srcset":
+				a.Val = rewriteSrcset(a.Val, func(url string) string {
+					return f(match, a.Key, url)
+				})
+			}
+		}
+	}
+}
+
+// rewriteSrcset replaces each URL in the srcset attribute value s with the
+// result of f, and returns the new value, with the candidates separated by
+// ", ".
+func rewriteSrcset(s string, f func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		// Skip the whitespace and commas before the URL.
+		for i < len(s) && (isHTMLSpace(rune(s[i])) || s[i] == ',') {
+			i++
+		}
+		if i == len(s) {
+			break
+		}
+		start := i
+		for i < len(s) && !isHTMLSpace(rune(s[i])) {
+			i++
+		}
+		url := s[start:i]
+		var descriptors string
+		if trimmed := strings.TrimRight(url, ","); trimmed != url {
+			// A comma at the end of the URL ends the candidate.
+			url = trimmed
+		} else {
+			// The descriptors run to the next comma that isn't in
+			// parentheses.
+			start, depth := i, 0
+			for ; i < len(s) && (s[i] != ',' || depth > 0); i++ {
+				switch s[i] {
+				case '(':
+					depth++
+				case ')':
+					if depth > 0 {
+						depth--
+					}
+				}
+			}
+			descriptors = strings.Join(strings.FieldsFunc(s[start:i], isHTMLSpace), " ")
+		}
+
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f(url))
+		if descriptors != "" {
+			b.WriteByte(' ')
+			b.WriteString(descriptors)
+		}
+	}
+	return b.String()
+}
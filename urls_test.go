@@ -0,0 +1,53 @@
+package cascadia
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestRewriteURLs(t *testing.T) {
+	doc := MustParseHTML(`<a href=" /a ">a</a><a href="https://example.com/b">b</a><img src="c.png" srcset="c.png 1x, c@2x.png 2x" alt="/x"><form action="/submit"></form><p data-src="/d"></p>`)
+	var keys []string
+	RewriteURLs(doc, MustParseGroup(t, "a, img, form, p"), func(n *html.Node, key, url string) string {
+		keys = append(keys, n.Data+"."+key)
+		if strings.HasPrefix(url, "/") {
+			return "https://example.com" + url
+		}
+		if !strings.Contains(url, "://") {
+			return "/static/" + url
+		}
+		return url
+	})
+
+	var b strings.Builder
+	if err := html.Render(&b, Query(doc, Tag("body"))); err != nil {
+		t.Fatal(err)
+	}
+	want := `<body><a href="https://example.com/a">a</a><a href="https://example.com/b">b</a><img src="/static/c.png" srcset="/static/c.png 1x, /static/c@2x.png 2x" alt="/x"/><form action="https://example.com/submit"></form><p data-src="/d"></p></body>`
+	if got := b.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if got, want := strings.Join(keys, " "), "a.href a.href img.src img.srcset img.srcset form.action"; got != want {
+		t.Errorf("f was called for %s, want %s", got, want)
+	}
+}
+
+func TestRewriteSrcset(t *testing.T) {
+	for s, want := range map[string]string{
+		"a.png":                      "<a.png>",
+		"a.png 1x,b.png 2x":          "<a.png> 1x, <b.png> 2x",
+		"  a.png   100w ,  b.png  ":  "<a.png> 100w, <b.png>",
+		"a.png,b.png":                "<a.png,b.png>", // one URL, as in the HTML spec
+		"a.png, b.png":               "<a.png>, <b.png>",
+		"data:x,y 1x":                "<data:x,y> 1x",
+		"a.png 1x (fn(a, b)), b.png": "<a.png> 1x (fn(a, b)), <b.png>",
+		" , ":                        "",
+	} {
+		got := rewriteSrcset(s, func(url string) string { return "<" + url + ">" })
+		if got != want {
+			t.Errorf("rewriteSrcset(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
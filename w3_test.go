@@ -100,3 +100,46 @@ func TestValidSelectors(t *testing.T) {
 
 	}
 }
+
+func TestValidate(t *testing.T) {
+	for _, test := range loadValidSelectors(t) {
+		if test.Xfail {
+			continue
+		}
+		if err := ValidateWithOptions(test.Selector, ParseOptions{PseudoElements: true}); err != nil {
+			t.Errorf("%s: unexpected error for %s: %s", test.Name, test.Selector, err)
+		}
+	}
+
+	c, err := ioutil.ReadFile("test_resources/invalid_selectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests []invalidSelector
+	if err = json.Unmarshal(c, &tests); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		if ValidateWithOptions(test.Selector, ParseOptions{PseudoElements: true}) == nil {
+			t.Errorf("%s: expected an error for %s", test.Name, test.Selector)
+		}
+	}
+
+	for sel, valid := range map[string]bool{
+		"p.a > b":             true,
+		"p:matches(^a+$)":     true,
+		"p:matches(a(b)":      false,
+		"[x #= (a|b)]":        true,
+		"[x #= (a|b]":         false,
+		"p::before":           false,
+		"p:unknown-pseudo":    false,
+		"div, p:has(a:first)": false,
+	} {
+		if skipRegexp(sel) {
+			continue
+		}
+		if err := Validate(sel); (err == nil) != valid {
+			t.Errorf("Validate(%s) returned %v, want valid = %v", sel, err, valid)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cascadia
+
+import (
+	"golang.org/x/net/html"
+)
+
+// A walker visits a node's descendants in document order. It follows the
+// nodes' parent and sibling links instead of recursing, so a very deep
+// document can't overflow the stack, and a search can stop and later
+// resume where it left off.
+type walker struct {
+	root *html.Node
+	n    *html.Node // the node returned last
+
+	self    bool // whether root itself is visited
+	started bool
+
+	// inert is true if the content of template elements is skipped. See
+	// MatchOptions.InertTemplates.
+	inert bool
+}
+
+// walk returns a walker for the descendants of root, starting with root
+// itself if self is true.
+func walk(root *html.Node, self bool) walker {
+	return walker{root: root, self: self}
+}
+
+// next returns the next node, or nil when there are no more. The node after
+// n is found when next is called again, so the caller may change n's
+// children in between.
+func (w *walker) next() *html.Node {
+	switch {
+	case !w.started:
+		w.started = true
+		if w.self {
+			w.n = w.root
+		} else if !(w.inert && isTemplate(w.root)) {
+			w.n = w.root.FirstChild
+		}
+	case w.n != nil && w.inert && isTemplate(w.n):
+		w.n = nextAfter(w.n, w.root)
+	case w.n != nil:
+		w.n = nextNode(w.n, w.root)
+	}
+	return w.n
+}
+
+// nextNode returns the node after n in a preorder walk of the descendants
+// of root, or nil at the end.
+func nextNode(n, root *html.Node) *html.Node {
+	if n.FirstChild != nil {
+		return n.FirstChild
+	}
+	return nextAfter(n, root)
+}
+
+// nextAfter is like nextNode, but it skips n's descendants.
+func nextAfter(n, root *html.Node) *html.Node {
+	for ; n != root && n != nil; n = n.Parent {
+		if n.NextSibling != nil {
+			return n.NextSibling
+		}
+	}
+	return nil
+}
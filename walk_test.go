@@ -0,0 +1,67 @@
+package cascadia
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestWalkDeepDocument(t *testing.T) {
+	const depth = 100000
+	doc := &html.Node{Type: html.DocumentNode}
+	parent := doc
+	for i := 0; i < depth; i++ {
+		n := &html.Node{Type: html.ElementNode, Data: "div"}
+		if i%1000 == 999 {
+			n.Attr = []html.Attribute{{Key: "class", Val: "mark"}}
+		}
+		parent.AppendChild(n)
+		parent = n
+	}
+
+	sel := MustCompile("div.mark")
+	if got := len(sel.MatchAll(doc)); got != depth/1000 {
+		t.Errorf("MatchAll found %d nodes, want %d", got, depth/1000)
+	}
+	if got := sel.MatchFirst(doc); got == nil || got.Parent == doc {
+		t.Errorf("MatchFirst returned the wrong node")
+	}
+	m := MustParseGroup(t, "div.mark")
+	if got := len(QueryAll(doc, m)); got != depth/1000 {
+		t.Errorf("QueryAll found %d nodes, want %d", got, depth/1000)
+	}
+	if got := len(QueryAllN(doc, m, 3)); got != 3 {
+		t.Errorf("QueryAllN found %d nodes, want 3", got)
+	}
+}
+
+func TestWalker(t *testing.T) {
+	doc := MustParseHTML(`<div><p>1</p><p>2</p></div><span></span>`)
+	body := Query(doc, MustParseGroup(t, "body"))
+
+	var got []string
+	w := walk(body, false)
+	for n := w.next(); n != nil; n = w.next() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		got = append(got, n.Data)
+		if n.Data == "div" {
+			// Children removed before the walk reaches them are skipped.
+			n.RemoveChild(n.LastChild)
+		}
+	}
+	if want := []string{"div", "p", "span"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("walk visited %v, want %v", got, want)
+	}
+
+	w = walk(body, true)
+	if n := w.next(); n != body {
+		t.Errorf("walk with self started at %v, want body", n)
+	}
+	empty := walk(&html.Node{Type: html.ElementNode, Data: "br"}, false)
+	if n := empty.next(); n != nil {
+		t.Errorf("walk of an empty node returned %v", n)
+	}
+}
@@ -0,0 +1,373 @@
+package cascadia
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// TestWPT runs tests from the css/selectors directory of the
+// web-platform-tests (https://github.com/web-platform-tests/wpt). They are
+// testharness.js pages, and TestWPT reads the test cases from the calls in
+// their scripts:
+//
+//	test_valid_selector(selector, ...)   // from parsing-testcommon.js
+//	test_invalid_selector(selector)
+//	testSelectorAll(selector, [elements])
+//	testSelectorAllFromMain(selector, [elements])
+//
+// The elements are the expected matches in document order, named by ID as
+// in the tests (where browsers resolve them as globals). The FromMain form
+// searches the descendants of the element with the ID "main". Other calls,
+// and calls with arguments that aren't literals, are skipped.
+//
+// By default it runs the pages in test_resources/wpt. They aren't copied
+// from the web-platform-tests: they are local fixtures, written in the same
+// format and laid out the same way, so that the runner is tested without a
+// checkout. To run the real tests, pass the path of a checkout with -wpt.
+// Known failures are listed in test_resources/wpt/expectations.txt; other
+// failures are errors.
+func TestWPT(t *testing.T) {
+	root := "test_resources/wpt"
+	if *wptDir != "" {
+		root = *wptDir
+	}
+	expected, err := loadWPTExpectations("test_resources/wpt/expectations.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran, failed int
+	err = filepath.Walk(filepath.Join(root, "css", "selectors"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".html") {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		doc, cases, err := loadWPTFile(path)
+		if err != nil {
+			return err
+		}
+		for _, c := range cases {
+			ran++
+			problem := c.run(doc)
+			key := rel + " " + c.sel
+			switch {
+			case problem != "" && expected[key]:
+				failed++
+				t.Logf("%s: %s: %s (expected)", rel, c.sel, problem)
+			case problem != "":
+				failed++
+				t.Errorf("%s: %s: %s", rel, c.sel, problem)
+			case expected[key]:
+				t.Logf("%s: %s: passed, but it is listed as a known failure", rel, c.sel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran == 0 {
+		t.Fatalf("no tests found in %s", root)
+	}
+	t.Logf("%d tests, %d failed", ran, failed)
+}
+
+var wptDir = flag.String("wpt", "", "run the css/selectors tests from this web-platform-tests checkout")
+
+// loadWPTExpectations reads the list of known failures, as a set of
+// "path selector" strings.
+func loadWPTExpectations(filename string) (map[string]bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	expected := make(map[string]bool)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			expected[line] = true
+		}
+	}
+	return expected, s.Err()
+}
+
+// A wptCase is a test case from a web-platform-tests page.
+type wptCase struct {
+	function string   // the function that was called
+	sel      string   // its selector argument
+	ids      []string // the IDs of the expected matches
+}
+
+// run runs c against doc, the test's page, and describes what went wrong,
+// or returns "" if it passed.
+func (c wptCase) run(doc *html.Node) string {
+	g, err := ParseGroupWithOptions(c.sel, ParseOptions{PseudoElements: true})
+	switch c.function {
+	case "test_valid_selector":
+		if err != nil {
+			return "unexpected error: " + err.Error()
+		}
+		return ""
+	case "test_invalid_selector":
+		if err == nil {
+			return "parsed without an error"
+		}
+		return ""
+	}
+	if err != nil {
+		return "unexpected error: " + err.Error()
+	}
+
+	root := doc
+	if strings.HasSuffix(c.function, "FromMain") {
+		if root = Query(doc, Universal().ID("main")); root == nil {
+			return "no element with the ID main"
+		}
+	}
+	got := []string{}
+	for _, n := range QueryAll(root, g) {
+		got = append(got, getId(n))
+	}
+	if !reflect.DeepEqual(got, c.ids) {
+		return "matched " + strings.Join(got, ", ") + "; want " + strings.Join(c.ids, ", ")
+	}
+	return ""
+}
+
+// wptFunctions lists the functions whose calls are test cases, and whether
+// their second argument is the list of expected matches.
+var wptFunctions = map[string]bool{
+	"test_valid_selector":     false,
+	"test_invalid_selector":   false,
+	"testSelectorAll":         true,
+	"testSelectorAllFromMain": true,
+}
+
+// loadWPTFile parses a test page and returns the test cases in it.
+func loadWPTFile(filename string) (*html.Node, []wptCase, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	doc, err := html.Parse(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cases []wptCase
+	for _, script := range QueryAll(doc, Tag("script")) {
+		cases = append(cases, wptCalls(nodeText(script))...)
+	}
+	return doc, cases, nil
+}
+
+// wptCalls finds the calls of the functions in wptFunctions in the script
+// src, and returns them as test cases. Function definitions and calls with
+// arguments other than string literals and lists of IDs are skipped.
+func wptCalls(src string) []wptCase {
+	var cases []wptCase
+	for i := 0; i < len(src); {
+		start := i
+		for i < len(src) && isJSIdentByte(src[i]) {
+			i++
+		}
+		if i == start {
+			i++
+			continue
+		}
+		name := src[start:i]
+		wantsIDs, ok := wptFunctions[name]
+		if !ok || (start > 0 && src[start-1] == '.') {
+			continue
+		}
+		j := skipJSSpace(src, i)
+		if j == len(src) || src[j] != '(' {
+			continue
+		}
+		var c wptCase
+		c.function = name
+		c.sel, j, ok = parseJSString(src, skipJSSpace(src, j+1))
+		if !ok {
+			continue
+		}
+		j = skipJSSpace(src, j)
+		if wantsIDs {
+			if j == len(src) || src[j] != ',' {
+				continue
+			}
+			c.ids, j, ok = parseJSIDList(src, skipJSSpace(src, j+1))
+			if !ok {
+				continue
+			}
+			j = skipJSSpace(src, j)
+		} else if j < len(src) && src[j] == ',' {
+			// The expected serialization, which isn't checked.
+			if _, j, ok = parseJSString(src, skipJSSpace(src, j+1)); !ok {
+				continue
+			}
+			j = skipJSSpace(src, j)
+		}
+		if j < len(src) && src[j] == ')' {
+			cases = append(cases, c)
+			i = j + 1
+		}
+	}
+	return cases
+}
+
+func isJSIdentByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// skipJSSpace returns the index of the first byte of src, from i, that isn't
+// whitespace or in a comment.
+func skipJSSpace(src string, i int) int {
+	for i < len(src) {
+		switch {
+		case src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end == -1 {
+				return len(src)
+			}
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return len(src)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// parseJSString parses the string literal at src[i], and returns its value
+// and the index after it. Template literals are accepted if they don't have
+// substitutions.
+func parseJSString(src string, i int) (s string, end int, ok bool) {
+	if i >= len(src) || (src[i] != '"' && src[i] != '\'' && src[i] != '`') {
+		return "", i, false
+	}
+	quote := src[i]
+	var b strings.Builder
+	for i++; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, true
+		case c == '$' && quote == '`' && i+1 < len(src) && src[i+1] == '{':
+			return "", i, false
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch e := src[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'f':
+				b.WriteByte('\f')
+			case '\n':
+				// A line continuation.
+			case 'x', 'u':
+				digits := 2
+				if e == 'u' {
+					digits = 4
+				}
+				if i+digits >= len(src) {
+					return "", i, false
+				}
+				r, err := strconv.ParseUint(src[i+1:i+1+digits], 16, 32)
+				if err != nil {
+					return "", i, false
+				}
+				var buf [utf8.UTFMax]byte
+				b.Write(buf[:utf8.EncodeRune(buf[:], rune(r))])
+				i += digits
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", i, false
+}
+
+// parseJSIDList parses a list of element IDs at src[i], written as an array
+// of identifiers (or of strings), and returns it and the index after it.
+func parseJSIDList(src string, i int) (ids []string, end int, ok bool) {
+	if i >= len(src) || src[i] != '[' {
+		return nil, i, false
+	}
+	ids = []string{}
+	i = skipJSSpace(src, i+1)
+	for i < len(src) && src[i] != ']' {
+		start := i
+		for i < len(src) && isJSIdentByte(src[i]) {
+			i++
+		}
+		id := src[start:i]
+		if id == "" {
+			if id, i, ok = parseJSString(src, i); !ok {
+				return nil, i, false
+			}
+		}
+		ids = append(ids, id)
+		i = skipJSSpace(src, i)
+		if i < len(src) && src[i] == ',' {
+			i = skipJSSpace(src, i+1)
+		} else if i < len(src) && src[i] != ']' {
+			return nil, i, false
+		}
+	}
+	if i == len(src) {
+		return nil, i, false
+	}
+	return ids, i + 1, true
+}
+
+func TestWPTCalls(t *testing.T) {
+	src := `
+		function testSelectorAll(selector, expected) { /* ... */ }
+		testSelectorAll(":first-child", [a, b2]); // a comment
+		testSelectorAllFromMain('p\x3eq', ["c"]);
+		test_valid_selector("a , b", "a, b");
+		test_invalid_selector(` + "`a,`" + `);
+		test_invalid_selector(` + "`${x}`" + `);
+		foo.test_invalid_selector("a");
+		testSelectorAll("p", [document.body]);
+		testSelectorAll("div", []);
+	`
+	want := []wptCase{
+		{"testSelectorAll", ":first-child", []string{"a", "b2"}},
+		{"testSelectorAllFromMain", "p>q", []string{"c"}},
+		{"test_valid_selector", "a , b", nil},
+		{"test_invalid_selector", "a,", nil},
+		{"testSelectorAll", "div", []string{}},
+	}
+	if got := wptCalls(src); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,308 @@
+package cascadia
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// An XPathError is returned by ToXPath when a selector uses something that
+// has no equivalent in XPath 1.0.
+type XPathError struct {
+	// Selector is the part of the selector that can't be translated.
+	Selector string
+
+	Reason string
+}
+
+func (e *XPathError) Error() string {
+	return fmt.Sprintf("can't translate %s to XPath: %s", e.Selector, e.Reason)
+}
+
+// ToXPath translates s to an XPath 1.0 expression that selects the same
+// elements, when it is evaluated with the document (or the element being
+// searched) as the context node.
+//
+// Tag, ID, class and attribute selectors (except #=), all the combinators,
+// :not() with a list of compound selectors, :has(), :haschild(), :empty,
+// :root, :contains(), and the :nth-child() and :only-child families are
+// supported. The *-of-type pseudo-classes need a tag name in the same
+// compound selector. Anything else makes ToXPath return an *XPathError.
+//
+// Case-insensitive comparisons, like [type=a i] and :contains(), only fold
+// ASCII letters.
+func ToXPath(s Sel) (string, error) {
+	return xpathPath(s, "descendant-or-self::")
+}
+
+// GroupToXPath is like ToXPath, but for a group of selectors. It joins the
+// translations with the XPath union operator.
+func GroupToXPath(g SelectorGroup) (string, error) {
+	paths := make([]string, len(g))
+	for i, s := range g {
+		p, err := ToXPath(s)
+		if err != nil {
+			return "", err
+		}
+		paths[i] = p
+	}
+	return strings.Join(paths, " | "), nil
+}
+
+// xpathPath returns a location path that selects the elements matching s,
+// starting with axis.
+func xpathPath(s Sel, axis string) (string, error) {
+	switch s := s.(type) {
+	case Builder:
+		return xpathPath(s.Sel(), axis)
+	case combinedSelector:
+		if s.second == nil {
+			return xpathPath(s.first, axis)
+		}
+		step, err := xpathStep(s.second)
+		if err != nil {
+			return "", err
+		}
+		var prefix string
+		if scope, ok := s.first.(scopePseudoClassSelector); ok && scope.implicit {
+			// A relative selector inside :has() starts from the element
+			// being tested.
+		} else {
+			prefix, err = xpathPath(s.first, axis)
+			if err != nil {
+				return "", err
+			}
+			prefix += "/"
+		}
+		switch s.combinator {
+		case ' ':
+			return prefix + "descendant::" + step, nil
+		case '>':
+			return prefix + "child::" + step, nil
+		case '+':
+			return prefix + "following-sibling::*[1]/self::" + step, nil
+		case '~':
+			return prefix + "following-sibling::" + step, nil
+		}
+		return "", &XPathError{s.String(), fmt.Sprintf("unknown combinator %q", s.combinator)}
+	}
+	step, err := xpathStep(s)
+	if err != nil {
+		return "", err
+	}
+	return axis + step, nil
+}
+
+// xpathStep returns the node test and predicates for an element that
+// matches s, which must be a compound selector or a simple selector.
+func xpathStep(s Sel) (string, error) {
+	var parts []Sel
+	switch c := s.(type) {
+	case compoundSelector:
+		if c.pseudoElement != "" {
+			return "", &XPathError{c.String(), "pseudo-elements are not supported"}
+		}
+		parts = c.selectors
+	case combinedSelector:
+		if c.second == nil {
+			return xpathStep(c.first)
+		}
+		return "", &XPathError{c.String(), "a combinator is not allowed here"}
+	default:
+		parts = []Sel{s}
+	}
+
+	name := "*"
+	for _, part := range parts {
+		if t, ok := part.(tagSelector); ok {
+			name = t.tag
+		}
+	}
+	var preds []string
+	for _, part := range parts {
+		if _, ok := part.(tagSelector); ok {
+			continue
+		}
+		p, err := xpathPredicate(part, name)
+		if err != nil {
+			return "", err
+		}
+		preds = append(preds, p)
+	}
+	if len(preds) == 0 {
+		return name, nil
+	}
+	return name + "[" + strings.Join(preds, " and ") + "]", nil
+}
+
+const (
+	upperASCII = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerASCII = "abcdefghijklmnopqrstuvwxyz"
+)
+
+// xpathPredicate returns an XPath expression that is true when the context
+// node matches s, which is one of the simple selectors in a compound
+// selector for elements named name.
+func xpathPredicate(s Sel, name string) (string, error) {
+	switch s := s.(type) {
+	case classSelector:
+		if s.quirks {
+			return "", &XPathError{s.String(), "quirks mode is not supported"}
+		}
+		return fmt.Sprintf("contains(concat(' ', normalize-space(@class), ' '), %s)", xpathLiteral(" "+s.class+" ")), nil
+	case idSelector:
+		if s.quirks {
+			return "", &XPathError{s.String(), "quirks mode is not supported"}
+		}
+		return "@id = " + xpathLiteral(s.id), nil
+	case attrSelector:
+		return xpathAttribute(s)
+	case compoundSelector, combinedSelector:
+		step, err := xpathStep(s)
+		if err != nil {
+			return "", err
+		}
+		return "self::" + step, nil
+
+	case relativePseudoClassSelector:
+		var tests []string
+		for _, sel := range s.match {
+			var t string
+			var err error
+			switch s.name {
+			case "not":
+				t, err = xpathStep(sel)
+				t = "self::" + t
+			case "has":
+				t, err = xpathPath(sel, "descendant::")
+			case "haschild":
+				t, err = xpathPath(sel, "child::")
+			}
+			if err != nil {
+				return "", err
+			}
+			tests = append(tests, t)
+		}
+		if s.name == "not" {
+			return "not(" + strings.Join(tests, " or ") + ")", nil
+		}
+		if len(tests) == 1 {
+			return tests[0], nil
+		}
+		return "(" + strings.Join(tests, " or ") + ")", nil
+
+	case nthPseudoClassSelector:
+		siblings := "*"
+		if s.ofType {
+			if name == "*" {
+				return "", &XPathError{s.String(), "needs a tag name"}
+			}
+			siblings = name
+		}
+		axis := "preceding-sibling::"
+		if s.last {
+			axis = "following-sibling::"
+		}
+		if s.a == 0 && s.b == 1 {
+			return fmt.Sprintf("not(%s%s)", axis, siblings), nil
+		}
+		// The element's position among its siblings is count+1, and it
+		// matches if that is an+b for some n >= 0.
+		count := fmt.Sprintf("count(%s%s)", axis, siblings)
+		if s.a == 0 {
+			return fmt.Sprintf("%s = %d", count, s.b-1), nil
+		}
+		offset := count
+		d := 1 - s.b
+		if s.a > 0 && d == 0 {
+			return fmt.Sprintf("%s mod %d = 0", count, s.a), nil
+		}
+		if s.a > 0 && d > 0 {
+			// offset can't be negative.
+			return fmt.Sprintf("(%s + %d) mod %d = 0", count, d, s.a), nil
+		}
+		switch {
+		case d > 0:
+			offset = fmt.Sprintf("(%s + %d)", count, d)
+		case d < 0:
+			offset = fmt.Sprintf("(%s - %d)", count, -d)
+		}
+		cmp := ">="
+		if s.a < 0 {
+			cmp = "<="
+		}
+		return fmt.Sprintf("%s mod %d = 0 and %s %s 0", offset, s.a, offset, cmp), nil
+
+	case onlyChildPseudoClassSelector:
+		if !s.ofType {
+			return "count(../*) = 1", nil
+		}
+		if name == "*" {
+			return "", &XPathError{s.String(), "needs a tag name"}
+		}
+		return fmt.Sprintf("count(../%s) = 1", name), nil
+
+	case emptyElementPseudoClassSelector:
+		return "not(*) and not(text()[normalize-space()])", nil
+	case rootPseudoClassSelector:
+		return "not(parent::*)", nil
+
+	case containsPseudoClassSelector:
+		if s.own || s.fold || s.word || s.text != nil {
+			break
+		}
+		return fmt.Sprintf("contains(translate(string(.), '%s', '%s'), %s)", upperASCII, lowerASCII, xpathLiteral(s.value)), nil
+	}
+	return "", &XPathError{s.String(), "no XPath equivalent"}
+}
+
+func xpathAttribute(s attrSelector) (string, error) {
+	attr := "@" + s.key
+	val := s.val
+	if s.insensitive {
+		attr = fmt.Sprintf("translate(%s, '%s', '%s')", attr, upperASCII, lowerASCII)
+		val = toLowerASCII(val)
+	}
+	lit := xpathLiteral(val)
+	nonBlank := fmt.Sprintf("normalize-space(@%s) != ''", s.key)
+
+	switch s.operation {
+	case "":
+		return attr, nil
+	case "=":
+		return attr + " = " + lit, nil
+	case "!=":
+		return fmt.Sprintf("not(%s = %s)", attr, lit), nil
+	case "~=":
+		return fmt.Sprintf("contains(concat(' ', normalize-space(%s), ' '), %s)", attr, xpathLiteral(" "+val+" ")), nil
+	case "|=":
+		return fmt.Sprintf("(%s = %s or starts-with(%s, %s))", attr, lit, attr, xpathLiteral(val+"-")), nil
+	case "^=":
+		return fmt.Sprintf("%s and starts-with(%s, %s)", nonBlank, attr, lit), nil
+	case "$=":
+		return fmt.Sprintf("%s and substring(%s, string-length(%s) - %d) = %s", nonBlank, attr, attr, utf8.RuneCountInString(val)-1, lit), nil
+	case "*=":
+		return fmt.Sprintf("%s and contains(%s, %s)", nonBlank, attr, lit), nil
+	case "<", "<=", ">", ">=":
+		return fmt.Sprintf("number(@%s) %s %s", s.key, s.operation, strconv.FormatFloat(s.number, 'f', -1, 64)), nil
+	}
+	return "", &XPathError{s.String(), "no XPath equivalent"}
+}
+
+// xpathLiteral returns s as an XPath string literal. XPath 1.0 has no escape
+// sequences, so a string containing both kinds of quote is built with
+// concat().
+func xpathLiteral(s string) string {
+	switch {
+	case !strings.Contains(s, "'"):
+		return "'" + s + "'"
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`
+	}
+	parts := strings.Split(s, "'")
+	for i, p := range parts {
+		parts[i] = "'" + p + "'"
+	}
+	return "concat(" + strings.Join(parts, `, "'", `) + ")"
+}
@@ -0,0 +1,92 @@
+package cascadia
+
+import (
+	"errors"
+	"testing"
+)
+
+var xpathTests = []struct {
+	sel, xpath string
+}{
+	{"p", "descendant-or-self::p"},
+	{"*", "descendant-or-self::*"},
+	{"div p.a", "descendant-or-self::div/descendant::p[contains(concat(' ', normalize-space(@class), ' '), ' a ')]"},
+	{"ul > li", "descendant-or-self::ul/child::li"},
+	{"h1 + p", "descendant-or-self::h1/following-sibling::*[1]/self::p"},
+	{"h1 ~ p", "descendant-or-self::h1/following-sibling::p"},
+	{"#a", "descendant-or-self::*[@id = 'a']"},
+	{"[href]", "descendant-or-self::*[@href]"},
+	{"[type=a i]", "descendant-or-self::*[translate(@type, 'ABCDEFGHIJKLMNOPQRSTUVWXYZ', 'abcdefghijklmnopqrstuvwxyz') = 'a']"},
+	{"[a!=b]", "descendant-or-self::*[not(@a = 'b')]"},
+	{"[a~=b]", "descendant-or-self::*[contains(concat(' ', normalize-space(@a), ' '), ' b ')]"},
+	{"[lang|=en]", "descendant-or-self::*[(@lang = 'en' or starts-with(@lang, 'en-'))]"},
+	{"[a^=b]", "descendant-or-self::*[normalize-space(@a) != '' and starts-with(@a, 'b')]"},
+	{"a[href$='.pdf']", "descendant-or-self::a[normalize-space(@href) != '' and substring(@href, string-length(@href) - 3) = '.pdf']"},
+	{"[a*=b]", "descendant-or-self::*[normalize-space(@a) != '' and contains(@a, 'b')]"},
+	{"[n>=2.5]", "descendant-or-self::*[number(@n) >= 2.5]"},
+	{"li:not(.a, b)", "descendant-or-self::li[not(self::*[contains(concat(' ', normalize-space(@class), ' '), ' a ')] or self::b)]"},
+	{"div:has(p)", "descendant-or-self::div[descendant::p]"},
+	{"div:haschild(p > a)", "descendant-or-self::div[child::p/child::a]"},
+	{"li:first-child", "descendant-or-self::li[not(preceding-sibling::*)]"},
+	{"p:last-of-type", "descendant-or-self::p[not(following-sibling::p)]"},
+	{"li:nth-child(3)", "descendant-or-self::li[count(preceding-sibling::*) = 2]"},
+	{"li:nth-child(odd)", "descendant-or-self::li[count(preceding-sibling::*) mod 2 = 0]"},
+	{"li:nth-child(2n)", "descendant-or-self::li[(count(preceding-sibling::*) + 1) mod 2 = 0]"},
+	{"li:nth-child(3n+5)", "descendant-or-self::li[(count(preceding-sibling::*) - 4) mod 3 = 0 and (count(preceding-sibling::*) - 4) >= 0]"},
+	{"li:nth-last-child(-n+3)", "descendant-or-self::li[(count(following-sibling::*) - 2) mod -1 = 0 and (count(following-sibling::*) - 2) <= 0]"},
+	{"li:only-child", "descendant-or-self::li[count(../*) = 1]"},
+	{"p:only-of-type", "descendant-or-self::p[count(../p) = 1]"},
+	{"p:empty", "descendant-or-self::p[not(*) and not(text()[normalize-space()])]"},
+	{":root", "descendant-or-self::*[not(parent::*)]"},
+	{`p:contains("It's \"x\"")`, `descendant-or-self::p[contains(translate(string(.), 'ABCDEFGHIJKLMNOPQRSTUVWXYZ', 'abcdefghijklmnopqrstuvwxyz'), concat('it', "'", 's "x"'))]`},
+}
+
+func TestToXPath(t *testing.T) {
+	for _, test := range xpathTests {
+		got, err := ToXPath(MustParse(t, test.sel))
+		if err != nil {
+			t.Errorf("%s: %v", test.sel, err)
+			continue
+		}
+		if got != test.xpath {
+			t.Errorf("%s: got %s, want %s", test.sel, got, test.xpath)
+		}
+	}
+}
+
+func TestGroupToXPath(t *testing.T) {
+	got, err := GroupToXPath(MustParseGroup(t, "h1, p.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "descendant-or-self::h1 | descendant-or-self::p[contains(concat(' ', normalize-space(@class), ' '), ' a ')]"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestToXPathUnsupported(t *testing.T) {
+	for _, sel := range []string{
+		"p:matches(x)",
+		"[href#=(x)]",
+		"*:first-of-type",
+		"li:first",
+		"p:not(div p)",
+		"p:containsOwn(x)",
+		"a:hover",
+	} {
+		if skipRegexp(sel) {
+			continue
+		}
+		_, err := ToXPath(MustParse(t, sel))
+		var xerr *XPathError
+		if !errors.As(err, &xerr) {
+			t.Errorf("%s: got error %v, want an *XPathError", sel, err)
+		}
+	}
+	_, err := ToXPath(MustParseGroupWithPseudoElements(t, "p::before")[0])
+	var xerr *XPathError
+	if !errors.As(err, &xerr) {
+		t.Errorf("p::before: got error %v, want an *XPathError", err)
+	}
+}